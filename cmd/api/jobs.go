@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+// registerJobs registers the background jobs with the scheduler
+func registerJobs(scheduler *services.SchedulerService, cfg *config.Config, ticketService *services.TicketService,
+	waitlistService *services.WaitlistService, mediaService *services.MediaService, reconciliationService *services.ReconciliationService,
+	eventCancellationService *services.EventCancellationService, eventSeriesService *services.EventSeriesService) {
+
+	// Converts images on this instance's disk, so it runs on every instance
+	if cfg.WebPConversionEnabled {
+		scheduler.MustRegister(services.Job{
+			Name:        "webp_conversion",
+			Description: "Convert new JPEG/PNG images in the local assets to WebP",
+			Interval:    5 * time.Minute,
+			Local:       true,
+			Run: func(ctx context.Context) (string, error) {
+				pending, err := mediaService.GetPendingWebPConversions()
+				if err != nil || len(pending) == 0 {
+					return "", err
+				}
+				converted := 0
+				for _, originalPath := range pending {
+					if ctx.Err() != nil {
+						break
+					}
+					webpPath, err := mediaService.ConvertToWebP(originalPath)
+					if err != nil {
+						log.Printf("WebP conversion error for %s: %v", originalPath, err)
+					} else {
+						converted++
+						log.Printf("WebP converted: %s -> %s", filepath.Base(originalPath), filepath.Base(webpPath))
+					}
+					// Small delay between conversions to not overload CPU
+					time.Sleep(100 * time.Millisecond)
+				}
+				return fmt.Sprintf("converted %d/%d images", converted, len(pending)), nil
+			},
+		})
+	}
+
+	if cfg.PendingTicketCleanupEnabled {
+		scheduler.MustRegister(services.Job{
+			Name:        "pending_ticket_cleanup",
+			Description: "Cancel pending tickets whose checkout was never paid",
+			Interval:    5 * time.Minute,
+			Run:         countJob(ticketService.CleanupStalePending, "cancelled %d stale tickets"),
+		})
+	}
+
+	// Finalizes cancellations after the 5 minute grace period
+	scheduler.MustRegister(services.Job{
+		Name:        "pending_cancellation_cleanup",
+		Description: "Finalize cancellations whose grace period is over",
+		Interval:    time.Minute,
+		Run:         countJob(ticketService.CleanupPendingCancellations, "finalized %d cancelled tickets"),
+	})
+
+	// Expire unclaimed waitlist offers and pass freed spots to the next in line
+	scheduler.MustRegister(services.Job{
+		Name:        "waitlist_offers",
+		Description: "Expire unclaimed waitlist offers and offer freed spots",
+		Interval:    time.Minute,
+		Run: countJob(func() (int64, error) {
+			offered, err := waitlistService.ProcessOffers()
+			return int64(offered), err
+		}, "sent %d spot offers"),
+	})
+
+	// Very recent pending tickets (0-30 seconds old) are polled every 5 seconds for quick user feedback
+	scheduler.MustRegister(services.Job{
+		Name:        "payment_fast_poll",
+		Description: "Check payments of pending tickets younger than 30 seconds",
+		Interval:    5 * time.Second,
+		Run:         countJob(ticketService.FastCheckRecentPending, "confirmed %d payments"),
+	})
+
+	// Older pending tickets (30 sec - 30 min old) as fallback if webhooks fail
+	scheduler.MustRegister(services.Job{
+		Name:        "payment_pending_check",
+		Description: "Check payments of pending tickets up to 30 minutes old",
+		Interval:    30 * time.Second,
+		Run:         countJob(ticketService.CheckPendingPayments, "confirmed %d payments"),
+	})
+
+	// Tickets in their grace period are reactivated if their payment completed after all
+	scheduler.MustRegister(services.Job{
+		Name:        "pending_cancellation_check",
+		Description: "Reactivate tickets in their grace period whose payment completed",
+		Interval:    10 * time.Second,
+		Run:         countJob(ticketService.CheckPendingCancellations, "reactivated %d tickets"),
+	})
+
+	if cfg.ReconciliationEnabled {
+		scheduler.MustRegister(services.Job{
+			Name:        "payment_reconciliation",
+			Description: "Compare the tickets of the last days with Stripe and PayPal and email the report",
+			Cron:        fmt.Sprintf("0 %d * * *", cfg.ReconciliationHour),
+			Run: func(ctx context.Context) (string, error) {
+				run, err := reconciliationService.RunNightly()
+				if run == nil {
+					return "", err
+				}
+				return fmt.Sprintf("checked %d checkouts, %d mismatches, %d fixed (report %s)", run.Checked, run.Mismatches, run.Fixed, run.ID), err
+			},
+		})
+	}
+
+	// Refunds are executed after their transaction commits; this sends the ones a restart left pending
+	scheduler.MustRegister(services.Job{
+		Name:        "refund_recovery",
+		Description: "Send refunds that are still pending after 10 minutes",
+		Interval:    5 * time.Minute,
+		Run:         countJob(ticketService.RefundService().ExecuteStale, "handled %d stale refunds"),
+	})
+
+	// Bulk event cancellations; one interrupted by a restart is resumed by the next run
+	scheduler.MustRegister(services.Job{
+		Name:        services.EventCancellationJob,
+		Description: "Cancel and refund the tickets of cancelled events and notify the attendees",
+		Interval:    time.Minute,
+		Run:         eventCancellationService.ProcessPending,
+	})
+
+	// Keeps the events of recurring series created months_ahead in advance
+	scheduler.MustRegister(services.Job{
+		Name:        "event_series",
+		Description: "Create the upcoming events of event series",
+		Cron:        "15 3 * * *",
+		Run:         countJob(eventSeriesService.GenerateAll, "created %d events"),
+	})
+
+	scheduler.MustRegister(services.Job{
+		Name:        "job_run_cleanup",
+		Description: "Delete old job runs",
+		Cron:        "30 4 * * *",
+		Run:         scheduler.CleanupRuns,
+	})
+}
+
+// countJob adapts a service method returning the number of processed items to a job
+func countJob(fn func() (int64, error), format string) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		n, err := fn()
+		if err != nil || n == 0 {
+			return "", err
+		}
+		return fmt.Sprintf(format, n), nil
+	}
+}
@@ -65,9 +65,25 @@ func main() {
 	mediaService := services.NewMediaService(db, cfg, s3Service, storageService)
 	musicService := services.NewMusicService(db, cfg, s3Service, storageService)
 	audioCacheService := services.NewAudioCacheService(cfg, s3Service)
-	qrService := services.NewQRService(cfg)
+	qrService, err := services.NewQRService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init QR service: %v", err)
+	}
+	checkInService := services.NewCheckInService(db, qrService)
 	backupService := services.NewBackupService(db, cfg, s3Service)
 	auditService := services.NewAuditService(db, emailService, cfg)
+	waitlistService := services.NewWaitlistService(db, cfg, emailService, smsService)
+	ticketService.AttachWaitlistService(waitlistService)
+	transferService := services.NewTransferService(db, cfg, ticketService, emailService)
+	promoService := services.NewPromoCodeService(db)
+	orderService := services.NewOrderService(db, ticketService)
+	ledgerService := services.NewLedgerService(db)
+	webhookService := services.NewWebhookService(db)
+	reconciliationService := services.NewReconciliationService(db, cfg, ticketService, emailService)
+	eventCancellationService := services.NewEventCancellationService(db, cfg, ticketService, emailService)
+	taxService := services.NewTaxService(db, cfg)
+	pickupService := services.NewPickupService(db, smsService)
+	eventSeriesService := services.NewEventSeriesService(db, eventService)
 
 	// Optional: sync missing images on start
 	if cfg.MediaSyncOnStart {
@@ -96,113 +112,21 @@ func main() {
 		}()
 	}
 
-	// Start background WebP conversion worker
-	if cfg.WebPConversionEnabled {
-		go func() {
-			// Initial delay to let the server start first
-			time.Sleep(30 * time.Second)
-			for {
-				pending, err := mediaService.GetPendingWebPConversions()
-				if err != nil {
-					log.Printf("WebP conversion scan error: %v", err)
-				} else if len(pending) > 0 {
-					log.Printf("WebP conversion: found %d images to convert", len(pending))
-					converted := 0
-					for _, originalPath := range pending {
-						webpPath, err := mediaService.ConvertToWebP(originalPath)
-						if err != nil {
-							log.Printf("WebP conversion error for %s: %v", originalPath, err)
-						} else {
-							converted++
-							log.Printf("WebP converted: %s -> %s", filepath.Base(originalPath), filepath.Base(webpPath))
-						}
-						// Small delay between conversions to not overload CPU
-						time.Sleep(100 * time.Millisecond)
-					}
-					log.Printf("WebP conversion batch complete: %d/%d converted", converted, len(pending))
-				}
-				// Check every 5 minutes for new images to convert
-				time.Sleep(5 * time.Minute)
-			}
-		}()
-	}
-
-	// Start periodic cleanup for stale pending tickets
-	if cfg.PendingTicketCleanupEnabled {
-		go func() {
-			for {
-				deleted, err := ticketService.CleanupStalePending()
-				if err != nil {
-					log.Printf("Pending ticket cleanup error: %v", err)
-				} else if deleted > 0 {
-					log.Printf("Pending ticket cleanup: cancelled %d stale tickets", deleted)
-				}
-				time.Sleep(5 * time.Minute)
-			}
-		}()
-	}
-
-	// Start periodic cleanup for pending_cancellation tickets (Grace Period)
-	// Finalizes cancellations after 5 minutes grace period
-	go func() {
-		for {
-			finalized, err := ticketService.CleanupPendingCancellations()
-			if err != nil {
-				log.Printf("Pending cancellation cleanup error: %v", err)
-			} else if finalized > 0 {
-				log.Printf("Grace period cleanup: finalized %d cancelled tickets after 5 min grace period", finalized)
-			}
-			time.Sleep(1 * time.Minute) // Check every minute
-		}
-	}()
-
-	// Fast-poll for very recent pending tickets (0-30 seconds old)
-	// Checks every 5 seconds for quick user feedback (like Shopify, Airbnb)
-	go func() {
-		for {
-			confirmed, err := ticketService.FastCheckRecentPending()
-			if err != nil {
-				log.Printf("Fast-poll error: %v", err)
-			} else if confirmed > 0 {
-				log.Printf("✅ Fast-poll: Confirmed %d payments", confirmed)
-			}
-			time.Sleep(5 * time.Second)
-		}
-	}()
-
-	// Regular poll for older pending tickets (30 sec - 30 min old)
-	// Checks every 30 seconds as fallback if webhooks fail
-	go func() {
-		for {
-			confirmed, err := ticketService.CheckPendingPayments()
-			if err != nil {
-				log.Printf("Pending payment check error: %v", err)
-			} else if confirmed > 0 {
-				log.Printf("✅ Pending payment check: Confirmed %d payments", confirmed)
-			}
-			time.Sleep(30 * time.Second)
-		}
-	}()
-
-	// Active check for pending_cancellation tickets (Grace Period)
-	// Checks every 10 seconds during 5-minute grace period
-	go func() {
-		for {
-			reactivated, err := ticketService.CheckPendingCancellations()
-			if err != nil {
-				log.Printf("Pending cancellation check error: %v", err)
-			} else if reactivated > 0 {
-				log.Printf("✅ Grace period: Reactivated %d tickets after finding completed payments", reactivated)
-			}
-			time.Sleep(10 * time.Second)
-		}
-	}()
+	// Background jobs (see jobs.go); shared jobs run on one instance at a time via Redis locks
+	schedulerService := services.NewSchedulerService(db, redisClient, cfg)
+	registerJobs(schedulerService, cfg, ticketService, waitlistService, mediaService, reconciliationService, eventCancellationService, eventSeriesService)
+	schedulerService.Start()
 
 	// Create admin user if not exists
 	if err := adminService.CreateDefaultAdmin(); err != nil {
 		log.Printf("Failed to create default admin: %v", err)
 	}
 
+	// Record VAT on order items booked before tax rates were stored
+	if err := taxService.BackfillOrderItems(); err != nil {
+		log.Printf("Failed to record VAT on order items: %v", err)
+	}
+
 	// Setup Gin router
 	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -221,9 +145,28 @@ func main() {
 	userHandler.AssetService = assetService
 	userHandler.StorageService = storageService
 	adminHandler := handlers.NewAdminHandler(adminService, eventService, inviteService, userService, ticketService, storageService, s3Service, qrService, backupService, emailService, auditService)
+	adminHandler.WaitlistService = waitlistService
+	waitlistHandler := handlers.NewWaitlistHandler(waitlistService, ticketService)
+	checkInHandler := handlers.NewCheckInHandler(checkInService, ticketService, qrService, auditService)
 	publicHandler := handlers.NewPublicHandler(eventService, inviteService, cfg)
-	stripeHandler := handlers.NewStripeHandler(ticketService, cfg, emailService)
-	paypalHandler := handlers.NewPayPalHandler(ticketService, emailService, cfg)
+	transferHandler := handlers.NewTransferHandler(transferService, ticketService)
+	promoHandler := handlers.NewPromoHandler(promoService)
+	orderHandler := handlers.NewOrderHandler(orderService, ticketService)
+	refundHandler := handlers.NewRefundHandler(ticketService.RefundService())
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	invoiceHandler := handlers.NewInvoiceHandler(ticketService.InvoiceService())
+	taxHandler := handlers.NewTaxHandler(taxService)
+	pickupHandler := handlers.NewPickupHandler(pickupService)
+	eventSeriesHandler := handlers.NewEventSeriesHandler(eventSeriesService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	disputeHandler := handlers.NewDisputeHandler(ticketService)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	jobHandler := handlers.NewJobHandler(schedulerService)
+	eventCancellationHandler := handlers.NewEventCancellationHandler(eventCancellationService, schedulerService)
+	stripeHandler := handlers.NewStripeHandler(ticketService, cfg, emailService, webhookService)
+	stripeHandler.TransferService = transferService
+	paypalHandler := handlers.NewPayPalHandler(ticketService, emailService, cfg, webhookService)
+	paypalHandler.TransferService = transferService
 	mediaHandler := handlers.NewMediaHandler(mediaService, storageService)
 	musicHandler := handlers.NewMusicHandler(musicService, storageService, audioCacheService)
 
@@ -283,9 +226,34 @@ func main() {
 			user.POST("/tickets/:id/confirm-payment", userHandler.ConfirmPayment)
 			user.DELETE("/tickets/:id", userHandler.CancelTicket)
 			user.POST("/tickets/:id/cancel-refund", userHandler.CancelTicketRefund)
+			user.GET("/tickets/:id/cancellation", userHandler.GetCancellationQuote)
+			user.GET("/tickets/:id/status-history", userHandler.GetTicketStatusHistory)
 			user.POST("/tickets/:id/cancel", userHandler.CancelTicketNoRefund)
+			user.GET("/tickets/:id/qr.png", checkInHandler.GetTicketQR)
+			user.GET("/tickets/:id/invoice", invoiceHandler.GetTicketInvoice)
+			user.GET("/tickets/:id/invoices", invoiceHandler.GetTicketInvoices)
+			user.GET("/tickets/:id/invoices/:invoiceId", invoiceHandler.DownloadTicketInvoice)
 			user.GET("/assets/:id/download", userHandler.DownloadAsset)
 			user.GET("/settings/pickup-price", userHandler.GetPickupServicePrice)
+			user.GET("/events/:id/pickup-options", pickupHandler.GetPickupOptions)
+			// Waitlist for fully booked events
+			user.POST("/events/:id/waitlist", waitlistHandler.JoinWaitlist)
+			user.DELETE("/events/:id/waitlist", waitlistHandler.LeaveWaitlist)
+			user.GET("/waitlist", waitlistHandler.GetUserWaitlist)
+			user.POST("/waitlist/claim", waitlistHandler.ClaimWaitlistOffer)
+			// Ticket transfers between members
+			user.POST("/tickets/:id/transfer", transferHandler.InitiateTransfer)
+			user.GET("/transfers", transferHandler.GetUserTransfers)
+			user.POST("/transfers/:id/accept", transferHandler.AcceptTransfer)
+			user.POST("/transfers/:id/confirm-payment", transferHandler.ConfirmTransferPayment)
+			user.POST("/transfers/:id/decline", transferHandler.DeclineTransfer)
+			user.DELETE("/transfers/:id", transferHandler.CancelTransfer)
+			// Promo codes
+			user.POST("/promo-codes/check", promoHandler.CheckPromoCode)
+			// Orders (several tickets and add-ons in one checkout)
+			user.POST("/orders", orderHandler.CreateOrder)
+			user.GET("/orders", orderHandler.GetUserOrders)
+			user.GET("/orders/:id", orderHandler.GetUserOrder)
 			// Image gallery
 			user.GET("/images", mediaHandler.GetPublicImages)
 			user.GET("/images/:id", mediaHandler.GetPublicImage)
@@ -305,6 +273,16 @@ func main() {
 			userStream.GET("/:id/stream", musicHandler.StreamMusicSetUser)
 		}
 
+		// Driver routes (pickup service)
+		driver := api.Group("/driver")
+		driver.Use(middleware.Auth(authService))
+		driver.Use(middleware.DriverOnly())
+		{
+			driver.GET("/events", pickupHandler.GetDriverEvents)
+			driver.GET("/events/:id/pickups", pickupHandler.GetDriverPickups)
+			driver.PUT("/pickups/:ticketId/status", pickupHandler.UpdatePickupStatus)
+		}
+
 		// Admin routes
 		admin := api.Group("/admin")
 		admin.Use(middleware.Auth(authService))
@@ -314,16 +292,52 @@ func main() {
 			admin.GET("/events", adminHandler.GetAllEvents)
 			admin.POST("/events", adminHandler.CreateEvent)
 			admin.PUT("/events/:id", adminHandler.UpdateEvent)
-			admin.DELETE("/events/:id", adminHandler.DeleteEvent)
+			admin.DELETE("/events/:id", eventCancellationHandler.DeleteEvent)
+
+			// Recurring events
+			admin.GET("/event-series", eventSeriesHandler.GetEventSeries)
+			admin.POST("/event-series", eventSeriesHandler.CreateEventSeries)
+			admin.GET("/event-series/:id", eventSeriesHandler.GetEventSeriesDetails)
+			admin.PUT("/event-series/:id", eventSeriesHandler.UpdateEventSeries)
+			admin.DELETE("/event-series/:id", eventSeriesHandler.DeactivateEventSeries)
+
 			// Specific routes BEFORE generic :id route to avoid conflicts
 			admin.GET("/events/:id/drinks.xlsx", adminHandler.ExportEventDrinksXLSX)
 			admin.GET("/events/:id/participants.csv", func(c *gin.Context) {
 				log.Printf("DEBUG: Route /events/:id/participants.csv matched for event ID: %s", c.Param("id"))
 				adminHandler.ExportEventParticipantsCSV(c)
 			})
-			admin.POST("/events/:id/deactivate", adminHandler.DeactivateEvent)
+			admin.POST("/events/:id/deactivate", eventCancellationHandler.DeactivateEvent)
+			admin.GET("/events/:id/cancellations", eventCancellationHandler.GetEventCancellations)
 			admin.POST("/events/:id/refund", adminHandler.RefundEventTickets)
 			admin.POST("/events/:id/announce", adminHandler.SendEventAnnouncement)
+			admin.GET("/events/:id/ticket-types", adminHandler.GetTicketTypes)
+			admin.POST("/events/:id/ticket-types", adminHandler.CreateTicketType)
+			admin.PUT("/events/:id/ticket-types/:typeId", adminHandler.UpdateTicketType)
+			admin.DELETE("/events/:id/ticket-types/:typeId", adminHandler.DeleteTicketType)
+			admin.GET("/events/:id/addons", adminHandler.GetAddons)
+			admin.POST("/events/:id/addons", adminHandler.CreateAddon)
+			admin.PUT("/events/:id/addons/:addonId", adminHandler.UpdateAddon)
+			admin.DELETE("/events/:id/addons/:addonId", adminHandler.DeleteAddon)
+			admin.GET("/events/:id/pickup-slots", pickupHandler.GetPickupSlots)
+			admin.POST("/events/:id/pickup-slots", pickupHandler.CreatePickupSlot)
+			admin.PUT("/events/:id/pickup-slots/:slotId", pickupHandler.UpdatePickupSlot)
+			admin.DELETE("/events/:id/pickup-slots/:slotId", pickupHandler.DeletePickupSlot)
+			admin.GET("/events/:id/pickups/manifest", pickupHandler.GetPickupManifest)
+			admin.GET("/events/:id/pickups/manifest.pdf", pickupHandler.DownloadPickupManifestPDF)
+			admin.POST("/events/:id/pickups/assign", pickupHandler.AssignPickups)
+			admin.GET("/events/:id/pickups/progress", pickupHandler.GetPickupProgress)
+			admin.PUT("/events/:id/waitlist/order", waitlistHandler.ReorderWaitlist)
+			admin.DELETE("/events/:id/waitlist/:entryId", waitlistHandler.RemoveWaitlistEntry)
+			admin.GET("/events/:id/checkin-stats", checkInHandler.GetCheckInStats)
+			admin.POST("/events/:id/checkin-bundle", checkInHandler.ExportBundle)
+
+			// Door check-in (scan ticket QR codes)
+			admin.POST("/checkin/validate", checkInHandler.ValidateScan)
+			admin.POST("/checkin", checkInHandler.CheckIn)
+			// Offline scanners: verify tokens with the public key, upload scans afterwards
+			admin.GET("/checkin/public-key", checkInHandler.GetSigningPublicKey)
+			admin.POST("/checkin/offline-scans", checkInHandler.UploadOfflineScans)
 			// Generic announcement to all users
 			admin.POST("/users/announce", adminHandler.SendAnnouncementToAllUsers)
 
@@ -350,6 +364,7 @@ func main() {
 				admin.PUT("/users/:id/password", adminHandler.ResetUserPassword)
 			}
 			admin.PUT("/users/:id/active", adminHandler.UpdateUserActive)
+			admin.PUT("/users/:id/driver", adminHandler.UpdateUserDriver)
 
 			// Ticket management (with rate limiting and 1-hour block after 5 attempts)
 			ticketCancelGroup := admin.Group("/tickets")
@@ -357,6 +372,55 @@ func main() {
 			{
 				ticketCancelGroup.POST("/:id/cancel", adminHandler.CancelTicket)
 			}
+			admin.GET("/tickets/:id/history", transferHandler.GetTicketHistory)
+			admin.GET("/tickets/:id/status-history", transferHandler.GetTicketStatusHistory)
+
+			// Promo codes
+			admin.GET("/promo-codes", promoHandler.GetPromoCodes)
+			admin.POST("/promo-codes", promoHandler.CreatePromoCode)
+			admin.PUT("/promo-codes/:id", promoHandler.UpdatePromoCode)
+			admin.DELETE("/promo-codes/:id", promoHandler.DeletePromoCode)
+			// Orders
+			admin.GET("/orders/:id", orderHandler.GetOrder)
+			admin.POST("/orders/:id/items/:itemId/refund", orderHandler.RefundOrderItem)
+
+			// Refunds
+			admin.GET("/refunds", refundHandler.GetRefunds)
+			admin.POST("/refunds/:id/retry", refundHandler.RetryRefund)
+
+			// Payment disputes (chargebacks)
+			admin.GET("/disputes", disputeHandler.GetDisputes)
+
+			// Payment reconciliation with the providers
+			admin.GET("/reconciliation", reconciliationHandler.GetRuns)
+			admin.GET("/reconciliation/:id", reconciliationHandler.GetRun)
+			admin.POST("/reconciliation", reconciliationHandler.StartRun)
+
+			// Event cancellations (progress and failures to handle by hand)
+			admin.GET("/event-cancellations/:id", eventCancellationHandler.GetEventCancellation)
+
+			// Background jobs
+			admin.GET("/jobs", jobHandler.GetJobs)
+			admin.GET("/jobs/:name/runs", jobHandler.GetJobRuns)
+			admin.POST("/jobs/:name/run", jobHandler.RunJob)
+
+			// Payment ledger
+			admin.GET("/ledger", ledgerHandler.GetTransactions)
+			admin.GET("/ledger/balances/:group", ledgerHandler.GetBalances)
+			admin.POST("/ledger/adjustments", ledgerHandler.CreateAdjustment)
+
+			// Invoices and credit notes
+			admin.GET("/invoices", invoiceHandler.GetInvoices)
+			admin.GET("/invoices/:id/pdf", invoiceHandler.DownloadInvoice)
+
+			// VAT rates and monthly VAT report
+			admin.GET("/tax/rates", taxHandler.GetTaxRates)
+			admin.GET("/tax/vat-report", taxHandler.GetVATReport)
+
+			// Payment webhooks (stored events, replay of failed ones)
+			admin.GET("/webhooks", webhookHandler.GetWebhookEvents)
+			admin.GET("/webhooks/:id", webhookHandler.GetWebhookEvent)
+			admin.POST("/webhooks/:id/replay", webhookHandler.ReplayWebhookEvent)
 
 			// Audit log management
 			admin.GET("/audit/logs", adminHandler.GetAuditLogs)
@@ -366,8 +430,12 @@ func main() {
 			admin.GET("/settings/pickup-price", adminHandler.GetPickupServicePrice)
 			admin.PUT("/settings/pickup-price", adminHandler.UpdatePickupServicePrice)
 
-			// Pickup export
-			admin.GET("/pickups/export.csv", adminHandler.ExportPickupCSV)
+			// Pickup zones (priced by postal code)
+			admin.GET("/pickup-zones", pickupHandler.GetPickupZones)
+			admin.POST("/pickup-zones", pickupHandler.CreatePickupZone)
+			admin.PUT("/pickup-zones/:zoneId", pickupHandler.UpdatePickupZone)
+			admin.DELETE("/pickup-zones/:zoneId", pickupHandler.DeletePickupZone)
+			admin.GET("/drivers", pickupHandler.GetDrivers)
 
 			// Backup management (read-only for monitoring)
 			admin.GET("/backups", adminHandler.GetAllBackups)
@@ -420,6 +488,15 @@ func main() {
 		// Payment webhooks
 		api.POST("/stripe/webhook", stripeHandler.HandleWebhook)
 		api.POST("/paypal/webhook", paypalHandler.HandleWebhook)
+
+		// Fake hosted checkout of the mock payment provider (development and tests only)
+		if mockProvider := ticketService.MockProvider(); mockProvider != nil {
+			log.Printf("WARN: Mock payments enabled - Stripe and PayPal are simulated, no money is moved")
+			mockPaymentHandler := handlers.NewMockPaymentHandler(mockProvider, cfg.PaymentMockOutcome)
+			api.GET("/mock-payments/checkout/:id", mockPaymentHandler.GetCheckout)
+			api.POST("/mock-payments/checkout/:id", mockPaymentHandler.CompleteCheckout)
+			api.POST("/mock-payments/paypal/webhook", mockPaymentHandler.DeliverPayPalWebhook)
+		}
 	}
 
 	// Start server
@@ -454,5 +531,10 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Let running jobs finish
+	if err := schedulerService.Stop(ctx); err != nil {
+		log.Printf("Scheduler shutdown: %v", err)
+	}
+
 	log.Println("Server exited")
 }
@@ -35,6 +35,9 @@ type Config struct {
 	JWTAccessTokenDuration  time.Duration
 	JWTRefreshTokenDuration time.Duration
 
+	// Ticket QR codes (Ed25519 seed, base64, 32 bytes, for offline-verifiable ticket tokens; required in production)
+	TicketSigningKey string
+
 	// Admin
 	AdminUsername string
 	AdminPassword string
@@ -57,6 +60,25 @@ type Config struct {
 	PayPalCancelURL    string
 	PayPalEnabled      bool
 
+	// Mock payments: replaces Stripe and PayPal with an offline provider; only with Env development or test
+	PaymentMockEnabled       bool
+	PaymentMockOutcome       string // preselected checkout outcome: success, decline, delayed, async_failure
+	PaymentMockRefundOutcome string // success or fail
+	PaymentMockDelaySeconds  int    // settle time of delayed and async_failure payments
+	PaymentMockWebhookURL    string // API base the simulated webhooks are posted to
+
+	// Payment reconciliation: nightly comparison of tickets with Stripe and PayPal
+	ReconciliationEnabled      bool
+	ReconciliationHour         int    // hour (Europe/Berlin) the nightly run starts
+	ReconciliationLookbackDays int    // tickets created in the last N days are checked
+	ReconciliationAutoFix      bool   // apply safe fixes in the nightly run
+	ReconciliationEmail        string // report recipient (defaults to ADMIN_ALERT_EMAIL)
+
+	// Background jobs
+	SchedulerEnabled          bool   // run scheduled jobs on this instance; manual triggers work regardless
+	SchedulerInstanceID       string // name of this instance in job locks and runs (defaults to the hostname)
+	SchedulerRunRetentionDays int    // job runs older than this are deleted
+
 	// SMTP
 	SMTPHost     string
 	SMTPPort     int
@@ -123,7 +145,9 @@ type Config struct {
 	ClickSendAPIKey   string
 	ClickSendFrom     string
 
-	// Ticket cancellation policy
+	// Default ticket cancellation policy for events without their own:
+	// TicketCancellationTiers ("30:100,14:50"), or else one tier of TicketCancellationDays/RefundPercent if enabled
+	TicketCancellationTiers         string
 	TicketCancellationEnabled       bool
 	TicketCancellationDays          int
 	TicketCancellationRefundPercent int
@@ -132,6 +156,21 @@ type Config struct {
 	PendingTicketTTLMinutes     int
 	PendingTicketCleanupEnabled bool
 
+	// Waitlist
+	WaitlistOfferTTLMinutes int // How long a waitlist offer can be claimed
+
+	// Invoices (Rechnungen / Gutschriften)
+	InvoiceSellerName      string
+	InvoiceSellerAddress   []string // address lines
+	InvoiceSellerEmail     string
+	InvoiceSellerVATID     string // USt-IdNr.
+	InvoiceSellerTaxNumber string // Steuernummer (used if no USt-IdNr.)
+	InvoiceVATRate         int    // percent, prices are gross
+	InvoiceSmallBusiness   bool   // Kleinunternehmer (§ 19 UStG): no VAT shown
+	// TaxRates sets the VAT rate per product type ("ticket:7,pickup:19,addon:19") for events
+	// without their own; product types not listed use InvoiceVATRate
+	TaxRates string
+
 	// Admin security & audit
 	AdminAlertEmail              string // Email for security alerts
 	AdminRateLimitActions        int    // Max actions per time window
@@ -173,6 +212,9 @@ func New() *Config {
 		JWTAccessTokenDuration:  getEnvAsDuration("JWT_ACCESS_TOKEN_DURATION", "1h"),
 		JWTRefreshTokenDuration: getEnvAsDuration("JWT_REFRESH_TOKEN_DURATION", "168h"),
 
+		// Ticket QR codes
+		TicketSigningKey: getEnv("TICKET_SIGNING_KEY", ""),
+
 		// Admin
 		AdminUsername: getEnv("ADMIN_USERNAME", "admin"),
 		AdminPassword: getEnv("ADMIN_PASSWORD", "admin123"),
@@ -195,6 +237,25 @@ func New() *Config {
 		PayPalCancelURL:  getEnv("PAYPAL_CANCEL_URL", "https://synesthesie.de/payment/cancel"),
 		PayPalEnabled:    getEnv("PAYPAL_ENABLED", "false") == "true",
 
+		// Mock payments
+		PaymentMockEnabled:       getEnv("PAYMENT_MOCK_ENABLED", "false") == "true",
+		PaymentMockOutcome:       getEnv("PAYMENT_MOCK_OUTCOME", "success"),
+		PaymentMockRefundOutcome: getEnv("PAYMENT_MOCK_REFUND_OUTCOME", "success"),
+		PaymentMockDelaySeconds:  getEnvAsInt("PAYMENT_MOCK_DELAY_SECONDS", 20),
+		PaymentMockWebhookURL:    getEnv("PAYMENT_MOCK_WEBHOOK_URL", "http://localhost:"+getEnv("PORT", "8080")+"/api/v1"),
+
+		// Payment reconciliation
+		ReconciliationEnabled:      getEnv("RECONCILIATION_ENABLED", "true") == "true",
+		ReconciliationHour:         getEnvAsInt("RECONCILIATION_HOUR", 3),
+		ReconciliationLookbackDays: getEnvAsInt("RECONCILIATION_LOOKBACK_DAYS", 30),
+		ReconciliationAutoFix:      getEnv("RECONCILIATION_AUTO_FIX", "false") == "true",
+		ReconciliationEmail:        getEnv("RECONCILIATION_EMAIL", getEnv("ADMIN_ALERT_EMAIL", getEnv("ADMIN_EMAIL", "admin@synesthesie.de"))),
+
+		// Background jobs
+		SchedulerEnabled:          getEnv("SCHEDULER_ENABLED", "true") == "true",
+		SchedulerInstanceID:       getEnv("SCHEDULER_INSTANCE_ID", defaultInstanceID()),
+		SchedulerRunRetentionDays: getEnvAsInt("SCHEDULER_RUN_RETENTION_DAYS", 7),
+
 		// SMTP
 		SMTPHost:     getEnv("SMTP_HOST", "smtp.strato.de"),
 		SMTPPort:     getEnvAsInt("SMTP_PORT", 465),
@@ -260,6 +321,7 @@ func New() *Config {
 		ClickSendFrom:     getEnv("CLICKSEND_FROM", "Synesthesie"),
 
 		// Ticket cancellation policy
+		TicketCancellationTiers:         getEnv("TICKET_CANCELLATION_TIERS", ""),
 		TicketCancellationEnabled:       getEnv("TICKET_CANCELLATION_ENABLED", "false") == "true",
 		TicketCancellationDays:          getEnvAsInt("TICKET_CANCELLATION_DAYS", 14),
 		TicketCancellationRefundPercent: getEnvAsInt("TICKET_CANCELLATION_REFUND_PERCENT", 50),
@@ -268,6 +330,19 @@ func New() *Config {
 		PendingTicketTTLMinutes:     getEnvAsInt("PENDING_TICKET_TTL_MINUTES", 30),
 		PendingTicketCleanupEnabled: getEnv("PENDING_TICKET_CLEANUP_ENABLED", "true") == "true",
 
+		// Waitlist
+		WaitlistOfferTTLMinutes: getEnvAsInt("WAITLIST_OFFER_TTL_MINUTES", 120),
+
+		// Invoices
+		InvoiceSellerName:      getEnv("INVOICE_SELLER_NAME", "Synesthesie"),
+		InvoiceSellerAddress:   getEnvAsSlice("INVOICE_SELLER_ADDRESS", []string{}),
+		InvoiceSellerEmail:     getEnv("INVOICE_SELLER_EMAIL", getEnv("SMTP_FROM", "info@synesthesie.de")),
+		InvoiceSellerVATID:     getEnv("INVOICE_SELLER_VAT_ID", ""),
+		InvoiceSellerTaxNumber: getEnv("INVOICE_SELLER_TAX_NUMBER", ""),
+		InvoiceVATRate:         getEnvAsInt("INVOICE_VAT_RATE", 19),
+		InvoiceSmallBusiness:   getEnv("INVOICE_SMALL_BUSINESS", "false") == "true",
+		TaxRates:               getEnv("TAX_RATES", ""),
+
 		// Admin security & audit
 		AdminAlertEmail:             getEnv("ADMIN_ALERT_EMAIL", getEnv("ADMIN_EMAIL", "admin@synesthesie.de")),
 		AdminRateLimitActions:       getEnvAsInt("ADMIN_RATE_LIMIT_ACTIONS", 10),
@@ -322,3 +397,11 @@ func getEnvAsSlice(key string, defaultValue []string) []string {
 	}
 	return strings.Split(valueStr, ",")
 }
+
+// defaultInstanceID names the instance by its hostname (the container ID in Docker)
+func defaultInstanceID() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "api"
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/synesthesie/backend/internal/models"
 	"github.com/synesthesie/backend/internal/services"
+	"gorm.io/gorm"
 )
 
 type AdminHandler struct {
@@ -31,6 +32,8 @@ type AdminHandler struct {
 	backupService  *services.BackupService
 	emailService   *services.EmailService
 	auditService   *services.AuditService
+	// Optional: waitlist shown in event details
+	WaitlistService *services.WaitlistService
 }
 
 func NewAdminHandler(adminService *services.AdminService, eventService *services.EventService, inviteService *services.InviteService, userService *services.UserService, ticketService *services.TicketService, storageService *services.StorageService, s3Service *services.S3Service, qrService *services.QRService, backupService *services.BackupService, emailService *services.EmailService, auditService *services.AuditService) *AdminHandler {
@@ -74,24 +77,26 @@ func (h *AdminHandler) GetAllEvents(c *gin.Context) {
 		availableSpots := event.GetAvailableSpots(h.eventService.GetDB())
 		turnover := turnoverMap[event.ID]
 		eventList[i] = gin.H{
-			"id":               event.ID,
-			"name":             event.Name,
-			"description":      event.Description,
-			"date_from":        event.DateFrom,
-			"date_to":          event.DateTo,
-			"time_from":        event.TimeFrom,
-			"time_to":          event.TimeTo,
-			"max_participants": event.MaxParticipants,
-			"price":            event.Price,
-			"guests_price":     event.GuestsPrice,
-			"bubble_price":     event.BubblePrice,
-			"plus_price":       event.PlusPrice,
-			"allowed_group":    event.AllowedGroup,
-			"is_active":        event.IsActive,
-			"available_spots":  availableSpots,
-			"turnover":         turnover,
-			"created_at":       event.CreatedAt,
-			"updated_at":       event.UpdatedAt,
+			"id":                  event.ID,
+			"name":                event.Name,
+			"description":         event.Description,
+			"date_from":           event.DateFrom,
+			"date_to":             event.DateTo,
+			"time_from":           event.TimeFrom,
+			"time_to":             event.TimeTo,
+			"max_participants":    event.MaxParticipants,
+			"price":               event.Price,
+			"guests_price":        event.GuestsPrice,
+			"bubble_price":        event.BubblePrice,
+			"plus_price":          event.PlusPrice,
+			"allowed_group":       event.AllowedGroup,
+			"is_active":           event.IsActive,
+			"cancellation_policy": event.CancellationPolicy,
+			"tax_rates":           event.TaxRates,
+			"available_spots":     availableSpots,
+			"turnover":            turnover,
+			"created_at":          event.CreatedAt,
+			"updated_at":          event.UpdatedAt,
 		}
 	}
 
@@ -108,17 +113,23 @@ func (h *AdminHandler) GetAllEvents(c *gin.Context) {
 // CreateEvent creates a new event
 func (h *AdminHandler) CreateEvent(c *gin.Context) {
 	var req struct {
-		Name            string    `json:"name" binding:"required"`
-		Description     string    `json:"description"`
-		DateFrom        time.Time `json:"date_from" binding:"required"`
-		DateTo          time.Time `json:"date_to" binding:"required"`
-		TimeFrom        string    `json:"time_from" binding:"required"`
-		TimeTo          string    `json:"time_to" binding:"required"`
-		MaxParticipants int       `json:"max_participants" binding:"required,min=1"`
-		AllowedGroup    string    `json:"allowed_group"` // all|guests|bubble|plus, default all
-		GuestsPrice     float64   `json:"guests_price"`  // default 100
-		BubblePrice     float64   `json:"bubble_price"`  // default 35
-		PlusPrice       float64   `json:"plus_price"`    // default 50
+		Name            string       `json:"name" binding:"required"`
+		Description     string       `json:"description"`
+		DateFrom        time.Time    `json:"date_from" binding:"required"`
+		DateTo          time.Time    `json:"date_to" binding:"required"`
+		TimeFrom        string       `json:"time_from" binding:"required"`
+		TimeTo          string       `json:"time_to" binding:"required"`
+		MaxParticipants int          `json:"max_participants" binding:"required,min=1"`
+		AllowedGroup    string       `json:"allowed_group"` // all|guests|bubble|plus, default all
+		GuestsPrice     models.Money `json:"guests_price"`  // EUR, default 100.00
+		BubblePrice     models.Money `json:"bubble_price"`  // EUR, default 35.00
+		PlusPrice       models.Money `json:"plus_price"`    // EUR, default 50.00
+		// Optional: refund tiers; without them the default cancellation policy applies
+		CancellationPolicy models.CancellationPolicy `json:"cancellation_policy"`
+		// Optional: VAT rates per product type (ticket, pickup, addon); others use the default rates
+		TaxRates models.TaxRates `json:"tax_rates"`
+		// Optional: without ticket types one type per group is created from the group prices
+		TicketTypes []ticketTypeRequest `json:"ticket_types"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -138,6 +149,12 @@ func (h *AdminHandler) CreateEvent(c *gin.Context) {
 		GuestsPrice:     req.GuestsPrice,
 		BubblePrice:     req.BubblePrice,
 		PlusPrice:       req.PlusPrice,
+
+		CancellationPolicy: req.CancellationPolicy,
+		TaxRates:           req.TaxRates,
+	}
+	for i := range req.TicketTypes {
+		event.TicketTypes = append(event.TicketTypes, *req.TicketTypes[i].toModel())
 	}
 
 	if err := h.eventService.CreateEvent(event); err != nil {
@@ -187,17 +204,21 @@ func (h *AdminHandler) UpdateEvent(c *gin.Context) {
 	}
 
 	var req struct {
-		Name            string    `json:"name"`
-		Description     string    `json:"description"`
-		DateFrom        time.Time `json:"date_from"`
-		DateTo          time.Time `json:"date_to"`
-		TimeFrom        string    `json:"time_from"`
-		TimeTo          string    `json:"time_to"`
-		MaxParticipants int       `json:"max_participants"`
-		AllowedGroup    string    `json:"allowed_group"`
-		GuestsPrice     *float64  `json:"guests_price"`
-		BubblePrice     *float64  `json:"bubble_price"`
-		PlusPrice       *float64  `json:"plus_price"`
+		Name            string        `json:"name"`
+		Description     string        `json:"description"`
+		DateFrom        time.Time     `json:"date_from"`
+		DateTo          time.Time     `json:"date_to"`
+		TimeFrom        string        `json:"time_from"`
+		TimeTo          string        `json:"time_to"`
+		MaxParticipants int           `json:"max_participants"`
+		AllowedGroup    string        `json:"allowed_group"`
+		GuestsPrice     *models.Money `json:"guests_price"`
+		BubblePrice     *models.Money `json:"bubble_price"`
+		PlusPrice       *models.Money `json:"plus_price"`
+		// Replaces the refund tiers; an empty list switches back to the default policy
+		CancellationPolicy *models.CancellationPolicy `json:"cancellation_policy"`
+		// Replaces the VAT rates; an empty object switches back to the default rates
+		TaxRates *models.TaxRates `json:"tax_rates"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -239,80 +260,19 @@ func (h *AdminHandler) UpdateEvent(c *gin.Context) {
 	if req.PlusPrice != nil {
 		updates["plus_price"] = *req.PlusPrice
 	}
-
-	if err := h.eventService.UpdateEvent(eventID, updates); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if req.CancellationPolicy != nil {
+		updates["cancellation_policy"] = *req.CancellationPolicy
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Event updated successfully"})
-}
-
-// DeleteEvent deletes an event
-func (h *AdminHandler) DeleteEvent(c *gin.Context) {
-	eventIDStr := c.Param("id")
-	eventID, err := uuid.Parse(eventIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
-		return
+	if req.TaxRates != nil {
+		updates["tax_rates"] = *req.TaxRates
 	}
 
-	// Anforderung „Event löschen“: Statt Hard-Delete setzen wir Event auf inactive,
-	// erstatten alle Tickets voll und informieren die Nutzer per E-Mail.
-	ts := services.NewTicketService(h.eventService.GetDB(), h.adminService.GetConfig())
-	if err := ts.CancelAllTicketsForEvent(eventID, true); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund/cancel tickets"})
-		return
-	}
-	if err := h.eventService.DeactivateEvent(eventID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// E-Mails an Ticketinhaber
-	// Lade betroffene Tickets (auch bereits pending/paid vor Statuswechsel) und sende Info-Mail
-	tsList, _ := services.NewTicketService(h.eventService.GetDB(), h.adminService.GetConfig()).GetEventTickets(eventID)
-	email := services.NewEmailService(h.adminService.GetConfig())
-	loc, _ := time.LoadLocation("Europe/Berlin")
-	cancelledAt := time.Now().In(loc).Format("02.01.2006 15:04")
-	for _, t := range tsList {
-		data := map[string]interface{}{
-			"UserName":       t.User.Name,
-			"EventName":      t.Event.Name,
-			"EventDate":      t.Event.DateFrom.In(loc).Format("02.01.2006"),
-			"EventTime":      t.Event.TimeFrom,
-			"TicketID":       t.ID,
-			"RefundAmount":   fmt.Sprintf("%.2f", t.TotalAmount),
-			"FullRefund":     true,
-			"PartialRefund":  false,
-			"CancellationBy": "abgesagt durch die Veranstalter:innen",
-			"CancellationAt": cancelledAt,
-		}
-		_ = email.SendEventCancelled(t.User.Email, data)
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Event cancelled and deactivated successfully"})
-}
-
-// DeactivateEvent deactivates an event
-func (h *AdminHandler) DeactivateEvent(c *gin.Context) {
-	eventIDStr := c.Param("id")
-	eventID, err := uuid.Parse(eventIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
-		return
-	}
-
-	// Optional: set all tickets of event to cancelled (no refund) when deactivating
-	ts := services.NewTicketService(h.eventService.GetDB(), h.adminService.GetConfig())
-	_ = ts.CancelAllTicketsForEvent(eventID, false)
-
-	if err := h.eventService.DeactivateEvent(eventID); err != nil {
+	if err := h.eventService.UpdateEvent(eventID, updates); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Event deactivated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Event updated successfully"})
 }
 
 // RefundEventTickets refunds all tickets for an event
@@ -360,13 +320,16 @@ func (h *AdminHandler) GetEventDetails(c *gin.Context) {
 
 	// Group participants by user group and sort alphabetically
 	type Participant struct {
-		TicketID string `json:"ticket_id"`
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Drink1   string `json:"drink1"`
-		Drink2   string `json:"drink2"`
-		Drink3   string `json:"drink3"`
-		Group    string `json:"group"`
+		TicketID   string `json:"ticket_id"`
+		Name       string `json:"name"`
+		Email      string `json:"email"`
+		Drink1     string `json:"drink1"`
+		Drink2     string `json:"drink2"`
+		Drink3     string `json:"drink3"`
+		Group      string `json:"group"`
+		TicketType string `json:"ticket_type"`
+		PromoCode  string `json:"promo_code,omitempty"`
+		BookedBy   string `json:"booked_by,omitempty"` // set for companions: the member who booked the ticket
 	}
 
 	groupedParticipants := make(map[string][]Participant)
@@ -383,13 +346,20 @@ func (h *AdminHandler) GetEventDetails(c *gin.Context) {
 		}
 
 		p := Participant{
-			TicketID: ticket.ID.String(),
-			Name:     ticket.User.Name,
-			Email:    ticket.User.Email,
-			Drink1:   ticket.User.Drink1,
-			Drink2:   ticket.User.Drink2,
-			Drink3:   ticket.User.Drink3,
-			Group:    ticket.User.Group,
+			TicketID:   ticket.ID.String(),
+			Name:       ticket.AttendeeName(),
+			Email:      ticket.User.Email,
+			Drink1:     ticket.User.Drink1,
+			Drink2:     ticket.User.Drink2,
+			Drink3:     ticket.User.Drink3,
+			Group:      ticket.User.Group,
+			TicketType: ticket.TicketTypeName,
+			PromoCode:  ticket.PromoCode,
+		}
+		if ticket.HolderName != "" {
+			// Companion of the buyer: drink preferences belong to the buyer
+			p.BookedBy = ticket.User.Name
+			p.Drink1, p.Drink2, p.Drink3 = "", "", ""
 		}
 
 		group := ticket.User.Group
@@ -419,35 +389,331 @@ func (h *AdminHandler) GetEventDetails(c *gin.Context) {
 	// Calculate available spots
 	availableSpots := event.GetAvailableSpots(h.eventService.GetDB())
 
+	// Waitlist in queue order
+	waitlist := []gin.H{}
+	if h.WaitlistService != nil {
+		entries, _ := h.WaitlistService.GetEventWaitlist(eventID)
+		for _, e := range entries {
+			waitlist = append(waitlist, gin.H{
+				"id":               e.ID,
+				"user_id":          e.UserID,
+				"name":             e.User.Name,
+				"email":            e.User.Email,
+				"group":            e.User.Group,
+				"position":         e.Position,
+				"status":           e.Status,
+				"offered_at":       e.OfferedAt,
+				"offer_expires_at": e.OfferExpiresAt,
+				"created_at":       e.CreatedAt,
+			})
+		}
+	}
+
+	// Ticket types with sales figures
+	ticketTypes := []gin.H{}
+	if types, err := h.eventService.GetTicketTypes(eventID); err == nil {
+		for _, t := range types {
+			ticketTypes = append(ticketTypes, ticketTypeJSON(t, h.eventService.GetDB()))
+		}
+	}
+
 	// Get turnover
 	turnoverMap, _ := h.eventService.GetTurnoverByEventIDs([]uuid.UUID{event.ID})
 	turnover := turnoverMap[event.ID]
 
 	c.JSON(http.StatusOK, gin.H{
 		"event": gin.H{
-			"id":                 event.ID,
-			"name":               event.Name,
-			"description":        event.Description,
-			"date_from":          event.DateFrom,
-			"date_to":            event.DateTo,
-			"time_from":          event.TimeFrom,
-			"time_to":            event.TimeTo,
-			"max_participants":   event.MaxParticipants,
-			"guests_price":       event.GuestsPrice,
-			"bubble_price":       event.BubblePrice,
-			"plus_price":         event.PlusPrice,
-			"allowed_group":      event.AllowedGroup,
-			"is_active":          event.IsActive,
-			"available_spots":    availableSpots,
-			"total_participants": totalParticipants,
-			"turnover":           turnover,
-			"created_at":         event.CreatedAt,
-			"updated_at":         event.UpdatedAt,
+			"id":                  event.ID,
+			"name":                event.Name,
+			"description":         event.Description,
+			"date_from":           event.DateFrom,
+			"date_to":             event.DateTo,
+			"time_from":           event.TimeFrom,
+			"time_to":             event.TimeTo,
+			"max_participants":    event.MaxParticipants,
+			"guests_price":        event.GuestsPrice,
+			"bubble_price":        event.BubblePrice,
+			"plus_price":          event.PlusPrice,
+			"allowed_group":       event.AllowedGroup,
+			"is_active":           event.IsActive,
+			"cancellation_policy": event.CancellationPolicy,
+			"tax_rates":           event.TaxRates,
+			"available_spots":     availableSpots,
+			"total_participants":  totalParticipants,
+			"turnover":            turnover,
+			"created_at":          event.CreatedAt,
+			"updated_at":          event.UpdatedAt,
 		},
 		"participants": groupedParticipants,
+		"ticket_types": ticketTypes,
+		"waitlist":     waitlist,
 	})
 }
 
+// ticketTypeRequest is the admin payload of a ticket type
+type ticketTypeRequest struct {
+	Name          string       `json:"name" binding:"required"`
+	Description   string       `json:"description"`
+	Price         models.Money `json:"price" binding:"min=0"`
+	Quota         int          `json:"quota" binding:"min=0"` // 0 = only limited by event capacity
+	SalesStart    *time.Time   `json:"sales_start"`
+	SalesEnd      *time.Time   `json:"sales_end"`
+	AllowedGroups []string     `json:"allowed_groups"` // empty = all groups the event allows
+	SortOrder     int          `json:"sort_order"`
+	IsActive      *bool        `json:"is_active"` // default true
+}
+
+func (r *ticketTypeRequest) toModel() *models.TicketType {
+	t := &models.TicketType{
+		Name:          r.Name,
+		Description:   r.Description,
+		Price:         r.Price,
+		Quota:         r.Quota,
+		SalesStart:    r.SalesStart,
+		SalesEnd:      r.SalesEnd,
+		AllowedGroups: strings.Join(r.AllowedGroups, ","),
+		SortOrder:     r.SortOrder,
+		IsActive:      true,
+	}
+	if r.IsActive != nil {
+		t.IsActive = *r.IsActive
+	}
+	return t
+}
+
+func ticketTypeJSON(t *models.TicketType, db *gorm.DB) gin.H {
+	return gin.H{
+		"id":             t.ID,
+		"event_id":       t.EventID,
+		"name":           t.Name,
+		"description":    t.Description,
+		"price":          t.Price,
+		"quota":          t.Quota,
+		"sales_start":    t.SalesStart,
+		"sales_end":      t.SalesEnd,
+		"allowed_groups": t.Groups(),
+		"sort_order":     t.SortOrder,
+		"is_active":      t.IsActive,
+		"on_sale":        t.OnSale(time.Now()),
+		"sold":           t.BookedCount(db),
+		"remaining":      t.Remaining(db),
+	}
+}
+
+// GetTicketTypes lists the ticket types of an event with sales figures
+// GET /admin/events/:id/ticket-types
+func (h *AdminHandler) GetTicketTypes(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	types, err := h.eventService.GetTicketTypes(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket types"})
+		return
+	}
+
+	list := make([]gin.H, len(types))
+	for i, t := range types {
+		list[i] = ticketTypeJSON(t, h.eventService.GetDB())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ticket_types": list})
+}
+
+// CreateTicketType adds a ticket type to an event
+// POST /admin/events/:id/ticket-types
+func (h *AdminHandler) CreateTicketType(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req ticketTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	t := req.toModel()
+	if err := h.eventService.CreateTicketType(eventID, t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ticketTypeJSON(t, h.eventService.GetDB()))
+}
+
+// UpdateTicketType replaces a ticket type definition
+// PUT /admin/events/:id/ticket-types/:typeId
+func (h *AdminHandler) UpdateTicketType(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	typeID, err := uuid.Parse(c.Param("typeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+		return
+	}
+
+	var req ticketTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.eventService.UpdateTicketType(eventID, typeID, req.toModel()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ticket type updated successfully"})
+}
+
+// DeleteTicketType removes a ticket type that has no tickets
+// DELETE /admin/events/:id/ticket-types/:typeId
+func (h *AdminHandler) DeleteTicketType(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	typeID, err := uuid.Parse(c.Param("typeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+		return
+	}
+
+	if err := h.eventService.DeleteTicketType(eventID, typeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ticket type deleted successfully"})
+}
+
+// addonRequest is the admin payload of an event add-on
+type addonRequest struct {
+	Name        string       `json:"name" binding:"required"`
+	Description string       `json:"description"`
+	Price       models.Money `json:"price" binding:"min=0"`
+	MaxPerOrder int          `json:"max_per_order" binding:"min=0"` // 0 = unlimited
+	SortOrder   int          `json:"sort_order"`
+	IsActive    *bool        `json:"is_active"` // default true
+}
+
+func (r *addonRequest) toModel() *models.EventAddon {
+	a := &models.EventAddon{
+		Name:        r.Name,
+		Description: r.Description,
+		Price:       r.Price,
+		MaxPerOrder: r.MaxPerOrder,
+		SortOrder:   r.SortOrder,
+		IsActive:    true,
+	}
+	if r.IsActive != nil {
+		a.IsActive = *r.IsActive
+	}
+	return a
+}
+
+// GetAddons lists the add-ons of an event
+// GET /admin/events/:id/addons
+func (h *AdminHandler) GetAddons(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	addons, err := h.eventService.GetAddons(eventID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve add-ons"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"addons": addons})
+}
+
+// CreateAddon adds an add-on to an event
+// POST /admin/events/:id/addons
+func (h *AdminHandler) CreateAddon(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req addonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	a := req.toModel()
+	if err := h.eventService.CreateAddon(eventID, a); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, a)
+}
+
+// UpdateAddon replaces an add-on definition
+// PUT /admin/events/:id/addons/:addonId
+func (h *AdminHandler) UpdateAddon(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	addonID, err := uuid.Parse(c.Param("addonId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid add-on ID"})
+		return
+	}
+
+	var req addonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.eventService.UpdateAddon(eventID, addonID, req.toModel()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Add-on updated successfully"})
+}
+
+// DeleteAddon removes an add-on that was never ordered
+// DELETE /admin/events/:id/addons/:addonId
+func (h *AdminHandler) DeleteAddon(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	addonID, err := uuid.Parse(c.Param("addonId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid add-on ID"})
+		return
+	}
+
+	if err := h.eventService.DeleteAddon(eventID, addonID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Add-on deleted successfully"})
+}
+
 // ExportEventParticipantsCSV exports event participants as CSV grouped by user group
 func (h *AdminHandler) ExportEventParticipantsCSV(c *gin.Context) {
 	log.Printf("DEBUG: ExportEventParticipantsCSV called for event ID: %s", c.Param("id"))
@@ -481,12 +747,13 @@ func (h *AdminHandler) ExportEventParticipantsCSV(c *gin.Context) {
 
 	// Collect participants from paid tickets
 	type ParticipantRow struct {
-		Group  string
-		Name   string
-		Email  string
-		Drink1 string
-		Drink2 string
-		Drink3 string
+		Group      string
+		TicketType string
+		Name       string
+		Email      string
+		Drink1     string
+		Drink2     string
+		Drink3     string
 	}
 
 	rows := make([]ParticipantRow, 0)
@@ -504,14 +771,21 @@ func (h *AdminHandler) ExportEventParticipantsCSV(c *gin.Context) {
 			group = "guests"
 		}
 
-		rows = append(rows, ParticipantRow{
-			Group:  group,
-			Name:   t.User.Name,
-			Email:  t.User.Email,
-			Drink1: t.User.Drink1,
-			Drink2: t.User.Drink2,
-			Drink3: t.User.Drink3,
-		})
+		row := ParticipantRow{
+			Group:      group,
+			TicketType: t.TicketTypeName,
+			Name:       t.AttendeeName(),
+			Email:      t.User.Email,
+			Drink1:     t.User.Drink1,
+			Drink2:     t.User.Drink2,
+			Drink3:     t.User.Drink3,
+		}
+		if t.HolderName != "" {
+			// Companion: booked by the member, no drink preferences of their own
+			row.Name = fmt.Sprintf("%s (Begleitung von %s)", t.HolderName, t.User.Name)
+			row.Drink1, row.Drink2, row.Drink3 = "", "", ""
+		}
+		rows = append(rows, row)
 	}
 
 	// Sort: first by group, then alphabetically by name
@@ -535,11 +809,11 @@ func (h *AdminHandler) ExportEventParticipantsCSV(c *gin.Context) {
 	// Write Excel-compatible separator hint
 	_ = w.Write([]string{"sep=,"})
 	// Header row
-	_ = w.Write([]string{"Gruppe", "Name", "Email", "Lieblingsgetraenk 1", "Lieblingsgetraenk 2", "Lieblingsgetraenk 3"})
+	_ = w.Write([]string{"Gruppe", "Ticket-Typ", "Name", "Email", "Lieblingsgetraenk 1", "Lieblingsgetraenk 2", "Lieblingsgetraenk 3"})
 
 	// Data rows
 	for _, r := range rows {
-		_ = w.Write([]string{r.Group, r.Name, r.Email, r.Drink1, r.Drink2, r.Drink3})
+		_ = w.Write([]string{r.Group, r.TicketType, r.Name, r.Email, r.Drink1, r.Drink2, r.Drink3})
 	}
 
 	w.Flush()
@@ -600,7 +874,8 @@ func (h *AdminHandler) ExportEventDrinksXLSX(c *gin.Context) {
 	drinkMap := make(map[string]*DrinkInfo)
 
 	for _, t := range tickets {
-		if t.Status != "paid" {
+		// Companions have no drink preferences of their own
+		if t.Status != "paid" || t.HolderName != "" {
 			continue
 		}
 		userName := t.User.Name
@@ -939,6 +1214,7 @@ func (h *AdminHandler) GetAllUsers(c *gin.Context) {
 			"name":                 user.Name,
 			"group":                user.Group,
 			"is_active":            user.IsActive,
+			"is_driver":            user.IsDriver,
 			"registered_with_code": user.RegisteredWithCode,
 			"created_at":           user.CreatedAt,
 		}
@@ -995,6 +1271,7 @@ func (h *AdminHandler) GetUserDetails(c *gin.Context) {
 			"drink3":               user.Drink3,
 			"group":                user.Group,
 			"is_active":            user.IsActive,
+			"is_driver":            user.IsDriver,
 			"registered_with_code": user.RegisteredWithCode,
 			"created_at":           user.CreatedAt,
 		},
@@ -1054,7 +1331,7 @@ func (h *AdminHandler) GetPickupServicePrice(c *gin.Context) {
 // UpdatePickupServicePrice updates the pickup service price
 func (h *AdminHandler) UpdatePickupServicePrice(c *gin.Context) {
 	var req struct {
-		Price float64 `json:"price" binding:"required,min=0"`
+		Price models.Money `json:"price" binding:"required,min=0"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1513,52 +1790,26 @@ func (h *AdminHandler) UpdateUserActive(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User active status updated", "is_active": true})
 }
 
-// ExportPickupCSV exports pickup bookings (paid by default) as CSV: name, mobile, pickup_address
-func (h *AdminHandler) ExportPickupCSV(c *gin.Context) {
-	eventIDStr := strings.TrimSpace(c.Query("event_id"))
-	status := strings.TrimSpace(c.DefaultQuery("status", "paid")) // paid|all
-	var eventID *uuid.UUID
-	if eventIDStr != "" {
-		if id, err := uuid.Parse(eventIDStr); err == nil {
-			eventID = &id
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event_id"})
-			return
-		}
-	}
-
-	// Use ticket service to list tickets with pickup
-	ts := services.NewTicketService(h.eventService.GetDB(), h.adminService.GetConfig())
-	tickets, err := ts.ListPickupTickets(eventID, status)
+// UpdateUserDriver grants or revokes the driver role of a user
+// PUT /admin/users/:id/driver
+func (h *AdminHandler) UpdateUserDriver(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pickup tickets"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
-	if len(tickets) == 0 {
-		c.JSON(http.StatusOK, gin.H{"status": "no_pickups"})
-		return
+	var req struct {
+		IsDriver *bool `json:"is_driver" binding:"required"`
 	}
-
-	buf := &bytes.Buffer{}
-	// Prepend UTF-8 BOM for better Excel compatibility (äöüß etc.)
-	_, _ = buf.Write([]byte{0xEF, 0xBB, 0xBF})
-	w := csv.NewWriter(buf)
-	_ = w.Write([]string{"Name", "Mobile", "Pickup-Address"})
-	for _, t := range tickets {
-		name := t.User.Name
-		mobile := t.User.Mobile
-		addr := t.PickupAddress
-		_ = w.Write([]string{name, mobile, addr})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "is_driver boolean required"})
+		return
 	}
-	w.Flush()
-	if err := w.Error(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate csv"})
+	if err := h.userService.UpdateUserDriver(userID, *req.IsDriver); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	c.Header("Content-Type", "text/csv; charset=utf-8")
-	c.Header("Content-Disposition", "attachment; filename=pickups.csv")
-	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+	c.JSON(http.StatusOK, gin.H{"message": "User driver role updated", "is_driver": *req.IsDriver})
 }
 
 // GetAllBackups retrieves all backups with pagination
@@ -1873,7 +2124,7 @@ func (h *AdminHandler) sendCancellationEmail(ticketID uuid.UUID, cancelledBy str
 
 	// Calculate refund details
 	refund := ticket.RefundedAmount
-	full := refund > 0 && refund+1e-9 >= ticket.TotalAmount
+	full := refund > 0 && refund >= ticket.TotalAmount
 	partial := refund > 0 && !full
 
 	data := map[string]interface{}{
@@ -1882,7 +2133,7 @@ func (h *AdminHandler) sendCancellationEmail(ticketID uuid.UUID, cancelledBy str
 		"EventDate":        eventDate,
 		"TicketID":         ticket.ID,
 		"CancellationDate": cancelDate,
-		"RefundAmount":     fmt.Sprintf("%.2f", refund),
+		"RefundAmount":     refund.String(),
 		"FullRefund":       full,
 		"PartialRefund":    partial,
 	}
@@ -1917,7 +2168,8 @@ func (h *AdminHandler) CancelTicket(c *gin.Context) {
 	}
 
 	// Cancel ticket
-	if err := h.ticketService.AdminCancelTicket(ticketID, mode); err != nil {
+	actorID := adminID.(uuid.UUID)
+	if err := h.ticketService.AdminCancelTicket(ticketID, mode, &actorID); err != nil {
 		if err.Error() == "refund_not_eligible" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "refund_not_eligible"})
 			return
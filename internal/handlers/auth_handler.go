@@ -189,6 +189,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			"email":           user.Email,
 			"name":            user.Name,
 			"is_admin":        user.IsAdmin,
+			"is_driver":       user.IsDriver,
 			"group":           user.Group,
 			"mobile_verified": user.MobileVerified,
 		},
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type CheckInHandler struct {
+	checkInService *services.CheckInService
+	ticketService  *services.TicketService
+	qrService      *services.QRService
+	auditService   *services.AuditService
+}
+
+func NewCheckInHandler(checkInService *services.CheckInService, ticketService *services.TicketService, qrService *services.QRService, auditService *services.AuditService) *CheckInHandler {
+	return &CheckInHandler{
+		checkInService: checkInService,
+		ticketService:  ticketService,
+		qrService:      qrService,
+		auditService:   auditService,
+	}
+}
+
+type scanRequest struct {
+	Code    string `json:"code" binding:"required"`
+	EventID string `json:"event_id"` // optional: reject tickets of other events
+}
+
+func (r *scanRequest) eventID() (*uuid.UUID, error) {
+	if r.EventID == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(r.EventID)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func scanTicketJSON(t *models.Ticket) gin.H {
+	return gin.H{
+		"ticket_id":       t.ID,
+		"event_id":        t.EventID,
+		"event_name":      t.Event.Name,
+		"name":            t.AttendeeName(),
+		"group":           t.User.Group,
+		"includes_pickup": t.IncludesPickup,
+		"status":          t.Status,
+		"checked_in_at":   t.CheckedInAt,
+	}
+}
+
+// respondScanError maps check-in errors to HTTP responses
+func respondScanError(c *gin.Context, ticket *models.Ticket, err error) {
+	var already *services.AlreadyCheckedInError
+	if errors.As(err, &already) {
+		resp := gin.H{
+			"error":              "ticket already checked in",
+			"checked_in_at":      already.CheckedInAt,
+			"checked_in_by":      already.CheckedInBy,
+			"checked_in_by_name": already.CheckedInName,
+		}
+		if ticket != nil {
+			resp["ticket"] = scanTicketJSON(ticket)
+		}
+		c.JSON(http.StatusConflict, resp)
+		return
+	}
+	switch err.Error() {
+	case "ticket not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "invalid qr code", "invalid qr signature":
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	}
+}
+
+// ValidateScan checks a scanned ticket QR code without checking it in
+// POST /admin/checkin/validate
+func (h *CheckInHandler) ValidateScan(c *gin.Context) {
+	var req scanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	eventID, err := req.eventID()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	ticket, err := h.checkInService.Validate(req.Code, eventID)
+	if err != nil {
+		respondScanError(c, ticket, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":  true,
+		"ticket": scanTicketJSON(ticket),
+	})
+}
+
+// CheckIn validates a scanned ticket QR code and marks the ticket as checked in
+// POST /admin/checkin
+func (h *CheckInHandler) CheckIn(c *gin.Context) {
+	staffID, _ := c.Get("userID")
+
+	var req scanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	eventID, err := req.eventID()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	ticket, err := h.checkInService.CheckIn(req.Code, eventID, staffID.(uuid.UUID))
+	if err != nil {
+		respondScanError(c, nil, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Checked in",
+		"ticket":  scanTicketJSON(ticket),
+	})
+}
+
+// GetCheckInStats returns live check-in counts for an event
+// GET /admin/events/:id/checkin-stats
+func (h *CheckInHandler) GetCheckInStats(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	stats, err := h.checkInService.GetEventStats(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve check-in stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetSigningPublicKey returns the Ed25519 key scanners use to verify ticket tokens offline
+// GET /admin/checkin/public-key
+func (h *CheckInHandler) GetSigningPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "Ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(h.qrService.TicketPublicKey()),
+	})
+}
+
+// ExportBundle exports the attendee list of an event encrypted for one scanner device
+// POST /admin/events/:id/checkin-bundle
+// Body: {"device_id": "door-1", "device_public_key": "<base64 X25519 public key>"}
+func (h *CheckInHandler) ExportBundle(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req struct {
+		DeviceID        string `json:"device_id" binding:"required"`
+		DevicePublicKey string `json:"device_public_key" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	devicePublicKey, err := base64.StdEncoding.DecodeString(req.DevicePublicKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_public_key must be base64"})
+		return
+	}
+
+	bundle, err := h.checkInService.ExportBundle(eventID, req.DeviceID, devicePublicKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Bundle contains attendee data: keep track of which device received it
+	if h.auditService != nil {
+		_ = h.auditService.LogAction(
+			adminID.(uuid.UUID),
+			"export_checkin_bundle",
+			"event",
+			eventID,
+			map[string]interface{}{
+				"device_id": req.DeviceID,
+				"attendees": bundle.AttendeeCount,
+			},
+			c.ClientIP(),
+			c.Request.UserAgent(),
+		)
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+// UploadOfflineScans merges scans a device recorded while offline
+// POST /admin/checkin/offline-scans
+// Body: {"device_id": "door-1", "scans": [{"token": "SYT1...", "scanned_at": "RFC3339"}]}
+func (h *CheckInHandler) UploadOfflineScans(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	var req struct {
+		DeviceID string                      `json:"device_id" binding:"required"`
+		Scans    []services.OfflineScanInput `json:"scans" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Scans) > 5000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many scans in one upload (max 5000)"})
+		return
+	}
+
+	results, err := h.checkInService.MergeOfflineScans(req.DeviceID, adminID.(uuid.UUID), req.Scans)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge scans"})
+		return
+	}
+
+	summary := map[string]int{}
+	for _, r := range results {
+		summary[r.Result]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id": req.DeviceID,
+		"summary":   summary,
+		"results":   results,
+	})
+}
+
+// GetTicketQR returns the check-in QR code of a paid ticket as PNG
+// GET /user/tickets/:id/qr.png
+func (h *CheckInHandler) GetTicketQR(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	ticket, err := h.ticketService.GetTicketByID(ticketID)
+	if err != nil || ticket.UserID != userID.(uuid.UUID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		return
+	}
+	if ticket.Status != "paid" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "QR code is only available for paid tickets"})
+		return
+	}
+
+	png, err := h.qrService.GenerateTicketQRPNG(ticket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, "image/png", png)
+}
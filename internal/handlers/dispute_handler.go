@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type DisputeHandler struct {
+	ticketService *services.TicketService
+}
+
+func NewDisputeHandler(ticketService *services.TicketService) *DisputeHandler {
+	return &DisputeHandler{
+		ticketService: ticketService,
+	}
+}
+
+// GetDisputes lists payment disputes (chargebacks) with their tickets
+// GET /admin/disputes?state=open
+func (h *DisputeHandler) GetDisputes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	state := strings.TrimSpace(c.Query("state")) // optional: open|won|lost
+
+	disputes, total, err := h.ticketService.GetDisputes(page, limit, state)
+	if err != nil {
+		if err.Error() == "invalid dispute state" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve disputes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"disputes": disputes,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
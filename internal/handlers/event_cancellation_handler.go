@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type EventCancellationHandler struct {
+	eventCancellationService *services.EventCancellationService
+	schedulerService         *services.SchedulerService
+}
+
+func NewEventCancellationHandler(eventCancellationService *services.EventCancellationService, schedulerService *services.SchedulerService) *EventCancellationHandler {
+	return &EventCancellationHandler{
+		eventCancellationService: eventCancellationService,
+		schedulerService:         schedulerService,
+	}
+}
+
+// DeleteEvent cancels an event: instead of a hard delete the event is deactivated, all tickets are
+// fully refunded and the attendees are informed by email. The tickets are processed in the background.
+// DELETE /admin/events/:id
+func (h *EventCancellationHandler) DeleteEvent(c *gin.Context) {
+	h.start(c, true, "Event cancellation started")
+}
+
+// DeactivateEvent deactivates an event and cancels its tickets without refund in the background
+// POST /admin/events/:id/deactivate
+func (h *EventCancellationHandler) DeactivateEvent(c *gin.Context) {
+	h.start(c, false, "Event deactivation started")
+}
+
+func (h *EventCancellationHandler) start(c *gin.Context, refundPaid bool, message string) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	adminID, _ := c.Get("userID")
+	triggeredBy := adminID.(uuid.UUID)
+
+	run, err := h.eventCancellationService.Start(eventID, refundPaid, &triggeredBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEventCancellationRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is already being cancelled"})
+		case err.Error() == "event not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel event"})
+		}
+		return
+	}
+
+	// Start right away; if the job is busy it picks the cancellation up before it ends, otherwise the next run does
+	if _, err := h.schedulerService.Trigger(services.EventCancellationJob, &triggeredBy); err != nil && !errors.Is(err, services.ErrJobRunning) {
+		log.Printf("Event cancellation %s: job not started, waiting for the next run: %v", run.ID, err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": message, "cancellation": run})
+}
+
+// GetEventCancellations lists the cancellations of an event
+// GET /admin/events/:id/cancellations
+func (h *EventCancellationHandler) GetEventCancellations(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	runs, err := h.eventCancellationService.GetEventCancellations(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event cancellations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cancellations": runs})
+}
+
+// GetEventCancellation returns the progress of a cancellation and the tickets that need to be handled by hand
+// GET /admin/event-cancellations/:id
+func (h *EventCancellationHandler) GetEventCancellation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cancellation ID"})
+		return
+	}
+
+	run, err := h.eventCancellationService.GetCancellation(id)
+	if err != nil {
+		if errors.Is(err, services.ErrEventCancellationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event cancellation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event cancellation"})
+		return
+	}
+	failures, err := h.eventCancellationService.GetFailures(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event cancellation"})
+		return
+	}
+
+	list := make([]gin.H, 0, len(failures))
+	for _, item := range failures {
+		list = append(list, gin.H{
+			"ticket_id":     item.TicketID,
+			"user_id":       item.UserID,
+			"user_name":     item.Ticket.User.Name,
+			"user_email":    item.Ticket.User.Email,
+			"from_status":   item.FromStatus,
+			"ticket_status": item.TicketStatus,
+			"refund_id":     item.RefundID,
+			"refund_amount": item.RefundAmount,
+			"refund_status": item.RefundStatus,
+			"notified_at":   item.NotifiedAt,
+			"error":         item.Error,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cancellation": run, "failures": list})
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type EventSeriesHandler struct {
+	eventSeriesService *services.EventSeriesService
+}
+
+func NewEventSeriesHandler(eventSeriesService *services.EventSeriesService) *EventSeriesHandler {
+	return &EventSeriesHandler{
+		eventSeriesService: eventSeriesService,
+	}
+}
+
+// eventSeriesRequest is the admin payload of an event series: the template of its events and the recurrence rule
+type eventSeriesRequest struct {
+	Name               string                    `json:"name" binding:"required"`
+	Description        string                    `json:"description"`
+	TimeFrom           string                    `json:"time_from" binding:"required"`
+	TimeTo             string                    `json:"time_to" binding:"required"`
+	EndDayOffset       int                       `json:"end_day_offset"` // 1 = ends the next day
+	MaxParticipants    int                       `json:"max_participants" binding:"required,min=1"`
+	AllowedGroup       string                    `json:"allowed_group"` // all|guests|bubble|plus, default all
+	GuestsPrice        models.Money              `json:"guests_price"`  // EUR, default 100.00
+	BubblePrice        models.Money              `json:"bubble_price"`  // EUR, default 35.00
+	PlusPrice          models.Money              `json:"plus_price"`    // EUR, default 50.00
+	CancellationPolicy models.CancellationPolicy `json:"cancellation_policy"`
+	TaxRates           models.TaxRates           `json:"tax_rates"`
+
+	Frequency   string          `json:"frequency" binding:"required"` // weekly|monthly
+	Interval    int             `json:"interval"`                     // default 1
+	Weekday     int             `json:"weekday"`                      // 0 = Sunday ... 6 = Saturday
+	WeekOfMonth int             `json:"week_of_month"`                // monthly: 1-5, -1 = last
+	StartsOn    time.Time       `json:"starts_on" binding:"required"`
+	Until       *time.Time      `json:"until"`
+	Exceptions  models.DateList `json:"exceptions"`   // YYYY-MM-DD
+	MonthsAhead int             `json:"months_ahead"` // default 3
+	IsActive    *bool           `json:"is_active"`    // default true
+}
+
+func (r *eventSeriesRequest) toModel() *models.EventSeries {
+	s := &models.EventSeries{
+		Name:               r.Name,
+		Description:        r.Description,
+		TimeFrom:           r.TimeFrom,
+		TimeTo:             r.TimeTo,
+		EndDayOffset:       r.EndDayOffset,
+		MaxParticipants:    r.MaxParticipants,
+		AllowedGroup:       r.AllowedGroup,
+		GuestsPrice:        r.GuestsPrice,
+		BubblePrice:        r.BubblePrice,
+		PlusPrice:          r.PlusPrice,
+		CancellationPolicy: r.CancellationPolicy,
+		TaxRates:           r.TaxRates,
+		Frequency:          r.Frequency,
+		Interval:           r.Interval,
+		Weekday:            r.Weekday,
+		WeekOfMonth:        r.WeekOfMonth,
+		StartsOn:           r.StartsOn,
+		Until:              r.Until,
+		Exceptions:         r.Exceptions,
+		MonthsAhead:        r.MonthsAhead,
+		IsActive:           true,
+	}
+	if r.IsActive != nil {
+		s.IsActive = *r.IsActive
+	}
+	return s
+}
+
+// GetEventSeries lists all event series
+// GET /admin/event-series
+func (h *EventSeriesHandler) GetEventSeries(c *gin.Context) {
+	series, err := h.eventSeriesService.GetAllSeries()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series})
+}
+
+// GetEventSeriesDetails returns an event series with its events
+// GET /admin/event-series/:id
+func (h *EventSeriesHandler) GetEventSeriesDetails(c *gin.Context) {
+	seriesID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	series, events, err := h.eventSeriesService.GetSeries(seriesID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series, "events": events})
+}
+
+// CreateEventSeries creates an event series and its events for the next months
+// POST /admin/event-series
+func (h *EventSeriesHandler) CreateEventSeries(c *gin.Context) {
+	var req eventSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series := req.toModel()
+	created, err := h.eventSeriesService.CreateSeries(series)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Event series created successfully",
+		"series":         series,
+		"events_created": created,
+	})
+}
+
+// UpdateEventSeries replaces an event series; with apply_to_future its future events without tickets follow
+// PUT /admin/event-series/:id
+func (h *EventSeriesHandler) UpdateEventSeries(c *gin.Context) {
+	seriesID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	var req struct {
+		eventSeriesRequest
+		ApplyToFuture bool `json:"apply_to_future"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.eventSeriesService.UpdateSeries(seriesID, req.toModel(), req.ApplyToFuture)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "series not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event series updated successfully", "events": result})
+}
+
+// DeactivateEventSeries ends an event series and deactivates its future events without tickets
+// DELETE /admin/event-series/:id
+func (h *EventSeriesHandler) DeactivateEventSeries(c *gin.Context) {
+	seriesID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	result, err := h.eventSeriesService.DeactivateSeries(seriesID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "series not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event series deactivated successfully", "events": result})
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type InvoiceHandler struct {
+	invoiceService *services.InvoiceService
+}
+
+func NewInvoiceHandler(invoiceService *services.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{
+		invoiceService: invoiceService,
+	}
+}
+
+// sendInvoicePDF streams the stored PDF of an invoice or credit note
+func sendInvoicePDF(c *gin.Context, invoice *models.Invoice) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoice.Number+".pdf"))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", invoice.PDF)
+}
+
+// GetTicketInvoice downloads the invoice of a paid ticket; it is issued on first download
+// GET /user/tickets/:id/invoice
+func (h *InvoiceHandler) GetTicketInvoice(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	invoice, err := h.invoiceService.UserTicketInvoice(ticketID, userID.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendInvoicePDF(c, invoice)
+}
+
+// GetTicketInvoices lists the invoice and credit notes issued for a ticket
+// GET /user/tickets/:id/invoices
+func (h *InvoiceHandler) GetTicketInvoices(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	invoices, err := h.invoiceService.GetTicketInvoices(ticketID, userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoices": invoices})
+}
+
+// DownloadTicketInvoice downloads an invoice or credit note of a ticket
+// GET /user/tickets/:id/invoices/:invoiceId
+func (h *InvoiceHandler) DownloadTicketInvoice(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+	invoiceID, err := uuid.Parse(c.Param("invoiceId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	invoice, err := h.invoiceService.GetUserInvoice(invoiceID, ticketID, userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+
+	sendInvoicePDF(c, invoice)
+}
+
+// GetInvoices lists issued invoices and credit notes for bookkeeping
+// GET /admin/invoices?kind=invoice|credit_note&from=YYYY-MM-DD&to=YYYY-MM-DD
+func (h *InvoiceHandler) GetInvoices(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	kind := strings.TrimSpace(c.Query("kind"))
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		loc = time.UTC
+	}
+	var from, to *time.Time
+	if s := c.Query("from"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date (YYYY-MM-DD)"})
+			return
+		}
+		from = &t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date (YYYY-MM-DD)"})
+			return
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+
+	invoices, total, err := h.invoiceService.GetInvoices(page, limit, kind, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invoices": invoices,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// DownloadInvoice downloads any invoice or credit note
+// GET /admin/invoices/:id/pdf
+func (h *InvoiceHandler) DownloadInvoice(c *gin.Context) {
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	invoice, err := h.invoiceService.GetInvoice(invoiceID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+
+	sendInvoicePDF(c, invoice)
+}
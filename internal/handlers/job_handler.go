@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type JobHandler struct {
+	schedulerService *services.SchedulerService
+}
+
+func NewJobHandler(schedulerService *services.SchedulerService) *JobHandler {
+	return &JobHandler{
+		schedulerService: schedulerService,
+	}
+}
+
+// GetJobs lists the background jobs with their schedule, next run and last run
+// GET /admin/jobs
+func (h *JobHandler) GetJobs(c *gin.Context) {
+	jobs, err := h.schedulerService.ListJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetJobRuns lists the recorded runs of a job
+// GET /admin/jobs/:name/runs?status=failed
+func (h *JobHandler) GetJobRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	status := strings.TrimSpace(c.Query("status")) // optional: succeeded|failed
+
+	runs, total, err := h.schedulerService.GetRuns(c.Param("name"), status, page, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs": runs,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// RunJob starts a job now on this instance; the run is recorded when it is done
+// POST /admin/jobs/:name/run
+func (h *JobHandler) RunJob(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	triggeredBy := adminID.(uuid.UUID)
+
+	run, err := h.schedulerService.Trigger(c.Param("name"), &triggeredBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, services.ErrJobRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": "Job is already running"})
+		default:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"run": run})
+}
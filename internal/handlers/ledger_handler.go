@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type LedgerHandler struct {
+	ledgerService *services.LedgerService
+}
+
+func NewLedgerHandler(ledgerService *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerService: ledgerService,
+	}
+}
+
+// ledgerFilter reads event_id, type, provider and the day range from/to (YYYY-MM-DD, Europe/Berlin, both inclusive)
+func ledgerFilter(c *gin.Context) (services.LedgerFilter, error) {
+	filter := services.LedgerFilter{
+		Type:     strings.TrimSpace(c.Query("type")),
+		Provider: strings.TrimSpace(c.Query("provider")),
+	}
+	if s := c.Query("event_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return filter, errors.New("Invalid event ID")
+		}
+		filter.EventID = &id
+	}
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		loc = time.UTC
+	}
+	if s := c.Query("from"); s != "" {
+		from, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			return filter, errors.New("Invalid from date (YYYY-MM-DD)")
+		}
+		filter.From = &from
+	}
+	if s := c.Query("to"); s != "" {
+		to, err := time.ParseInLocation("2006-01-02", s, loc)
+		if err != nil {
+			return filter, errors.New("Invalid to date (YYYY-MM-DD)")
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	return filter, nil
+}
+
+// GetTransactions lists ledger entries with optional filters
+// GET /admin/ledger
+func (h *LedgerHandler) GetTransactions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	filter, err := ledgerFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, total, err := h.ledgerService.GetTransactions(page, limit, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": entries,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetBalances sums the ledger per event, provider or day
+// GET /admin/ledger/balances/:group (events|providers|days)
+func (h *LedgerHandler) GetBalances(c *gin.Context) {
+	groups := map[string]string{"events": "event", "providers": "provider", "days": "day"}
+	groupBy, ok := groups[c.Param("group")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group must be events, providers or days"})
+		return
+	}
+
+	filter, err := ledgerFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	balances, err := h.ledgerService.Balances(groupBy, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balances"})
+		return
+	}
+
+	var total services.LedgerBalance
+	for _, b := range balances {
+		total.Payments += b.Payments
+		total.Refunds += b.Refunds
+		total.Fees += b.Fees
+		total.Adjustments += b.Adjustments
+		total.Net += b.Net
+		total.Transactions += b.Transactions
+	}
+	total.Key = "total"
+
+	c.JSON(http.StatusOK, gin.H{"balances": balances, "total": total})
+}
+
+// CreateAdjustment books a manual correction in the ledger
+// POST /admin/ledger/adjustments
+func (h *LedgerHandler) CreateAdjustment(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	var req struct {
+		Amount      models.Money `json:"amount" binding:"required"` // positive = money in, negative = money out
+		Description string       `json:"description" binding:"required"`
+		Provider    string       `json:"provider"` // optional: stripe|paypal
+		ProviderRef string       `json:"provider_ref"`
+		EventID     string       `json:"event_id"`
+		TicketID    string       `json:"ticket_id"`
+		OrderID     string       `json:"order_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdBy := adminID.(uuid.UUID)
+	entry := &models.PaymentTransaction{
+		Amount:      req.Amount,
+		Description: strings.TrimSpace(req.Description),
+		Provider:    req.Provider,
+		ProviderRef: req.ProviderRef,
+		CreatedBy:   &createdBy,
+	}
+	var err error
+	if entry.EventID, err = optionalUUID(req.EventID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	if entry.TicketID, err = optionalUUID(req.TicketID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+	if entry.OrderID, err = optionalUUID(req.OrderID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	if err := h.ledgerService.AddAdjustment(entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// optionalUUID parses an optional ID; an empty string gives nil
+func optionalUUID(s string) (*uuid.UUID, error) {
+	if s == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
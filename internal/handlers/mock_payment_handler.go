@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+// MockPaymentHandler serves the fake hosted checkout of the mock payment provider.
+// Only routed when PAYMENT_MOCK_ENABLED=true (never in production).
+type MockPaymentHandler struct {
+	mockProvider *services.MockProvider
+	outcome      string // preselected outcome
+}
+
+func NewMockPaymentHandler(mockProvider *services.MockProvider, outcome string) *MockPaymentHandler {
+	return &MockPaymentHandler{
+		mockProvider: mockProvider,
+		outcome:      outcome,
+	}
+}
+
+var mockCheckoutPage = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html lang="de">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Testzahlung – {{.Checkout.Provider}}</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#f4f4f5;margin:0;padding:2rem}
+main{max-width:28rem;margin:auto;background:#fff;border-radius:8px;padding:1.5rem;box-shadow:0 1px 4px rgba(0,0,0,.1)}
+.banner{background:#fef3c7;color:#92400e;padding:.5rem;border-radius:4px;font-size:.9rem}
+.amount{font-size:2rem;font-weight:bold;margin:1rem 0}
+label{display:block;margin:.4rem 0}
+button{margin-top:1rem;width:100%;padding:.75rem;font-size:1rem;border:0;border-radius:4px;background:#4f46e5;color:#fff;cursor:pointer}
+</style>
+</head>
+<body>
+<main>
+<p class="banner">Simulierte {{.Checkout.Provider}}-Zahlung – es wird kein Geld bewegt.</p>
+<p>{{.Checkout.Description}}</p>
+<p class="amount">{{.Checkout.Amount}} €</p>
+{{if eq .Checkout.Status "open"}}
+<form method="post">
+{{range .Outcomes}}<label><input type="radio" name="outcome" value="{{.}}"{{if eq . $.Outcome}} checked{{end}}> {{.}}</label>
+{{end}}<button type="submit">Weiter</button>
+</form>
+{{else}}
+<p>Status: <strong>{{.Checkout.Status}}</strong></p>
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+// GetCheckout shows the fake hosted checkout page (or the checkout as JSON with ?format=json)
+// GET /mock-payments/checkout/:id
+func (h *MockPaymentHandler) GetCheckout(c *gin.Context) {
+	checkout, err := h.mockProvider.GetCheckout(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checkout not found"})
+		return
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, checkout)
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = mockCheckoutPage.Execute(c.Writer, gin.H{
+		"Checkout": checkout,
+		"Outcomes": services.MockOutcomes,
+		"Outcome":  h.outcome,
+	})
+}
+
+// CompleteCheckout completes a checkout with an outcome (success, decline, delayed, async_failure, cancel).
+// The page form gets a redirect to the success/cancel URL; JSON clients get the URL in the response.
+// POST /mock-payments/checkout/:id
+func (h *MockPaymentHandler) CompleteCheckout(c *gin.Context) {
+	var req struct {
+		Outcome string `json:"outcome" form:"outcome"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	redirectURL, err := h.mockProvider.Complete(c.Param("id"), strings.TrimSpace(req.Outcome))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		c.JSON(http.StatusOK, gin.H{"redirect_url": redirectURL})
+		return
+	}
+	c.Redirect(http.StatusSeeOther, redirectURL)
+}
+
+// DeliverPayPalWebhook signs a PayPal webhook body (e.g. a recorded fixture) with the mock signature
+// and posts it to our PayPal webhook, which verifies and processes it as usual
+// POST /mock-payments/paypal/webhook
+func (h *MockPaymentHandler) DeliverPayPalWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	status, err := h.mockProvider.DeliverPayPalWebhook(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhook_status": status})
+}
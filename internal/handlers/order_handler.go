@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type OrderHandler struct {
+	orderService  *services.OrderService
+	ticketService *services.TicketService
+}
+
+func NewOrderHandler(orderService *services.OrderService, ticketService *services.TicketService) *OrderHandler {
+	return &OrderHandler{
+		orderService:  orderService,
+		ticketService: ticketService,
+	}
+}
+
+func orderJSON(o *models.Order) gin.H {
+	tickets := make([]gin.H, len(o.Tickets))
+	for i, t := range o.Tickets {
+		tickets[i] = gin.H{
+			"id":              t.ID,
+			"holder_name":     t.HolderName,
+			"status":          t.Status,
+			"ticket_type":     t.TicketTypeName,
+			"price":           t.Price,
+			"discount_amount": t.DiscountAmount,
+			"includes_pickup": t.IncludesPickup,
+			"pickup_address":  t.PickupAddress,
+			"pickup_zone":     t.PickupZoneName,
+			"pickup_slot_id":  t.PickupSlotID,
+			"total_amount":    t.TotalAmount,
+			"refunded_amount": t.RefundedAmount,
+		}
+	}
+	items := o.Items
+	if items == nil {
+		items = []models.OrderItem{}
+	}
+	return gin.H{
+		"id":               o.ID,
+		"status":           o.PaymentStatus(),
+		"payment_provider": o.PaymentProvider,
+		"total_amount":     o.TotalAmount,
+		"refunded_amount":  o.RefundedAmount,
+		"created_at":       o.CreatedAt,
+		"event": gin.H{
+			"id":        o.Event.ID,
+			"name":      o.Event.Name,
+			"date_from": o.Event.DateFrom,
+		},
+		"tickets": tickets,
+		"items":   items,
+	}
+}
+
+// CreateOrder books several tickets (for the user and named companions) and add-ons with one checkout
+// POST /user/orders
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		EventID string `json:"event_id" binding:"required"`
+		Tickets []struct {
+			TicketTypeID     string `json:"ticket_type_id"` // optional
+			HolderName       string `json:"holder_name"`    // empty = ticket for the buyer
+			IncludesPickup   bool   `json:"includes_pickup"`
+			PickupAddress    string `json:"pickup_address"`
+			PickupPostalCode string `json:"pickup_postal_code"`
+			PickupSlotID     string `json:"pickup_slot_id"`
+		} `json:"tickets" binding:"required"`
+		Addons []struct {
+			AddonID  string `json:"addon_id"`
+			Quantity int    `json:"quantity"`
+		} `json:"addons"`
+		PromoCode       string `json:"promo_code"`
+		PaymentProvider string `json:"payment_provider"` // "stripe" or "paypal" (optional, defaults to stripe)
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	eventID, err := uuid.Parse(req.EventID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	orderReq := &services.OrderRequest{
+		EventID:         eventID,
+		PromoCode:       req.PromoCode,
+		PaymentProvider: req.PaymentProvider,
+	}
+	if orderReq.PaymentProvider == "" {
+		orderReq.PaymentProvider = "stripe"
+	}
+	for _, t := range req.Tickets {
+		if t.IncludesPickup && t.PickupAddress == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Pickup address is required when pickup service is selected"})
+			return
+		}
+		ticketTypeID := uuid.Nil
+		if t.TicketTypeID != "" {
+			if ticketTypeID, err = uuid.Parse(t.TicketTypeID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+				return
+			}
+		}
+		pickupSlotID := uuid.Nil
+		if t.IncludesPickup && t.PickupSlotID != "" {
+			if pickupSlotID, err = uuid.Parse(t.PickupSlotID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pickup slot ID"})
+				return
+			}
+		}
+		orderReq.Tickets = append(orderReq.Tickets, services.OrderTicketRequest{
+			TicketTypeID:     ticketTypeID,
+			HolderName:       t.HolderName,
+			IncludesPickup:   t.IncludesPickup,
+			PickupAddress:    t.PickupAddress,
+			PickupPostalCode: t.PickupPostalCode,
+			PickupSlotID:     pickupSlotID,
+		})
+	}
+	for _, a := range req.Addons {
+		addonID, err := uuid.Parse(a.AddonID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid add-on ID"})
+			return
+		}
+		orderReq.Addons = append(orderReq.Addons, services.OrderAddonRequest{AddonID: addonID, Quantity: a.Quantity})
+	}
+
+	order, checkoutURL, err := h.ticketService.CreateOrderWithProvider(userID.(uuid.UUID), orderReq)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticketIDs := make([]uuid.UUID, len(order.Tickets))
+	for i, t := range order.Tickets {
+		ticketIDs[i] = t.ID
+	}
+
+	// checkout_url is empty when discounts cover the whole amount (tickets are already paid)
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":         order.ID,
+		"ticket_id":        order.Tickets[0].ID,
+		"ticket_ids":       ticketIDs,
+		"status":           order.Tickets[0].Status,
+		"checkout_url":     checkoutURL,
+		"payment_provider": orderReq.PaymentProvider,
+		"hold_expires_at":  order.Tickets[0].HoldExpiresAt,
+		"total_amount":     order.TotalAmount,
+		"items":            order.Items,
+	})
+}
+
+// GetUserOrders lists the orders of the current user
+// GET /user/orders
+func (h *OrderHandler) GetUserOrders(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	orders, err := h.orderService.GetUserOrders(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		return
+	}
+
+	list := make([]gin.H, len(orders))
+	for i, o := range orders {
+		list[i] = orderJSON(o)
+	}
+	c.JSON(http.StatusOK, gin.H{"orders": list})
+}
+
+// GetUserOrder returns an order of the current user
+// GET /user/orders/:id
+func (h *OrderHandler) GetUserOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetUserOrder(orderID, userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, orderJSON(order))
+}
+
+// GetOrder returns an order with its buyer
+// GET /admin/orders/:id
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := orderJSON(order)
+	resp["user"] = gin.H{
+		"id":       order.User.ID,
+		"name":     order.User.Name,
+		"username": order.User.Username,
+		"email":    order.User.Email,
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RefundOrderItem fully refunds one item of an order (ticket, pickup or add-on)
+// POST /admin/orders/:id/items/:itemId/refund
+func (h *OrderHandler) RefundOrderItem(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order item ID"})
+		return
+	}
+
+	actorID := adminID.(uuid.UUID)
+	item, err := h.orderService.RefundItem(orderID, itemID, &actorID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "order not found" || err.Error() == "order item not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order item refunded successfully", "item": item})
+}
@@ -2,30 +2,42 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
 	"github.com/synesthesie/backend/internal/services"
 )
 
 type PayPalHandler struct {
-	ticketService *services.TicketService
-	emailService  *services.EmailService
-	cfg           *config.Config
+	ticketService  *services.TicketService
+	emailService   *services.EmailService
+	cfg            *config.Config
+	webhookService *services.WebhookService
+	// verifier checks webhook signatures; nil while PayPal is not enabled
+	verifier services.PayPalWebhookVerifier
+	// Optional: completes ticket transfers paid through a charge order
+	TransferService *services.TransferService
 }
 
-func NewPayPalHandler(ticketService *services.TicketService, emailService *services.EmailService, cfg *config.Config) *PayPalHandler {
-	return &PayPalHandler{
-		ticketService: ticketService,
-		emailService:  emailService,
-		cfg:           cfg,
+func NewPayPalHandler(ticketService *services.TicketService, emailService *services.EmailService, cfg *config.Config, webhookService *services.WebhookService) *PayPalHandler {
+	h := &PayPalHandler{
+		ticketService:  ticketService,
+		emailService:   emailService,
+		cfg:            cfg,
+		webhookService: webhookService,
+		verifier:       ticketService.PayPalWebhookVerifier(),
 	}
+	webhookService.RegisterProcessor("paypal", h.processEvent)
+	return h
 }
 
 // PayPalWebhookEvent represents a PayPal webhook event
@@ -34,18 +46,35 @@ type PayPalWebhookEvent struct {
 	EventType  string `json:"event_type"`
 	CreateTime string `json:"create_time"`
 	Resource   struct {
-		ID          string `json:"id"`
-		OrderID     string `json:"order_id"`
-		Status      string `json:"status"`
-		Amount      struct {
-			Currency string `json:"currency_code"`
-			Value    string `json:"value"`
-		} `json:"amount"`
-		CustomID string `json:"custom_id"` // This is our ticket_id
+		ID                        string                `json:"id"`
+		OrderID                   string                `json:"order_id"`
+		Status                    string                `json:"status"`
+		Amount                    services.PayPalAmount `json:"amount"`
+		CustomID                  string                `json:"custom_id"` // This is our ticket_id
+		SellerReceivableBreakdown struct {
+			PayPalFee services.PayPalAmount `json:"paypal_fee"`
+		} `json:"seller_receivable_breakdown"`
+		// Refund resources link to the refunded capture with rel "up"
+		Links []struct {
+			Href string `json:"href"`
+			Rel  string `json:"rel"`
+		} `json:"links"`
 	} `json:"resource"`
 }
 
-// HandleWebhook processes PayPal webhook events
+// upCaptureID returns the capture a refund or reversal resource belongs to
+func (e *PayPalWebhookEvent) upCaptureID() string {
+	for _, l := range e.Resource.Links {
+		if l.Rel == "up" && strings.Contains(l.Href, "/captures/") {
+			return l.Href[strings.LastIndex(l.Href, "/")+1:]
+		}
+	}
+	return ""
+}
+
+// HandleWebhook stores PayPal webhook events and processes them.
+// Redeliveries of an event that was already processed are acknowledged without processing it again.
+// POST /paypal/webhook
 func (h *PayPalHandler) HandleWebhook(c *gin.Context) {
 	// Read the request body
 	body, err := io.ReadAll(c.Request.Body)
@@ -65,41 +94,84 @@ func (h *PayPalHandler) HandleWebhook(c *gin.Context) {
 
 	log.Printf("PayPal webhook received: event_type=%s, id=%s", event.EventType, event.ID)
 
+	// Verify the transmission signature against PAYPAL_WEBHOOK_ID
+	verifyErr := errors.New("PayPal is not enabled")
+	if h.verifier != nil {
+		verifyErr = h.verifier.VerifyWebhookSignature(c.Request.Header, body)
+	}
+	if verifyErr != nil {
+		log.Printf("PayPal webhook: signature verification failed for %s: %v", event.ID, verifyErr)
+		h.webhookService.Reject("paypal", event.ID, event.EventType, body, verifyErr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	_, duplicate, err := h.webhookService.Receive("paypal", event.ID, event.EventType, body, models.WebhookVerificationVerified)
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Duplicate event"})
+		return
+	}
+	if err != nil {
+		// PayPal retries non-2xx responses; the event is stored as failed until then
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Webhook received",
+	})
+}
+
+// processEvent handles a stored PayPal event; it also runs for admin replays
+func (h *PayPalHandler) processEvent(body []byte) error {
+	var event PayPalWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
 	// Handle different event types
 	switch event.EventType {
 	case "PAYMENT.CAPTURE.COMPLETED":
-		h.handlePaymentCaptureCompleted(event)
+		return h.handlePaymentCaptureCompleted(event)
 	case "CHECKOUT.ORDER.APPROVED":
 		// Order approved, but not yet captured - we can ignore this
 		log.Printf("PayPal order approved: %s", event.Resource.ID)
 	case "PAYMENT.CAPTURE.DENIED":
-		log.Printf("PayPal payment denied: %s", event.Resource.ID)
+		return h.handlePaymentCaptureDenied(event)
 	case "PAYMENT.CAPTURE.REFUNDED":
-		log.Printf("PayPal payment refunded: %s", event.Resource.ID)
+		return h.handleCaptureRefunded(event, models.RefundReasonProviderRefund)
+	case "PAYMENT.CAPTURE.REVERSED":
+		return h.handleCaptureRefunded(event, models.RefundReasonPaymentReversed)
 	default:
 		log.Printf("PayPal webhook: unhandled event type: %s", event.EventType)
 	}
-
-	// Always return 200 OK to acknowledge receipt
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"message": "Webhook received",
-	})
+	return nil
 }
 
-// handlePaymentCaptureCompleted handles successful PayPal payment capture
-func (h *PayPalHandler) handlePaymentCaptureCompleted(event PayPalWebhookEvent) {
+// handlePaymentCaptureCompleted handles successful PayPal payment capture.
+// Errors leave the event failed for a retry or replay; a capture for a deleted ticket is alerted instead.
+func (h *PayPalHandler) handlePaymentCaptureCompleted(event PayPalWebhookEvent) error {
 	// Extract ticket ID from custom_id or order_id
 	ticketIDStr := event.Resource.CustomID
 	if ticketIDStr == "" {
 		log.Printf("PayPal webhook: no custom_id found in event")
-		return
+		return nil
+	}
+
+	// Extra charges (e.g. transfer price differences) carry a charge reference instead of a ticket ID
+	if strings.HasPrefix(ticketIDStr, "transfer:") {
+		if h.TransferService != nil {
+			if err := h.TransferService.HandleChargePaid(ticketIDStr, event.Resource.ID); err != nil {
+				return fmt.Errorf("failed to process charge %s: %w", ticketIDStr, err)
+			}
+		}
+		return nil
 	}
 
 	ticketID, err := uuid.Parse(ticketIDStr)
 	if err != nil {
-		log.Printf("PayPal webhook: invalid ticket ID: %s", ticketIDStr)
-		return
+		return fmt.Errorf("invalid ticket ID: %s", ticketIDStr)
 	}
 
 	// Get the ticket
@@ -110,7 +182,6 @@ func (h *PayPalHandler) handlePaymentCaptureCompleted(event PayPalWebhookEvent)
 		log.Printf("⚠️ PayPal webhook: CRITICAL - Payment received for deleted ticket %s (capture: %s)", ticketID, event.Resource.ID)
 		log.Printf("⚠️ PayPal webhook: User paid but ticket was cancelled. Manual refund required!")
 
-		// TODO: Send alert email to admin
 		if h.emailService != nil {
 			subject := "🚨 KRITISCH: PayPal-Zahlung ohne Ticket"
 			body := fmt.Sprintf(`
@@ -133,13 +204,18 @@ Dies passiert wenn User während der Zahlung das Ticket abbricht.
 
 			_ = h.emailService.SendGenericTextEmail(h.cfg.AdminAlertEmail, subject, body)
 		}
-		return
+		return nil
 	}
 
-	// Check if already processed
+	// Check if already processed (polling may have been faster; the ledger still gets the fee and
+	// the buyer the confirmation, unless it went out already)
 	if ticket.Status == "paid" {
 		log.Printf("PayPal webhook: ticket already paid: %s", ticketID)
-		return
+		if err := h.bookCapture(ticketID, event); err != nil {
+			return err
+		}
+		sendTicketConfirmations(h.cfg, h.ticketService, h.emailService, ticketID)
+		return nil
 	}
 
 	// Check if ticket was cancelled or is pending cancellation
@@ -150,27 +226,27 @@ Dies passiert wenn User während der Zahlung das Ticket abbricht.
 		// Reactivate the ticket (user paid, so they should get the ticket)
 		// This is the GRACE PERIOD in action - payment completed before final cancellation!
 		updates := map[string]interface{}{
-			"status":             "paid",
-			"cancelled_at":       nil,
-			"paypal_capture_id":  event.Resource.ID,
+			"cancelled_at":      nil,
+			"paypal_capture_id": event.Resource.ID,
 		}
+		change := models.StatusChange{Actor: models.StatusActorProvider, Reason: "paypal_webhook_grace_period", ProviderRef: event.Resource.ID}
 
-		if err := h.ticketService.UpdateTicket(ticketID, updates); err != nil {
-			log.Printf("PayPal webhook: failed to reactivate ticket: %v", err)
-			return
+		if err := h.ticketService.MarkCheckoutPaid(ticketID, change, updates); err != nil {
+			return fmt.Errorf("failed to reactivate ticket: %w", err)
 		}
 
 		log.Printf("✅ PayPal webhook: Ticket %s reactivated and marked as paid (was: %s)", ticketID, ticket.Status)
-		return
+		if err := h.bookCapture(ticketID, event); err != nil {
+			return err
+		}
+		sendTicketConfirmations(h.cfg, h.ticketService, h.emailService, ticketID)
+		return nil
 	}
 
 	// Update ticket status (normal flow)
-	ticket.Status = "paid"
-	ticket.PayPalCaptureID = event.Resource.ID // Save capture ID for refunds
-
-	if err := h.ticketService.UpdateTicketStatus(ticketID, "paid"); err != nil {
-		log.Printf("PayPal webhook: failed to update ticket status: %v", err)
-		return
+	change := models.StatusChange{Actor: models.StatusActorProvider, Reason: "paypal_webhook", ProviderRef: event.Resource.ID}
+	if err := h.ticketService.MarkCheckoutPaid(ticketID, change, nil); err != nil {
+		return fmt.Errorf("failed to update ticket status: %w", err)
 	}
 
 	// Update PayPal capture ID separately
@@ -179,11 +255,73 @@ Dies passiert wenn User während der Zahlung das Ticket abbricht.
 	}
 
 	log.Printf("PayPal webhook: ticket %s marked as paid (capture: %s)", ticketID, event.Resource.ID)
+	if err := h.bookCapture(ticketID, event); err != nil {
+		return err
+	}
+
+	// Send confirmation email (one per ticket of the order, as for Stripe payments)
+	sendTicketConfirmations(h.cfg, h.ticketService, h.emailService, ticketID)
+	return nil
+}
+
+// handlePaymentCaptureDenied cancels the tickets of a checkout whose capture PayPal denied
+// (a capture that was pending, e.g. for an eCheck) and frees their seats
+func (h *PayPalHandler) handlePaymentCaptureDenied(event PayPalWebhookEvent) error {
+	ticketIDStr := event.Resource.CustomID
+	if ticketIDStr == "" {
+		log.Printf("PayPal webhook: no custom_id found in denied capture %s", event.Resource.ID)
+		return nil
+	}
+	if strings.HasPrefix(ticketIDStr, "transfer:") {
+		// The charge is only applied once paid; nothing to undo
+		log.Printf("PayPal webhook: charge %s denied (capture: %s)", ticketIDStr, event.Resource.ID)
+		return nil
+	}
+	ticketID, err := uuid.Parse(ticketIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid ticket ID: %s", ticketIDStr)
+	}
+
+	cancelled, err := h.ticketService.CancelDeniedPayment(ticketID, "paypal", event.Resource.ID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel tickets of denied capture %s: %w", event.Resource.ID, err)
+	}
+	log.Printf("PayPal webhook: capture %s denied, %d tickets of checkout %s cancelled", event.Resource.ID, cancelled, ticketID)
+	return nil
+}
+
+// handleCaptureRefunded books a refund or reversal of a capture. Refunds we requested ourselves are
+// recognised by their refund ID; others (PayPal dashboard, reversals) update tickets and refund amounts.
+func (h *PayPalHandler) handleCaptureRefunded(event PayPalWebhookEvent, reason string) error {
+	amount, err := event.Resource.Amount.Money()
+	if err != nil {
+		return fmt.Errorf("invalid refund amount %q: %w", event.Resource.Amount.Value, err)
+	}
+	if amount < 0 {
+		amount = -amount
+	}
 
-	// Send confirmation email
-	if h.emailService != nil {
-		// TODO: Send ticket confirmation email
-		log.Printf("PayPal webhook: TODO - send confirmation email for ticket %s", ticketID)
+	// The capture ID stored on the tickets wins (older tickets may carry the order ID instead)
+	captureID := event.upCaptureID()
+	if ticketID, err := uuid.Parse(event.Resource.CustomID); err == nil {
+		if ticket, err := h.ticketService.GetTicketByID(ticketID); err == nil && ticket.PayPalCaptureID != "" {
+			captureID = ticket.PayPalCaptureID
+		}
 	}
+	if captureID == "" {
+		return fmt.Errorf("refund %s does not reference a capture", event.Resource.ID)
+	}
+
+	log.Printf("PayPal webhook: capture %s refunded %s %s (%s, refund: %s)", captureID, amount, event.Resource.Amount.Currency, reason, event.Resource.ID)
+	return h.ticketService.ApplyProviderRefund("paypal", captureID, event.Resource.ID, amount, reason)
 }
 
+// bookCapture adds a completed capture and its PayPal fee to the payment ledger
+func (h *PayPalHandler) bookCapture(ticketID uuid.UUID, event PayPalWebhookEvent) error {
+	fee, _ := event.Resource.SellerReceivableBreakdown.PayPalFee.Money()
+	if err := h.ticketService.RecordPayPalCapture(ticketID, event.Resource.ID, fee); err != nil {
+		log.Printf("PayPal webhook: CRITICAL - failed to book capture %s in ledger: %v", event.Resource.ID, err)
+		return fmt.Errorf("failed to book capture %s in ledger: %w", event.Resource.ID, err)
+	}
+	return nil
+}
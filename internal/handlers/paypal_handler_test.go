@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+	"github.com/synesthesie/backend/internal/testutil"
+)
+
+// IDs the PayPal fixtures refer to; they are replaced by the booked ticket and a fresh capture ID
+const (
+	fixtureTicketID  = "3f2b7c1e-9a4d-4e7b-8c21-5d6f0a9b1c2d"
+	fixtureCaptureID = "7NW873794T343360M"
+)
+
+const stubSignature = "stub-signature"
+
+// stubVerifier stands in for PayPal's verify-webhook-signature API
+type stubVerifier struct {
+	calls int
+}
+
+func (v *stubVerifier) VerifyWebhookSignature(header http.Header, body []byte) error {
+	v.calls++
+	if header.Get("PAYPAL-TRANSMISSION-SIG") != stubSignature {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// loadPayPalFixture reads a recorded webhook and points it at the given ticket and capture.
+// Event and resource IDs get the suffix, so the fixtures can be delivered again in a later test run.
+func loadPayPalFixture(t *testing.T, name string, ticketID uuid.UUID, captureID, suffix string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "paypal", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event PayPalWebhookEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return []byte(strings.NewReplacer(
+		fixtureCaptureID, captureID,
+		fixtureTicketID, ticketID.String(),
+		event.ID, event.ID+"-"+suffix,
+		event.Resource.ID, event.Resource.ID+suffix,
+	).Replace(string(raw)))
+}
+
+// deliverPayPalWebhook posts a signed webhook to the handler and returns the response message
+func deliverPayPalWebhook(t *testing.T, router *gin.Engine, body []byte) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/paypal/webhook", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("PAYPAL-TRANSMISSION-SIG", stubSignature)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("webhook answered %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Message
+}
+
+func TestPayPalWebhookFixtures(t *testing.T) {
+	db := testutil.OpenDB(t)
+	gin.SetMode(gin.TestMode)
+	cfg := testutil.Config()
+	ticketService := services.NewTicketService(db, cfg)
+	webhookService := services.NewWebhookService(db)
+	h := NewPayPalHandler(ticketService, nil, cfg, webhookService)
+	verifier := &stubVerifier{}
+	h.verifier = verifier
+	router := gin.New()
+	router.POST("/paypal/webhook", h.HandleWebhook)
+
+	// The fixtures are a 45 EUR capture: completed, refunded in two parts, or denied
+	event := testutil.CreateEvent(t, db, 10, 4500)
+	book := func() *models.Ticket {
+		buyer := testutil.CreateUser(t, db, "guests")
+		order, _, err := ticketService.CreateOrderWithProvider(buyer.ID, &services.OrderRequest{
+			EventID:         event.ID,
+			Tickets:         []services.OrderTicketRequest{{}},
+			PaymentProvider: "paypal",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &order.Tickets[0]
+	}
+	captured, denied := book(), book()
+	captureID := strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", "")[:17])
+	suffix := uuid.NewString()[:8]
+
+	cases := []struct {
+		fixture string
+		ticket  *models.Ticket
+		status  models.TicketStatus
+	}{
+		{"payment_capture_completed.json", captured, models.TicketPaid},
+		{"payment_capture_refunded.json", captured, models.TicketPaid},
+		{"payment_capture_reversed.json", captured, models.TicketRefunded},
+		{"payment_capture_denied.json", denied, models.TicketCancelled},
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "paypal", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]bool{}
+	for _, c := range cases {
+		covered[c.fixture] = true
+	}
+	for _, f := range files {
+		if !covered[filepath.Base(f)] {
+			t.Errorf("fixture %s is not covered", filepath.Base(f))
+		}
+	}
+
+	for _, c := range cases {
+		body := loadPayPalFixture(t, c.fixture, c.ticket.ID, captureID, suffix)
+		var sent PayPalWebhookEvent
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Fatal(err)
+		}
+
+		calls := verifier.calls
+		if msg := deliverPayPalWebhook(t, router, body); msg != "Webhook received" {
+			t.Fatalf("%s: response %q, want it processed", c.fixture, msg)
+		}
+		if verifier.calls != calls+1 {
+			t.Errorf("%s: signature was not verified", c.fixture)
+		}
+		got, err := ticketService.GetTicketByID(c.ticket.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != c.status {
+			t.Fatalf("%s: ticket %s, want %s", c.fixture, got.Status, c.status)
+		}
+
+		// PayPal redelivers events; a second delivery is acknowledged without processing it again
+		if msg := deliverPayPalWebhook(t, router, body); msg != "Duplicate event" {
+			t.Errorf("%s: second delivery answered %q, want a duplicate", c.fixture, msg)
+		}
+		var stored models.WebhookEvent
+		if err := db.Where("provider = ? AND event_id = ?", "paypal", sent.ID).First(&stored).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != models.WebhookStatusProcessed || stored.Duplicates != 1 || stored.Attempts != 1 {
+			t.Errorf("%s: stored event %s with %d duplicates and %d attempts, want processed once with 1 duplicate",
+				c.fixture, stored.Status, stored.Duplicates, stored.Attempts)
+		}
+		if got, _ := ticketService.GetTicketByID(c.ticket.ID); got.Status != c.status {
+			t.Errorf("%s: ticket %s after the duplicate, want %s", c.fixture, got.Status, c.status)
+		}
+	}
+
+	var refunded models.Ticket
+	if err := db.First(&refunded, "id = ?", captured.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if refunded.RefundedAmount != 4500 {
+		t.Errorf("refunded %s, want 45.00", refunded.RefundedAmount)
+	}
+}
@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type PickupHandler struct {
+	pickupService *services.PickupService
+}
+
+func NewPickupHandler(pickupService *services.PickupService) *PickupHandler {
+	return &PickupHandler{
+		pickupService: pickupService,
+	}
+}
+
+// GetPickupOptions returns the pickup zones and bookable time slots of an event;
+// with postal_code the price and time slots of its zone
+// GET /user/events/:id/pickup-options?postal_code=
+func (h *PickupHandler) GetPickupOptions(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	opts, err := h.pickupService.GetOptions(eventID, c.Query("postal_code"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, opts)
+}
+
+// pickupZoneRequest is the admin payload of a pickup zone
+type pickupZoneRequest struct {
+	Name        string       `json:"name" binding:"required"`
+	PostalCodes string       `json:"postal_codes" binding:"required"` // comma-separated postal codes or prefixes
+	Price       models.Money `json:"price" binding:"min=0"`
+	SortOrder   int          `json:"sort_order"`
+	IsActive    *bool        `json:"is_active"` // default true
+}
+
+func (r *pickupZoneRequest) toModel() *models.PickupZone {
+	z := &models.PickupZone{
+		Name:        r.Name,
+		PostalCodes: r.PostalCodes,
+		Price:       r.Price,
+		SortOrder:   r.SortOrder,
+		IsActive:    true,
+	}
+	if r.IsActive != nil {
+		z.IsActive = *r.IsActive
+	}
+	return z
+}
+
+// GetPickupZones lists all pickup zones
+// GET /admin/pickup-zones
+func (h *PickupHandler) GetPickupZones(c *gin.Context) {
+	zones, err := h.pickupService.GetZones(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pickup zones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"zones": zones})
+}
+
+// CreatePickupZone adds a pickup zone
+// POST /admin/pickup-zones
+func (h *PickupHandler) CreatePickupZone(c *gin.Context) {
+	var req pickupZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	z := req.toModel()
+	if err := h.pickupService.CreateZone(z); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, z)
+}
+
+// UpdatePickupZone replaces a pickup zone definition
+// PUT /admin/pickup-zones/:zoneId
+func (h *PickupHandler) UpdatePickupZone(c *gin.Context) {
+	zoneID, err := uuid.Parse(c.Param("zoneId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zone ID"})
+		return
+	}
+
+	var req pickupZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.pickupService.UpdateZone(zoneID, req.toModel()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pickup zone updated successfully"})
+}
+
+// DeletePickupZone removes a pickup zone that was never booked
+// DELETE /admin/pickup-zones/:zoneId
+func (h *PickupHandler) DeletePickupZone(c *gin.Context) {
+	zoneID, err := uuid.Parse(c.Param("zoneId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zone ID"})
+		return
+	}
+
+	if err := h.pickupService.DeleteZone(zoneID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pickup zone deleted successfully"})
+}
+
+// pickupSlotRequest is the admin payload of a pickup time slot
+type pickupSlotRequest struct {
+	ZoneID      *uuid.UUID `json:"zone_id"` // empty = serves every zone
+	Name        string     `json:"name"`
+	StartsAt    time.Time  `json:"starts_at" binding:"required"`
+	EndsAt      time.Time  `json:"ends_at" binding:"required"`
+	Capacity    int        `json:"capacity" binding:"required,min=1"` // seats in the vehicle
+	DriverID    *uuid.UUID `json:"driver_id"`                         // driver account; fills empty driver name and phone
+	DriverName  string     `json:"driver_name"`
+	DriverPhone string     `json:"driver_phone"`
+	IsActive    *bool      `json:"is_active"` // default true
+}
+
+func (r *pickupSlotRequest) toModel() *models.PickupSlot {
+	s := &models.PickupSlot{
+		ZoneID:      r.ZoneID,
+		Name:        r.Name,
+		StartsAt:    r.StartsAt,
+		EndsAt:      r.EndsAt,
+		Capacity:    r.Capacity,
+		DriverID:    r.DriverID,
+		DriverName:  r.DriverName,
+		DriverPhone: r.DriverPhone,
+		IsActive:    true,
+	}
+	if r.IsActive != nil {
+		s.IsActive = *r.IsActive
+	}
+	return s
+}
+
+// GetPickupSlots lists the pickup time slots of an event with their booked and free seats
+// GET /admin/events/:id/pickup-slots
+func (h *PickupHandler) GetPickupSlots(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	slots, err := h.pickupService.GetSlots(eventID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pickup slots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slots": slots})
+}
+
+// CreatePickupSlot adds a pickup time slot to an event
+// POST /admin/events/:id/pickup-slots
+func (h *PickupHandler) CreatePickupSlot(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req pickupSlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s := req.toModel()
+	if err := h.pickupService.CreateSlot(eventID, s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+// UpdatePickupSlot replaces a pickup time slot definition
+// PUT /admin/events/:id/pickup-slots/:slotId
+func (h *PickupHandler) UpdatePickupSlot(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	slotID, err := uuid.Parse(c.Param("slotId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pickup slot ID"})
+		return
+	}
+
+	var req pickupSlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.pickupService.UpdateSlot(eventID, slotID, req.toModel()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pickup slot updated successfully"})
+}
+
+// DeletePickupSlot removes a pickup time slot that was never booked
+// DELETE /admin/events/:id/pickup-slots/:slotId
+func (h *PickupHandler) DeletePickupSlot(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	slotID, err := uuid.Parse(c.Param("slotId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pickup slot ID"})
+		return
+	}
+
+	if err := h.pickupService.DeleteSlot(eventID, slotID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pickup slot deleted successfully"})
+}
+
+// GetPickupManifest returns the pickups of an event grouped by time slot and zone, as JSON or CSV (format=csv).
+// status: "paid" (default) | "all" (includes pending)
+// GET /admin/events/:id/pickups/manifest
+func (h *PickupHandler) GetPickupManifest(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	manifest, err := h.pickupService.Manifest(eventID, strings.TrimSpace(c.DefaultQuery("status", "paid")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, manifest)
+		return
+	}
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		loc = time.UTC
+	}
+	buf := &bytes.Buffer{}
+	// Prepend UTF-8 BOM for better Excel compatibility (äöüß etc.)
+	_, _ = buf.Write([]byte{0xEF, 0xBB, 0xBF})
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"Zeitfenster", "Tour", "Fahrer", "Zone", "Name", "Mobil", "Adresse", "PLZ", "Status", "Abholung"})
+	for _, ms := range manifest.Slots {
+		var window, tour string
+		if ms.Slot != nil {
+			window = ms.Slot.StartsAt.In(loc).Format("02.01.2006 15:04") + "-" + ms.Slot.EndsAt.In(loc).Format("15:04")
+			tour = ms.Slot.Name
+		}
+		for _, zone := range ms.Zones {
+			for _, stop := range zone.Stops {
+				_ = w.Write([]string{window, tour, stop.DriverName, zone.Name, stop.Name, stop.Mobile, stop.Address, stop.PostalCode,
+					string(stop.Status), stop.PickupStatus})
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("ERROR: Failed to generate CSV: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate csv"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=pickups.csv")
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// DownloadPickupManifestPDF renders the printable pickup lists of an event, one per driver;
+// with driver=<name> only that driver's list
+// GET /admin/events/:id/pickups/manifest.pdf
+func (h *PickupHandler) DownloadPickupManifestPDF(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	driver := strings.TrimSpace(c.Query("driver"))
+	pdf, err := h.pickupService.ManifestPDF(eventID, driver)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := "abholliste.pdf"
+	if driver != "" {
+		filename = fmt.Sprintf("abholliste_%s.pdf", strings.ReplaceAll(driver, " ", "_"))
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// GetDrivers lists the active users with the driver role
+// GET /admin/drivers
+func (h *PickupHandler) GetDrivers(c *gin.Context) {
+	drivers, err := h.pickupService.GetDrivers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drivers"})
+		return
+	}
+
+	list := make([]gin.H, len(drivers))
+	for i, d := range drivers {
+		list[i] = gin.H{
+			"id":     d.ID,
+			"name":   d.Name,
+			"email":  d.Email,
+			"mobile": d.Mobile,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"drivers": list})
+}
+
+// AssignPickups assigns pickups of an event to a driver; without driver_id they go back to the driver of their time slot
+// POST /admin/events/:id/pickups/assign
+func (h *PickupHandler) AssignPickups(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req struct {
+		TicketIDs []uuid.UUID `json:"ticket_ids" binding:"required,min=1"`
+		DriverID  *uuid.UUID  `json:"driver_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+	actorID := adminID.(uuid.UUID)
+	if err := h.pickupService.AssignPickups(eventID, req.TicketIDs, req.DriverID, &actorID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pickups assigned successfully", "count": len(req.TicketIDs)})
+}
+
+// GetPickupProgress counts the paid pickups of an event by driver and pickup status
+// GET /admin/events/:id/pickups/progress
+func (h *PickupHandler) GetPickupProgress(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	progress, err := h.pickupService.Progress(eventID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+// GetDriverEvents lists the upcoming events the current driver has pickups for
+// GET /driver/events
+func (h *PickupHandler) GetDriverEvents(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	events, err := h.pickupService.DriverEvents(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// GetDriverPickups returns the paid pickups of the current driver for an event, by time slot and zone
+// GET /driver/events/:id/pickups
+func (h *PickupHandler) GetDriverPickups(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	manifest, err := h.pickupService.DriverManifest(userID.(uuid.UUID), eventID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, manifest)
+}
+
+// UpdatePickupStatus sets the progress of a pickup (open, en_route, picked_up, no_show);
+// en_route sends the rider an SMS the first time
+// PUT /driver/pickups/:ticketId/status
+func (h *PickupHandler) UpdatePickupStatus(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	isAdmin, _ := c.Get("isAdmin")
+	ticketID, err := uuid.Parse(c.Param("ticketId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	admin, _ := isAdmin.(bool)
+	ticket, err := h.pickupService.UpdatePickupStatus(ticketID, userID.(uuid.UUID), admin, strings.TrimSpace(req.Status))
+	if err != nil {
+		status := http.StatusBadRequest
+		switch err.Error() {
+		case "pickup not found":
+			status = http.StatusNotFound
+		case "pickup is not assigned to you":
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticket_id":        ticket.ID,
+		"pickup_status":    ticket.PickupStatus,
+		"pickup_status_at": ticket.PickupStatusAt,
+	})
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type PromoHandler struct {
+	promoService *services.PromoCodeService
+}
+
+func NewPromoHandler(promoService *services.PromoCodeService) *PromoHandler {
+	return &PromoHandler{
+		promoService: promoService,
+	}
+}
+
+// promoCodeRequest is the admin payload of a promo code
+type promoCodeRequest struct {
+	Code         string `json:"code" binding:"required"`
+	Description  string `json:"description"`
+	DiscountType string `json:"discount_type" binding:"required"` // percent, fixed
+	// DiscountValue is a percentage (12.5) or an amount in EUR, depending on DiscountType
+	DiscountValue  json.RawMessage `json:"discount_value" binding:"required"`
+	MaxUses        int             `json:"max_uses"`          // 0 = unlimited
+	MaxUsesPerUser int             `json:"max_uses_per_user"` // 0 = unlimited
+	ValidFrom      *time.Time      `json:"valid_from"`
+	ValidUntil     *time.Time      `json:"valid_until"`
+	EventIDs       []string        `json:"event_ids"`      // empty = all events
+	AllowedGroups  []string        `json:"allowed_groups"` // empty = all groups
+	IsActive       *bool           `json:"is_active"`      // default true
+}
+
+func (r *promoCodeRequest) toModel() (*models.PromoCode, error) {
+	eventIDs := make([]string, 0, len(r.EventIDs))
+	for _, s := range r.EventIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, errors.New("Invalid event ID in event_ids")
+		}
+		eventIDs = append(eventIDs, id.String())
+	}
+	p := &models.PromoCode{
+		Code:           r.Code,
+		Description:    r.Description,
+		DiscountType:   r.DiscountType,
+		MaxUses:        r.MaxUses,
+		MaxUsesPerUser: r.MaxUsesPerUser,
+		ValidFrom:      r.ValidFrom,
+		ValidUntil:     r.ValidUntil,
+		EventIDs:       strings.Join(eventIDs, ","),
+		AllowedGroups:  strings.Join(r.AllowedGroups, ","),
+		IsActive:       true,
+	}
+	if r.IsActive != nil {
+		p.IsActive = *r.IsActive
+	}
+	// An unknown discount type is rejected by the service
+	switch r.DiscountType {
+	case models.PromoDiscountPercent:
+		if err := json.Unmarshal(r.DiscountValue, &p.DiscountPercent); err != nil {
+			return nil, err
+		}
+	case models.PromoDiscountFixed:
+		if err := json.Unmarshal(r.DiscountValue, &p.DiscountAmount); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// discountValue is the percentage or the amount of a code, as the API writes it in discount_value
+func discountValue(p *models.PromoCode) interface{} {
+	if p.DiscountType == models.PromoDiscountPercent {
+		return p.DiscountPercent
+	}
+	return p.DiscountAmount
+}
+
+func (h *PromoHandler) promoCodeJSON(p *models.PromoCode) gin.H {
+	eventIDs := p.Events()
+	if eventIDs == nil {
+		eventIDs = []uuid.UUID{}
+	}
+	groups := p.Groups()
+	if groups == nil {
+		groups = []string{}
+	}
+	return gin.H{
+		"id":                p.ID,
+		"code":              p.Code,
+		"description":       p.Description,
+		"discount_type":     p.DiscountType,
+		"discount_value":    discountValue(p),
+		"max_uses":          p.MaxUses,
+		"max_uses_per_user": p.MaxUsesPerUser,
+		"valid_from":        p.ValidFrom,
+		"valid_until":       p.ValidUntil,
+		"event_ids":         eventIDs,
+		"allowed_groups":    groups,
+		"is_active":         p.IsActive,
+		"valid_now":         p.ValidAt(time.Now()),
+		"used":              h.promoService.UsageCount(p),
+		"created_at":        p.CreatedAt,
+	}
+}
+
+// GetPromoCodes lists all promo codes with their usage
+// GET /admin/promo-codes
+func (h *PromoHandler) GetPromoCodes(c *gin.Context) {
+	codes, err := h.promoService.GetPromoCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve promo codes"})
+		return
+	}
+
+	list := make([]gin.H, len(codes))
+	for i, p := range codes {
+		list[i] = h.promoCodeJSON(p)
+	}
+	c.JSON(http.StatusOK, gin.H{"promo_codes": list})
+}
+
+// CreatePromoCode creates a promo code
+// POST /admin/promo-codes
+func (h *PromoHandler) CreatePromoCode(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	var req promoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := req.toModel()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	createdBy := adminID.(uuid.UUID)
+	p.CreatedBy = &createdBy
+
+	if err := h.promoService.CreatePromoCode(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.promoCodeJSON(p))
+}
+
+// UpdatePromoCode replaces a promo code definition
+// PUT /admin/promo-codes/:id
+func (h *PromoHandler) UpdatePromoCode(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code ID"})
+		return
+	}
+
+	var req promoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := req.toModel()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.promoService.UpdatePromoCode(id, p); err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "promo code not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promo code updated successfully"})
+}
+
+// DeletePromoCode removes a promo code that was never used
+// DELETE /admin/promo-codes/:id
+func (h *PromoHandler) DeletePromoCode(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo code ID"})
+		return
+	}
+
+	if err := h.promoService.DeletePromoCode(id); err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "promo code not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promo code deleted successfully"})
+}
+
+// CheckPromoCode shows the discount a code gives on a booking before checkout
+// POST /user/promo-codes/check
+// Body: {"code": "SOLI", "event_id": "<uuid>", "ticket_type_id": "<uuid>" (optional)}
+func (h *PromoHandler) CheckPromoCode(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Code         string `json:"code" binding:"required"`
+		EventID      string `json:"event_id" binding:"required"`
+		TicketTypeID string `json:"ticket_type_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	eventID, err := uuid.Parse(req.EventID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	ticketTypeID := uuid.Nil
+	if req.TicketTypeID != "" {
+		if ticketTypeID, err = uuid.Parse(req.TicketTypeID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+			return
+		}
+	}
+
+	promo, ticketType, discount, err := h.promoService.Preview(userID.(uuid.UUID), eventID, ticketTypeID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":            true,
+		"code":             promo.Code,
+		"description":      promo.Description,
+		"ticket_type_id":   ticketType.ID,
+		"ticket_type":      ticketType.Name,
+		"price":            ticketType.Price,
+		"discount_amount":  discount,
+		"discounted_price": ticketType.Price - discount,
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *services.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService *services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciliationService: reconciliationService,
+	}
+}
+
+// GetRuns lists reconciliation reports (without their mismatches)
+// GET /admin/reconciliation
+func (h *ReconciliationHandler) GetRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	runs, total, err := h.reconciliationService.GetRuns(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reconciliation runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs": runs,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetRun returns one reconciliation report with its mismatches
+// GET /admin/reconciliation/:id
+func (h *ReconciliationHandler) GetRun(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	run, err := h.reconciliationService.GetRun(id)
+	if err != nil {
+		if errors.Is(err, services.ErrReconciliationRunNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation run not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reconciliation run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// StartRun reconciles the tickets created between from and to (YYYY-MM-DD, Europe/Berlin, both inclusive)
+// and returns the report; from defaults to 30 days before to, to defaults to now
+// POST /admin/reconciliation
+func (h *ReconciliationHandler) StartRun(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	var req struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		AutoFix   bool   `json:"auto_fix"`
+		SendEmail bool   `json:"send_email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		loc = time.UTC
+	}
+	to := time.Now()
+	if req.To != "" {
+		day, err := time.ParseInLocation("2006-01-02", req.To, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date (YYYY-MM-DD)"})
+			return
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -30)
+	if req.From != "" {
+		from, err = time.ParseInLocation("2006-01-02", req.From, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date (YYYY-MM-DD)"})
+			return
+		}
+	}
+
+	triggeredBy := adminID.(uuid.UUID)
+	run, err := h.reconciliationService.Run(from, to, req.AutoFix, "admin", &triggeredBy)
+	if run == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Reconciliation failed", "run": run})
+		return
+	}
+
+	if req.SendEmail {
+		if err := h.reconciliationService.SendReport(run); err != nil {
+			log.Printf("Reconciliation %s: failed to email report: %v", run.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type RefundHandler struct {
+	refundService *services.RefundService
+}
+
+func NewRefundHandler(refundService *services.RefundService) *RefundHandler {
+	return &RefundHandler{
+		refundService: refundService,
+	}
+}
+
+// GetRefunds lists refund attempts with optional filters
+// GET /admin/refunds?status=failed&ticket_id=...&order_id=...
+func (h *RefundHandler) GetRefunds(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	status := strings.TrimSpace(c.Query("status")) // optional: pending|succeeded|failed
+
+	var ticketID, orderID *uuid.UUID
+	if s := c.Query("ticket_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+			return
+		}
+		ticketID = &id
+	}
+	if s := c.Query("order_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		orderID = &id
+	}
+
+	refunds, total, err := h.refundService.GetRefunds(page, limit, status, ticketID, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refunds": refunds,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// RetryRefund sends a failed refund to the payment provider again
+// POST /admin/refunds/:id/retry
+func (h *RefundHandler) RetryRefund(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	refundID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+		return
+	}
+
+	actorID := adminID.(uuid.UUID)
+	refund, err := h.refundService.Retry(refundID, &actorID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "refund not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The new attempt may have failed again; its status and error tell
+	c.JSON(http.StatusOK, gin.H{"refund": refund})
+}
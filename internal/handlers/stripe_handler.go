@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,25 +14,33 @@ import (
 	"github.com/stripe/stripe-go/v82"
 	"github.com/stripe/stripe-go/v82/webhook"
 	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
 	"github.com/synesthesie/backend/internal/services"
-	jwtpkg "github.com/synesthesie/backend/pkg/jwt"
 )
 
 type StripeHandler struct {
-	ticketService *services.TicketService
-	cfg           *config.Config
-	emailService  *services.EmailService
+	ticketService  *services.TicketService
+	cfg            *config.Config
+	emailService   *services.EmailService
+	webhookService *services.WebhookService
+	// Optional: completes ticket transfers paid through a charge checkout
+	TransferService *services.TransferService
 }
 
-func NewStripeHandler(ticketService *services.TicketService, cfg *config.Config, emailService *services.EmailService) *StripeHandler {
-	return &StripeHandler{
-		ticketService: ticketService,
-		cfg:           cfg,
-		emailService:  emailService,
+func NewStripeHandler(ticketService *services.TicketService, cfg *config.Config, emailService *services.EmailService, webhookService *services.WebhookService) *StripeHandler {
+	h := &StripeHandler{
+		ticketService:  ticketService,
+		cfg:            cfg,
+		emailService:   emailService,
+		webhookService: webhookService,
 	}
+	webhookService.RegisterProcessor("stripe", h.processEvent)
+	return h
 }
 
-// HandleWebhook handles Stripe webhook events
+// HandleWebhook verifies and stores Stripe webhook events, then processes them.
+// Redeliveries of an event that was already processed are acknowledged without processing it again.
+// POST /stripe/webhook
 func (h *StripeHandler) HandleWebhook(c *gin.Context) {
 	const MaxBodyBytes = int64(65536)
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxBodyBytes)
@@ -49,36 +59,71 @@ func (h *StripeHandler) HandleWebhook(c *gin.Context) {
 	event, err := webhook.ConstructEvent(payload, signatureHeader, h.cfg.StripeWebhookSecret)
 	if err != nil {
 		log.Printf("ERROR: Webhook signature verification failed: %v", err)
+		// Keep the unverified body for inspection; ID and type are taken from it as-is
+		var unverified struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(payload, &unverified)
+		h.webhookService.Reject("stripe", unverified.ID, unverified.Type, payload, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature"})
 		return
 	}
 
 	log.Printf("INFO: Received Stripe event type: %s, ID: %s", event.Type, event.ID)
 
+	_, duplicate, err := h.webhookService.Receive("stripe", event.ID, string(event.Type), payload, models.WebhookVerificationVerified)
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Duplicate event"})
+		return
+	}
+	if err != nil {
+		// Stripe retries non-2xx responses; the event is stored as failed until then
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Event processed"})
+}
+
+// processEvent handles a verified Stripe event; it also runs for admin replays of stored events
+func (h *StripeHandler) processEvent(payload []byte) error {
+	var event stripe.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("error parsing webhook JSON: %w", err)
+	}
+
 	// Handle the event
 	switch event.Type {
 	case "checkout.session.completed":
 		var session stripe.CheckoutSession
 		err := json.Unmarshal(event.Data.Raw, &session)
 		if err != nil {
-			log.Printf("ERROR: Failed to parse webhook JSON for checkout.session.completed: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error parsing webhook JSON"})
-			return
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
+		}
+
+		// Extra charges (e.g. transfer price differences) carry charge_ref instead of ticket_id
+		if chargeRef, ok := session.Metadata["charge_ref"]; ok {
+			paymentIntentID := ""
+			if session.PaymentIntent != nil {
+				paymentIntentID = session.PaymentIntent.ID
+			}
+			if h.TransferService != nil {
+				if err := h.TransferService.HandleChargePaid(chargeRef, paymentIntentID); err != nil {
+					return fmt.Errorf("failed to process charge %s: %w", chargeRef, err)
+				}
+			}
+			return nil
 		}
 
 		// Get ticket ID from metadata
 		ticketIDStr, ok := session.Metadata["ticket_id"]
 		if !ok {
-			log.Printf("ERROR: ticket_id not found in metadata for session %s", session.ID)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Ticket ID not found in metadata"})
-			return
+			return fmt.Errorf("ticket_id not found in metadata for session %s", session.ID)
 		}
 
 		ticketID, err := uuid.Parse(ticketIDStr)
 		if err != nil {
-			log.Printf("ERROR: Invalid ticket_id format in metadata: %s", ticketIDStr)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
-			return
+			return fmt.Errorf("invalid ticket_id format in metadata: %s", ticketIDStr)
 		}
 
 		paymentIntentID := ""
@@ -90,103 +135,53 @@ func (h *StripeHandler) HandleWebhook(c *gin.Context) {
 
 		// Confirm payment
 		if err := h.ticketService.ConfirmPayment(ticketID, paymentIntentID); err != nil {
-			log.Printf("ERROR: Failed to confirm payment for ticket %s: %v", ticketID, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm payment"})
-			return
-		}
-
-		// Send confirmation email
-		if h.emailService != nil {
-			ticket, tErr := h.ticketService.GetTicketByID(ticketID)
-			if tErr != nil {
-				log.Printf("WARN: Payment confirmed but failed to load ticket %s: %v", ticketID, tErr)
-			} else {
-				// Format date/time in Europe/Berlin
-				loc, _ := time.LoadLocation("Europe/Berlin")
-				eventDate := ticket.Event.DateFrom.In(loc).Format("02.01.2006")
-				eventTime := ticket.Event.TimeFrom
-
-				// Generate ICS link for calendar add using PUBLIC_URL
-				base := h.cfg.PublicURL
-				if base == "" {
-					if h.cfg.Env == "production" {
-						base = "https://api.synesthesie.de"
-					} else {
-						base = "https://api-dev.synesthesie.de"
-					}
-				}
-				// sign short-lived calendar token (e.g., 24h)
-				token, _ := jwtpkg.GenerateCalendarToken(ticket.Event.ID.String(), h.cfg.JWTSecret, 24*time.Hour)
-				icsURL := base + "/api/v1/public/events/ics?token=" + token
-
-				data := map[string]interface{}{
-					"UserName":       ticket.User.Name,
-					"EventName":      ticket.Event.Name,
-					"TicketID":       ticket.ID,
-					"EventDate":      eventDate,
-					"EventTime":      eventTime,
-					"IncludesPickup": ticket.IncludesPickup,
-					"PickupAddress":  ticket.PickupAddress,
-					"EventPrice":     ticket.Price,
-					"PickupPrice":    ticket.PickupPrice,
-					"TotalAmount":    ticket.TotalAmount,
-					"ICSLink":        icsURL,
-				}
-				if err := h.emailService.SendTicketConfirmation(ticket.User.Email, data); err != nil {
-					log.Printf("WARN: Failed to send ticket confirmation email for ticket %s: %v", ticketID, err)
-				}
-			}
+			return fmt.Errorf("failed to confirm payment for ticket %s: %w", ticketID, err)
 		}
 
+		// Send confirmation email (one per ticket of the order, each with its own QR code)
+		sendTicketConfirmations(h.cfg, h.ticketService, h.emailService, ticketID)
+
 		log.Printf("SUCCESS: Payment confirmed for TicketID: %s", ticketID)
-		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Payment confirmed"})
-		return
+		return nil
 
 	case "checkout.session.expired":
 		var session stripe.CheckoutSession
 		if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
-			log.Printf("ERROR: Failed to parse webhook JSON for checkout.session.expired: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error parsing webhook JSON"})
-			return
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
+		}
+		// Expired charge checkouts need no cleanup; the charge can simply be retried
+		if _, ok := session.Metadata["charge_ref"]; ok {
+			return nil
 		}
 		// Lookup by metadata
 		ticketIDStr, ok := session.Metadata["ticket_id"]
 		if !ok {
-			log.Printf("ERROR: ticket_id not found in metadata for expired session %s", session.ID)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Ticket ID not found in metadata"})
-			return
+			return fmt.Errorf("ticket_id not found in metadata for expired session %s", session.ID)
 		}
 		ticketID, err := uuid.Parse(ticketIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
-			return
+			return fmt.Errorf("invalid ticket_id format in metadata: %s", ticketIDStr)
 		}
 		_ = h.ticketService.CancelPendingBySystem(ticketID, "session_expired")
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
-		return
+		return nil
 
 	case "checkout.session.async_payment_failed":
 		var session stripe.CheckoutSession
 		if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
-			log.Printf("ERROR: Failed to parse webhook JSON for checkout.session.async_payment_failed: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error parsing webhook JSON"})
-			return
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
 		}
 		if ticketIDStr, ok := session.Metadata["ticket_id"]; ok {
 			if ticketID, err := uuid.Parse(ticketIDStr); err == nil {
 				_ = h.ticketService.CancelPendingBySystem(ticketID, "async_payment_failed")
 			}
 		}
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
-		return
+		return nil
 
 	case "payment_intent.succeeded":
 		var paymentIntent stripe.PaymentIntent
 		err := json.Unmarshal(event.Data.Raw, &paymentIntent)
 		if err != nil {
-			log.Printf("ERROR: Failed to parse webhook JSON for payment_intent.succeeded: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error parsing webhook JSON"})
-			return
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
 		}
 		log.Printf("INFO: Received payment_intent.succeeded for %s", paymentIntent.ID)
 		// Usually handled by checkout.session.completed, but good for logging.
@@ -195,9 +190,7 @@ func (h *StripeHandler) HandleWebhook(c *gin.Context) {
 		var paymentIntent stripe.PaymentIntent
 		err := json.Unmarshal(event.Data.Raw, &paymentIntent)
 		if err != nil {
-			log.Printf("ERROR: Failed to parse webhook JSON for payment_intent.payment_failed: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Error parsing webhook JSON"})
-			return
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
 		}
 		var reason string
 		if paymentIntent.LastPaymentError != nil {
@@ -206,8 +199,198 @@ func (h *StripeHandler) HandleWebhook(c *gin.Context) {
 		log.Printf("WARN: Payment failed for PaymentIntent %s. Reason: %s", paymentIntent.ID, reason)
 		// Optionally: Update ticket status to 'failed'
 
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
+		}
+		return h.handleChargeRefunded(&charge)
+
+	case "charge.refund.updated", "refund.updated":
+		// A refund that was still pending when the charge was reported refunded
+		var refund stripe.Refund
+		if err := json.Unmarshal(event.Data.Raw, &refund); err != nil {
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
+		}
+		if refund.PaymentIntent == nil {
+			return nil
+		}
+		return h.applyRefunds(refund.PaymentIntent.ID, []services.ProviderRefund{{
+			ID:     refund.ID,
+			Amount: models.Money(refund.Amount),
+			Status: string(refund.Status),
+		}})
+
+	case "charge.dispute.created", "charge.dispute.updated":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
+		}
+		return h.handleDisputeOpened(&dispute)
+
+	case "charge.dispute.closed":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return fmt.Errorf("error parsing webhook JSON: %w", err)
+		}
+		return h.handleDisputeClosed(&dispute)
+
 	default:
 		log.Printf("INFO: Unhandled Stripe event type: %s", event.Type)
-		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Unhandled event type"})
 	}
+	return nil
+}
+
+// handleChargeRefunded books refunds made outside our API, e.g. in the Stripe dashboard.
+// Refunds we sent ourselves are recognised by their refund ID and skipped.
+func (h *StripeHandler) handleChargeRefunded(charge *stripe.Charge) error {
+	if charge.PaymentIntent == nil || charge.PaymentIntent.ID == "" {
+		log.Printf("INFO: charge.refunded for charge %s without payment intent ignored", charge.ID)
+		return nil
+	}
+
+	var refunds []services.ProviderRefund
+	if charge.Refunds != nil && len(charge.Refunds.Data) > 0 {
+		for _, r := range charge.Refunds.Data {
+			refunds = append(refunds, services.ProviderRefund{
+				ID:     r.ID,
+				Amount: models.Money(r.Amount),
+				Status: string(r.Status),
+			})
+		}
+	} else {
+		// Newer API versions no longer include the refunds in the charge
+		lister := h.ticketService.ChargeRefundLister()
+		if lister == nil {
+			return fmt.Errorf("charge %s carries no refunds", charge.ID)
+		}
+		var err error
+		if refunds, err = lister.ChargeRefunds(charge.ID); err != nil {
+			return err
+		}
+	}
+	return h.applyRefunds(charge.PaymentIntent.ID, refunds)
+}
+
+// applyRefunds books the succeeded refunds of a payment intent; pending ones follow with a refund update
+func (h *StripeHandler) applyRefunds(paymentIntentID string, refunds []services.ProviderRefund) error {
+	for _, r := range refunds {
+		if r.Status != string(stripe.RefundStatusSucceeded) {
+			continue
+		}
+		if err := h.ticketService.ApplyProviderRefund("stripe", paymentIntentID, r.ID, r.Amount, models.RefundReasonProviderRefund); err != nil {
+			return fmt.Errorf("failed to book refund %s of %s: %w", r.ID, paymentIntentID, err)
+		}
+	}
+	return nil
+}
+
+// disputeInput converts a Stripe dispute
+func disputeInput(d *stripe.Dispute) services.DisputeInput {
+	in := services.DisputeInput{
+		Provider:          "stripe",
+		ProviderDisputeID: d.ID,
+		Amount:            models.Money(d.Amount),
+		Currency:          string(d.Currency),
+		Reason:            string(d.Reason),
+		Status:            string(d.Status),
+	}
+	if d.PaymentIntent != nil {
+		in.PaymentRef = d.PaymentIntent.ID
+	}
+	if d.EvidenceDetails != nil && d.EvidenceDetails.DueBy > 0 {
+		dueBy := time.Unix(d.EvidenceDetails.DueBy, 0)
+		in.EvidenceDueBy = &dueBy
+	}
+	return in
+}
+
+// handleDisputeOpened records a chargeback; its tickets are disputed (refused at check-in) until it closes
+func (h *StripeHandler) handleDisputeOpened(d *stripe.Dispute) error {
+	dispute, created, err := h.ticketService.OpenDispute(disputeInput(d))
+	if err != nil {
+		return fmt.Errorf("failed to record dispute %s: %w", d.ID, err)
+	}
+	if !created {
+		return nil
+	}
+
+	dueBy := "-"
+	if dispute.EvidenceDueBy != nil {
+		dueBy = dispute.EvidenceDueBy.Format("02.01.2006 15:04")
+	}
+	h.sendDisputeAlert("⚠️ Chargeback eröffnet: "+dispute.ProviderDisputeID, fmt.Sprintf(`
+Gegen eine Stripe-Zahlung wurde ein Chargeback (Dispute) eröffnet.
+
+Dispute-ID: %s
+Payment Intent: %s
+Betrag: %s %s
+Grund: %s
+Status: %s
+Nachweise einreichen bis: %s
+
+Betroffene Tickets (bis zum Abschluss gesperrt, Check-in nicht möglich):
+%s
+
+AKTION ERFORDERLICH:
+Nachweise im Stripe Dashboard einreichen, sonst geht der Betrag verloren.
+`, dispute.ProviderDisputeID, dispute.PaymentRef, dispute.Amount, strings.ToUpper(dispute.Currency), dispute.Reason, dispute.Status, dueBy, disputeTicketList(dispute)))
+	return nil
+}
+
+// handleDisputeClosed stores the outcome of a chargeback: a lost dispute is booked as a refund,
+// otherwise the tickets are valid again
+func (h *StripeHandler) handleDisputeClosed(d *stripe.Dispute) error {
+	// Records the dispute in case the created event never arrived
+	if _, _, err := h.ticketService.OpenDispute(disputeInput(d)); err != nil {
+		return fmt.Errorf("failed to record dispute %s: %w", d.ID, err)
+	}
+	dispute, closed, err := h.ticketService.CloseDispute(d.ID, string(d.Status))
+	if err != nil {
+		return fmt.Errorf("failed to close dispute %s: %w", d.ID, err)
+	}
+	if !closed {
+		return nil
+	}
+
+	result := "Gewonnen: Die Tickets sind wieder gültig."
+	if dispute.Outcome == models.DisputeOutcomeLost {
+		result = "Verloren: Der Betrag wurde als Erstattung (chargeback) gebucht, voll erstattete Tickets sind storniert."
+	}
+	h.sendDisputeAlert("Chargeback abgeschlossen: "+dispute.ProviderDisputeID, fmt.Sprintf(`
+Ein Chargeback (Dispute) wurde abgeschlossen.
+
+Dispute-ID: %s
+Payment Intent: %s
+Betrag: %s %s
+Status: %s
+
+%s
+
+Tickets:
+%s
+`, dispute.ProviderDisputeID, dispute.PaymentRef, dispute.Amount, strings.ToUpper(dispute.Currency), dispute.Status, result, disputeTicketList(dispute)))
+	return nil
+}
+
+// sendDisputeAlert notifies the admin alert address
+func (h *StripeHandler) sendDisputeAlert(subject, body string) {
+	if h.emailService == nil || h.cfg.AdminAlertEmail == "" {
+		return
+	}
+	if err := h.emailService.SendGenericTextEmail(h.cfg.AdminAlertEmail, subject, body); err != nil {
+		log.Printf("WARN: Failed to send dispute alert (%s): %v", subject, err)
+	}
+}
+
+// disputeTicketList lists the tickets of a dispute for the alert email
+func disputeTicketList(dispute *models.Dispute) string {
+	if len(dispute.Tickets) == 0 {
+		return "- keine Tickets zu dieser Zahlung gefunden"
+	}
+	var b strings.Builder
+	for _, t := range dispute.Tickets {
+		fmt.Fprintf(&b, "- %s (%s, %s) – %s, Status: %s\n", t.ID, t.AttendeeName(), t.User.Email, t.Event.Name, t.Status)
+	}
+	return b.String()
 }
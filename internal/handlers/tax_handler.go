@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type TaxHandler struct {
+	taxService *services.TaxService
+}
+
+func NewTaxHandler(taxService *services.TaxService) *TaxHandler {
+	return &TaxHandler{
+		taxService: taxService,
+	}
+}
+
+// GetTaxRates returns the default VAT rates per product type
+// GET /admin/tax/rates
+func (h *TaxHandler) GetTaxRates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rates": h.taxService.DefaultRates()})
+}
+
+// GetVATReport sums the VAT of a month per rate, as JSON or CSV (format=csv)
+// GET /admin/tax/vat-report?month=YYYY-MM
+func (h *TaxHandler) GetVATReport(c *gin.Context) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		loc = time.UTC
+	}
+	month := time.Now().In(loc)
+	if s := c.Query("month"); s != "" {
+		month, err = time.ParseInLocation("2006-01", s, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month (YYYY-MM)"})
+			return
+		}
+	}
+
+	report, err := h.taxService.MonthlyReport(month)
+	if err != nil {
+		log.Printf("ERROR: Failed to create VAT report for %s: %v", month.Format("2006-01"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create VAT report"})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	// Prepend UTF-8 BOM for better Excel compatibility
+	_, _ = buf.Write([]byte{0xEF, 0xBB, 0xBF})
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"sep=,"})
+	_ = w.Write([]string{"Monat", "USt-Satz", "Umsatz brutto", "Umsatz netto", "USt Umsatz",
+		"Erstattungen brutto", "Erstattungen netto", "USt Erstattungen", "Brutto", "Netto", "USt"})
+	row := func(rate string, a services.VATAmounts) {
+		_ = w.Write([]string{report.Month, rate,
+			a.SalesGross.String(), a.SalesNet.String(), a.SalesTax.String(),
+			a.RefundsGross.String(), a.RefundsNet.String(), a.RefundsTax.String(),
+			a.Gross.String(), a.Net.String(), a.Tax.String()})
+	}
+	for _, r := range report.Rates {
+		row(strconv.Itoa(r.Rate)+" %", r.VATAmounts)
+	}
+	row("Summe", report.Total)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("ERROR: Failed to generate CSV: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate csv"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"USt_%s.csv\"", report.Month))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
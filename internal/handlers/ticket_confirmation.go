@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/services"
+	jwtpkg "github.com/synesthesie/backend/pkg/jwt"
+)
+
+// sendTicketConfirmations emails the confirmation (with QR code and invoice) for every paid ticket of
+// the order a ticket belongs to. Each ticket is confirmed once, whichever payment notification comes first.
+func sendTicketConfirmations(cfg *config.Config, ticketService *services.TicketService, emailService *services.EmailService, ticketID uuid.UUID) {
+	if emailService == nil {
+		return
+	}
+	tickets, tErr := ticketService.GetOrderTickets(ticketID)
+	if tErr != nil {
+		log.Printf("WARN: Payment confirmed but failed to load ticket %s: %v", ticketID, tErr)
+	}
+	for _, ticket := range tickets {
+		if ticket.Status != "paid" {
+			continue
+		}
+		claimed, cErr := ticketService.ClaimConfirmationEmail(ticket.ID)
+		if cErr != nil {
+			log.Printf("WARN: Failed to claim confirmation email for ticket %s: %v", ticket.ID, cErr)
+			continue
+		}
+		if !claimed {
+			continue // already confirmed
+		}
+
+		// Format date/time in Europe/Berlin
+		loc, _ := time.LoadLocation("Europe/Berlin")
+		eventDate := ticket.Event.DateFrom.In(loc).Format("02.01.2006")
+		eventTime := ticket.Event.TimeFrom
+
+		// Generate ICS link for calendar add using PUBLIC_URL
+		base := cfg.PublicURL
+		if base == "" {
+			if cfg.Env == "production" {
+				base = "https://api.synesthesie.de"
+			} else {
+				base = "https://api-dev.synesthesie.de"
+			}
+		}
+		// sign short-lived calendar token (e.g., 24h)
+		token, _ := jwtpkg.GenerateCalendarToken(ticket.Event.ID.String(), cfg.JWTSecret, 24*time.Hour)
+		icsURL := base + "/api/v1/public/events/ics?token=" + token
+
+		data := map[string]interface{}{
+			"UserName":       ticket.User.Name,
+			"HolderName":     ticket.HolderName,
+			"EventName":      ticket.Event.Name,
+			"TicketID":       ticket.ID,
+			"EventDate":      eventDate,
+			"EventTime":      eventTime,
+			"IncludesPickup": ticket.IncludesPickup,
+			"PickupAddress":  ticket.PickupAddress,
+			"EventPrice":     ticket.Price,
+			"TicketTypeName": ticket.TicketTypeName,
+			"PromoCode":      ticket.PromoCode,
+			"DiscountAmount": ticket.DiscountAmount,
+			"PickupPrice":    ticket.PickupPrice,
+			"TotalAmount":    ticket.TotalAmount,
+			"ICSLink":        icsURL,
+		}
+		// Embed signed check-in QR code
+		qrService, qErr := services.NewQRService(cfg)
+		if qErr == nil {
+			var qrPNG []byte
+			if qrPNG, qErr = qrService.GenerateTicketQRPNG(ticket); qErr == nil {
+				data["TicketQRPNG"] = qrPNG
+			}
+		}
+		if qErr != nil {
+			log.Printf("WARN: Failed to generate ticket QR for %s: %v", ticket.ID, qErr)
+		}
+		// Attach the invoice; the buyer can still download it later if issuing fails now
+		if invoice, iErr := ticketService.InvoiceService().IssueInvoice(ticket.ID); iErr == nil {
+			data["InvoicePDF"] = invoice.PDF
+			data["InvoiceNumber"] = invoice.Number
+		} else {
+			log.Printf("WARN: Failed to issue invoice for ticket %s: %v", ticket.ID, iErr)
+		}
+		if err := emailService.SendTicketConfirmation(ticket.User.Email, data); err != nil {
+			log.Printf("WARN: Failed to send ticket confirmation email for ticket %s: %v", ticket.ID, err)
+			// Let the next notification or a replay try again
+			if rErr := ticketService.ReleaseConfirmationEmail(ticket.ID); rErr != nil {
+				log.Printf("WARN: Failed to release confirmation email for ticket %s: %v", ticket.ID, rErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type TransferHandler struct {
+	transferService *services.TransferService
+	ticketService   *services.TicketService
+}
+
+func NewTransferHandler(transferService *services.TransferService, ticketService *services.TicketService) *TransferHandler {
+	return &TransferHandler{
+		transferService: transferService,
+		ticketService:   ticketService,
+	}
+}
+
+func transferJSON(t *models.TicketTransfer, userID uuid.UUID) gin.H {
+	direction := "outgoing"
+	if t.ToUserID == userID {
+		direction = "incoming"
+	}
+	return gin.H{
+		"id":               t.ID,
+		"ticket_id":        t.TicketID,
+		"event_id":         t.Ticket.EventID,
+		"event_name":       t.Ticket.Event.Name,
+		"event_date":       t.Ticket.Event.DateFrom,
+		"direction":        direction,
+		"from_user":        gin.H{"id": t.FromUserID, "name": t.FromUser.Name, "username": t.FromUser.Username},
+		"to_user":          gin.H{"id": t.ToUserID, "name": t.ToUser.Name, "username": t.ToUser.Username},
+		"status":           t.Status,
+		"old_price":        t.OldPrice,
+		"new_price":        t.NewPrice,
+		"price_difference": t.PriceDifference,
+		"responded_at":     t.RespondedAt,
+		"completed_at":     t.CompletedAt,
+		"created_at":       t.CreatedAt,
+	}
+}
+
+// InitiateTransfer starts a transfer of a paid ticket to another member
+// POST /user/tickets/:id/transfer
+// Body: {"recipient": "<username or email>"}
+func (h *TransferHandler) InitiateTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	var req struct {
+		Recipient string `json:"recipient" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := h.transferService.Initiate(ticketID, userID.(uuid.UUID), req.Recipient)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "ticket not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":               transfer.ID,
+		"ticket_id":        transfer.TicketID,
+		"to_user_id":       transfer.ToUserID,
+		"status":           transfer.Status,
+		"price_difference": transfer.PriceDifference,
+		"created_at":       transfer.CreatedAt,
+	})
+}
+
+// GetUserTransfers lists incoming and outgoing transfers of the current user
+// GET /user/transfers
+func (h *TransferHandler) GetUserTransfers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	uid := userID.(uuid.UUID)
+
+	transfers, err := h.transferService.GetUserTransfers(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transfers"})
+		return
+	}
+
+	list := make([]gin.H, len(transfers))
+	for i, t := range transfers {
+		list[i] = transferJSON(t, uid)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transfers": list})
+}
+
+// AcceptTransfer accepts an incoming transfer. If the recipient pays more, a checkout URL is returned.
+// POST /user/transfers/:id/accept
+// Body: {"payment_provider": "stripe" | "paypal"} (only used when a price difference is due)
+func (h *TransferHandler) AcceptTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	transferID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	var req struct {
+		PaymentProvider string `json:"payment_provider"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	transfer, checkoutURL, err := h.transferService.Accept(transferID, userID.(uuid.UUID), req.PaymentProvider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"id":               transfer.ID,
+		"ticket_id":        transfer.TicketID,
+		"status":           transfer.Status,
+		"price_difference": transfer.PriceDifference,
+	}
+	if checkoutURL != "" {
+		resp["checkout_url"] = checkoutURL
+		resp["payment_provider"] = transfer.ChargeProvider
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmTransferPayment completes a transfer after the price difference was paid
+// POST /user/transfers/:id/confirm-payment
+func (h *TransferHandler) ConfirmTransferPayment(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	transferID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	transfer, err := h.transferService.ConfirmPayment(transferID, userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":        transfer.ID,
+		"ticket_id": transfer.TicketID,
+		"status":    transfer.Status,
+	})
+}
+
+// DeclineTransfer rejects an incoming transfer
+// POST /user/transfers/:id/decline
+func (h *TransferHandler) DeclineTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	transferID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	if err := h.transferService.Decline(transferID, userID.(uuid.UUID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer declined"})
+}
+
+// CancelTransfer withdraws an outgoing transfer
+// DELETE /user/transfers/:id
+func (h *TransferHandler) CancelTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	transferID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	if err := h.transferService.Cancel(transferID, userID.(uuid.UUID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer cancelled"})
+}
+
+// GetTicketHistory returns the history of a ticket (admin)
+// GET /admin/tickets/:id/history
+func (h *TransferHandler) GetTicketHistory(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	entries, err := h.ticketService.GetTicketHistory(ticketID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ticket history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": entries})
+}
+
+// GetTicketStatusHistory returns the status changes of a ticket with actor, reason and provider reference (admin)
+// GET /admin/tickets/:id/status-history
+func (h *TransferHandler) GetTicketStatusHistory(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	entries, err := h.ticketService.GetStatusHistory(ticketID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve status history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": entries})
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"bytes"
-	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -147,10 +146,10 @@ func (h *UserHandler) GetUserEvents(c *gin.Context) {
 		return
 	}
 
-	// Create a map of event IDs to tickets
+	// Create a map of event IDs to the user's own tickets (not those bought for companions)
 	ticketMap := make(map[uuid.UUID]*models.Ticket)
 	for _, ticket := range userTickets {
-		if ticket.Status == "paid" || ticket.Status == "pending" {
+		if ticket.HolderName == "" && (ticket.Status == "paid" || ticket.Status == "pending") {
 			ticketMap[ticket.EventID] = ticket
 		}
 	}
@@ -169,13 +168,46 @@ func (h *UserHandler) GetUserEvents(c *gin.Context) {
 			continue
 		}
 
-		availableSpots := event.GetAvailableSpots(h.eventService.GetDB())
-		price := event.GuestsPrice
-		if user.Group == "bubble" {
-			price = event.BubblePrice
+		db := h.eventService.GetDB()
+		availableSpots := event.GetAvailableSpots(db)
+
+		// Ticket types for the user's group; "price" is the type booked by default
+		var price interface{}
+		ticketTypes := []gin.H{}
+		types, _ := h.eventService.GetTicketTypes(event.ID)
+		now := time.Now()
+		for _, t := range types {
+			if !t.IsActive || !t.AllowsGroup(user.Group) {
+				continue
+			}
+			remaining := t.Remaining(db)
+			onSale := t.OnSale(now) && remaining != 0
+			if onSale && price == nil {
+				price = t.Price
+			}
+			ticketTypes = append(ticketTypes, gin.H{
+				"id":          t.ID,
+				"name":        t.Name,
+				"description": t.Description,
+				"price":       t.Price,
+				"sales_start": t.SalesStart,
+				"sales_end":   t.SalesEnd,
+				"on_sale":     onSale,
+				"remaining":   remaining,
+			})
 		}
-		if user.Group == "plus" {
-			price = event.PlusPrice
+
+		// Add-ons that can be ordered with the tickets
+		addons := []gin.H{}
+		activeAddons, _ := h.eventService.GetAddons(event.ID, true)
+		for _, a := range activeAddons {
+			addons = append(addons, gin.H{
+				"id":            a.ID,
+				"name":          a.Name,
+				"description":   a.Description,
+				"price":         a.Price,
+				"max_per_order": a.MaxPerOrder,
+			})
 		}
 
 		item := gin.H{
@@ -187,10 +219,13 @@ func (h *UserHandler) GetUserEvents(c *gin.Context) {
 			"time_from":        event.TimeFrom,
 			"time_to":          event.TimeTo,
 			"price":            price,
+			"ticket_types":     ticketTypes,
+			"addons":           addons,
 			"max_participants": event.MaxParticipants,
 			"available_spots":  availableSpots,
 			"has_ticket":       false,
 		}
+		item["cancellation_policy"], _ = h.ticketService.CancellationPolicy(event)
 
 		if ticket, exists := ticketMap[event.ID]; exists {
 			item["has_ticket"] = true
@@ -238,13 +273,21 @@ func (h *UserHandler) GetUserTickets(c *gin.Context) {
 	for i, ticket := range tickets {
 		ticketList[i] = gin.H{
 			"id":              ticket.ID,
+			"order_id":        ticket.OrderID,
+			"holder_name":     ticket.HolderName,
 			"status":          ticket.Status,
 			"price":           ticket.Price,
+			"ticket_type":     ticket.TicketTypeName,
+			"promo_code":      ticket.PromoCode,
+			"discount_amount": ticket.DiscountAmount,
 			"includes_pickup": ticket.IncludesPickup,
 			"pickup_price":    ticket.PickupPrice,
 			"pickup_address":  ticket.PickupAddress,
+			"pickup_zone":     ticket.PickupZoneName,
+			"pickup_slot_id":  ticket.PickupSlotID,
 			"total_amount":    ticket.TotalAmount,
 			"refunded_amount": ticket.RefundedAmount,
+			"hold_expires_at": ticket.HoldExpiresAt,
 			"created_at":      ticket.CreatedAt,
 			"cancelled_at":    ticket.CancelledAt,
 			"refunded_at":     ticket.RefundedAt,
@@ -267,10 +310,14 @@ func (h *UserHandler) BookTicket(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var req struct {
-		EventID         string `json:"event_id" binding:"required"`
-		IncludesPickup  bool   `json:"includes_pickup"`
-		PickupAddress   string `json:"pickup_address"`
-		PaymentProvider string `json:"payment_provider"` // "stripe" or "paypal" (optional, defaults to stripe)
+		EventID          string `json:"event_id" binding:"required"`
+		TicketTypeID     string `json:"ticket_type_id"` // optional: defaults to the first type available to the user
+		PromoCode        string `json:"promo_code"`     // optional
+		IncludesPickup   bool   `json:"includes_pickup"`
+		PickupAddress    string `json:"pickup_address"`
+		PickupPostalCode string `json:"pickup_postal_code"` // selects the pickup zone
+		PickupSlotID     string `json:"pickup_slot_id"`     // required if the event has pickup time slots
+		PaymentProvider  string `json:"payment_provider"`   // "stripe" or "paypal" (optional, defaults to stripe)
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -291,6 +338,24 @@ func (h *UserHandler) BookTicket(c *gin.Context) {
 		return
 	}
 
+	ticketReq := services.OrderTicketRequest{
+		IncludesPickup:   req.IncludesPickup,
+		PickupAddress:    req.PickupAddress,
+		PickupPostalCode: req.PickupPostalCode,
+	}
+	if req.TicketTypeID != "" {
+		if ticketReq.TicketTypeID, err = uuid.Parse(req.TicketTypeID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+			return
+		}
+	}
+	if req.IncludesPickup && req.PickupSlotID != "" {
+		if ticketReq.PickupSlotID, err = uuid.Parse(req.PickupSlotID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pickup slot ID"})
+			return
+		}
+	}
+
 	// Validate and set default payment provider
 	paymentProvider := req.PaymentProvider
 	if paymentProvider == "" {
@@ -305,8 +370,8 @@ func (h *UserHandler) BookTicket(c *gin.Context) {
 	ticket, checkoutURL, err := h.ticketService.CreateTicketWithProvider(
 		userID.(uuid.UUID),
 		eventID,
-		req.IncludesPickup,
-		req.PickupAddress,
+		ticketReq,
+		req.PromoCode,
 		paymentProvider,
 	)
 	if err != nil {
@@ -314,10 +379,15 @@ func (h *UserHandler) BookTicket(c *gin.Context) {
 		return
 	}
 
+	// checkout_url is empty when the discount covers the whole amount (ticket is already paid)
 	c.JSON(http.StatusOK, gin.H{
 		"ticket_id":        ticket.ID,
+		"status":           ticket.Status,
 		"checkout_url":     checkoutURL,
 		"payment_provider": paymentProvider,
+		"hold_expires_at":  ticket.HoldExpiresAt,
+		"discount_amount":  ticket.DiscountAmount,
+		"total_amount":     ticket.TotalAmount,
 	})
 }
 
@@ -339,7 +409,7 @@ func (h *UserHandler) sendCancellationEmail(ticketID uuid.UUID, cancelledBy stri
 
 	// Calculate refund details
 	refund := ticket.RefundedAmount
-	full := refund > 0 && refund+1e-9 >= ticket.TotalAmount
+	full := refund > 0 && refund >= ticket.TotalAmount
 	partial := refund > 0 && !full
 
 	data := map[string]interface{}{
@@ -348,7 +418,7 @@ func (h *UserHandler) sendCancellationEmail(ticketID uuid.UUID, cancelledBy stri
 		"EventDate":        eventDate,
 		"TicketID":         ticket.ID,
 		"CancellationDate": cancelDate,
-		"RefundAmount":     fmt.Sprintf("%.2f", refund),
+		"RefundAmount":     refund.String(),
 		"FullRefund":       full,
 		"PartialRefund":    partial,
 	}
@@ -393,6 +463,54 @@ func (h *UserHandler) CancelTicket(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Ticket cancelled successfully"})
 }
 
+// GetCancellationQuote shows what cancelling the ticket now would refund under the event's cancellation policy
+// GET /user/tickets/:id/cancellation
+func (h *UserHandler) GetCancellationQuote(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	quote, err := h.ticketService.QuoteCancellation(ticketID, userID.(uuid.UUID))
+	if err != nil {
+		if err.Error() == "ticket not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate refund"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// GetTicketStatusHistory lists how the status of the user's ticket changed over time
+// GET /user/tickets/:id/status-history
+func (h *UserHandler) GetTicketStatusHistory(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	entries, err := h.ticketService.GetUserStatusHistory(ticketID, userID.(uuid.UUID))
+	if err != nil {
+		if err.Error() == "ticket not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve status history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": entries})
+}
+
 // CancelTicketRefund cancels with explicit refund mode (if eligible)
 func (h *UserHandler) CancelTicketRefund(c *gin.Context) {
 	userID, _ := c.Get("userID")
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type WaitlistHandler struct {
+	waitlistService *services.WaitlistService
+	ticketService   *services.TicketService
+}
+
+func NewWaitlistHandler(waitlistService *services.WaitlistService, ticketService *services.TicketService) *WaitlistHandler {
+	return &WaitlistHandler{
+		waitlistService: waitlistService,
+		ticketService:   ticketService,
+	}
+}
+
+// JoinWaitlist puts the current user on the waitlist of a fully booked event
+// POST /user/events/:id/waitlist
+func (h *WaitlistHandler) JoinWaitlist(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	entry, err := h.waitlistService.Join(userID.(uuid.UUID), eventID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         entry.ID,
+		"event_id":   entry.EventID,
+		"position":   entry.Position,
+		"status":     entry.Status,
+		"created_at": entry.CreatedAt,
+	})
+}
+
+// LeaveWaitlist removes the current user from the waitlist of an event
+// DELETE /user/events/:id/waitlist
+func (h *WaitlistHandler) LeaveWaitlist(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	if err := h.waitlistService.Leave(userID.(uuid.UUID), eventID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from waitlist"})
+}
+
+// GetUserWaitlist lists the active waitlist entries of the current user
+// GET /user/waitlist
+func (h *WaitlistHandler) GetUserWaitlist(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	entries, err := h.waitlistService.GetUserEntries(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve waitlist"})
+		return
+	}
+
+	list := make([]gin.H, len(entries))
+	for i, e := range entries {
+		list[i] = gin.H{
+			"id":               e.ID,
+			"event_id":         e.EventID,
+			"event_name":       e.Event.Name,
+			"event_date":       e.Event.DateFrom,
+			"position":         e.Position,
+			"status":           e.Status,
+			"offer_expires_at": e.OfferExpiresAt,
+			"created_at":       e.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"waitlist": list})
+}
+
+// ClaimWaitlistOffer books the spot offered through a waitlist claim link
+// POST /user/waitlist/claim
+func (h *WaitlistHandler) ClaimWaitlistOffer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Token            string `json:"token" binding:"required"`
+		TicketTypeID     string `json:"ticket_type_id"` // optional
+		PromoCode        string `json:"promo_code"`     // optional
+		IncludesPickup   bool   `json:"includes_pickup"`
+		PickupAddress    string `json:"pickup_address"`
+		PickupPostalCode string `json:"pickup_postal_code"`
+		PickupSlotID     string `json:"pickup_slot_id"`
+		PaymentProvider  string `json:"payment_provider"` // "stripe" or "paypal" (optional, defaults to stripe)
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.IncludesPickup && req.PickupAddress == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pickup address is required when pickup service is selected"})
+		return
+	}
+
+	paymentProvider := req.PaymentProvider
+	if paymentProvider == "" {
+		paymentProvider = "stripe"
+	}
+
+	ticketReq := services.OrderTicketRequest{
+		IncludesPickup:   req.IncludesPickup,
+		PickupAddress:    req.PickupAddress,
+		PickupPostalCode: req.PickupPostalCode,
+	}
+	var err error
+	if req.TicketTypeID != "" {
+		if ticketReq.TicketTypeID, err = uuid.Parse(req.TicketTypeID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket type ID"})
+			return
+		}
+	}
+	if req.IncludesPickup && req.PickupSlotID != "" {
+		if ticketReq.PickupSlotID, err = uuid.Parse(req.PickupSlotID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pickup slot ID"})
+			return
+		}
+	}
+
+	ticket, checkoutURL, err := h.ticketService.ClaimWaitlistOffer(
+		userID.(uuid.UUID),
+		req.Token,
+		ticketReq,
+		req.PromoCode,
+		paymentProvider,
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticket_id":        ticket.ID,
+		"event_id":         ticket.EventID,
+		"status":           ticket.Status,
+		"checkout_url":     checkoutURL,
+		"payment_provider": paymentProvider,
+		"hold_expires_at":  ticket.HoldExpiresAt,
+		"discount_amount":  ticket.DiscountAmount,
+		"total_amount":     ticket.TotalAmount,
+	})
+}
+
+// ReorderWaitlist sets the queue order of an event (admin)
+// PUT /admin/events/:id/waitlist/order
+// Body: {"entry_ids": ["<uuid>", ...]} containing every active entry exactly once
+func (h *WaitlistHandler) ReorderWaitlist(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	var req struct {
+		EntryIDs []uuid.UUID `json:"entry_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.waitlistService.Reorder(eventID, req.EntryIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Waitlist reordered"})
+}
+
+// RemoveWaitlistEntry takes an entry off an event's waitlist (admin)
+// DELETE /admin/events/:id/waitlist/:entryId
+func (h *WaitlistHandler) RemoveWaitlistEntry(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	entryID, err := uuid.Parse(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	if err := h.waitlistService.Remove(eventID, entryID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Waitlist entry removed"})
+}
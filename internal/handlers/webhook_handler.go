@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/services"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// GetWebhookEvents lists stored payment webhooks (without body) with optional filters
+// GET /admin/webhooks?status=failed&provider=stripe&event_type=...
+func (h *WebhookHandler) GetWebhookEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	status := strings.TrimSpace(c.Query("status"))     // optional: processing|processed|failed|rejected
+	provider := strings.TrimSpace(c.Query("provider")) // optional: stripe|paypal
+	eventType := strings.TrimSpace(c.Query("event_type"))
+
+	events, total, err := h.webhookService.GetEvents(page, limit, provider, status, eventType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": events,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetWebhookEvent returns a stored webhook including its raw body
+// GET /admin/webhooks/:id
+func (h *WebhookHandler) GetWebhookEvent(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	event, err := h.webhookService.GetEvent(id)
+	if err != nil {
+		if errors.Is(err, services.ErrWebhookEventNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook event not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": event})
+}
+
+// ReplayWebhookEvent processes a failed webhook again from its stored body
+// POST /admin/webhooks/:id/replay
+func (h *WebhookHandler) ReplayWebhookEvent(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	actorID := adminID.(uuid.UUID)
+	event, err := h.webhookService.Replay(id, &actorID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrWebhookEventNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The replay may have failed again; its status and error tell
+	c.JSON(http.StatusOK, gin.H{"webhook": event})
+}
@@ -58,6 +58,7 @@ func Auth(authService *services.AuthService) gin.HandlerFunc {
 		c.Set("userID", userID)
 		c.Set("user", user)
 		c.Set("isAdmin", user.IsAdmin)
+		c.Set("isDriver", user.IsDriver)
 
 		c.Next()
 	}
@@ -75,3 +76,19 @@ func AdminOnly() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// DriverOnly creates a middleware that checks if user is a pickup driver (admins may step in)
+func DriverOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		isDriver, _ := c.Get("isDriver")
+		isAdmin, _ := c.Get("isAdmin")
+		if driver, _ := isDriver.(bool); !driver {
+			if admin, _ := isAdmin.(bool); !admin {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Driver access required"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CancellationTier refunds RefundPercent of a ticket cancelled at least DaysBefore full days before the event
+type CancellationTier struct {
+	DaysBefore    int `json:"days_before"`
+	RefundPercent int `json:"refund_percent"`
+}
+
+// CancellationPolicy is a list of tiers, e.g. 100% up to 30 days before, 50% up to 14 days.
+// Cancelling later than the last tier refunds nothing. A nil policy means the default policy applies.
+type CancellationPolicy []CancellationTier
+
+// ParseCancellationPolicy parses "days:percent" pairs separated by commas, e.g. "30:100,14:50"
+func ParseCancellationPolicy(s string) (CancellationPolicy, error) {
+	var policy CancellationPolicy
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		days, percent, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid cancellation tier %q, expected days:percent", part)
+		}
+		d, err1 := strconv.Atoi(strings.TrimSpace(days))
+		p, err2 := strconv.Atoi(strings.TrimSpace(percent))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid cancellation tier %q, expected days:percent", part)
+		}
+		policy = append(policy, CancellationTier{DaysBefore: d, RefundPercent: p})
+	}
+	return policy.Normalize()
+}
+
+// Normalize validates the tiers and sorts them from the earliest cancellation to the latest
+func (p CancellationPolicy) Normalize() (CancellationPolicy, error) {
+	if len(p) > 10 {
+		return nil, errors.New("a cancellation policy can have at most 10 tiers")
+	}
+	tiers := append(CancellationPolicy{}, p...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].DaysBefore > tiers[j].DaysBefore })
+	for i, t := range tiers {
+		if t.DaysBefore < 0 || t.DaysBefore > 365 {
+			return nil, errors.New("days_before must be between 0 and 365")
+		}
+		if t.RefundPercent < 0 || t.RefundPercent > 100 {
+			return nil, errors.New("refund_percent must be between 0 and 100")
+		}
+		if i > 0 && t.DaysBefore == tiers[i-1].DaysBefore {
+			return nil, fmt.Errorf("duplicate cancellation tier for %d days", t.DaysBefore)
+		}
+	}
+	return tiers, nil
+}
+
+// TierAt returns the tier that applies when cancelling at the given time, or nil if none does (no refund).
+// Days are counted in full days until the event starts.
+func (p CancellationPolicy) TierAt(eventStart, at time.Time) *CancellationTier {
+	daysUntilEvent := int(eventStart.Sub(at).Hours() / 24)
+	var match *CancellationTier
+	for i := range p {
+		t := &p[i]
+		if daysUntilEvent >= t.DaysBefore && (match == nil || t.DaysBefore > match.DaysBefore) {
+			match = t
+		}
+	}
+	return match
+}
+
+// RefundPercentAt returns the refund percentage for a cancellation at the given time
+func (p CancellationPolicy) RefundPercentAt(eventStart, at time.Time) int {
+	if t := p.TierAt(eventStart, at); t != nil {
+		return t.RefundPercent
+	}
+	return 0
+}
+
+// Value stores the policy as JSON (NULL for no policy)
+func (p CancellationPolicy) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal([]CancellationTier(p))
+	return string(b), err
+}
+
+// Scan reads the policy from JSON
+func (p *CancellationPolicy) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported cancellation policy value %T", value)
+	}
+	var tiers []CancellationTier
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		return err
+	}
+	*p = tiers
+	return nil
+}
@@ -76,8 +76,16 @@ func Migrate(db *gorm.DB) error {
 		// Don't fail completely, continue with AutoMigrate
 	}
 
+	// Must run before AutoMigrate, which would otherwise cut the decimal amounts to whole euros
+	if err := migrateMoneyToCents(db); err != nil {
+		return fmt.Errorf("failed to convert amounts to cents: %w", err)
+	}
+
+	// Tickets paid before confirmations were tracked have had their email already
+	backfillConfirmations := db.Migrator().HasTable(&Ticket{}) && !db.Migrator().HasColumn(&Ticket{}, "confirmation_sent_at")
+
 	// Run AutoMigrate for all models
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&User{},
 		&Event{},
 		&Ticket{},
@@ -90,7 +98,220 @@ func Migrate(db *gorm.DB) error {
 		&Backup{},
 		&Image{},    // Image gallery model
 		&MusicSet{}, // Music set model (single audio file per set)
-	)
+		&WaitlistEntry{},
+		&OfflineScan{},
+		&TicketTransfer{},
+		&TicketHistory{},
+		&TicketStatusHistory{},
+		&TicketType{},
+		&PromoCode{},
+		&Order{},
+		&OrderItem{},
+		&EventAddon{},
+		&Refund{},
+		&PaymentTransaction{},
+		&Invoice{},
+		&InvoiceCounter{},
+		&WebhookEvent{},
+		&Dispute{},
+		&ReconciliationRun{},
+		&JobRun{},
+		&JobStatus{},
+		&EventCancellation{},
+		&EventCancellationItem{},
+		&PickupZone{},
+		&PickupSlot{},
+		&EventSeries{},
+	); err != nil {
+		return err
+	}
+
+	// The old column is NOT NULL without a default, so no promo code can be created while it exists
+	if err := migratePromoDiscountValue(db); err != nil {
+		return fmt.Errorf("failed to split promo code discounts: %w", err)
+	}
+
+	// Data migrations that need the new tables
+	if backfillConfirmations {
+		if err := db.Exec(`UPDATE tickets SET confirmation_sent_at = updated_at WHERE status <> 'pending'`).Error; err != nil {
+			log.Printf("Warning: Confirmation backfill failed: %v", err)
+		}
+	}
+	if err := migrateGroupPricesToTicketTypes(db); err != nil {
+		log.Printf("Warning: Ticket type migration failed: %v", err)
+	}
+	if err := migrateTicketsToOrders(db); err != nil {
+		log.Printf("Warning: Order migration failed: %v", err)
+	}
+	// Pickups booked before drivers reported their progress
+	if err := db.Exec(`UPDATE tickets SET pickup_status = ? WHERE includes_pickup = true AND pickup_status = ''`, PickupStatusOpen).Error; err != nil {
+		log.Printf("Warning: Pickup status backfill failed: %v", err)
+	}
+	return nil
+}
+
+// moneyColumns lists the columns holding Money amounts, which were decimal euros before
+var moneyColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"events", []string{"price", "guests_price", "bubble_price", "plus_price"}},
+	{"tickets", []string{"price", "pickup_price", "total_amount", "discount_amount", "refunded_amount"}},
+	{"ticket_types", []string{"price"}},
+	{"ticket_transfers", []string{"old_price", "new_price", "price_difference"}},
+	{"promo_codes", []string{"discount_value"}},
+	{"orders", []string{"total_amount", "refunded_amount"}},
+	{"order_items", []string{"unit_price", "discount_amount", "amount", "refunded_amount"}},
+	{"event_addons", []string{"price"}},
+	{"refunds", []string{"amount"}},
+	{"payment_transactions", []string{"amount"}},
+	{"disputes", []string{"amount"}},
+	{"invoices", []string{"net_amount", "vat_amount", "gross_amount"}},
+	{"event_cancellations", []string{"refunded_amount"}},
+	{"event_cancellation_items", []string{"refund_amount"}},
+}
+
+// migrateMoneyToCents converts the money columns still holding decimal euros to integer cents.
+// A column is converted once: afterwards it is a bigint and skipped. Defaults are dropped and
+// set again in cents by AutoMigrate. Amounts in JSON (invoice lines, reconciliation items) and
+// settings stay decimal, which is how Money reads and writes them.
+func migrateMoneyToCents(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range moneyColumns {
+			for _, column := range t.columns {
+				var dataType string
+				if err := tx.Raw(`
+					SELECT data_type FROM information_schema.columns
+					WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?
+				`, t.table, column).Scan(&dataType).Error; err != nil {
+					return err
+				}
+				// Missing (new installation) or already converted
+				if dataType == "" || dataType == "bigint" {
+					continue
+				}
+
+				log.Printf("Converting %s.%s (%s) to cents...", t.table, column, dataType)
+				if err := tx.Exec(fmt.Sprintf(
+					`ALTER TABLE %[1]s ALTER COLUMN %[2]s DROP DEFAULT, ALTER COLUMN %[2]s TYPE bigint USING round(%[2]s * 100)::bigint`,
+					t.table, column)).Error; err != nil {
+					return fmt.Errorf("failed to convert %s.%s: %w", t.table, column, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// migratePromoDiscountValue moves the former discount_value of promo codes, a percentage in hundredths
+// or an amount in cents (after migrateMoneyToCents), to discount_percent or discount_amount
+func migratePromoDiscountValue(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&PromoCode{}, "discount_value") {
+		return nil
+	}
+
+	log.Println("Splitting promo code discounts into percentages and amounts...")
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE promo_codes SET discount_percent = discount_value WHERE discount_type = ?`, PromoDiscountPercent).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`UPDATE promo_codes SET discount_amount = discount_value WHERE discount_type = ?`, PromoDiscountFixed).Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&PromoCode{}, "discount_value")
+	})
+}
+
+// migrateTicketsToOrders wraps every ticket booked before orders existed in an order of its own
+// (reusing the ticket ID) with a ticket item and, if booked, a pickup item
+func migrateTicketsToOrders(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&Ticket{}).Where("order_id IS NULL").Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	log.Printf("Migrating %d tickets to orders...", count)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO orders (id, user_id, event_id, payment_provider, total_amount, refunded_amount, created_at, updated_at)
+			SELECT id, user_id, event_id, COALESCE(payment_provider, 'stripe'), total_amount, refunded_amount, created_at, updated_at
+			FROM tickets WHERE order_id IS NULL
+		`).Error; err != nil {
+			return fmt.Errorf("failed to create orders: %w", err)
+		}
+
+		// Refunded amounts are booked on the ticket item first, the rest on the pickup item
+		if err := tx.Exec(`
+			INSERT INTO order_items (id, order_id, kind, ticket_id, name, unit_price, quantity, discount_amount, amount, status, refunded_amount, refunded_at, created_at, updated_at)
+			SELECT gen_random_uuid(), id, ?, id,
+				'Ticket' || CASE WHEN ticket_type_name <> '' THEN ' (' || ticket_type_name || ')' ELSE '' END,
+				price, 1, discount_amount, price - discount_amount,
+				CASE status WHEN 'refunded' THEN ? WHEN 'cancelled' THEN ? ELSE ? END,
+				LEAST(refunded_amount, price - discount_amount), refunded_at, created_at, updated_at
+			FROM tickets WHERE order_id IS NULL
+		`, OrderItemTicket, OrderItemStatusRefunded, OrderItemStatusCancelled, OrderItemStatusActive).Error; err != nil {
+			return fmt.Errorf("failed to create ticket items: %w", err)
+		}
+		if err := tx.Exec(`
+			INSERT INTO order_items (id, order_id, kind, ticket_id, name, description, unit_price, quantity, amount, status, refunded_amount, refunded_at, created_at, updated_at)
+			SELECT gen_random_uuid(), id, ?, id, 'Abholservice', 'Abholung von: ' || pickup_address,
+				pickup_price, 1, pickup_price,
+				CASE status WHEN 'refunded' THEN ? WHEN 'cancelled' THEN ? ELSE ? END,
+				GREATEST(refunded_amount - (price - discount_amount), 0), refunded_at, created_at, updated_at
+			FROM tickets WHERE order_id IS NULL AND includes_pickup = true
+		`, OrderItemPickup, OrderItemStatusRefunded, OrderItemStatusCancelled, OrderItemStatusActive).Error; err != nil {
+			return fmt.Errorf("failed to create pickup items: %w", err)
+		}
+
+		if err := tx.Exec(`UPDATE tickets SET order_id = id WHERE order_id IS NULL`).Error; err != nil {
+			return fmt.Errorf("failed to link tickets to orders: %w", err)
+		}
+
+		log.Println("✅ Tickets migrated to orders")
+		return nil
+	})
+}
+
+// migrateGroupPricesToTicketTypes creates ticket types equivalent to the former
+// per-group prices for every event without types and links existing tickets to them
+func migrateGroupPricesToTicketTypes(db *gorm.DB) error {
+	var events []Event
+	if err := db.Where("NOT EXISTS (SELECT 1 FROM ticket_types tt WHERE tt.event_id = events.id)").
+		Find(&events).Error; err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	log.Printf("Migrating group prices of %d events to ticket types...", len(events))
+	for i := range events {
+		types := events[i].DefaultTicketTypes()
+		if len(types) == 0 {
+			continue
+		}
+		if err := db.Create(&types).Error; err != nil {
+			return fmt.Errorf("failed to create ticket types for event %s: %w", events[i].ID, err)
+		}
+	}
+
+	// Existing tickets were priced by the buyer's group
+	if err := db.Exec(`
+		UPDATE tickets SET ticket_type_id = tt.id, ticket_type_name = tt.name
+		FROM users u, ticket_types tt
+		WHERE tickets.ticket_type_id IS NULL
+		AND u.id = tickets.user_id
+		AND tt.event_id = tickets.event_id
+		AND tt.legacy_group = u."group"
+	`).Error; err != nil {
+		return fmt.Errorf("failed to link tickets to ticket types: %w", err)
+	}
+
+	log.Println("✅ Ticket types migrated")
+	return nil
 }
 
 // runManualMigrations runs manual SQL migrations for existing tables
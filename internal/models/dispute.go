@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DisputeOutcomeWon  = "won"
+	DisputeOutcomeLost = "lost"
+)
+
+// Dispute is a chargeback the buyer's bank opened against a payment. While it is open the tickets
+// paid with that payment are disputed and refused at check-in; a lost dispute is booked as a refund.
+type Dispute struct {
+	ID                uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Provider          string    `gorm:"type:varchar(20);not null" json:"provider"` // stripe
+	ProviderDisputeID string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"provider_dispute_id"`
+	// PaymentRef is the disputed payment (Stripe payment intent)
+	PaymentRef string `gorm:"type:varchar(255);not null;index" json:"payment_ref"`
+	Amount     Money  `gorm:"not null" json:"amount"`
+	Currency   string `gorm:"type:varchar(3)" json:"currency"`
+	Reason     string `gorm:"type:varchar(50)" json:"reason"` // provider reason, e.g. fraudulent, product_not_received
+	// Status is the provider status, e.g. needs_response, under_review, won, lost
+	Status        string     `gorm:"type:varchar(30);not null;index" json:"status"`
+	Outcome       string     `gorm:"type:varchar(10)" json:"outcome,omitempty"` // won, lost; empty while open
+	EvidenceDueBy *time.Time `json:"evidence_due_by,omitempty"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Tickets []Ticket `gorm:"foreignKey:DisputeID" json:"tickets,omitempty"`
+}
+
+func (d *Dispute) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
@@ -16,18 +16,29 @@ type Event struct {
 	TimeFrom        string    `gorm:"not null" json:"time_from"` // Format: "HH:MM"
 	TimeTo          string    `gorm:"not null" json:"time_to"`   // Format: "HH:MM"
 	MaxParticipants int       `gorm:"not null" json:"max_participants"`
-	// Deprecated: Price bleibt für Alt-Clients erhalten, wird aber nicht mehr für Kaufpreis genutzt
-	Price        float64   `gorm:"not null;default:0" json:"price"`
-	GuestsPrice  float64   `gorm:"not null;default:100" json:"guests_price"`
-	BubblePrice  float64   `gorm:"not null;default:35" json:"bubble_price"`
-	PlusPrice    float64   `gorm:"not null;default:50" json:"plus_price"`
+	// Deprecated: Price bleibt für Alt-Clients erhalten, wird aber nicht mehr für Kaufpreis genutzt.
+	// Guests/Bubble/PlusPrice werden auf die migrierten Ticket-Typen (LegacyGroup) gespiegelt.
+	Price        Money     `gorm:"not null;default:0" json:"price"`
+	GuestsPrice  Money     `gorm:"not null;default:10000" json:"guests_price"`
+	BubblePrice  Money     `gorm:"not null;default:3500" json:"bubble_price"`
+	PlusPrice    Money     `gorm:"not null;default:5000" json:"plus_price"`
 	AllowedGroup string    `gorm:"type:varchar(16);not null;default:'all'" json:"allowed_group"` // all|guests|bubble|plus
 	IsActive     bool      `gorm:"default:true" json:"is_active"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 
+	// Refund tiers for cancelled tickets; without its own policy the default policy applies
+	CancellationPolicy CancellationPolicy `gorm:"type:jsonb" json:"cancellation_policy,omitempty"`
+	// VAT rates per product type; product types not listed use the default rates
+	TaxRates TaxRates `gorm:"type:jsonb" json:"tax_rates,omitempty"`
+
+	// Series the event was created from, and its date in the series (YYYY-MM-DD)
+	SeriesID   *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_events_series_date" json:"series_id,omitempty"`
+	SeriesDate string     `gorm:"type:varchar(10);not null;default:'';uniqueIndex:idx_events_series_date" json:"series_date,omitempty"`
+
 	// Relations
-	Tickets []Ticket `gorm:"foreignKey:EventID" json:"tickets,omitempty"`
+	Tickets     []Ticket     `gorm:"foreignKey:EventID" json:"tickets,omitempty"`
+	TicketTypes []TicketType `gorm:"foreignKey:EventID" json:"ticket_types,omitempty"`
 }
 
 func (e *Event) BeforeCreate(tx *gorm.DB) error {
@@ -37,11 +48,64 @@ func (e *Event) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// GetAvailableSpots returns the number of available spots for the event
+// GetAvailableSpots returns the number of available spots for the event.
+// Paid and disputed tickets, tickets in the cancellation grace period, pending tickets
+// whose seat hold has not yet expired and open waitlist offers all occupy a spot.
 func (e *Event) GetAvailableSpots(db *gorm.DB) int {
+	now := time.Now()
 	var bookedCount int64
-	db.Model(&Ticket{}).Where("event_id = ? AND status IN ?", e.ID, []string{"paid", "pending"}).Count(&bookedCount)
-	return e.MaxParticipants - int(bookedCount)
+	occupyingTickets(db.Model(&Ticket{}), now).
+		Where("event_id = ?", e.ID).
+		Count(&bookedCount)
+	var offeredCount int64
+	db.Model(&WaitlistEntry{}).
+		Where("event_id = ? AND status = ? AND offer_expires_at > ?", e.ID, WaitlistStatusOffered, now).
+		Count(&offeredCount)
+	return e.MaxParticipants - int(bookedCount) - int(offeredCount)
+}
+
+// occupyingTickets restricts a ticket query to tickets that hold a spot
+func occupyingTickets(q *gorm.DB, now time.Time) *gorm.DB {
+	return q.Where("(status IN ? OR (status = ? AND (hold_expires_at IS NULL OR hold_expires_at > ?)))",
+		[]string{"paid", "disputed", "pending_cancellation"}, "pending", now)
+}
+
+// DefaultTicketTypes builds the ticket types equivalent to the per-group prices
+// (one type per group the event is open for)
+func (e *Event) DefaultTicketTypes() []TicketType {
+	defaults := []struct {
+		group, name string
+		price       Money
+	}{
+		{"guests", "Guests", e.GuestsPrice},
+		{"bubble", "Bubble", e.BubblePrice},
+		{"plus", "Plus", e.PlusPrice},
+	}
+	var types []TicketType
+	for i, d := range defaults {
+		if !e.AllowsGroup(d.group) {
+			continue
+		}
+		types = append(types, TicketType{
+			EventID:       e.ID,
+			Name:          d.name,
+			Price:         d.price,
+			AllowedGroups: d.group,
+			SortOrder:     i,
+			IsActive:      true,
+			LegacyGroup:   d.group,
+		})
+	}
+	return types
+}
+
+// AllowsGroup reports whether members of the given group may book the event
+func (e *Event) AllowsGroup(group string) bool {
+	switch e.AllowedGroup {
+	case "guests", "bubble", "plus":
+		return group == e.AllowedGroup
+	}
+	return true
 }
 
 type SystemSetting struct {
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event cancellation states
+const (
+	EventCancellationRunning   = "running"
+	EventCancellationCompleted = "completed"
+)
+
+// Event cancellation item states
+const (
+	EventCancellationItemPending = "pending"
+	EventCancellationItemDone    = "done"
+	EventCancellationItemFailed  = "failed" // needs to be handled by hand, see Error
+)
+
+// EventCancellation cancels all tickets of an event in the background. Progress is kept per ticket in
+// its items, so an interrupted cancellation continues where it stopped.
+type EventCancellation struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EventID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"event_id"`
+	RefundPaid  bool       `gorm:"not null" json:"refund_paid"` // paid tickets are fully refunded, otherwise cancelled without refund
+	Status      string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	TriggeredBy *uuid.UUID `gorm:"type:uuid" json:"triggered_by,omitempty"`
+
+	Total          int   `gorm:"not null;default:0" json:"total"`
+	Processed      int   `gorm:"not null;default:0" json:"processed"`
+	Failed         int   `gorm:"not null;default:0" json:"failed"`
+	Notified       int   `gorm:"not null;default:0" json:"notified"` // attendees emailed
+	RefundedAmount Money `gorm:"not null;default:0" json:"refunded_amount"`
+
+	Error      string     `gorm:"type:text" json:"error,omitempty"` // add-on refunds and other problems outside the tickets
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (c *EventCancellation) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// EventCancellationItem is the cancellation of one ticket
+type EventCancellationItem struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CancellationID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_event_cancellation_ticket" json:"cancellation_id"`
+	TicketID       uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_event_cancellation_ticket" json:"ticket_id"`
+	UserID         uuid.UUID    `gorm:"type:uuid;not null" json:"user_id"`
+	Status         string       `gorm:"type:varchar(20);not null;index" json:"status"`
+	FromStatus     TicketStatus `gorm:"type:varchar(30);not null" json:"from_status"`    // ticket status when the cancellation started
+	TicketStatus   TicketStatus `gorm:"type:varchar(30)" json:"ticket_status,omitempty"` // status the ticket ended up in
+	RefundID       *uuid.UUID   `gorm:"type:uuid" json:"refund_id,omitempty"`
+	RefundAmount   Money        `gorm:"not null;default:0" json:"refund_amount"`
+	RefundStatus   string       `gorm:"type:varchar(20)" json:"refund_status,omitempty"`
+	NotifiedAt     *time.Time   `json:"notified_at,omitempty"`
+	Error          string       `gorm:"type:text" json:"error,omitempty"`
+	ProcessedAt    *time.Time   `json:"processed_at,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+
+	Ticket Ticket `gorm:"foreignKey:TicketID" json:"-"`
+}
+
+func (i *EventCancellationItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
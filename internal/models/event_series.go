@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Recurrence frequencies of an event series
+const (
+	SeriesWeekly  = "weekly"
+	SeriesMonthly = "monthly"
+)
+
+// SeriesDateFormat is the format of occurrence and exception dates
+const SeriesDateFormat = "2006-01-02"
+
+// EventSeries is a recurring event. Its template (times, capacity, prices, allowed group and policies)
+// is copied into an Event for every date of its recurrence rule. The rule follows RRULE (RFC 5545):
+// FREQ=WEEKLY or FREQ=MONTHLY with INTERVAL and BYDAY (monthly with a position, e.g. 1FR or -1SA),
+// DTSTART (StartsOn), UNTIL and EXDATE (Exceptions).
+type EventSeries struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name        string    `gorm:"not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	TimeFrom    string    `gorm:"not null" json:"time_from"` // Format: "HH:MM"
+	TimeTo      string    `gorm:"not null" json:"time_to"`   // Format: "HH:MM"
+	// EndDayOffset is the day an occurrence ends relative to the day it starts (1 = ends after midnight)
+	EndDayOffset       int                `gorm:"not null;default:0" json:"end_day_offset"`
+	MaxParticipants    int                `gorm:"not null" json:"max_participants"`
+	GuestsPrice        Money              `gorm:"not null;default:10000" json:"guests_price"`
+	BubblePrice        Money              `gorm:"not null;default:3500" json:"bubble_price"`
+	PlusPrice          Money              `gorm:"not null;default:5000" json:"plus_price"`
+	AllowedGroup       string             `gorm:"type:varchar(16);not null;default:'all'" json:"allowed_group"` // all|guests|bubble|plus
+	CancellationPolicy CancellationPolicy `gorm:"type:jsonb" json:"cancellation_policy,omitempty"`
+	TaxRates           TaxRates           `gorm:"type:jsonb" json:"tax_rates,omitempty"`
+
+	// Recurrence rule
+	Frequency string `gorm:"type:varchar(10);not null" json:"frequency"` // weekly|monthly
+	Interval  int    `gorm:"not null;default:1" json:"interval"`         // every n weeks or months
+	Weekday   int    `gorm:"not null;default:0" json:"weekday"`          // 0 = Sunday ... 6 = Saturday
+	// WeekOfMonth picks the nth weekday of the month in monthly series: 1-5, -1 = last; months without it are skipped
+	WeekOfMonth int        `gorm:"not null;default:0" json:"week_of_month"`
+	StartsOn    time.Time  `gorm:"not null" json:"starts_on"`
+	Until       *time.Time `json:"until,omitempty"`
+	// Exceptions are the dates (YYYY-MM-DD) left out of the series
+	Exceptions DateList `gorm:"type:jsonb" json:"exceptions"`
+	// MonthsAhead is how far ahead occurrences are created
+	MonthsAhead int       `gorm:"not null;default:3" json:"months_ahead"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (s *EventSeries) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// Dates returns the dates of the series from `from` to `to` (both inclusive), as midnight in loc
+func (s *EventSeries) Dates(from, to time.Time, loc *time.Location) []time.Time {
+	day := func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	start, first, last := day(s.StartsOn), day(from), day(to)
+	if s.Until != nil && day(*s.Until).Before(last) {
+		last = day(*s.Until)
+	}
+	if start.After(first) {
+		first = start
+	}
+	interval := s.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	weekday := time.Weekday(s.Weekday)
+
+	var dates []time.Time
+	add := func(d time.Time) {
+		if !d.Before(first) && !d.After(last) && !s.Exceptions.Contains(d.Format(SeriesDateFormat)) {
+			dates = append(dates, d)
+		}
+	}
+	switch s.Frequency {
+	case SeriesWeekly:
+		// The intervals count from the first matching weekday on or after the start
+		d := start.AddDate(0, 0, (int(weekday)-int(start.Weekday())+7)%7)
+		for ; !d.After(last); d = d.AddDate(0, 0, 7*interval) {
+			add(d)
+		}
+	case SeriesMonthly:
+		for m := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, loc); !m.After(last); m = m.AddDate(0, interval, 0) {
+			if d, ok := nthWeekday(m, weekday, s.WeekOfMonth); ok {
+				add(d)
+			}
+		}
+	}
+	return dates
+}
+
+// nthWeekday returns the nth weekday of the month starting at month (n = -1: the last one)
+func nthWeekday(month time.Time, weekday time.Weekday, n int) (time.Time, bool) {
+	if n < 0 {
+		last := month.AddDate(0, 1, -1)
+		return last.AddDate(0, 0, -((int(last.Weekday()) - int(weekday) + 7) % 7)), true
+	}
+	d := month.AddDate(0, 0, (int(weekday)-int(month.Weekday())+7)%7+7*(n-1))
+	return d, d.Month() == month.Month()
+}
+
+// Occurrence builds the event of the series on the given date; times are applied when it is created
+func (s *EventSeries) Occurrence(date time.Time) *Event {
+	seriesID := s.ID
+	return &Event{
+		Name:               s.Name,
+		Description:        s.Description,
+		DateFrom:           date,
+		DateTo:             date.AddDate(0, 0, s.EndDayOffset),
+		TimeFrom:           s.TimeFrom,
+		TimeTo:             s.TimeTo,
+		MaxParticipants:    s.MaxParticipants,
+		GuestsPrice:        s.GuestsPrice,
+		BubblePrice:        s.BubblePrice,
+		PlusPrice:          s.PlusPrice,
+		AllowedGroup:       s.AllowedGroup,
+		IsActive:           true,
+		CancellationPolicy: s.CancellationPolicy,
+		TaxRates:           s.TaxRates,
+		SeriesID:           &seriesID,
+		SeriesDate:         date.Format(SeriesDateFormat),
+	}
+}
+
+// DateList is a sorted list of dates (YYYY-MM-DD) stored as JSON
+type DateList []string
+
+// Normalize validates the dates and sorts them without duplicates
+func (l DateList) Normalize() (DateList, error) {
+	seen := map[string]bool{}
+	out := DateList{}
+	for _, d := range l {
+		d = strings.TrimSpace(d)
+		if _, err := time.Parse(SeriesDateFormat, d); err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", d)
+		}
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// Contains reports whether the list contains a date
+func (l DateList) Contains(date string) bool {
+	for _, d := range l {
+		if d == date {
+			return true
+		}
+	}
+	return false
+}
+
+// Value stores the dates as JSON
+func (l DateList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(l))
+	return string(b), err
+}
+
+// Scan reads the dates from JSON
+func (l *DateList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = DateList{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported date list value %T", value)
+	}
+	var dates []string
+	if err := json.Unmarshal(data, &dates); err != nil {
+		return err
+	}
+	*l = dates
+	return nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	InvoiceKindInvoice    = "invoice"     // Rechnung
+	InvoiceKindCreditNote = "credit_note" // Gutschrift (Rechnungskorrektur) for a refund
+)
+
+// Invoice is an issued invoice or credit note. It keeps a snapshot of seller, buyer and lines
+// together with the rendered PDF, so it never changes once issued. Numbers come from
+// InvoiceCounter and are gap-free per series and year (e.g. RE-2026-00042, GS-2026-00007).
+type Invoice struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Number string    `gorm:"type:varchar(32);uniqueIndex;not null" json:"number"`
+	Kind   string    `gorm:"type:varchar(20);not null" json:"kind"` // invoice, credit_note
+	// TicketID is the ticket the invoice was issued for; credit notes point to the ticket of the corrected invoice
+	TicketID uuid.UUID  `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	OrderID  *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	UserID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	// RefundID links a credit note to the refund it documents
+	RefundID *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:"refund_id,omitempty"`
+	// CorrectsInvoiceID links a credit note to the invoice it corrects
+	CorrectsInvoiceID *uuid.UUID `gorm:"type:uuid;index" json:"corrects_invoice_id,omitempty"`
+
+	SellerName    string `gorm:"type:varchar(255);not null" json:"seller_name"`
+	SellerAddress string `gorm:"type:text" json:"seller_address"` // newline separated
+	SellerTaxID   string `gorm:"type:varchar(64)" json:"seller_tax_id"`
+	BuyerName     string `gorm:"type:varchar(255);not null" json:"buyer_name"`
+	BuyerEmail    string `gorm:"type:varchar(255)" json:"buyer_email"`
+
+	IssuedAt    time.Time `gorm:"not null" json:"issued_at"`
+	ServiceDate time.Time `json:"service_date"` // Leistungsdatum (event date)
+	// Lines holds the InvoiceLine entries as JSON
+	Lines     string `gorm:"type:text;not null" json:"-"`
+	NetAmount Money  `gorm:"not null" json:"net_amount"`
+	VATAmount Money  `gorm:"not null" json:"vat_amount"`
+	// GrossAmount is negative for credit notes
+	GrossAmount   Money     `gorm:"not null" json:"gross_amount"`
+	SmallBusiness bool      `gorm:"not null;default:false" json:"small_business"`
+	PDF           []byte    `gorm:"type:bytea" json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// InvoiceLine is one position of an invoice; amounts are gross (VAT included)
+type InvoiceLine struct {
+	OrderItemID *uuid.UUID `json:"order_item_id,omitempty"`
+	Description string     `json:"description"`
+	Quantity    int        `json:"quantity"`
+	UnitPrice   Money      `json:"unit_price"`
+	Discount    Money      `json:"discount,omitempty"`
+	Amount      Money      `json:"amount"`
+	VATRate     int        `json:"vat_rate"` // percent
+}
+
+// InvoiceLines decodes the stored lines
+func (i *Invoice) InvoiceLines() []InvoiceLine {
+	var lines []InvoiceLine
+	_ = json.Unmarshal([]byte(i.Lines), &lines)
+	return lines
+}
+
+// InvoiceCounter holds the last number issued in a series (e.g. "RE-2026").
+// It is locked while a number is taken, so numbers are sequential without gaps.
+type InvoiceCounter struct {
+	Series     string `gorm:"type:varchar(16);primary_key" json:"series"`
+	LastNumber int    `gorm:"not null;default:0" json:"last_number"`
+}
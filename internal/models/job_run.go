@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun records one execution of a background job that did something or failed: where and why it ran,
+// how long it took and what it did. Scheduled runs with nothing to do only count towards the job's JobStatus.
+type JobRun struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	JobName     string     `gorm:"type:varchar(64);not null;index:idx_job_runs_job_started,priority:1" json:"job_name"`
+	Instance    string     `gorm:"type:varchar(255)" json:"instance"`
+	Trigger     string     `gorm:"type:varchar(16);not null" json:"trigger"` // schedule, manual
+	TriggeredBy *uuid.UUID `gorm:"type:uuid" json:"triggered_by,omitempty"`
+	Status      string     `gorm:"type:varchar(16);not null;default:'running'" json:"status"` // running, succeeded, failed
+	Result      string     `gorm:"type:text" json:"result,omitempty"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt   time.Time  `gorm:"not null;index:idx_job_runs_job_started,priority:2" json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DurationMs  int64      `gorm:"not null;default:0" json:"duration_ms"`
+}
+
+func (r *JobRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// JobStatus keeps one row per job with its latest run and how often it ran, including runs without a JobRun
+type JobStatus struct {
+	JobName        string    `gorm:"type:varchar(64);primary_key" json:"job_name"`
+	LastRunAt      time.Time `gorm:"not null" json:"last_run_at"`
+	LastStatus     string    `gorm:"type:varchar(16);not null" json:"last_status"` // succeeded, failed
+	LastDurationMs int64     `gorm:"not null;default:0" json:"last_duration_ms"`
+	LastInstance   string    `gorm:"type:varchar(255)" json:"last_instance"`
+	Runs           int64     `gorm:"not null;default:0" json:"runs"`
+	Failures       int64     `gorm:"not null;default:0" json:"failures"`
+}
+
+func (JobStatus) TableName() string {
+	return "job_statuses"
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Currency is the ISO 4217 code of the currency all prices and payments are in
+const Currency = "EUR"
+
+// Money is an amount in minor units (cents) of Currency. Amounts are stored and calculated as
+// integers, so splitting or refunding part of a price never loses or invents a cent.
+// In JSON and in settings it is written as a decimal amount (35.15), as the API always did.
+type Money int64
+
+// Cents returns the amount in minor units, e.g. for payment provider APIs
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// String formats the amount with two decimals, e.g. "35.15" or "-3.50"
+func (m Money) String() string {
+	sign := ""
+	c := int64(m)
+	if c < 0 {
+		sign = "-"
+		c = -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+// Format formats the amount for German texts, e.g. "1.234,50 €"
+func (m Money) Format() string {
+	s := m.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	units, cents, _ := strings.Cut(s, ".")
+	var grouped []byte
+	for i := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			grouped = append(grouped, '.')
+		}
+		grouped = append(grouped, units[i])
+	}
+	return sign + string(grouped) + "," + cents + " €"
+}
+
+// MulDiv returns m * num / den rounded half away from zero
+func (m Money) MulDiv(num, den int64) Money {
+	if den == 0 {
+		return 0
+	}
+	n := int64(m) * num
+	q, r := n/den, n%den
+	if r < 0 {
+		r = -r
+	}
+	d := den
+	if d < 0 {
+		d = -d
+	}
+	if 2*r >= d {
+		if (n < 0) != (den < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Money(q)
+}
+
+// Percent returns percent of m rounded to the cent, e.g. 50% of 35.15 is 17.58
+func (m Money) Percent(percent int) Money {
+	return m.MulDiv(int64(percent), 100)
+}
+
+// BasisPoints is a percentage in hundredths of a percent (12.5% is 1250), so fractional
+// percentages are exact. In JSON it is written as a decimal percentage (12.5).
+type BasisPoints int64
+
+// FullPercent is 100% in basis points
+const FullPercent BasisPoints = 10000
+
+// Of returns the percentage of an amount rounded to the cent, e.g. 12.5% of 20.00 is 2.50
+func (b BasisPoints) Of(m Money) Money {
+	return m.MulDiv(int64(b), int64(FullPercent))
+}
+
+// String formats the percentage with two decimals, e.g. "12.50"
+func (b BasisPoints) String() string {
+	return Money(b).String()
+}
+
+// MarshalJSON writes the percentage as a decimal number
+func (b BasisPoints) MarshalJSON() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalJSON reads a decimal percentage with at most two decimals, as a number or a string
+func (b *BasisPoints) UnmarshalJSON(data []byte) error {
+	// Same decimal format as amounts
+	var m Money
+	if err := m.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("invalid percentage %s: at most two decimals", data)
+	}
+	*b = BasisPoints(m)
+	return nil
+}
+
+var errInvalidMoney = errors.New("invalid amount")
+
+// ParseMoney parses a decimal amount like "35.15", "35,15", "-3.5" or "100". More than two
+// decimals are only accepted if they are zeros ("15.000000"), so no amount is rounded silently.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg, s = true, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	units, frac, _ := strings.Cut(strings.Replace(s, ",", ".", 1), ".")
+	if units == "" && frac == "" {
+		return 0, fmt.Errorf("%w %q", errInvalidMoney, s)
+	}
+	if len(frac) > 2 {
+		if strings.Trim(frac[2:], "0") != "" {
+			return 0, fmt.Errorf("%w %q: at most two decimals", errInvalidMoney, s)
+		}
+		frac = frac[:2]
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	if units == "" {
+		units = "0"
+	}
+	u, err := strconv.ParseUint(units, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", errInvalidMoney, s)
+	}
+	f, err := strconv.ParseUint(frac, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", errInvalidMoney, s)
+	}
+	c := int64(u)*100 + int64(f)
+	if neg {
+		c = -c
+	}
+	return Money(c), nil
+}
+
+// MarshalJSON writes the amount as a decimal number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads a decimal number or a string holding one
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if strings.ContainsAny(s, "eE") {
+		// exponent notation, e.g. 1e2 from a client serialising floats
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%w %q", errInvalidMoney, s)
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OfflineScan records a ticket scan uploaded by a door scanner after running offline.
+// Every upload is kept so conflicts between devices remain traceable.
+type OfflineScan struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TicketID   uuid.UUID `gorm:"type:uuid;not null;index;uniqueIndex:idx_offline_scans_device_ticket_time" json:"ticket_id"`
+	EventID    uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	DeviceID   string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_offline_scans_device_ticket_time" json:"device_id"`
+	ScannedAt  time.Time `gorm:"not null;uniqueIndex:idx_offline_scans_device_ticket_time" json:"scanned_at"`
+	UploadedBy uuid.UUID `gorm:"type:uuid;not null" json:"uploaded_by"`
+	Result     string    `gorm:"type:varchar(20);not null" json:"result"` // checked_in, conflict, rejected
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (o *OfflineScan) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	OrderItemTicket = "ticket"
+	OrderItemPickup = "pickup"
+	OrderItemAddon  = "addon"
+
+	OrderItemStatusActive    = "active"
+	OrderItemStatusRefunded  = "refunded"
+	OrderItemStatusCancelled = "cancelled"
+)
+
+// Order groups the tickets and add-ons bought with one checkout.
+// All tickets of an order share the checkout and payment references (stored on the tickets),
+// so they change from pending to paid together; afterwards each item can be refunded on its own.
+// A single ticket booking is an order with one ticket item (plus an optional pickup item).
+type Order struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	EventID         uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	PaymentProvider string    `gorm:"type:varchar(20);default:'stripe'" json:"payment_provider"`
+	TotalAmount     Money     `gorm:"not null;default:0" json:"total_amount"`
+	RefundedAmount  Money     `gorm:"not null;default:0" json:"refunded_amount"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	// Relations
+	Items   []OrderItem `gorm:"foreignKey:OrderID" json:"items,omitempty"`
+	Tickets []Ticket    `gorm:"foreignKey:OrderID" json:"tickets,omitempty"`
+	User    User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Event   Event       `gorm:"foreignKey:EventID" json:"event,omitempty"`
+}
+
+func (o *Order) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// PaymentStatus derives the state of the order from its tickets (requires Tickets to be loaded)
+func (o *Order) PaymentStatus() string {
+	counts := map[TicketStatus]int{}
+	for _, t := range o.Tickets {
+		counts[t.Status]++
+	}
+	for _, status := range []TicketStatus{TicketDisputed, TicketPaid, TicketPending, TicketPendingCancellation} {
+		if counts[status] > 0 {
+			return string(status)
+		}
+	}
+	if len(o.Tickets) > 0 && counts[TicketRefunded] == len(o.Tickets) {
+		return "refunded"
+	}
+	return "cancelled"
+}
+
+// PaymentTicket returns a ticket carrying the payment references of the order, or nil
+func (o *Order) PaymentTicket() *Ticket {
+	for i := range o.Tickets {
+		t := &o.Tickets[i]
+		if t.StripePaymentIntentID != "" || t.PayPalCaptureID != "" {
+			return t
+		}
+	}
+	return nil
+}
+
+// OrderItem is one line of an order: a ticket, the pickup service of a ticket, or an add-on
+type OrderItem struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	Kind    string    `gorm:"type:varchar(16);not null" json:"kind"` // ticket, pickup, addon
+	// TicketID links ticket and pickup items to their ticket
+	TicketID       *uuid.UUID `gorm:"type:uuid;index" json:"ticket_id,omitempty"`
+	AddonID        *uuid.UUID `gorm:"type:uuid" json:"addon_id,omitempty"`
+	Name           string     `gorm:"type:varchar(255);not null" json:"name"`
+	Description    string     `gorm:"type:text" json:"description,omitempty"`
+	UnitPrice      Money      `gorm:"not null;default:0" json:"unit_price"`
+	Quantity       int        `gorm:"not null;default:1" json:"quantity"`
+	DiscountAmount Money      `gorm:"not null;default:0" json:"discount_amount,omitempty"`
+	// Amount is what is charged for the item: UnitPrice * Quantity - DiscountAmount
+	Amount         Money      `gorm:"not null;default:0" json:"amount"`
+	TaxRate        int        `gorm:"not null;default:0" json:"tax_rate"`                       // VAT rate in percent
+	NetAmount      Money      `gorm:"not null;default:0" json:"net_amount"`                     // Amount without VAT
+	TaxAmount      Money      `gorm:"not null;default:0" json:"tax_amount"`                     // VAT contained in Amount
+	Status         string     `gorm:"type:varchar(20);not null;default:'active'" json:"status"` // active, refunded, cancelled
+	RefundedAmount Money      `gorm:"not null;default:0" json:"refunded_amount,omitempty"`
+	RefundedAt     *time.Time `json:"refunded_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (i *OrderItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// SetTaxRate records the VAT rate of the item and splits its amount into net and VAT
+func (i *OrderItem) SetTaxRate(rate int) {
+	i.TaxRate = rate
+	i.NetAmount, i.TaxAmount = SplitGross(i.Amount, rate)
+}
+
+// RefundableAmount returns the part of the item amount not yet refunded
+func (i *OrderItem) RefundableAmount() Money {
+	return i.Amount - i.RefundedAmount
+}
+
+// EventAddon is an extra that can be bought with the tickets of an event (e.g. drink vouchers, merch)
+type EventAddon struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EventID     uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Price       Money     `gorm:"not null;default:0" json:"price"`
+	// MaxPerOrder limits the quantity in one order; 0 = unlimited
+	MaxPerOrder int       `gorm:"not null;default:0" json:"max_per_order"`
+	SortOrder   int       `gorm:"not null;default:0" json:"sort_order"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (a *EventAddon) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// SameCheckout restricts a ticket query to the given ticket and the other tickets of its order,
+// which were paid with the same checkout and share its payment state
+func SameCheckout(ticketID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(id = ? OR order_id = (SELECT order_id FROM tickets WHERE id = ?))", ticketID, ticketID)
+	}
+}
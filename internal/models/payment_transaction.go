@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	TransactionPayment    = "payment"    // money received with an auto-captured payment (Stripe)
+	TransactionCapture    = "capture"    // money received with a captured order (PayPal)
+	TransactionRefund     = "refund"     // money given back
+	TransactionFee        = "fee"        // provider fee withheld
+	TransactionAdjustment = "adjustment" // manual correction by an admin
+)
+
+var errTransactionAppendOnly = errors.New("payment transactions are append-only")
+
+// PaymentTransaction is one entry of the append-only ledger of money movement.
+// Amount is signed from our point of view: payments and captures are positive,
+// refunds and fees negative; adjustments may be either. Entries are never changed,
+// corrections are booked as new entries.
+type PaymentTransaction struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Type   string    `gorm:"type:varchar(20);not null;index" json:"type"` // payment, capture, refund, fee, adjustment
+	Amount Money     `gorm:"not null" json:"amount"`
+	// Currency is always EUR for now
+	Currency string `gorm:"type:varchar(3);not null;default:'EUR'" json:"currency"`
+	Provider string `gorm:"type:varchar(20);index" json:"provider,omitempty"` // stripe, paypal; empty for manual entries
+	// ProviderRef is the provider's ID of the movement (payment intent, capture or refund ID)
+	ProviderRef string     `gorm:"type:varchar(255)" json:"provider_ref,omitempty"`
+	EventID     *uuid.UUID `gorm:"type:uuid;index" json:"event_id,omitempty"`
+	OrderID     *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	TicketID    *uuid.UUID `gorm:"type:uuid;index" json:"ticket_id,omitempty"`
+	RefundID    *uuid.UUID `gorm:"type:uuid" json:"refund_id,omitempty"`
+	TransferID  *uuid.UUID `gorm:"type:uuid" json:"transfer_id,omitempty"`
+	Description string     `gorm:"type:text" json:"description,omitempty"`
+	// IdempotencyKey makes repeated notifications of the same movement (webhook, polling, return page) book it once
+	IdempotencyKey *string    `gorm:"type:varchar(255);uniqueIndex" json:"-"`
+	CreatedBy      *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (t *PaymentTransaction) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (t *PaymentTransaction) BeforeUpdate(tx *gorm.DB) error {
+	return errTransactionAppendOnly
+}
+
+func (t *PaymentTransaction) BeforeDelete(tx *gorm.DB) error {
+	return errTransactionAppendOnly
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PickupZone is an area the pickup service drives to, priced by postal code
+type PickupZone struct {
+	ID   uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name string    `gorm:"type:varchar(100);not null" json:"name"`
+	// PostalCodes is a comma-separated list of postal codes or prefixes ("10115,104"); the longest match wins
+	PostalCodes string    `gorm:"type:text;not null;default:''" json:"postal_codes"`
+	Price       Money     `gorm:"not null;default:0" json:"price"`
+	SortOrder   int       `gorm:"not null;default:0" json:"sort_order"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (z *PickupZone) BeforeCreate(tx *gorm.DB) error {
+	if z.ID == uuid.Nil {
+		z.ID = uuid.New()
+	}
+	return nil
+}
+
+// Codes returns the postal codes and prefixes of the zone as a list
+func (z *PickupZone) Codes() []string {
+	var codes []string
+	for _, c := range strings.Split(z.PostalCodes, ",") {
+		if c = NormalizePostalCode(c); c != "" {
+			codes = append(codes, c)
+		}
+	}
+	return codes
+}
+
+// MatchLength returns the length of the longest code or prefix of the zone matching a postal code (0 = no match)
+func (z *PickupZone) MatchLength(postalCode string) int {
+	postalCode = NormalizePostalCode(postalCode)
+	best := 0
+	for _, c := range z.Codes() {
+		if strings.HasPrefix(postalCode, c) && len(c) > best {
+			best = len(c)
+		}
+	}
+	return best
+}
+
+// NormalizePostalCode removes whitespace from a postal code
+func NormalizePostalCode(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// Progress of a pickup, set by its driver
+const (
+	PickupStatusOpen     = "open"      // not started yet
+	PickupStatusEnRoute  = "en_route"  // driver is on the way; the rider gets an SMS
+	PickupStatusPickedUp = "picked_up" // rider is on board
+	PickupStatusNoShow   = "no_show"   // rider was not there
+)
+
+// PickupStatuses lists the statuses a driver can set
+var PickupStatuses = []string{PickupStatusOpen, PickupStatusEnRoute, PickupStatusPickedUp, PickupStatusNoShow}
+
+// IsPickupStatus reports whether s is a known pickup status
+func IsPickupStatus(s string) bool {
+	for _, status := range PickupStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PickupSlot is a pickup time window of an event with the seats of one vehicle
+type PickupSlot struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EventID uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	// ZoneID limits the slot to one zone; nil = the slot serves every zone
+	ZoneID   *uuid.UUID `gorm:"type:uuid;index" json:"zone_id,omitempty"`
+	Name     string     `gorm:"type:varchar(100);not null;default:''" json:"name"` // e.g. "Tour Nord"
+	StartsAt time.Time  `gorm:"not null" json:"starts_at"`
+	EndsAt   time.Time  `gorm:"not null" json:"ends_at"`
+	// Capacity is the number of seats in the vehicle
+	Capacity int `gorm:"not null;default:0" json:"capacity"`
+	// DriverID is the driver account doing the tour; DriverName and DriverPhone are printed on manifests
+	DriverID    *uuid.UUID `gorm:"type:uuid;index" json:"driver_id,omitempty"`
+	DriverName  string     `gorm:"type:varchar(255);not null;default:''" json:"driver_name"`
+	DriverPhone string     `gorm:"type:varchar(50);not null;default:''" json:"driver_phone"`
+	IsActive    bool       `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (s *PickupSlot) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// ServesZone reports whether riders of the given zone can book the slot
+func (s *PickupSlot) ServesZone(zoneID *uuid.UUID) bool {
+	return s.ZoneID == nil || (zoneID != nil && *s.ZoneID == *zoneID)
+}
+
+// BookedCount returns how many tickets with pickup currently occupy a seat of the slot
+func (s *PickupSlot) BookedCount(db *gorm.DB) int {
+	var count int64
+	occupyingTickets(db.Model(&Ticket{}), time.Now()).
+		Where("pickup_slot_id = ? AND includes_pickup = ?", s.ID, true).
+		Count(&count)
+	return int(count)
+}
+
+// Remaining returns the number of free seats of the slot
+func (s *PickupSlot) Remaining(db *gorm.DB) int {
+	remaining := s.Capacity - s.BookedCount(db)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
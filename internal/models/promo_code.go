@@ -0,0 +1,152 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PromoDiscountPercent = "percent"
+	PromoDiscountFixed   = "fixed"
+)
+
+// PromoCode is a discount code members can enter when booking a ticket.
+// The discount applies to the ticket price only (not to the pickup service).
+type PromoCode struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code         string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"code"` // stored upper case
+	Description  string    `gorm:"type:text" json:"description"`
+	DiscountType string    `gorm:"type:varchar(16);not null" json:"discount_type"` // percent, fixed
+	// DiscountPercent is the discount of percent codes, DiscountAmount the one of fixed codes; the other is 0
+	DiscountPercent BasisPoints `gorm:"not null;default:0" json:"discount_percent"`
+	DiscountAmount  Money       `gorm:"not null;default:0" json:"discount_amount"`
+	// MaxUses limits the number of tickets booked with the code; 0 = unlimited
+	MaxUses        int        `gorm:"not null;default:0" json:"max_uses"`
+	MaxUsesPerUser int        `gorm:"not null;default:0" json:"max_uses_per_user"`
+	ValidFrom      *time.Time `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time `json:"valid_until,omitempty"`
+	// EventIDs is a comma-separated list of event IDs; empty = every event
+	EventIDs string `gorm:"type:text;not null;default:''" json:"-"`
+	// AllowedGroups is a comma-separated list (guests,bubble,plus); empty = every group
+	AllowedGroups string     `gorm:"type:varchar(64);not null;default:''" json:"allowed_groups"`
+	IsActive      bool       `gorm:"default:true" json:"is_active"`
+	CreatedBy     *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (p *PromoCode) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// NormalizePromoCode returns the canonical (trimmed, upper case) form of a code
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Events returns the events the code is restricted to (empty = all)
+func (p *PromoCode) Events() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, s := range strings.Split(p.EventIDs, ",") {
+		if id, err := uuid.Parse(strings.TrimSpace(s)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// AppliesToEvent reports whether the code may be used for the given event
+func (p *PromoCode) AppliesToEvent(eventID uuid.UUID) bool {
+	ids := p.Events()
+	if len(ids) == 0 {
+		return true
+	}
+	for _, id := range ids {
+		if id == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+// Groups returns the allowed groups as a list (empty = all)
+func (p *PromoCode) Groups() []string {
+	var groups []string
+	for _, g := range strings.Split(p.AllowedGroups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// AllowsGroup reports whether members of the given group may use the code
+func (p *PromoCode) AllowsGroup(group string) bool {
+	groups := p.Groups()
+	if len(groups) == 0 {
+		return true
+	}
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidAt reports whether the code is active and inside its validity window
+func (p *PromoCode) ValidAt(at time.Time) bool {
+	if !p.IsActive {
+		return false
+	}
+	if p.ValidFrom != nil && at.Before(*p.ValidFrom) {
+		return false
+	}
+	if p.ValidUntil != nil && at.After(*p.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// Discount returns the discount on the given ticket price, rounded to cents and capped at the price
+func (p *PromoCode) Discount(price Money) Money {
+	var discount Money
+	switch p.DiscountType {
+	case PromoDiscountPercent:
+		discount = p.DiscountPercent.Of(price)
+	case PromoDiscountFixed:
+		discount = p.DiscountAmount
+	}
+	if discount > price {
+		discount = price
+	}
+	if discount < 0 {
+		discount = 0
+	}
+	return discount
+}
+
+// UsageCount returns how many tickets booked with the code currently hold a spot.
+// Cancelled and refunded tickets give their use back.
+func (p *PromoCode) UsageCount(db *gorm.DB) int {
+	var count int64
+	occupyingTickets(db.Model(&Ticket{}), time.Now()).
+		Where("promo_code_id = ?", p.ID).
+		Count(&count)
+	return int(count)
+}
+
+// UserUsageCount returns how many of those tickets belong to the given user
+func (p *PromoCode) UserUsageCount(db *gorm.DB, userID uuid.UUID) int {
+	var count int64
+	occupyingTickets(db.Model(&Ticket{}), time.Now()).
+		Where("promo_code_id = ? AND user_id = ?", p.ID, userID).
+		Count(&count)
+	return int(count)
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reconciliation mismatch types
+const (
+	ReconciliationPaidNotConfirmed    = "paid_not_confirmed"     // paid at the provider, tickets still pending locally
+	ReconciliationPaidButCancelled    = "paid_but_cancelled"     // paid at the provider, tickets cancelled locally
+	ReconciliationNotPaidAtProvider   = "not_paid_at_provider"   // tickets paid locally, no payment at the provider
+	ReconciliationAmountMismatch      = "amount_mismatch"        // provider captured another amount than the order total
+	ReconciliationRefundNotRecorded   = "refund_not_recorded"    // provider refunded more than we booked
+	ReconciliationRefundNotAtProvider = "refund_not_at_provider" // we booked refunds the provider does not show
+	ReconciliationCheckFailed         = "check_failed"           // provider lookup failed
+)
+
+// ReconciliationItem is one mismatch between a checkout and its payment at the provider
+type ReconciliationItem struct {
+	Type             string      `json:"type"`
+	Provider         string      `json:"provider"`
+	CheckoutID       string      `json:"checkout_id"` // Stripe session / PayPal order
+	PaymentRef       string      `json:"payment_ref,omitempty"`
+	OrderID          *uuid.UUID  `json:"order_id,omitempty"`
+	TicketIDs        []uuid.UUID `json:"ticket_ids"`
+	LocalStatus      string      `json:"local_status"`
+	ProviderStatus   string      `json:"provider_status,omitempty"`
+	LocalAmount      Money       `json:"local_amount"`
+	ProviderAmount   Money       `json:"provider_amount"`
+	LocalRefunded    Money       `json:"local_refunded"`
+	ProviderRefunded Money       `json:"provider_refunded"`
+	Fixed            bool        `json:"fixed"`
+	FixError         string      `json:"fix_error,omitempty"`
+	Note             string      `json:"note,omitempty"`
+}
+
+// ReconciliationRun is a comparison of the tickets created in a period with their payments at Stripe and PayPal
+type ReconciliationRun struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PeriodFrom  time.Time  `gorm:"not null" json:"period_from"`
+	PeriodTo    time.Time  `gorm:"not null" json:"period_to"`
+	Trigger     string     `gorm:"type:varchar(20);not null" json:"trigger"` // nightly, admin
+	TriggeredBy *uuid.UUID `gorm:"type:uuid" json:"triggered_by,omitempty"`
+	AutoFix     bool       `gorm:"not null;default:false" json:"auto_fix"`
+	Checked     int        `gorm:"not null;default:0" json:"checked"` // checkouts compared
+	Mismatches  int        `gorm:"not null;default:0" json:"mismatches"`
+	Fixed       int        `gorm:"not null;default:0" json:"fixed"`
+	// Items lists the mismatches (omitted in run lists)
+	Items      []ReconciliationItem `gorm:"type:jsonb;serializer:json" json:"items,omitempty"`
+	Error      string               `gorm:"type:text" json:"error,omitempty"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+}
+
+func (r *ReconciliationRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+
+	RefundReasonUserCancellation  = "user_cancellation"
+	RefundReasonAdminCancellation = "admin_cancellation"
+	RefundReasonAdminRefund       = "admin_refund"
+	RefundReasonEventCancelled    = "event_cancelled"
+	RefundReasonOrderItem         = "order_item"
+	RefundReasonTransferPrice     = "transfer_price_difference"
+	RefundReasonTransferCharge    = "transfer_charge"
+	RefundReasonProviderRefund    = "provider_refund"  // refunded at the provider (e.g. in the PayPal dashboard)
+	RefundReasonPaymentReversed   = "payment_reversed" // payment reversed by the provider (e.g. buyer complaint)
+	RefundReasonChargeback        = "chargeback"       // dispute lost, the bank took the money back
+	RefundReasonSeatUnavailable   = "seat_unavailable" // paid after the seat hold ran out and the event had filled up
+
+	RefundInitiatorUser   = "user"
+	RefundInitiatorAdmin  = "admin"
+	RefundInitiatorSystem = "system"
+	// RefundInitiatorProvider marks refunds the provider carried out on its own and reported to us
+	RefundInitiatorProvider = "provider"
+)
+
+// Refund is one attempt to give money back through a payment provider.
+// It is written before the provider is called and updated with the outcome;
+// a failed refund is retried with a new record pointing to it via RetryOfID.
+type Refund struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID     *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	TicketID    *uuid.UUID `gorm:"type:uuid;index" json:"ticket_id,omitempty"`
+	OrderItemID *uuid.UUID `gorm:"type:uuid" json:"order_item_id,omitempty"`
+	TransferID  *uuid.UUID `gorm:"type:uuid" json:"transfer_id,omitempty"`
+	Amount      Money      `gorm:"not null" json:"amount"`
+	Provider    string     `gorm:"type:varchar(20)" json:"provider"` // stripe, paypal
+	// ProviderRef is the payment being refunded (Stripe payment intent / PayPal capture)
+	ProviderRef string `gorm:"type:varchar(255)" json:"provider_ref"`
+	// ProviderRefundID is the refund ID returned by the provider
+	ProviderRefundID string     `gorm:"type:varchar(255)" json:"provider_refund_id,omitempty"`
+	Status           string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"` // pending, succeeded, failed
+	Reason           string     `gorm:"type:varchar(50);not null" json:"reason"`
+	Initiator        string     `gorm:"type:varchar(10);not null" json:"initiator"` // user, admin, system, provider
+	InitiatedBy      *uuid.UUID `gorm:"type:uuid" json:"initiated_by,omitempty"`
+	Error            string     `gorm:"type:text" json:"error,omitempty"`
+	RetryOfID        *uuid.UUID `gorm:"type:uuid;index" json:"retry_of_id,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func (r *Refund) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TaxProductTypes are the product types with their own VAT rate: the order item kinds
+var TaxProductTypes = []string{OrderItemTicket, OrderItemPickup, OrderItemAddon}
+
+// TaxRates maps a product type to its VAT rate in percent, e.g. 7% for tickets and 19% for the pickup service.
+// Prices are gross, the VAT is contained in them. An event's rates override the default rates of the
+// product types they list; a nil map means the default rates apply.
+type TaxRates map[string]int
+
+// ParseTaxRates parses "type:percent" pairs separated by commas, e.g. "ticket:7,pickup:19"
+func ParseTaxRates(s string) (TaxRates, error) {
+	rates := TaxRates{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, percent, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid tax rate %q, expected type:percent", part)
+		}
+		p, err := strconv.Atoi(strings.TrimSpace(percent))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tax rate %q, expected type:percent", part)
+		}
+		rates[strings.TrimSpace(kind)] = p
+	}
+	return rates.Normalize()
+}
+
+// Normalize validates the product types and rates
+func (r TaxRates) Normalize() (TaxRates, error) {
+	out := TaxRates{}
+	for kind, rate := range r {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if !isTaxProductType(kind) {
+			return nil, fmt.Errorf("unknown product type %q, expected %s", kind, strings.Join(TaxProductTypes, ", "))
+		}
+		if rate < 0 || rate > 100 {
+			return nil, fmt.Errorf("tax rate for %s must be between 0 and 100", kind)
+		}
+		out[kind] = rate
+	}
+	return out, nil
+}
+
+func isTaxProductType(kind string) bool {
+	for _, t := range TaxProductTypes {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitGross returns the net amount and the VAT contained in a gross amount
+func SplitGross(gross Money, rate int) (net, tax Money) {
+	net = gross.MulDiv(100, int64(100+rate))
+	return net, gross - net
+}
+
+// Value stores the rates as JSON (NULL for no rates)
+func (r TaxRates) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[string]int(r))
+	return string(b), err
+}
+
+// Scan reads the rates from JSON
+func (r *TaxRates) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported tax rates value %T", value)
+	}
+	var rates map[string]int
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return err
+	}
+	*r = rates
+	return nil
+}
@@ -11,12 +11,38 @@ type Ticket struct {
 	ID                    uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	UserID                uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
 	EventID               uuid.UUID  `gorm:"type:uuid;not null" json:"event_id"`
-	Status                string     `gorm:"not null;default:'pending'" json:"status"` // pending, paid, cancelled, refunded
-	Price                 float64    `gorm:"not null" json:"price"`
+	Status                TicketStatus `gorm:"type:varchar(30);not null;default:'pending'" json:"status"` // see ticket_status.go
+	Price                 Money      `gorm:"not null" json:"price"`
 	IncludesPickup        bool       `gorm:"default:false" json:"includes_pickup"`
-	PickupPrice           float64    `json:"pickup_price,omitempty"`
+	PickupPrice           Money      `json:"pickup_price,omitempty"`
 	PickupAddress         string     `json:"pickup_address,omitempty"`
-	TotalAmount           float64    `gorm:"not null" json:"total_amount"`
+	// Pickup zone (found by postal code, name kept for manifests) and booked time slot
+	PickupPostalCode      string     `gorm:"type:varchar(10);not null;default:''" json:"pickup_postal_code,omitempty"`
+	PickupZoneID          *uuid.UUID `gorm:"type:uuid;index" json:"pickup_zone_id,omitempty"`
+	PickupZoneName        string     `gorm:"type:varchar(100);not null;default:''" json:"pickup_zone_name,omitempty"`
+	PickupSlotID          *uuid.UUID `gorm:"type:uuid;index" json:"pickup_slot_id,omitempty"`
+	// Driver of the pickup (overrides the driver of the time slot) and its progress, see PickupStatus*
+	PickupDriverID        *uuid.UUID `gorm:"type:uuid;index" json:"pickup_driver_id,omitempty"`
+	PickupStatus          string     `gorm:"type:varchar(20);not null;default:''" json:"pickup_status,omitempty"`
+	PickupStatusAt        *time.Time `json:"pickup_status_at,omitempty"`
+	PickupNotifiedAt      *time.Time `json:"-"` // rider was told by SMS that the driver is on the way
+	TotalAmount           Money      `gorm:"not null" json:"total_amount"`
+
+	// Order the ticket was bought with; HolderName is set for tickets bought for a named companion
+	OrderID    *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	HolderName string     `gorm:"type:varchar(255);not null;default:''" json:"holder_name,omitempty"`
+
+	// Ticket type the ticket was bought as (name kept for exports and receipts)
+	TicketTypeID   *uuid.UUID `gorm:"type:uuid;index" json:"ticket_type_id,omitempty"`
+	TicketTypeName string     `gorm:"type:varchar(100)" json:"ticket_type_name,omitempty"`
+
+	// Promo code discount on the ticket price; TotalAmount is what is actually charged
+	PromoCodeID    *uuid.UUID `gorm:"type:uuid;index" json:"promo_code_id,omitempty"`
+	PromoCode      string     `gorm:"type:varchar(64)" json:"promo_code,omitempty"`
+	DiscountAmount Money      `gorm:"not null;default:0" json:"discount_amount,omitempty"`
+
+	// Seat hold: a pending ticket keeps its seat until HoldExpiresAt
+	HoldExpiresAt *time.Time `gorm:"index" json:"hold_expires_at,omitempty"`
 
 	// Payment Provider (stripe or paypal)
 	PaymentProvider string `gorm:"type:varchar(20);default:'stripe'" json:"payment_provider"`
@@ -29,7 +55,20 @@ type Ticket struct {
 	PayPalOrderID   string `gorm:"type:varchar(255)" json:"-"`
 	PayPalCaptureID string `gorm:"type:varchar(255)" json:"-"`
 
-	RefundedAmount  float64    `json:"refunded_amount,omitempty"`
+	// Door check-in
+	CheckedInAt     *time.Time `json:"checked_in_at,omitempty"`
+	CheckedInBy     *uuid.UUID `gorm:"type:uuid" json:"checked_in_by,omitempty"`
+	CheckedInDevice string     `gorm:"type:varchar(100)" json:"checked_in_device,omitempty"` // scanner device for offline check-ins
+	// Bumped whenever the ticket is reissued (e.g. transferred); older QR codes are rejected
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
+
+	// Chargeback against the payment of the ticket (latest one); the ticket is disputed while it is open
+	DisputeID *uuid.UUID `gorm:"type:uuid;index" json:"dispute_id,omitempty"`
+
+	// Set once the confirmation email went out, so each ticket is confirmed once (webhook, retries, replays)
+	ConfirmationSentAt *time.Time `json:"-"`
+
+	RefundedAmount  Money      `json:"refunded_amount,omitempty"`
 	RefundedAt      *time.Time `json:"refunded_at,omitempty"`
 	CancelledAt     *time.Time `json:"cancelled_at,omitempty"`
 	CreatedAt       time.Time  `json:"created_at"`
@@ -47,34 +86,33 @@ func (t *Ticket) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// CalculateTotalAmount calculates the total amount including pickup service
-func (t *Ticket) CalculateTotalAmount() {
-	t.TotalAmount = t.Price
-	if t.IncludesPickup {
-		t.TotalAmount += t.PickupPrice
-	}
+// HoldExpired reports whether the seat hold of a pending ticket has run out
+func (t *Ticket) HoldExpired() bool {
+	return t.Status == "pending" && t.HoldExpiresAt != nil && time.Now().After(*t.HoldExpiresAt)
 }
 
-// CanBeCancelled checks if the ticket can be cancelled (7 days before event)
-func (t *Ticket) CanBeCancelled() bool {
-	if t.Status != "paid" {
-		return false
+// AttendeeName returns the name of the person using the ticket: the named companion, or the buyer
+func (t *Ticket) AttendeeName() string {
+	if t.HolderName != "" {
+		return t.HolderName
 	}
+	return t.User.Name
+}
 
-	// Load event if not loaded
-	if t.Event.ID == uuid.Nil {
-		return false
-	}
+// RefundableAmount returns the part of the paid amount not yet refunded
+func (t *Ticket) RefundableAmount() Money {
+	return t.TotalAmount - t.RefundedAmount
+}
 
-	// Check if event is at least 7 days away
-	daysUntilEvent := time.Until(t.Event.DateFrom).Hours() / 24
-	return daysUntilEvent >= 7
+// NetPrice returns the ticket price after the promo discount
+func (t *Ticket) NetPrice() Money {
+	return t.Price - t.DiscountAmount
 }
 
-// GetRefundAmount calculates the refund amount (50% if cancelled by user)
-func (t *Ticket) GetRefundAmount(fullRefund bool) float64 {
-	if fullRefund {
-		return t.TotalAmount
+// CalculateTotalAmount calculates the total amount including pickup service and discount
+func (t *Ticket) CalculateTotalAmount() {
+	t.TotalAmount = t.NetPrice()
+	if t.IncludesPickup {
+		t.TotalAmount += t.PickupPrice
 	}
-	return t.TotalAmount * 0.5
 }
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TicketStatus is the lifecycle state of a ticket. Statuses only change through TransitionTickets
+// (or Ticket.TransitionTo), which enforces the allowed transitions and records them in the status history.
+type TicketStatus string
+
+const (
+	TicketPending             TicketStatus = "pending"              // checkout started, seat held
+	TicketPendingCancellation TicketStatus = "pending_cancellation" // cancelled while unpaid, payment may still arrive (grace period)
+	TicketPaid                TicketStatus = "paid"
+	TicketDisputed            TicketStatus = "disputed" // chargeback open
+	TicketCancelled           TicketStatus = "cancelled"
+	TicketRefunded            TicketStatus = "refunded"
+)
+
+// ticketTransitions lists the statuses each status may change to; "" is a ticket being created
+var ticketTransitions = map[TicketStatus][]TicketStatus{
+	"":                        {TicketPending, TicketPaid},
+	TicketPending:             {TicketPaid, TicketPendingCancellation, TicketCancelled},
+	TicketPendingCancellation: {TicketPaid, TicketCancelled},
+	TicketPaid:                {TicketDisputed, TicketCancelled, TicketRefunded},
+	TicketDisputed:            {TicketPaid, TicketRefunded},
+	TicketCancelled:           {TicketPaid}, // payment completed after the checkout was cancelled
+}
+
+// ErrIllegalTicketTransition is returned for a status change the state machine does not allow
+var ErrIllegalTicketTransition = errors.New("illegal ticket status transition")
+
+// Valid reports whether s is a known status
+func (s TicketStatus) Valid() bool {
+	switch s {
+	case TicketPending, TicketPendingCancellation, TicketPaid, TicketDisputed, TicketCancelled, TicketRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether a ticket in status s may change to status to
+func (s TicketStatus) CanTransitionTo(to TicketStatus) bool {
+	for _, allowed := range ticketTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Actors of a status change
+const (
+	StatusActorUser     = "user"
+	StatusActorAdmin    = "admin"
+	StatusActorSystem   = "system"   // background jobs and cleanups
+	StatusActorProvider = "provider" // payment provider webhooks and polling
+)
+
+// StatusChange describes who changed the status of tickets and why
+type StatusChange struct {
+	Actor       string
+	ActorID     *uuid.UUID // user/admin who triggered it
+	Reason      string
+	ProviderRef string // payment, refund or dispute reference at the provider
+}
+
+// TicketStatusHistory is an append-only log of the status changes of a ticket
+type TicketStatusHistory struct {
+	ID          uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TicketID    uuid.UUID    `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	FromStatus  TicketStatus `gorm:"type:varchar(30);not null;default:''" json:"from_status"` // empty when the ticket was created
+	ToStatus    TicketStatus `gorm:"type:varchar(30);not null" json:"to_status"`
+	Actor       string       `gorm:"type:varchar(20);not null" json:"actor"`
+	ActorID     *uuid.UUID   `gorm:"type:uuid" json:"actor_id,omitempty"`
+	Reason      string       `gorm:"type:varchar(100)" json:"reason,omitempty"`
+	ProviderRef string       `gorm:"type:varchar(255)" json:"provider_ref,omitempty"`
+	CreatedAt   time.Time    `gorm:"index" json:"created_at"`
+}
+
+func (TicketStatusHistory) TableName() string {
+	return "ticket_status_history"
+}
+
+func (h *TicketStatusHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
+
+func newStatusHistory(ticketID uuid.UUID, from, to TicketStatus, change StatusChange) TicketStatusHistory {
+	actor := change.Actor
+	if actor == "" {
+		actor = StatusActorSystem
+	}
+	return TicketStatusHistory{
+		TicketID:    ticketID,
+		FromStatus:  from,
+		ToStatus:    to,
+		Actor:       actor,
+		ActorID:     change.ActorID,
+		Reason:      change.Reason,
+		ProviderRef: change.ProviderRef,
+	}
+}
+
+// RecordTicketCreated writes the first status history entry of a newly created ticket
+func RecordTicketCreated(tx *gorm.DB, t *Ticket, change StatusChange) error {
+	if !TicketStatus("").CanTransitionTo(t.Status) {
+		return fmt.Errorf("%w: ticket cannot be created as %s", ErrIllegalTicketTransition, t.Status)
+	}
+	entry := newStatusHistory(t.ID, "", t.Status, change)
+	return tx.Create(&entry).Error
+}
+
+// TransitionTickets changes the tickets selected by the scopes to status to, along with the other columns
+// in updates, and records each change in the status history. The tickets are locked first; tickets already
+// in status to are left alone. If any selected ticket may not make the transition nothing is changed and
+// ErrIllegalTicketTransition is returned, so scopes should filter on the statuses a caller expects.
+// Returns the number of changed tickets.
+func TransitionTickets(db *gorm.DB, to TicketStatus, change StatusChange, updates map[string]interface{}, scopes ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	var changed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tickets []Ticket
+		if err := tx.Model(&Ticket{}).Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(scopes...).
+			Select("id", "status").Find(&tickets).Error; err != nil {
+			return err
+		}
+
+		var ids []uuid.UUID
+		var history []TicketStatusHistory
+		for _, t := range tickets {
+			if t.Status == to {
+				continue
+			}
+			if !t.Status.CanTransitionTo(to) {
+				return fmt.Errorf("%w: ticket %s from %s to %s", ErrIllegalTicketTransition, t.ID, t.Status, to)
+			}
+			ids = append(ids, t.ID)
+			history = append(history, newStatusHistory(t.ID, t.Status, to, change))
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		values := map[string]interface{}{}
+		for k, v := range updates {
+			values[k] = v
+		}
+		values["status"] = to
+		res := tx.Model(&Ticket{}).Where("id IN ?", ids).Updates(values)
+		if res.Error != nil {
+			return res.Error
+		}
+		changed = res.RowsAffected
+		return tx.Create(&history).Error
+	})
+	return changed, err
+}
+
+// TransitionTo changes the status of the ticket as TransitionTickets does and updates t on success.
+// A ticket already in status to is left alone.
+func (t *Ticket) TransitionTo(db *gorm.DB, to TicketStatus, change StatusChange, updates map[string]interface{}) error {
+	if _, err := TransitionTickets(db, to, change, updates, WithTicketID(t.ID)); err != nil {
+		return err
+	}
+	t.Status = to
+	return nil
+}
+
+// WithTicketStatus selects the tickets in one of the given statuses
+func WithTicketStatus(statuses ...TicketStatus) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("status IN ?", statuses)
+	}
+}
+
+// WithTicketID selects a single ticket
+func WithTicketID(id uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", id)
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	TransferStatusPending         = "pending"
+	TransferStatusAwaitingPayment = "awaiting_payment"
+	TransferStatusCompleted       = "completed"
+	TransferStatusDeclined        = "declined"
+	TransferStatusCancelled       = "cancelled"
+)
+
+// TicketTransfer hands a paid ticket from its owner to another member.
+// The recipient accepts; a higher group price is charged to the recipient,
+// a lower one is refunded to the original payment.
+type TicketTransfer struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TicketID   uuid.UUID `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	FromUserID uuid.UUID `gorm:"type:uuid;not null;index" json:"from_user_id"`
+	ToUserID   uuid.UUID `gorm:"type:uuid;not null;index" json:"to_user_id"`
+	Status     string    `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"` // pending, awaiting_payment, completed, declined, cancelled
+
+	// Price difference (recipient price - current ticket price); > 0 is charged, < 0 refunded
+	OldPrice        Money `json:"old_price"`
+	NewPrice        Money `json:"new_price"`
+	PriceDifference Money `json:"price_difference"`
+	// Ticket type the recipient gets (same type if their group may hold it)
+	NewTicketTypeID *uuid.UUID `gorm:"type:uuid" json:"new_ticket_type_id,omitempty"`
+
+	// Charge of a positive difference
+	ChargeProvider   string `gorm:"type:varchar(20)" json:"charge_provider,omitempty"`
+	ChargeReference  string `gorm:"type:varchar(255)" json:"-"` // Stripe session / PayPal order
+	ChargePaymentRef string `gorm:"type:varchar(255)" json:"-"` // Stripe payment intent / PayPal capture
+
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relations
+	Ticket   Ticket `gorm:"foreignKey:TicketID" json:"ticket,omitempty"`
+	FromUser User   `gorm:"foreignKey:FromUserID" json:"from_user,omitempty"`
+	ToUser   User   `gorm:"foreignKey:ToUserID" json:"to_user,omitempty"`
+}
+
+func (t *TicketTransfer) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsOpen reports whether the transfer can still be accepted, paid or cancelled
+func (t *TicketTransfer) IsOpen() bool {
+	return t.Status == TransferStatusPending || t.Status == TransferStatusAwaitingPayment
+}
+
+// TicketHistory is an append-only log of what happened to a ticket
+type TicketHistory struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TicketID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	Action    string     `gorm:"type:varchar(50);not null" json:"action"`
+	ActorID   *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"` // user/admin who triggered it; nil for system
+	Details   string     `gorm:"type:text" json:"details,omitempty"`  // JSON
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (TicketHistory) TableName() string {
+	return "ticket_history"
+}
+
+func (h *TicketHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
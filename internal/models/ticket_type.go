@@ -0,0 +1,98 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TicketType is a purchasable ticket category of an event (e.g. "Early Bird", "Regular", "Soli", "Crew")
+// with its own price, quota, sales window and allowed user groups.
+type TicketType struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EventID     uuid.UUID `gorm:"type:uuid;not null;index" json:"event_id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Price       Money     `gorm:"not null;default:0" json:"price"`
+	// Quota limits how many tickets of this type can be sold; 0 = only limited by event capacity
+	Quota      int        `gorm:"not null;default:0" json:"quota"`
+	SalesStart *time.Time `json:"sales_start,omitempty"`
+	SalesEnd   *time.Time `json:"sales_end,omitempty"`
+	// AllowedGroups is a comma-separated list (guests,bubble,plus); empty = every group the event allows
+	AllowedGroups string `gorm:"type:varchar(64);not null;default:''" json:"allowed_groups"`
+	SortOrder     int    `gorm:"not null;default:0" json:"sort_order"`
+	IsActive      bool   `gorm:"default:true" json:"is_active"`
+	// LegacyGroup marks types migrated from the former per-group event prices
+	LegacyGroup string    `gorm:"type:varchar(16)" json:"legacy_group,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (t *TicketType) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// Groups returns the allowed groups as a list (empty = all)
+func (t *TicketType) Groups() []string {
+	var groups []string
+	for _, g := range strings.Split(t.AllowedGroups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// AllowsGroup reports whether members of the given group may buy this type
+func (t *TicketType) AllowsGroup(group string) bool {
+	groups := t.Groups()
+	if len(groups) == 0 {
+		return true
+	}
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// OnSale reports whether the type is active and inside its sales window
+func (t *TicketType) OnSale(at time.Time) bool {
+	if !t.IsActive {
+		return false
+	}
+	if t.SalesStart != nil && at.Before(*t.SalesStart) {
+		return false
+	}
+	if t.SalesEnd != nil && at.After(*t.SalesEnd) {
+		return false
+	}
+	return true
+}
+
+// BookedCount returns how many tickets of this type currently occupy a spot
+func (t *TicketType) BookedCount(db *gorm.DB) int {
+	var count int64
+	occupyingTickets(db.Model(&Ticket{}), time.Now()).
+		Where("ticket_type_id = ?", t.ID).
+		Count(&count)
+	return int(count)
+}
+
+// Remaining returns the number of tickets of this type still for sale, or -1 without quota
+func (t *TicketType) Remaining(db *gorm.DB) int {
+	if t.Quota <= 0 {
+		return -1
+	}
+	remaining := t.Quota - t.BookedCount(db)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -19,6 +19,7 @@ type User struct {
 	Drink2             string    `json:"drink2"`
 	Drink3             string    `json:"drink3"`
 	IsAdmin            bool      `gorm:"default:false" json:"is_admin"`
+	IsDriver           bool      `gorm:"default:false" json:"is_driver"` // drives the pickup service, see /driver routes
 	IsActive           bool      `gorm:"default:true" json:"is_active"`
 	RegisteredWithCode string    `json:"registered_with_code,omitempty"`
 	Group              string    `gorm:"type:varchar(20);not null;default:'guests'" json:"group"`
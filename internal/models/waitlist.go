@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	WaitlistStatusWaiting = "waiting"
+	WaitlistStatusOffered = "offered"
+	WaitlistStatusClaimed = "claimed"
+	WaitlistStatusExpired = "expired"
+	WaitlistStatusRemoved = "removed"
+)
+
+// WaitlistEntry is a user's place in the queue of a fully booked event.
+// When a spot frees up the next waiting entry receives a time-limited offer;
+// while the offer is open the spot is held for that user.
+type WaitlistEntry struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EventID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"event_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Position       int        `gorm:"not null" json:"position"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'waiting';index" json:"status"` // waiting, offered, claimed, expired, removed
+	OfferToken     *string    `gorm:"uniqueIndex" json:"-"`
+	OfferedAt      *time.Time `json:"offered_at,omitempty"`
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relations
+	User  User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Event Event `gorm:"foreignKey:EventID" json:"event,omitempty"`
+}
+
+func (w *WaitlistEntry) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive reports whether the entry is still in the queue (waiting or holding an offer)
+func (w *WaitlistEntry) IsActive() bool {
+	return w.Status == WaitlistStatusWaiting || w.Status == WaitlistStatusOffered
+}
+
+// OfferExpired reports whether an open offer ran out without being claimed
+func (w *WaitlistEntry) OfferExpired() bool {
+	return w.Status == WaitlistStatusOffered && w.OfferExpiresAt != nil && time.Now().After(*w.OfferExpiresAt)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookStatusProcessing = "processing"
+	WebhookStatusProcessed  = "processed"
+	WebhookStatusFailed     = "failed"
+	WebhookStatusRejected   = "rejected" // verification failed, never processed
+
+	WebhookVerificationVerified   = "verified"
+	WebhookVerificationFailed     = "failed"
+	WebhookVerificationUnverified = "unverified" // provider has no verification configured
+)
+
+// WebhookEvent is an incoming payment provider webhook stored with its raw body before it is processed.
+// The provider event ID is unique per provider (rejected events excepted, their ID cannot be trusted),
+// so redeliveries are recognised and skipped; failed events can be replayed from the stored body.
+type WebhookEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Provider  string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_webhook_events_provider_event,where:status <> 'rejected'" json:"provider"` // stripe, paypal
+	EventID   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_webhook_events_provider_event,where:status <> 'rejected'" json:"event_id"`
+	EventType string    `gorm:"type:varchar(100);index" json:"event_type"`
+	// Payload is the raw request body as received
+	Payload           string `gorm:"type:text;not null" json:"payload,omitempty"`
+	Verification      string `gorm:"type:varchar(20);not null" json:"verification"` // verified, failed, unverified
+	VerificationError string `gorm:"type:text" json:"verification_error,omitempty"`
+	Status            string `gorm:"type:varchar(20);not null;index" json:"status"` // processing, processed, failed, rejected
+	Error             string `gorm:"type:text" json:"error,omitempty"`
+	// Attempts counts processing runs (first delivery, provider retries of failed events, replays)
+	Attempts     int        `gorm:"not null;default:0" json:"attempts"`
+	Duplicates   int        `gorm:"not null;default:0" json:"duplicates"` // skipped redeliveries
+	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
+	LastReplayBy *uuid.UUID `gorm:"type:uuid" json:"last_replay_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (e *WebhookEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
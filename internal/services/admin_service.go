@@ -2,7 +2,6 @@ package services
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/synesthesie/backend/internal/config"
@@ -123,7 +122,7 @@ func (s *AdminService) ResetUserPassword(userID uuid.UUID) (string, error) {
 }
 
 // GetPickupServicePrice retrieves the current pickup service price
-func (s *AdminService) GetPickupServicePrice() (float64, error) {
+func (s *AdminService) GetPickupServicePrice() (models.Money, error) {
 	var setting models.SystemSetting
 	err := s.db.Where("key = ?", "pickup_service_price").First(&setting).Error
 
@@ -132,31 +131,26 @@ func (s *AdminService) GetPickupServicePrice() (float64, error) {
 			// Create default setting
 			setting = models.SystemSetting{
 				Key:   "pickup_service_price",
-				Value: "10.00",
+				Value: defaultPickupServicePrice.String(),
 			}
 			if err := s.db.Create(&setting).Error; err != nil {
 				return 0, err
 			}
-			return 10.00, nil
+			return defaultPickupServicePrice, nil
 		}
 		return 0, err
 	}
 
-	var price float64
-	if _, err := fmt.Sscanf(setting.Value, "%f", &price); err != nil {
-		return 0, err
-	}
-
-	return price, nil
+	return models.ParseMoney(setting.Value)
 }
 
 // UpdatePickupServicePrice updates the pickup service price
-func (s *AdminService) UpdatePickupServicePrice(price float64) error {
+func (s *AdminService) UpdatePickupServicePrice(price models.Money) error {
 	if price < 0 {
 		return errors.New("price cannot be negative")
 	}
 
-	value := fmt.Sprintf("%.2f", price)
+	value := price.String()
 
 	// Update or create setting
 	var setting models.SystemSetting
@@ -204,12 +198,19 @@ func (s *AdminService) GetDashboardStats() (map[string]interface{}, error) {
 	stats["tickets_sold"] = ticketCount
 
 	// Total revenue
-	var totalRevenue float64
-	if err := s.db.Model(&models.Ticket{}).Where("status = ?", "paid").Select("COALESCE(SUM(total_amount), 0)").Scan(&totalRevenue).Error; err != nil {
+	var totalRevenue models.Money
+	if err := s.db.Model(&models.Ticket{}).Where("status = ?", "paid").Select("COALESCE(SUM(total_amount), 0)::bigint").Scan(&totalRevenue).Error; err != nil {
 		return nil, err
 	}
 	stats["total_revenue"] = totalRevenue
 
+	// Net revenue from the payment ledger (payments - refunds - fees +/- adjustments)
+	var netRevenue models.Money
+	if err := s.db.Model(&models.PaymentTransaction{}).Select("COALESCE(SUM(amount), 0)::bigint").Scan(&netRevenue).Error; err != nil {
+		return nil, err
+	}
+	stats["net_revenue"] = netRevenue
+
 	// Unused invite codes (new status)
 	var unusedInvites int64
 	if err := s.db.Model(&models.InviteCode{}).Where("status = ?", models.InviteStatusNew).Count(&unusedInvites).Error; err != nil {
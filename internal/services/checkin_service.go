@@ -0,0 +1,396 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"golang.org/x/crypto/nacl/box"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckInService validates scanned ticket QR codes at the door and records check-ins
+type CheckInService struct {
+	db        *gorm.DB
+	qrService *QRService
+}
+
+func NewCheckInService(db *gorm.DB, qrService *QRService) *CheckInService {
+	return &CheckInService{db: db, qrService: qrService}
+}
+
+// AlreadyCheckedInError is returned when a ticket is scanned a second time
+type AlreadyCheckedInError struct {
+	CheckedInAt   time.Time
+	CheckedInBy   uuid.UUID
+	CheckedInName string
+}
+
+func (e *AlreadyCheckedInError) Error() string {
+	return fmt.Sprintf("ticket already checked in at %s by %s", e.CheckedInAt.Format(time.RFC3339), e.CheckedInName)
+}
+
+// CheckInStats holds the live door counts of an event
+type CheckInStats struct {
+	EventID     uuid.UUID `json:"event_id"`
+	Paid        int64     `json:"paid"`
+	CheckedIn   int64     `json:"checked_in"`
+	Outstanding int64     `json:"outstanding"`
+}
+
+// Validate verifies a scanned payload without checking the ticket in.
+// eventID is optional and restricts the scan to one event.
+func (s *CheckInService) Validate(payload string, eventID *uuid.UUID) (*models.Ticket, error) {
+	claims, err := s.qrService.VerifyTicketToken(payload, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	ticketID := claims.TicketID
+
+	var ticket models.Ticket
+	if err := s.db.Preload("User").Preload("Event").First(&ticket, "id = ?", ticketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("ticket not found")
+		}
+		return nil, err
+	}
+	if err := s.checkTicket(&ticket, eventID, claims.Version); err != nil {
+		return &ticket, err
+	}
+	if ticket.CheckedInAt != nil {
+		return &ticket, s.alreadyCheckedIn(&ticket)
+	}
+	return &ticket, nil
+}
+
+// CheckIn verifies a scanned payload and marks the ticket as checked in by staffID.
+// A second scan returns *AlreadyCheckedInError with who checked the ticket in and when.
+func (s *CheckInService) CheckIn(payload string, eventID *uuid.UUID, staffID uuid.UUID) (*models.Ticket, error) {
+	claims, err := s.qrService.VerifyTicketToken(payload, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	ticketID := claims.TicketID
+
+	var ticket models.Ticket
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the ticket so two scanners cannot both check it in
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, "id = ?", ticketID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("ticket not found")
+			}
+			return err
+		}
+		if err := s.checkTicket(&ticket, eventID, claims.Version); err != nil {
+			return err
+		}
+		if ticket.CheckedInAt != nil {
+			return s.alreadyCheckedIn(&ticket)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&ticket).Updates(map[string]interface{}{
+			"checked_in_at": now,
+			"checked_in_by": staffID,
+		}).Error; err != nil {
+			return err
+		}
+		ticket.CheckedInAt = &now
+		ticket.CheckedInBy = &staffID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload with relations for the scanner display
+	if err := s.db.Preload("User").Preload("Event").First(&ticket, "id = ?", ticketID).Error; err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// GetEventStats returns paid and checked-in ticket counts for an event
+func (s *CheckInService) GetEventStats(eventID uuid.UUID) (*CheckInStats, error) {
+	stats := &CheckInStats{EventID: eventID}
+	if err := s.db.Model(&models.Ticket{}).
+		Where("event_id = ? AND status = ?", eventID, "paid").
+		Count(&stats.Paid).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.Ticket{}).
+		Where("event_id = ? AND status = ? AND checked_in_at IS NOT NULL", eventID, "paid").
+		Count(&stats.CheckedIn).Error; err != nil {
+		return nil, err
+	}
+	stats.Outstanding = stats.Paid - stats.CheckedIn
+	return stats, nil
+}
+
+// checkTicket ensures the ticket is valid for entry
+func (s *CheckInService) checkTicket(ticket *models.Ticket, eventID *uuid.UUID, tokenVersion int) error {
+	if eventID != nil && ticket.EventID != *eventID {
+		return errors.New("ticket is for a different event")
+	}
+	if tokenVersion != ticket.TokenVersion {
+		return errors.New("qr code was reissued")
+	}
+	if ticket.Status == "disputed" {
+		// Open chargeback: the money may be gone, entry needs the dispute resolved
+		return errors.New("ticket payment is disputed")
+	}
+	if ticket.Status != "paid" {
+		return fmt.Errorf("ticket is not valid (status: %s)", ticket.Status)
+	}
+	return nil
+}
+
+func (s *CheckInService) alreadyCheckedIn(ticket *models.Ticket) error {
+	e := &AlreadyCheckedInError{CheckedInAt: *ticket.CheckedInAt}
+	if ticket.CheckedInBy != nil {
+		e.CheckedInBy = *ticket.CheckedInBy
+		var staff models.User
+		if err := s.db.Select("id", "name", "username").First(&staff, "id = ?", *ticket.CheckedInBy).Error; err == nil {
+			e.CheckedInName = staff.Name
+			if e.CheckedInName == "" {
+				e.CheckedInName = staff.Username
+			}
+		}
+	}
+	return e
+}
+
+// BundleAttendee is one entry of the offline attendee list
+type BundleAttendee struct {
+	TicketID       uuid.UUID  `json:"ticket_id"`
+	Name           string     `json:"name"`
+	Group          string     `json:"group"`
+	IncludesPickup bool       `json:"includes_pickup"`
+	TokenVersion   int        `json:"token_version"` // tokens with another "v" claim were reissued
+	CheckedInAt    *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// bundlePayload is the plaintext of an encrypted attendee bundle
+type bundlePayload struct {
+	EventID          uuid.UUID        `json:"event_id"`
+	EventName        string           `json:"event_name"`
+	EventDate        time.Time        `json:"event_date"`
+	DeviceID         string           `json:"device_id"`
+	GeneratedAt      time.Time        `json:"generated_at"`
+	SigningPublicKey string           `json:"signing_public_key"`
+	Attendees        []BundleAttendee `json:"attendees"`
+}
+
+// CheckInBundle is an attendee list encrypted for a single scanner device
+type CheckInBundle struct {
+	EventID          uuid.UUID `json:"event_id"`
+	DeviceID         string    `json:"device_id"`
+	GeneratedAt      time.Time `json:"generated_at"`
+	AttendeeCount    int       `json:"attendee_count"`
+	SigningPublicKey string    `json:"signing_public_key"`
+	Encryption       string    `json:"encryption"`
+	Ciphertext       string    `json:"ciphertext"`
+}
+
+// ExportBundle builds the attendee list of an event and seals it for the scanner's
+// X25519 public key (NaCl sealed box), so only that device can read it.
+func (s *CheckInService) ExportBundle(eventID uuid.UUID, deviceID string, devicePublicKey []byte) (*CheckInBundle, error) {
+	if deviceID == "" {
+		return nil, errors.New("device_id is required")
+	}
+	if len(devicePublicKey) != 32 {
+		return nil, errors.New("device public key must be 32 bytes")
+	}
+
+	var event models.Event
+	if err := s.db.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, errors.New("event not found")
+	}
+
+	var tickets []models.Ticket
+	if err := s.db.Preload("User").
+		Where("event_id = ? AND status = ?", eventID, "paid").
+		Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+
+	signingKey := base64.StdEncoding.EncodeToString(s.qrService.TicketPublicKey())
+	payload := bundlePayload{
+		EventID:          event.ID,
+		EventName:        event.Name,
+		EventDate:        event.DateFrom,
+		DeviceID:         deviceID,
+		GeneratedAt:      time.Now(),
+		SigningPublicKey: signingKey,
+		Attendees:        make([]BundleAttendee, 0, len(tickets)),
+	}
+	for _, t := range tickets {
+		payload.Attendees = append(payload.Attendees, BundleAttendee{
+			TicketID:       t.ID,
+			Name:           t.AttendeeName(),
+			Group:          t.User.Group,
+			IncludesPickup: t.IncludesPickup,
+			TokenVersion:   t.TokenVersion,
+			CheckedInAt:    t.CheckedInAt,
+		})
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var recipient [32]byte
+	copy(recipient[:], devicePublicKey)
+	sealed, err := box.SealAnonymous(nil, plaintext, &recipient, rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt bundle: %w", err)
+	}
+
+	return &CheckInBundle{
+		EventID:          event.ID,
+		DeviceID:         deviceID,
+		GeneratedAt:      payload.GeneratedAt,
+		AttendeeCount:    len(payload.Attendees),
+		SigningPublicKey: signingKey,
+		Encryption:       "nacl-sealedbox-x25519-xsalsa20-poly1305",
+		Ciphertext:       base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+// OfflineScanInput is one scan recorded by a scanner while offline
+type OfflineScanInput struct {
+	Token     string    `json:"token"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// OfflineScanResult reports how an uploaded scan was merged
+type OfflineScanResult struct {
+	Token       string     `json:"token"`
+	TicketID    *uuid.UUID `json:"ticket_id,omitempty"`
+	Result      string     `json:"result"` // checked_in, conflict, rejected, duplicate
+	Reason      string     `json:"reason,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+	Device      string     `json:"checked_in_device,omitempty"`
+}
+
+// maxScanClockSkew tolerates scanner clocks running slightly ahead of the server
+const maxScanClockSkew = 5 * time.Minute
+
+// MergeOfflineScans merges scans uploaded by a scanner device.
+// Conflicts between devices (or with online check-ins) are resolved in favour of the
+// earliest scan, which becomes the ticket's check-in; later scans are recorded as conflicts.
+// Re-uploading the same scan is idempotent.
+func (s *CheckInService) MergeOfflineScans(deviceID string, uploadedBy uuid.UUID, scans []OfflineScanInput) ([]OfflineScanResult, error) {
+	if deviceID == "" {
+		return nil, errors.New("device_id is required")
+	}
+
+	results := make([]OfflineScanResult, 0, len(scans))
+	for _, scan := range scans {
+		res := OfflineScanResult{Token: scan.Token}
+
+		if scan.ScannedAt.IsZero() || scan.ScannedAt.After(time.Now().Add(maxScanClockSkew)) {
+			res.Result, res.Reason = "rejected", "invalid scanned_at"
+			results = append(results, res)
+			continue
+		}
+		// Tokens are checked against the scan time: uploads may arrive after the token expired
+		claims, err := s.qrService.VerifyTicketToken(scan.Token, scan.ScannedAt)
+		if err != nil {
+			res.Result, res.Reason = "rejected", err.Error()
+			results = append(results, res)
+			continue
+		}
+		ticketID := claims.TicketID
+		res.TicketID = &ticketID
+
+		if err := s.mergeScan(deviceID, uploadedBy, claims, scan.ScannedAt.UTC(), &res); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (s *CheckInService) mergeScan(deviceID string, uploadedBy uuid.UUID, claims *TicketTokenClaims, scannedAt time.Time, res *OfflineScanResult) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, "id = ?", claims.TicketID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				res.Result, res.Reason = "rejected", "ticket not found"
+				return nil
+			}
+			return err
+		}
+
+		// Same scan uploaded again (e.g. retry after a dropped connection)
+		var existing int64
+		tx.Model(&models.OfflineScan{}).
+			Where("device_id = ? AND ticket_id = ? AND scanned_at = ?", deviceID, ticket.ID, scannedAt).
+			Count(&existing)
+		if existing > 0 {
+			res.Result = "duplicate"
+			res.CheckedInAt, res.Device = ticket.CheckedInAt, ticket.CheckedInDevice
+			return nil
+		}
+
+		record := models.OfflineScan{
+			TicketID:   ticket.ID,
+			EventID:    ticket.EventID,
+			DeviceID:   deviceID,
+			ScannedAt:  scannedAt,
+			UploadedBy: uploadedBy,
+		}
+
+		switch {
+		case ticket.EventID != claims.EventID:
+			record.Result, record.Reason = "rejected", "ticket is for a different event"
+		case ticket.TokenVersion != claims.Version:
+			// Ticket was transferred; the old holder's code is no longer valid
+			record.Result, record.Reason = "rejected", "qr code was reissued"
+		case ticket.Status == "disputed":
+			record.Result, record.Reason = "rejected", "ticket payment is disputed"
+		case ticket.Status != "paid":
+			// Ticket was cancelled after the bundle was exported; keep the scan for review
+			record.Result, record.Reason = "rejected", fmt.Sprintf("ticket is not valid (status: %s)", ticket.Status)
+		case ticket.CheckedInAt == nil || scannedAt.Before(*ticket.CheckedInAt):
+			if ticket.CheckedInAt != nil {
+				// An earlier scan wins: previously accepted uploads become conflicts
+				if err := tx.Model(&models.OfflineScan{}).
+					Where("ticket_id = ? AND result = ?", ticket.ID, "checked_in").
+					Updates(map[string]interface{}{"result": "conflict", "reason": "superseded by earlier scan"}).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&ticket).Updates(map[string]interface{}{
+				"checked_in_at":     scannedAt,
+				"checked_in_by":     uploadedBy,
+				"checked_in_device": deviceID,
+			}).Error; err != nil {
+				return err
+			}
+			ticket.CheckedInAt = &scannedAt
+			ticket.CheckedInDevice = deviceID
+			record.Result = "checked_in"
+		default:
+			record.Result = "conflict"
+			record.Reason = "already checked in"
+			if ticket.CheckedInDevice != "" {
+				record.Reason = "already checked in on " + ticket.CheckedInDevice
+			}
+		}
+
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		res.Result, res.Reason = record.Result, record.Reason
+		res.CheckedInAt, res.Device = ticket.CheckedInAt, ticket.CheckedInDevice
+		return nil
+	})
+}
@@ -42,6 +42,7 @@ func (s *EmailService) loadTemplates() {
 		"password_reset.html",
 		"event_announcement.html",
 		"generic_announcement.html",
+		"waitlist_offer.html",
 	}
 
 	for _, file := range templateFiles {
@@ -77,7 +78,16 @@ func (s *EmailService) SendPasswordResetLinkEmail(to, name, resetURL string) err
 	return s.sendEmail(to, "Passwort zurücksetzen", "password_reset.html", data)
 }
 
-// SendTicketConfirmation sends a ticket purchase confirmation email
+// inlineImage is a PNG embedded in an HTML email and referenced via cid:<ContentID>
+type inlineImage struct {
+	ContentID string
+	Filename  string
+	Data      []byte
+}
+
+// SendTicketConfirmation sends a ticket purchase confirmation email.
+// If ticketData contains "TicketQRPNG" ([]byte), the check-in QR code is embedded inline.
+// If it contains "InvoicePDF" ([]byte), the invoice is attached, named after "InvoiceNumber".
 func (s *EmailService) SendTicketConfirmation(to string, ticketData map[string]interface{}) error {
 	subject := "Ticketbestätigung - Synesthesie"
 
@@ -87,12 +97,19 @@ func (s *EmailService) SendTicketConfirmation(to string, ticketData map[string]i
 	if !exists {
 		return fmt.Errorf("template %s not found", "ticket_confirmation.html")
 	}
+
+	var images []inlineImage
+	if qrPNG, ok := ticketData["TicketQRPNG"].([]byte); ok && len(qrPNG) > 0 {
+		ticketData["TicketQRDataURI"] = template.URL(fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(qrPNG)))
+		images = append(images, inlineImage{ContentID: "ticketqr", Filename: "ticket-qr.png", Data: qrPNG})
+	}
+
 	// Try to load image bytes
 	imgPath := filepath.Join("pictures", "lageplan.png")
-	imgData, err := ioutil.ReadFile(imgPath)
-	if err == nil {
+	if imgData, err := ioutil.ReadFile(imgPath); err == nil {
 		// Provide Data-URI fallback to template
 		ticketData["LageplanDataURI"] = fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(imgData))
+		images = append(images, inlineImage{ContentID: "lageplan", Filename: "lageplan.png", Data: imgData})
 	}
 
 	var htmlBody bytes.Buffer
@@ -100,50 +117,87 @@ func (s *EmailService) SendTicketConfirmation(to string, ticketData map[string]i
 		return fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	if err != nil {
-		// If the image file was not found/readable, send HTML with Data-URI not set (template still renders)
+	invoicePDF, _ := ticketData["InvoicePDF"].([]byte)
+	if len(images) == 0 && len(invoicePDF) == 0 {
+		// No images or attachment available: send plain HTML (template still renders)
 		return s.sendEmail(to, subject, "ticket_confirmation.html", ticketData)
 	}
 
-	imgB64 := base64.StdEncoding.EncodeToString(imgData)
+	// Body: HTML, with inline images as multipart/related
+	bodyType := "text/html; charset=\"UTF-8\""
+	var body bytes.Buffer
+	if len(images) > 0 {
+		boundary := fmt.Sprintf("rel-%d", time.Now().UnixNano())
+		bodyType = fmt.Sprintf("multipart/related; type=\"text/html\"; boundary=%q", boundary)
+
+		// HTML part
+		body.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		body.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+		body.WriteString(htmlBody.String())
+		body.WriteString("\r\n")
+
+		// Inline image parts (CID)
+		for _, img := range images {
+			body.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+			body.WriteString(fmt.Sprintf("Content-Type: image/png; name=%q\r\n", img.Filename))
+			body.WriteString("Content-Transfer-Encoding: base64\r\n")
+			body.WriteString(fmt.Sprintf("Content-ID: <%s>\r\n", img.ContentID))
+			body.WriteString(fmt.Sprintf("Content-Disposition: inline; filename=%q\r\n", img.Filename))
+			body.WriteString(fmt.Sprintf("Content-Location: %s\r\n\r\n", img.Filename))
+			writeBase64Lines(&body, img.Data)
+		}
+		body.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+	} else {
+		body.WriteString(htmlBody.String())
+		body.WriteString("\r\n")
+	}
 
-	// Build multipart/related message with inline image
 	from := fmt.Sprintf("%s <%s>", s.cfg.SMTPFromName, s.cfg.SMTPFrom)
 	subjectEnc := mime.BEncoding.Encode("UTF-8", subject)
-	boundary := fmt.Sprintf("rel-%d", time.Now().UnixNano())
 
 	var msg bytes.Buffer
 	msg.WriteString(fmt.Sprintf("From: %s\r\n", from))
 	msg.WriteString(fmt.Sprintf("To: %s\r\n", to))
 	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subjectEnc))
 	msg.WriteString("MIME-Version: 1.0\r\n")
-	msg.WriteString(fmt.Sprintf("Content-Type: multipart/related; type=\"text/html\"; boundary=%q\r\n", boundary))
-	msg.WriteString("\r\n")
-
-	// HTML part
-	msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-	msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
-	msg.WriteString(htmlBody.String())
-	msg.WriteString("\r\n")
-
-	// Inline image part (CID)
-	msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-	msg.WriteString("Content-Type: image/png; name=\"lageplan.png\"\r\n")
+
+	if len(invoicePDF) == 0 {
+		msg.WriteString(fmt.Sprintf("Content-Type: %s\r\n\r\n", bodyType))
+		msg.Write(body.Bytes())
+		return s.sendSMTP(to, msg.Bytes())
+	}
+
+	// Invoice attached: wrap the body in multipart/mixed
+	filename := "Rechnung.pdf"
+	if number, ok := ticketData["InvoiceNumber"].(string); ok && number != "" {
+		filename = number + ".pdf"
+	}
+	mixed := fmt.Sprintf("mix-%d", time.Now().UnixNano())
+	msg.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mixed))
+	msg.WriteString(fmt.Sprintf("--%s\r\n", mixed))
+	msg.WriteString(fmt.Sprintf("Content-Type: %s\r\n\r\n", bodyType))
+	msg.Write(body.Bytes())
+	msg.WriteString(fmt.Sprintf("--%s\r\n", mixed))
+	msg.WriteString(fmt.Sprintf("Content-Type: application/pdf; name=%q\r\n", filename))
 	msg.WriteString("Content-Transfer-Encoding: base64\r\n")
-	msg.WriteString("Content-ID: <lageplan>\r\n")
-	msg.WriteString("Content-Disposition: inline; filename=\"lageplan.png\"\r\n")
-	msg.WriteString("Content-Location: lageplan.png\r\n\r\n")
-	for i := 0; i < len(imgB64); i += 76 {
+	msg.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=%q\r\n\r\n", filename))
+	writeBase64Lines(&msg, invoicePDF)
+	msg.WriteString(fmt.Sprintf("--%s--\r\n", mixed))
+
+	return s.sendSMTP(to, msg.Bytes())
+}
+
+// writeBase64Lines writes data base64 encoded in lines of 76 characters
+func writeBase64Lines(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for i := 0; i < len(encoded); i += 76 {
 		end := i + 76
-		if end > len(imgB64) {
-			end = len(imgB64)
+		if end > len(encoded) {
+			end = len(encoded)
 		}
-		msg.WriteString(imgB64[i:end])
-		msg.WriteString("\r\n")
+		buf.WriteString(encoded[i:end])
+		buf.WriteString("\r\n")
 	}
-	msg.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
-
-	return s.sendSMTP(to, msg.Bytes())
 }
 
 // SendEventReminder sends an event reminder email
@@ -158,9 +212,18 @@ func (s *EmailService) SendCancellationConfirmation(to string, cancellationData
 	return s.sendEmail(to, subject, "cancellation_confirmation.html", cancellationData)
 }
 
-// SendEventCancelled notifies users that an event was cancelled (full refund issued)
+// SendWaitlistOffer sends the time-limited claim link for a freed spot
+func (s *EmailService) SendWaitlistOffer(to string, offerData map[string]interface{}) error {
+	subject := "Ein Platz ist frei geworden – Synesthesie"
+	return s.sendEmail(to, subject, "waitlist_offer.html", offerData)
+}
+
+// SendEventCancelled notifies users that an event was cancelled (with a full refund if data["FullRefund"] is set)
 func (s *EmailService) SendEventCancelled(to string, data map[string]interface{}) error {
-	subject := "Event abgesagt – vollständige Rückerstattung"
+	subject := "Event abgesagt"
+	if full, _ := data["FullRefund"].(bool); full {
+		subject = "Event abgesagt – vollständige Rückerstattung"
+	}
 	return s.sendEmail(to, subject, "cancellation_confirmation.html", data)
 }
 
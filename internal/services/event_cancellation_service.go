@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventCancellationJob is the scheduler job working off event cancellations
+const EventCancellationJob = "event_cancellations"
+
+var (
+	ErrEventCancellationNotFound = errors.New("event cancellation not found")
+	ErrEventCancellationRunning  = errors.New("event is already being cancelled")
+)
+
+// eventCancellationBatch is the number of tickets processed between progress updates
+const eventCancellationBatch = 20
+
+// EventCancellationService cancels all tickets of an event in the background. Start records one item per
+// ticket and deactivates the event; the event_cancellations job then cancels or refunds the tickets one by
+// one and emails each attendee. A failing ticket is recorded and does not stop the others, and a
+// cancellation interrupted by a restart is picked up by the next job run. The admin gets a report at the end.
+type EventCancellationService struct {
+	db            *gorm.DB
+	cfg           *config.Config
+	ticketService *TicketService
+	emailService  *EmailService
+}
+
+func NewEventCancellationService(db *gorm.DB, cfg *config.Config, ticketService *TicketService, emailService *EmailService) *EventCancellationService {
+	return &EventCancellationService{
+		db:            db,
+		cfg:           cfg,
+		ticketService: ticketService,
+		emailService:  emailService,
+	}
+}
+
+// Start deactivates the event and records the cancellation of its open and paid tickets. If refundPaid is
+// true paid tickets are fully refunded, otherwise they are cancelled without refund.
+// The tickets are processed by the event_cancellations job.
+func (s *EventCancellationService) Start(eventID uuid.UUID, refundPaid bool, triggeredBy *uuid.UUID) (*models.EventCancellation, error) {
+	run := &models.EventCancellation{
+		EventID:     eventID,
+		RefundPaid:  refundPaid,
+		Status:      models.EventCancellationRunning,
+		TriggeredBy: triggeredBy,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the event serialises cancellations of the same event and the last bookings
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("event not found")
+			}
+			return err
+		}
+		var running int64
+		if err := tx.Model(&models.EventCancellation{}).
+			Where("event_id = ? AND status = ?", eventID, models.EventCancellationRunning).
+			Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return ErrEventCancellationRunning
+		}
+		if err := tx.Model(&event).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		// A cancelled event of a series is not created again
+		if err := addSeriesException(tx, &event); err != nil {
+			return err
+		}
+
+		var tickets []models.Ticket
+		if err := tx.Select("id", "user_id", "status").
+			Where("event_id = ?", eventID).
+			Scopes(models.WithTicketStatus(models.TicketPending, models.TicketPendingCancellation, models.TicketPaid, models.TicketDisputed)).
+			Order("created_at ASC").Find(&tickets).Error; err != nil {
+			return err
+		}
+		run.Total = len(tickets)
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+
+		items := make([]models.EventCancellationItem, len(tickets))
+		for i, t := range tickets {
+			items[i] = models.EventCancellationItem{
+				CancellationID: run.ID,
+				TicketID:       t.ID,
+				UserID:         t.UserID,
+				Status:         models.EventCancellationItemPending,
+				FromStatus:     t.Status,
+			}
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(items, 500).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Event cancellation %s started for event %s: %d tickets (refund paid: %t)", run.ID, eventID, run.Total, refundPaid)
+	return run, nil
+}
+
+// ProcessPending works off the running event cancellations one after the other, oldest first, including
+// those started meanwhile (job event_cancellations). A cancelled context stops after the current ticket;
+// the rest is done by the next run.
+func (s *EventCancellationService) ProcessPending(ctx context.Context) (string, error) {
+	var results []string
+	for ctx.Err() == nil {
+		var run models.EventCancellation
+		err := s.db.Where("status = ?", models.EventCancellationRunning).Order("created_at ASC").First(&run).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return strings.Join(results, "; "), err
+		}
+		if err := s.process(ctx, &run); err != nil {
+			return strings.Join(results, "; "), err
+		}
+		results = append(results, fmt.Sprintf("event %s: %d tickets, %d failed", run.EventID, run.Total, run.Failed))
+	}
+	return strings.Join(results, "; "), nil
+}
+
+// process cancels the remaining tickets of a cancellation and completes it
+func (s *EventCancellationService) process(ctx context.Context, run *models.EventCancellation) error {
+	var event models.Event
+	if err := s.db.First(&event, "id = ?", run.EventID).Error; err != nil {
+		return err
+	}
+
+	for {
+		var items []*models.EventCancellationItem
+		if err := s.db.Preload("Ticket.User").
+			Where("cancellation_id = ? AND status = ?", run.ID, models.EventCancellationItemPending).
+			Order("created_at ASC, id ASC").Limit(eventCancellationBatch).Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				if err := s.updateProgress(run); err != nil {
+					return err
+				}
+				return ctx.Err()
+			}
+			if err := s.processItem(run, &event, item); err != nil {
+				return err
+			}
+		}
+		if err := s.updateProgress(run); err != nil {
+			return err
+		}
+	}
+
+	// Add-ons are only useful at the event; refund them with the tickets
+	if run.RefundPaid {
+		if err := s.ticketService.RefundEventAddons(run.EventID); err != nil {
+			run.Error = err.Error()
+		}
+	}
+
+	now := time.Now()
+	run.Status = models.EventCancellationCompleted
+	run.FinishedAt = &now
+	if err := s.updateProgress(run); err != nil {
+		return err
+	}
+	log.Printf("Event cancellation %s completed: %d tickets, %d failed", run.ID, run.Processed, run.Failed)
+
+	if err := s.SendReport(run); err != nil {
+		log.Printf("Event cancellation %s: failed to send report: %v", run.ID, err)
+	}
+	return nil
+}
+
+// processItem cancels one ticket and notifies its attendee. Problems are recorded on the item, which is
+// then failed; only a failure to store the outcome is returned.
+func (s *EventCancellationService) processItem(run *models.EventCancellation, event *models.Event, item *models.EventCancellationItem) error {
+	var problems []string
+	updates := map[string]interface{}{}
+
+	ticket, refund, err := s.ticketService.CancelTicketForEvent(item.TicketID, run.RefundPaid)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	if ticket != nil {
+		updates["ticket_status"] = ticket.Status
+	}
+	// A refund still pending was recorded before a crash and never sent; send it before the item is done.
+	// Its idempotency key keeps the provider from paying it out twice.
+	if refund != nil && refund.Status == models.RefundStatusPending {
+		_ = s.ticketService.RefundService().Execute(refund)
+	}
+	var refundAmount models.Money
+	if refund != nil {
+		refundAmount = refund.Amount
+		updates["refund_id"] = refund.ID
+		updates["refund_amount"] = refund.Amount
+		updates["refund_status"] = refund.Status
+		if refund.Status == models.RefundStatusFailed {
+			problems = append(problems, "refund failed: "+refund.Error)
+		}
+	}
+
+	// Only attendees are told; unpaid checkouts simply end
+	if err == nil && item.NotifiedAt == nil && item.FromStatus == models.TicketPaid && s.emailService != nil {
+		if sendErr := s.sendNotice(run, event, item, refundAmount); sendErr != nil {
+			problems = append(problems, "email failed: "+sendErr.Error())
+		} else {
+			updates["notified_at"] = time.Now()
+		}
+	}
+
+	updates["status"] = models.EventCancellationItemDone
+	if len(problems) > 0 {
+		updates["status"] = models.EventCancellationItemFailed
+		updates["error"] = strings.Join(problems, "; ")
+		log.Printf("Event cancellation %s: ticket %s failed: %s", run.ID, item.TicketID, updates["error"])
+	}
+	updates["processed_at"] = time.Now()
+	return s.db.Model(item).Updates(updates).Error
+}
+
+// sendNotice emails the buyer of a ticket that the event was cancelled and what they get back
+func (s *EventCancellationService) sendNotice(run *models.EventCancellation, event *models.Event, item *models.EventCancellationItem, refundAmount models.Money) error {
+	user := item.Ticket.User
+	if user.Email == "" {
+		return errors.New("buyer has no email address")
+	}
+	loc := berlinLocation()
+	data := map[string]interface{}{
+		"UserName":       user.Name,
+		"EventName":      event.Name,
+		"EventDate":      event.DateFrom.In(loc).Format("02.01.2006"),
+		"EventTime":      event.TimeFrom,
+		"TicketID":       item.TicketID,
+		"RefundAmount":   refundAmount.String(),
+		"FullRefund":     run.RefundPaid,
+		"PartialRefund":  false,
+		"CancellationBy": "abgesagt durch die Veranstalter:innen",
+		"CancellationAt": run.CreatedAt.In(loc).Format("02.01.2006 15:04"),
+	}
+	return s.emailService.SendEventCancelled(user.Email, data)
+}
+
+// updateProgress recounts the items of a cancellation, so the numbers stay right after an interruption
+func (s *EventCancellationService) updateProgress(run *models.EventCancellation) error {
+	var counts struct {
+		Processed      int
+		Failed         int
+		Notified       int
+		RefundedAmount models.Money
+	}
+	if err := s.db.Model(&models.EventCancellationItem{}).
+		Select(`COUNT(*) FILTER (WHERE status <> ?) AS processed,
+			COUNT(*) FILTER (WHERE status = ?) AS failed,
+			COUNT(notified_at) AS notified,
+			COALESCE(SUM(refund_amount) FILTER (WHERE refund_status = ?), 0)::bigint AS refunded_amount`,
+			models.EventCancellationItemPending, models.EventCancellationItemFailed, models.RefundStatusSucceeded).
+		Where("cancellation_id = ?", run.ID).
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+	run.Processed, run.Failed, run.Notified = counts.Processed, counts.Failed, counts.Notified
+	run.RefundedAmount = counts.RefundedAmount
+	return s.db.Model(run).Updates(map[string]interface{}{
+		"processed":       run.Processed,
+		"failed":          run.Failed,
+		"notified":        run.Notified,
+		"refunded_amount": run.RefundedAmount,
+		"status":          run.Status,
+		"error":           run.Error,
+		"finished_at":     run.FinishedAt,
+	}).Error
+}
+
+// SendReport emails the outcome of a cancellation to the admin alert address, listing the tickets to handle by hand
+func (s *EventCancellationService) SendReport(run *models.EventCancellation) error {
+	if s.emailService == nil || s.cfg.AdminAlertEmail == "" {
+		return nil
+	}
+	var event models.Event
+	if err := s.db.First(&event, "id = ?", run.EventID).Error; err != nil {
+		return err
+	}
+	failures, err := s.GetFailures(run.ID)
+	if err != nil {
+		return err
+	}
+	loc := berlinLocation()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Absage von %s (%s)\n\n", event.Name, event.DateFrom.In(loc).Format("02.01.2006"))
+	if run.RefundPaid {
+		fmt.Fprintf(&b, "Bezahlte Tickets wurden voll erstattet.\n")
+	} else {
+		fmt.Fprintf(&b, "Bezahlte Tickets wurden ohne Erstattung storniert.\n")
+	}
+	fmt.Fprintf(&b, "Tickets: %d\nBenachrichtigt: %d\nErstattet: %s EUR\nFehlgeschlagen: %d\n", run.Total, run.Notified, run.RefundedAmount, run.Failed)
+	if run.Error != "" {
+		fmt.Fprintf(&b, "\nFEHLER: %s\n", run.Error)
+	}
+	if len(failures) > 0 {
+		fmt.Fprintf(&b, "\nVon Hand zu klären:\n")
+	}
+	for _, item := range failures {
+		fmt.Fprintf(&b, "\n- Ticket %s (%s, %s)\n", item.TicketID, item.Ticket.User.Name, item.Ticket.User.Email)
+		fmt.Fprintf(&b, "  Status: %s -> %s\n", item.FromStatus, item.TicketStatus)
+		if item.RefundID != nil {
+			fmt.Fprintf(&b, "  Refund %s: %s EUR, %s\n", *item.RefundID, item.RefundAmount, item.RefundStatus)
+		}
+		fmt.Fprintf(&b, "  Fehler: %s\n", item.Error)
+	}
+	fmt.Fprintf(&b, "\nDetails: GET /api/v1/admin/event-cancellations/%s\n", run.ID)
+
+	subject := fmt.Sprintf("Absage %s: %d Tickets, %d fehlgeschlagen", event.Name, run.Total, run.Failed)
+	return s.emailService.SendGenericTextEmail(s.cfg.AdminAlertEmail, subject, b.String())
+}
+
+// GetCancellation returns a cancellation with its current progress
+func (s *EventCancellationService) GetCancellation(id uuid.UUID) (*models.EventCancellation, error) {
+	var run models.EventCancellation
+	if err := s.db.First(&run, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventCancellationNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetFailures returns the tickets of a cancellation that failed, with their buyer
+func (s *EventCancellationService) GetFailures(id uuid.UUID) ([]*models.EventCancellationItem, error) {
+	var items []*models.EventCancellationItem
+	err := s.db.Preload("Ticket.User").
+		Where("cancellation_id = ? AND status = ?", id, models.EventCancellationItemFailed).
+		Order("created_at ASC, id ASC").Find(&items).Error
+	return items, err
+}
+
+// GetEventCancellations lists the cancellations of an event, newest first
+func (s *EventCancellationService) GetEventCancellations(eventID uuid.UUID) ([]*models.EventCancellation, error) {
+	var runs []*models.EventCancellation
+	err := s.db.Where("event_id = ?", eventID).Order("created_at DESC").Find(&runs).Error
+	return runs, err
+}
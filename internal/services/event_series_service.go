@@ -0,0 +1,461 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventSeriesService manages recurring events. The events of a series are created ahead from its
+// template (job event_series); editing a series can carry the changes over to its future events,
+// as long as nobody has booked them yet.
+type EventSeriesService struct {
+	db           *gorm.DB
+	eventService *EventService
+}
+
+func NewEventSeriesService(db *gorm.DB, eventService *EventService) *EventSeriesService {
+	return &EventSeriesService{db: db, eventService: eventService}
+}
+
+func (s *EventSeriesService) validateSeries(series *models.EventSeries) error {
+	series.Name = strings.TrimSpace(series.Name)
+	if series.Name == "" {
+		return errors.New("name is required")
+	}
+	if series.StartsOn.IsZero() {
+		return errors.New("starts_on is required")
+	}
+	if series.Until != nil && series.Until.Before(series.StartsOn) {
+		return errors.New("until must not be before starts_on")
+	}
+
+	// Same checks as for a single event, on the first day of the series
+	if series.EndDayOffset < 0 || series.EndDayOffset > 7 {
+		return errors.New("end_day_offset must be between 0 and 7")
+	}
+	df, err := s.eventService.composeDateTime(series.StartsOn, series.TimeFrom)
+	if err != nil {
+		return errors.New("invalid time_from format; expected HH:MM")
+	}
+	dt, err := s.eventService.composeDateTime(series.StartsOn.AddDate(0, 0, series.EndDayOffset), series.TimeTo)
+	if err != nil {
+		return errors.New("invalid time_to format; expected HH:MM")
+	}
+	if df.After(dt) {
+		return errors.New("start date must be before end date")
+	}
+	if series.MaxParticipants <= 0 {
+		return errors.New("max participants must be greater than 0")
+	}
+	if series.AllowedGroup == "" {
+		series.AllowedGroup = "all"
+	}
+	if series.AllowedGroup != "all" && series.AllowedGroup != "guests" && series.AllowedGroup != "bubble" && series.AllowedGroup != "plus" {
+		return errors.New("invalid allowed_group; must be 'all', 'guests', 'bubble' or 'plus'")
+	}
+	// Default prices if unset, as for single events
+	if series.GuestsPrice <= 0 {
+		series.GuestsPrice = 10000
+	}
+	if series.BubblePrice <= 0 {
+		series.BubblePrice = 3500
+	}
+	if series.PlusPrice <= 0 {
+		series.PlusPrice = 5000
+	}
+	if len(series.CancellationPolicy) == 0 {
+		series.CancellationPolicy = nil
+	} else {
+		policy, err := series.CancellationPolicy.Normalize()
+		if err != nil {
+			return err
+		}
+		series.CancellationPolicy = policy
+	}
+	if len(series.TaxRates) == 0 {
+		series.TaxRates = nil
+	} else {
+		rates, err := series.TaxRates.Normalize()
+		if err != nil {
+			return err
+		}
+		series.TaxRates = rates
+	}
+
+	// Recurrence rule
+	switch series.Frequency {
+	case models.SeriesWeekly:
+		series.WeekOfMonth = 0
+	case models.SeriesMonthly:
+		if series.WeekOfMonth != -1 && (series.WeekOfMonth < 1 || series.WeekOfMonth > 5) {
+			return errors.New("week_of_month must be 1-5 or -1 (last)")
+		}
+	default:
+		return errors.New("invalid frequency; must be 'weekly' or 'monthly'")
+	}
+	if series.Interval == 0 {
+		series.Interval = 1
+	}
+	if series.Interval < 1 || series.Interval > 12 {
+		return errors.New("interval must be between 1 and 12")
+	}
+	if series.Weekday < 0 || series.Weekday > 6 {
+		return errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	exceptions, err := series.Exceptions.Normalize()
+	if err != nil {
+		return err
+	}
+	series.Exceptions = exceptions
+	if series.MonthsAhead == 0 {
+		series.MonthsAhead = 3
+	}
+	if series.MonthsAhead < 1 || series.MonthsAhead > 24 {
+		return errors.New("months_ahead must be between 1 and 24")
+	}
+	return nil
+}
+
+// GetAllSeries lists all event series
+func (s *EventSeriesService) GetAllSeries() ([]*models.EventSeries, error) {
+	var series []*models.EventSeries
+	err := s.db.Order("is_active DESC, name ASC").Find(&series).Error
+	return series, err
+}
+
+// GetSeries returns an event series with its events in date order
+func (s *EventSeriesService) GetSeries(seriesID uuid.UUID) (*models.EventSeries, []*models.Event, error) {
+	var series models.EventSeries
+	if err := s.db.First(&series, "id = ?", seriesID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("series not found")
+		}
+		return nil, nil, err
+	}
+	var events []*models.Event
+	if err := s.db.Where("series_id = ?", seriesID).Order("date_from ASC").Find(&events).Error; err != nil {
+		return nil, nil, err
+	}
+	return &series, events, nil
+}
+
+// CreateSeries creates an event series and its events for the next months
+func (s *EventSeriesService) CreateSeries(series *models.EventSeries) (int, error) {
+	if err := s.validateSeries(series); err != nil {
+		return 0, err
+	}
+	if err := s.db.Create(series).Error; err != nil {
+		return 0, err
+	}
+	if !series.IsActive {
+		return 0, nil
+	}
+	return s.generate(series)
+}
+
+// SeriesUpdateResult counts what editing a series did to its future events
+type SeriesUpdateResult struct {
+	Updated int `json:"updated"` // future events without tickets now following the series
+	Removed int `json:"removed"` // future events without tickets no longer in the series, deactivated
+	Created int `json:"created"` // events created for new dates
+	Booked  int `json:"booked"`  // future events with tickets, left as they are
+}
+
+// UpdateSeries replaces an event series. With applyToFuture its future events without tickets follow the
+// changes: they get the new template, events on dates the series no longer has are deactivated, and
+// events on new dates are created. Events with tickets are never changed; without applyToFuture only
+// events created later use the new template.
+func (s *EventSeriesService) UpdateSeries(seriesID uuid.UUID, series *models.EventSeries, applyToFuture bool) (*SeriesUpdateResult, error) {
+	if err := s.validateSeries(series); err != nil {
+		return nil, err
+	}
+
+	result := &SeriesUpdateResult{}
+	loc := berlinLocation()
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.EventSeries
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", seriesID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("series not found")
+			}
+			return err
+		}
+		series.ID = current.ID
+		if err := tx.Model(&models.EventSeries{}).Where("id = ?", seriesID).Updates(map[string]interface{}{
+			"name":                series.Name,
+			"description":         series.Description,
+			"time_from":           series.TimeFrom,
+			"time_to":             series.TimeTo,
+			"end_day_offset":      series.EndDayOffset,
+			"max_participants":    series.MaxParticipants,
+			"guests_price":        series.GuestsPrice,
+			"bubble_price":        series.BubblePrice,
+			"plus_price":          series.PlusPrice,
+			"allowed_group":       series.AllowedGroup,
+			"cancellation_policy": series.CancellationPolicy,
+			"tax_rates":           series.TaxRates,
+			"frequency":           series.Frequency,
+			"interval":            series.Interval,
+			"weekday":             series.Weekday,
+			"week_of_month":       series.WeekOfMonth,
+			"starts_on":           series.StartsOn,
+			"until":               series.Until,
+			"exceptions":          series.Exceptions,
+			"months_ahead":        series.MonthsAhead,
+			"is_active":           series.IsActive,
+		}).Error; err != nil {
+			return err
+		}
+		if !applyToFuture {
+			return nil
+		}
+
+		var events []*models.Event
+		if err := tx.Where("series_id = ? AND date_from > ?", seriesID, now).Order("date_from ASC").Find(&events).Error; err != nil {
+			return err
+		}
+		booked, err := eventsWithTickets(tx, events)
+		if err != nil {
+			return err
+		}
+
+		// Dates up to the last existing event, so events created with a longer months_ahead stay
+		until := now.AddDate(0, series.MonthsAhead, 0)
+		for _, ev := range events {
+			if ev.DateFrom.After(until) {
+				until = ev.DateFrom
+			}
+		}
+		scheduled := map[string]bool{}
+		if series.IsActive {
+			for _, d := range series.Dates(now, until, loc) {
+				scheduled[d.Format(models.SeriesDateFormat)] = true
+			}
+		}
+
+		for _, ev := range events {
+			if booked[ev.ID] {
+				result.Booked++
+				continue
+			}
+			if !scheduled[ev.SeriesDate] {
+				if ev.IsActive {
+					if err := tx.Model(&models.Event{}).Where("id = ?", ev.ID).Update("is_active", false).Error; err != nil {
+						return err
+					}
+					result.Removed++
+				}
+				continue
+			}
+			if err := s.applyTemplate(tx, series, ev, loc); err != nil {
+				return err
+			}
+			result.Updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if series.IsActive {
+		created, err := s.generate(series)
+		result.Created = created
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// applyTemplate gives an event of a series the template of the series
+func (s *EventSeriesService) applyTemplate(tx *gorm.DB, series *models.EventSeries, ev *models.Event, loc *time.Location) error {
+	date, err := time.ParseInLocation(models.SeriesDateFormat, ev.SeriesDate, loc)
+	if err != nil {
+		return fmt.Errorf("event %s has an invalid series date: %w", ev.ID, err)
+	}
+	template := series.Occurrence(date)
+	df, err := s.eventService.composeDateTime(template.DateFrom, template.TimeFrom)
+	if err != nil {
+		return errors.New("invalid time_from format; expected HH:MM")
+	}
+	dt, err := s.eventService.composeDateTime(template.DateTo, template.TimeTo)
+	if err != nil {
+		return errors.New("invalid time_to format; expected HH:MM")
+	}
+	if err := tx.Model(&models.Event{}).Where("id = ?", ev.ID).Updates(map[string]interface{}{
+		"name":                template.Name,
+		"description":         template.Description,
+		"date_from":           df,
+		"date_to":             dt,
+		"time_from":           template.TimeFrom,
+		"time_to":             template.TimeTo,
+		"max_participants":    template.MaxParticipants,
+		"allowed_group":       template.AllowedGroup,
+		"guests_price":        template.GuestsPrice,
+		"bubble_price":        template.BubblePrice,
+		"plus_price":          template.PlusPrice,
+		"cancellation_policy": template.CancellationPolicy,
+		"tax_rates":           template.TaxRates,
+		"is_active":           true,
+	}).Error; err != nil {
+		return err
+	}
+	template.ID = ev.ID
+	return syncDefaultTicketTypes(tx, template)
+}
+
+// syncDefaultTicketTypes matches the ticket types of the group prices to the prices and allowed group of an event:
+// types of groups no longer allowed are deactivated, types of newly allowed groups are created
+func syncDefaultTicketTypes(tx *gorm.DB, event *models.Event) error {
+	var existing []models.TicketType
+	if err := tx.Where("event_id = ? AND legacy_group <> ''", event.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	byGroup := map[string]*models.TicketType{}
+	for i := range existing {
+		byGroup[existing[i].LegacyGroup] = &existing[i]
+	}
+
+	for _, t := range event.DefaultTicketTypes() {
+		if cur, ok := byGroup[t.LegacyGroup]; ok {
+			if err := tx.Model(&models.TicketType{}).Where("id = ?", cur.ID).Updates(map[string]interface{}{
+				"price":     t.Price,
+				"is_active": true,
+			}).Error; err != nil {
+				return err
+			}
+			delete(byGroup, t.LegacyGroup)
+			continue
+		}
+		t := t
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+	}
+	for _, cur := range byGroup {
+		if err := tx.Model(&models.TicketType{}).Where("id = ?", cur.ID).Update("is_active", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventsWithTickets returns which of the events have tickets (in any status)
+func eventsWithTickets(db *gorm.DB, events []*models.Event) (map[uuid.UUID]bool, error) {
+	booked := map[uuid.UUID]bool{}
+	if len(events) == 0 {
+		return booked, nil
+	}
+	ids := make([]uuid.UUID, len(events))
+	for i, ev := range events {
+		ids[i] = ev.ID
+	}
+	var withTickets []uuid.UUID
+	if err := db.Model(&models.Ticket{}).Where("event_id IN ?", ids).Distinct().Pluck("event_id", &withTickets).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range withTickets {
+		booked[id] = true
+	}
+	return booked, nil
+}
+
+// DeactivateSeries ends an event series: no more events are created and its future events without
+// tickets are deactivated. Events with tickets stay; cancel them like single events.
+func (s *EventSeriesService) DeactivateSeries(seriesID uuid.UUID) (*SeriesUpdateResult, error) {
+	var series models.EventSeries
+	if err := s.db.First(&series, "id = ?", seriesID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("series not found")
+		}
+		return nil, err
+	}
+	series.IsActive = false
+	return s.UpdateSeries(seriesID, &series, true)
+}
+
+// generate creates the missing events of a series up to its months_ahead. Dates that already have an event
+// (also a deactivated one) are skipped, so a cancelled date is not created again.
+func (s *EventSeriesService) generate(series *models.EventSeries) (int, error) {
+	loc := berlinLocation()
+	now := time.Now()
+
+	var existing []string
+	if err := s.db.Model(&models.Event{}).Where("series_id = ?", series.ID).Pluck("series_date", &existing).Error; err != nil {
+		return 0, err
+	}
+	exists := map[string]bool{}
+	for _, d := range existing {
+		exists[d] = true
+	}
+
+	created := 0
+	for _, date := range series.Dates(now, now.AddDate(0, series.MonthsAhead, 0), loc) {
+		if exists[date.Format(models.SeriesDateFormat)] {
+			continue
+		}
+		event := series.Occurrence(date)
+		if start, err := s.eventService.composeDateTime(event.DateFrom, event.TimeFrom); err == nil && !start.After(now) {
+			continue
+		}
+		if err := s.eventService.CreateEvent(event); err != nil {
+			return created, fmt.Errorf("failed to create event of series %s on %s: %w", series.ID, event.SeriesDate, err)
+		}
+		created++
+	}
+	if created > 0 {
+		log.Printf("Event series %s: created %d events", series.ID, created)
+	}
+	return created, nil
+}
+
+// GenerateAll creates the upcoming events of all active series (job event_series)
+func (s *EventSeriesService) GenerateAll() (int64, error) {
+	var series []*models.EventSeries
+	if err := s.db.Where("is_active = ?", true).Find(&series).Error; err != nil {
+		return 0, err
+	}
+	var total int64
+	var errs []error
+	for _, sr := range series {
+		created, err := s.generate(sr)
+		total += int64(created)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// addSeriesException leaves the date of a cancelled event out of its series, so editing the series
+// does not bring it back
+func addSeriesException(tx *gorm.DB, event *models.Event) error {
+	if event.SeriesID == nil || event.SeriesDate == "" {
+		return nil
+	}
+	var series models.EventSeries
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&series, "id = ?", *event.SeriesID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if series.Exceptions.Contains(event.SeriesDate) {
+		return nil
+	}
+	exceptions, err := append(series.Exceptions, event.SeriesDate).Normalize()
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.EventSeries{}).Where("id = ?", series.ID).Update("exceptions", exceptions).Error
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -42,17 +43,17 @@ func (s *EventService) composeDateTime(date time.Time, hm string) (time.Time, er
 }
 
 // GetTurnoverByEventIDs returns a map[eventID]turnover (sum of total_amount for paid tickets)
-func (s *EventService) GetTurnoverByEventIDs(eventIDs []uuid.UUID) (map[uuid.UUID]float64, error) {
-	result := make(map[uuid.UUID]float64)
+func (s *EventService) GetTurnoverByEventIDs(eventIDs []uuid.UUID) (map[uuid.UUID]models.Money, error) {
+	result := make(map[uuid.UUID]models.Money)
 	if len(eventIDs) == 0 {
 		return result, nil
 	}
 	var rows []struct {
 		EventID uuid.UUID
-		Sum     float64
+		Sum     models.Money
 	}
 	err := s.db.Model(&models.Ticket{}).
-		Select("event_id, COALESCE(SUM(total_amount), 0) as sum").
+		Select("event_id, COALESCE(SUM(total_amount), 0)::bigint as sum").
 		Where("status = ?", "paid").
 		Where("event_id IN ?", eventIDs).
 		Group("event_id").
@@ -98,18 +99,50 @@ func (s *EventService) CreateEvent(event *models.Event) error {
 
 	// Default prices if unset
 	if event.GuestsPrice <= 0 {
-		event.GuestsPrice = 100
+		event.GuestsPrice = 10000
 	}
 	if event.BubblePrice <= 0 {
-		event.BubblePrice = 35
+		event.BubblePrice = 3500
 	}
 	if event.PlusPrice <= 0 {
-		event.PlusPrice = 50
+		event.PlusPrice = 5000
 	}
 	if event.GuestsPrice < 0 || event.BubblePrice < 0 || event.PlusPrice < 0 {
 		return errors.New("prices cannot be negative")
 	}
 
+	// Without tiers the default cancellation policy applies
+	if len(event.CancellationPolicy) == 0 {
+		event.CancellationPolicy = nil
+	} else {
+		policy, err := event.CancellationPolicy.Normalize()
+		if err != nil {
+			return err
+		}
+		event.CancellationPolicy = policy
+	}
+
+	// Without rates the default VAT rates apply
+	if len(event.TaxRates) == 0 {
+		event.TaxRates = nil
+	} else {
+		rates, err := event.TaxRates.Normalize()
+		if err != nil {
+			return err
+		}
+		event.TaxRates = rates
+	}
+
+	// Without explicit ticket types the event sells one type per group at the group prices
+	if len(event.TicketTypes) == 0 {
+		event.TicketTypes = event.DefaultTicketTypes()
+	}
+	for i := range event.TicketTypes {
+		if err := validateTicketType(&event.TicketTypes[i]); err != nil {
+			return err
+		}
+	}
+
 	return s.db.Create(event).Error
 }
 
@@ -161,15 +194,37 @@ func (s *EventService) UpdateEvent(eventID uuid.UUID, updates map[string]interfa
 	if v, ok := updates["allowed_group"].(string); ok && v != "" {
 		ev.AllowedGroup = v
 	}
-	if v, ok := updates["guests_price"].(float64); ok {
+	if v, ok := updates["guests_price"].(models.Money); ok {
 		ev.GuestsPrice = v
 	}
-	if v, ok := updates["bubble_price"].(float64); ok {
+	if v, ok := updates["bubble_price"].(models.Money); ok {
 		ev.BubblePrice = v
 	}
-	if v, ok := updates["plus_price"].(float64); ok {
+	if v, ok := updates["plus_price"].(models.Money); ok {
 		ev.PlusPrice = v
 	}
+	if v, ok := updates["cancellation_policy"].(models.CancellationPolicy); ok {
+		// An empty list switches the event back to the default policy
+		policy, err := v.Normalize()
+		if err != nil {
+			return err
+		}
+		if len(policy) == 0 {
+			policy = nil
+		}
+		ev.CancellationPolicy = policy
+	}
+	if v, ok := updates["tax_rates"].(models.TaxRates); ok {
+		// An empty object switches the event back to the default rates
+		rates, err := v.Normalize()
+		if err != nil {
+			return err
+		}
+		if len(rates) == 0 {
+			rates = nil
+		}
+		ev.TaxRates = rates
+	}
 
 	// Compose new DateFrom/DateTo using possibly updated times
 	df, err := s.composeDateTime(ev.DateFrom, ev.TimeFrom)
@@ -197,19 +252,34 @@ func (s *EventService) UpdateEvent(eventID uuid.UUID, updates map[string]interfa
 		return errors.New("prices cannot be negative")
 	}
 
-	return s.db.Model(&models.Event{}).Where("id = ?", eventID).Updates(map[string]interface{}{
-		"name":             ev.Name,
-		"description":      ev.Description,
-		"date_from":        ev.DateFrom,
-		"date_to":          ev.DateTo,
-		"time_from":        ev.TimeFrom,
-		"time_to":          ev.TimeTo,
-		"max_participants": ev.MaxParticipants,
-		"allowed_group":    ev.AllowedGroup,
-		"guests_price":     ev.GuestsPrice,
-		"bubble_price":     ev.BubblePrice,
-		"plus_price":       ev.PlusPrice,
-	}).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Keep ticket types migrated from the group prices in sync for older clients
+		for group, key := range map[string]string{"guests": "guests_price", "bubble": "bubble_price", "plus": "plus_price"} {
+			if v, ok := updates[key].(models.Money); ok {
+				if err := tx.Model(&models.TicketType{}).
+					Where("event_id = ? AND legacy_group = ?", eventID, group).
+					Update("price", v).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Model(&models.Event{}).Where("id = ?", eventID).Updates(map[string]interface{}{
+			"name":                ev.Name,
+			"description":         ev.Description,
+			"date_from":           ev.DateFrom,
+			"date_to":             ev.DateTo,
+			"time_from":           ev.TimeFrom,
+			"time_to":             ev.TimeTo,
+			"max_participants":    ev.MaxParticipants,
+			"allowed_group":       ev.AllowedGroup,
+			"guests_price":        ev.GuestsPrice,
+			"bubble_price":        ev.BubblePrice,
+			"plus_price":          ev.PlusPrice,
+			"cancellation_policy": ev.CancellationPolicy,
+			"tax_rates":           ev.TaxRates,
+		}).Error
+	})
 }
 
 // DeleteEvent deletes an event
@@ -324,3 +394,180 @@ func (s *EventService) CheckEventAvailability(eventID uuid.UUID) (bool, int, err
 
 	return availableSpots > 0, availableSpots, nil
 }
+
+var validTicketTypeGroups = map[string]bool{"guests": true, "bubble": true, "plus": true}
+
+// validateTicketType checks and normalizes a ticket type definition
+func validateTicketType(t *models.TicketType) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return errors.New("ticket type name is required")
+	}
+	if t.Price < 0 {
+		return errors.New("prices cannot be negative")
+	}
+	if t.Quota < 0 {
+		return errors.New("quota cannot be negative")
+	}
+	if t.SalesStart != nil && t.SalesEnd != nil && t.SalesStart.After(*t.SalesEnd) {
+		return errors.New("sales start must be before sales end")
+	}
+	groups := t.Groups()
+	for _, g := range groups {
+		if !validTicketTypeGroups[g] {
+			return errors.New("invalid allowed_groups; must be 'guests', 'bubble' or 'plus'")
+		}
+	}
+	t.AllowedGroups = strings.Join(groups, ",")
+	return nil
+}
+
+// GetTicketTypes returns the ticket types of an event in display order
+func (s *EventService) GetTicketTypes(eventID uuid.UUID) ([]*models.TicketType, error) {
+	var types []*models.TicketType
+	err := s.db.Where("event_id = ?", eventID).Order("sort_order ASC, price ASC").Find(&types).Error
+	return types, err
+}
+
+// CreateTicketType adds a ticket type to an event
+func (s *EventService) CreateTicketType(eventID uuid.UUID, t *models.TicketType) error {
+	if _, err := s.GetEventByID(eventID); err != nil {
+		return err
+	}
+	t.EventID = eventID
+	if err := validateTicketType(t); err != nil {
+		return err
+	}
+	return s.db.Create(t).Error
+}
+
+// UpdateTicketType replaces the definition of a ticket type.
+// Tickets already sold keep their price; the name shown on them is updated.
+func (s *EventService) UpdateTicketType(eventID, typeID uuid.UUID, t *models.TicketType) error {
+	var existing models.TicketType
+	if err := s.db.First(&existing, "id = ? AND event_id = ?", typeID, eventID).Error; err != nil {
+		return errors.New("ticket type not found")
+	}
+	if err := validateTicketType(t); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"name":           t.Name,
+			"description":    t.Description,
+			"price":          t.Price,
+			"quota":          t.Quota,
+			"sales_start":    t.SalesStart,
+			"sales_end":      t.SalesEnd,
+			"allowed_groups": t.AllowedGroups,
+			"sort_order":     t.SortOrder,
+			"is_active":      t.IsActive,
+		}).Error; err != nil {
+			return err
+		}
+		if existing.Name != t.Name {
+			return tx.Model(&models.Ticket{}).Where("ticket_type_id = ?", typeID).
+				Update("ticket_type_name", t.Name).Error
+		}
+		return nil
+	})
+}
+
+// DeleteTicketType removes a ticket type that was never sold
+func (s *EventService) DeleteTicketType(eventID, typeID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&models.Ticket{}).Where("ticket_type_id = ?", typeID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("ticket type has tickets; deactivate it instead")
+	}
+
+	result := s.db.Where("id = ? AND event_id = ?", typeID, eventID).Delete(&models.TicketType{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("ticket type not found")
+	}
+	return nil
+}
+
+// validateAddon checks and normalizes an add-on definition
+func validateAddon(a *models.EventAddon) error {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		return errors.New("add-on name is required")
+	}
+	if a.Price < 0 {
+		return errors.New("prices cannot be negative")
+	}
+	if a.MaxPerOrder < 0 {
+		return errors.New("max_per_order cannot be negative")
+	}
+	return nil
+}
+
+// GetAddons returns the add-ons of an event in display order
+func (s *EventService) GetAddons(eventID uuid.UUID, activeOnly bool) ([]*models.EventAddon, error) {
+	var addons []*models.EventAddon
+	query := s.db.Where("event_id = ?", eventID)
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	err := query.Order("sort_order ASC, name ASC").Find(&addons).Error
+	return addons, err
+}
+
+// CreateAddon adds an add-on to an event
+func (s *EventService) CreateAddon(eventID uuid.UUID, a *models.EventAddon) error {
+	if _, err := s.GetEventByID(eventID); err != nil {
+		return err
+	}
+	a.EventID = eventID
+	if err := validateAddon(a); err != nil {
+		return err
+	}
+	return s.db.Create(a).Error
+}
+
+// UpdateAddon replaces the definition of an add-on.
+// Orders already placed keep the name and price they were bought with.
+func (s *EventService) UpdateAddon(eventID, addonID uuid.UUID, a *models.EventAddon) error {
+	var existing models.EventAddon
+	if err := s.db.First(&existing, "id = ? AND event_id = ?", addonID, eventID).Error; err != nil {
+		return errors.New("add-on not found")
+	}
+	if err := validateAddon(a); err != nil {
+		return err
+	}
+	return s.db.Model(&existing).Updates(map[string]interface{}{
+		"name":          a.Name,
+		"description":   a.Description,
+		"price":         a.Price,
+		"max_per_order": a.MaxPerOrder,
+		"sort_order":    a.SortOrder,
+		"is_active":     a.IsActive,
+	}).Error
+}
+
+// DeleteAddon removes an add-on that was never ordered
+func (s *EventService) DeleteAddon(eventID, addonID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&models.OrderItem{}).Where("addon_id = ?", addonID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("add-on has been ordered; deactivate it instead")
+	}
+
+	result := s.db.Where("id = ? AND event_id = ?", addonID, eventID).Delete(&models.EventAddon{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("add-on not found")
+	}
+	return nil
+}
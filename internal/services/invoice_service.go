@@ -0,0 +1,636 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	invoiceSeries    = "RE" // Rechnung
+	creditNoteSeries = "GS" // Gutschrift
+)
+
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// InvoiceService issues invoices (Rechnungen) for paid tickets and credit notes (Gutschriften) for refunds.
+// Invoices are issued once per ticket and never changed; refunds are documented by credit notes
+// referencing the invoice. Numbers are taken from a locked counter in the same transaction that
+// stores the document, so a failed issue does not leave a gap.
+type InvoiceService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewInvoiceService(db *gorm.DB, cfg *config.Config) *InvoiceService {
+	return &InvoiceService{db: db, cfg: cfg}
+}
+
+// nextInvoiceNumber takes the next number of a series for the current year, e.g. RE-2026-00042
+func nextInvoiceNumber(tx *gorm.DB, prefix string, issuedAt time.Time) (string, error) {
+	series := fmt.Sprintf("%s-%d", prefix, issuedAt.In(berlinLocation()).Year())
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InvoiceCounter{Series: series}).Error; err != nil {
+		return "", err
+	}
+	var counter models.InvoiceCounter
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counter, "series = ?", series).Error; err != nil {
+		return "", err
+	}
+	counter.LastNumber++
+	if err := tx.Model(&counter).Update("last_number", counter.LastNumber).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%05d", series, counter.LastNumber), nil
+}
+
+func berlinLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// vatRate returns the VAT rate shown on an invoice line; 0 for small businesses (§ 19 UStG)
+func (s *InvoiceService) vatRate(rate int) int {
+	if s.cfg.InvoiceSmallBusiness {
+		return 0
+	}
+	return rate
+}
+
+// IssueInvoice returns the invoice of a paid ticket, issuing it on first call
+func (s *InvoiceService) IssueInvoice(ticketID uuid.UUID) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = s.issueInvoice(tx, ticketID)
+		return err
+	})
+	return invoice, err
+}
+
+// issueInvoice returns the existing invoice of a ticket or issues it within tx.
+// The ticket row is locked so concurrent calls issue one invoice.
+func (s *InvoiceService) issueInvoice(tx *gorm.DB, ticketID uuid.UUID) (*models.Invoice, error) {
+	var ticket models.Ticket
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, "id = ?", ticketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("ticket not found")
+		}
+		return nil, err
+	}
+
+	var existing models.Invoice
+	err := tx.Where("ticket_id = ? AND kind = ?", ticketID, models.InvoiceKindInvoice).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if _, ref := ticketPaymentRef(&ticket); ref == "" {
+		return nil, errors.New("ticket has not been paid")
+	}
+
+	var event models.Event
+	if err := tx.First(&event, "id = ?", ticket.EventID).Error; err != nil {
+		return nil, err
+	}
+	buyer, err := ticketPayer(tx, &ticket)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.ticketInvoiceLines(tx, &ticket, &event)
+	if err != nil {
+		return nil, err
+	}
+	if invoiceGross(lines) <= 0 {
+		return nil, errors.New("nothing to invoice for a free ticket")
+	}
+
+	invoice := &models.Invoice{
+		Kind:        models.InvoiceKindInvoice,
+		TicketID:    ticket.ID,
+		OrderID:     ticket.OrderID,
+		UserID:      buyer.ID,
+		BuyerName:   buyer.Name,
+		BuyerEmail:  buyer.Email,
+		ServiceDate: event.DateFrom,
+	}
+	if err := s.finishInvoice(tx, invoice, invoiceSeries, lines, nil); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// ticketPayer returns the user who paid for a ticket: the buyer of its order, or the ticket owner for tickets without order
+func ticketPayer(tx *gorm.DB, ticket *models.Ticket) (*models.User, error) {
+	userID := ticket.UserID
+	if ticket.OrderID != nil {
+		var order models.Order
+		if err := tx.Select("id", "user_id").First(&order, "id = ?", *ticket.OrderID).Error; err != nil {
+			return nil, err
+		}
+		userID = order.UserID
+	}
+	var user models.User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ticketInvoiceLines returns the lines invoiced with a ticket: its ticket and pickup items at the
+// VAT rates stored on them and, for the first ticket of an order, the add-ons of the order.
+// Tickets bought before orders existed are invoiced from the ticket's own prices.
+func (s *InvoiceService) ticketInvoiceLines(tx *gorm.DB, ticket *models.Ticket, event *models.Event) ([]models.InvoiceLine, error) {
+	if ticket.OrderID == nil {
+		rates := eventTaxRates(s.cfg, event)
+		lines := []models.InvoiceLine{{
+			Description: ticketLineItemName(ticket, event),
+			Quantity:    1,
+			UnitPrice:   ticket.Price,
+			Discount:    ticket.DiscountAmount,
+			Amount:      ticket.Price - ticket.DiscountAmount,
+			VATRate:     s.vatRate(rates[models.OrderItemTicket]),
+		}}
+		if ticket.IncludesPickup && ticket.PickupPrice > 0 {
+			lines = append(lines, models.InvoiceLine{
+				Description: "Abholservice",
+				Quantity:    1,
+				UnitPrice:   ticket.PickupPrice,
+				Amount:      ticket.PickupPrice,
+				VATRate:     s.vatRate(rates[models.OrderItemPickup]),
+			})
+		}
+		return lines, nil
+	}
+
+	var first models.Ticket
+	if err := tx.Select("id").Where("order_id = ?", *ticket.OrderID).
+		Order("created_at ASC, id ASC").First(&first).Error; err != nil {
+		return nil, err
+	}
+	query := tx.Where("order_id = ?", *ticket.OrderID)
+	if first.ID == ticket.ID {
+		query = query.Where("ticket_id = ? OR kind = ?", ticket.ID, models.OrderItemAddon)
+	} else {
+		query = query.Where("ticket_id = ?", ticket.ID)
+	}
+	var items []models.OrderItem
+	if err := query.Order("created_at ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.InvoiceLine, 0, len(items))
+	for i := range items {
+		item := &items[i]
+		itemID := item.ID
+		desc := item.Name
+		if item.Kind == models.OrderItemTicket && ticket.HolderName != "" {
+			desc += " – für " + ticket.HolderName
+		}
+		lines = append(lines, models.InvoiceLine{
+			OrderItemID: &itemID,
+			Description: desc,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Discount:    item.DiscountAmount,
+			Amount:      item.Amount,
+			VATRate:     s.vatRate(item.TaxRate),
+		})
+	}
+	return lines, nil
+}
+
+// IssueCreditNote documents a succeeded refund with a credit note against the invoice of the refunded ticket.
+// The invoice is issued first if the buyer never requested it. Refunds of transfer charges are not
+// invoiced and get no credit note.
+func (s *InvoiceService) IssueCreditNote(r *models.Refund) (*models.Invoice, error) {
+	if r == nil || r.Status != models.RefundStatusSucceeded || r.Reason == models.RefundReasonTransferCharge {
+		return nil, nil
+	}
+
+	var creditNote *models.Invoice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Invoice
+		err := tx.Where("refund_id = ?", r.ID).First(&existing).Error
+		if err == nil {
+			creditNote = &existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		ticketID, err := refundInvoiceTicket(tx, r)
+		if err != nil || ticketID == uuid.Nil {
+			return err
+		}
+		invoice, err := s.issueInvoice(tx, ticketID)
+		if err != nil {
+			return err
+		}
+
+		creditNote = &models.Invoice{
+			Kind:              models.InvoiceKindCreditNote,
+			TicketID:          invoice.TicketID,
+			OrderID:           invoice.OrderID,
+			UserID:            invoice.UserID,
+			RefundID:          &r.ID,
+			CorrectsInvoiceID: &invoice.ID,
+			BuyerName:         invoice.BuyerName,
+			BuyerEmail:        invoice.BuyerEmail,
+			ServiceDate:       invoice.ServiceDate,
+		}
+		return s.finishInvoice(tx, creditNote, creditNoteSeries, creditNoteLines(invoice, r), invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return creditNote, nil
+}
+
+// refundInvoiceTicket returns the ticket whose invoice a refund corrects. Order item refunds carry the
+// ticket holding the payment, so the ticket is taken from the item; add-ons belong to the first ticket.
+func refundInvoiceTicket(tx *gorm.DB, r *models.Refund) (uuid.UUID, error) {
+	if r.OrderItemID == nil {
+		if r.TicketID == nil {
+			return uuid.Nil, nil
+		}
+		return *r.TicketID, nil
+	}
+	var item models.OrderItem
+	if err := tx.First(&item, "id = ?", *r.OrderItemID).Error; err != nil {
+		return uuid.Nil, err
+	}
+	if item.TicketID != nil {
+		return *item.TicketID, nil
+	}
+	var first models.Ticket
+	if err := tx.Select("id").Where("order_id = ?", item.OrderID).
+		Order("created_at ASC, id ASC").First(&first).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return first.ID, nil
+}
+
+// creditNoteLines splits a refund over the VAT rates of the corrected invoice. A refund of a single
+// order item uses the rate of its line; otherwise the amount is shared in proportion to each rate's gross.
+func creditNoteLines(invoice *models.Invoice, r *models.Refund) []models.InvoiceLine {
+	desc := fmt.Sprintf("Erstattung zu Rechnung %s", invoice.Number)
+	lines := invoice.InvoiceLines()
+
+	if r.OrderItemID != nil {
+		for _, l := range lines {
+			if l.OrderItemID != nil && *l.OrderItemID == *r.OrderItemID {
+				return []models.InvoiceLine{{
+					OrderItemID: l.OrderItemID,
+					Description: desc + ": " + l.Description,
+					Quantity:    1,
+					UnitPrice:   -r.Amount,
+					Amount:      -r.Amount,
+					VATRate:     l.VATRate,
+				}}
+			}
+		}
+	}
+
+	groups := vatBreakdown(lines)
+	total := invoiceGross(lines)
+	var out []models.InvoiceLine
+	remaining := r.Amount
+	for i, g := range groups {
+		amount := remaining
+		if i < len(groups)-1 && total != 0 {
+			amount = r.Amount.MulDiv(g.Gross.Cents(), total.Cents())
+		}
+		remaining -= amount
+		lineDesc := desc
+		if len(groups) > 1 {
+			lineDesc += fmt.Sprintf(" (%d %% USt)", g.Rate)
+		}
+		out = append(out, models.InvoiceLine{
+			Description: lineDesc,
+			Quantity:    1,
+			UnitPrice:   -amount,
+			Amount:      -amount,
+			VATRate:     g.Rate,
+		})
+	}
+	return out
+}
+
+// finishInvoice fills seller snapshot, totals and number, renders the PDF and stores the document within tx.
+// The counter row stays locked until tx ends, so everything that does not need the number (totals and
+// the PDF layout) is done before taking it; the number is filled into the laid out PDF afterwards.
+func (s *InvoiceService) finishInvoice(tx *gorm.DB, invoice *models.Invoice, series string, lines []models.InvoiceLine, corrects *models.Invoice) error {
+	linesJSON, err := json.Marshal(lines)
+	if err != nil {
+		return err
+	}
+
+	invoice.IssuedAt = time.Now()
+	invoice.SellerName = s.cfg.InvoiceSellerName
+	invoice.SellerAddress = strings.Join(s.sellerAddress(), "\n")
+	invoice.SellerTaxID = s.sellerTaxID()
+	invoice.SmallBusiness = s.cfg.InvoiceSmallBusiness
+	invoice.Lines = string(linesJSON)
+	for _, g := range vatBreakdown(lines) {
+		invoice.NetAmount += g.Net
+		invoice.VATAmount += g.VAT
+		invoice.GrossAmount += g.Gross
+	}
+	pdf := s.layoutPDF(invoice, lines, corrects)
+
+	number, err := nextInvoiceNumber(tx, series, invoice.IssuedAt)
+	if err != nil {
+		return fmt.Errorf("failed to assign invoice number: %w", err)
+	}
+	invoice.Number = number
+	pdf.RegisterAlias(invoiceNumberAlias, number)
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return fmt.Errorf("failed to render invoice: %w", err)
+	}
+	invoice.PDF = out.Bytes()
+
+	if err := tx.Create(invoice).Error; err != nil {
+		return fmt.Errorf("failed to store invoice: %w", err)
+	}
+	return nil
+}
+
+func (s *InvoiceService) sellerAddress() []string {
+	var lines []string
+	for _, l := range s.cfg.InvoiceSellerAddress {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// sellerTaxID returns the USt-IdNr. or, if there is none, the Steuernummer
+func (s *InvoiceService) sellerTaxID() string {
+	if s.cfg.InvoiceSellerVATID != "" {
+		return "USt-IdNr.: " + s.cfg.InvoiceSellerVATID
+	}
+	if s.cfg.InvoiceSellerTaxNumber != "" {
+		return "Steuernummer: " + s.cfg.InvoiceSellerTaxNumber
+	}
+	return ""
+}
+
+// vatGroup sums the lines of one VAT rate; VAT is calculated from the gross amounts
+type vatGroup struct {
+	Rate  int
+	Gross models.Money
+	Net   models.Money
+	VAT   models.Money
+}
+
+func vatBreakdown(lines []models.InvoiceLine) []vatGroup {
+	byRate := map[int]*vatGroup{}
+	for _, l := range lines {
+		g, ok := byRate[l.VATRate]
+		if !ok {
+			g = &vatGroup{Rate: l.VATRate}
+			byRate[l.VATRate] = g
+		}
+		g.Gross += l.Amount
+	}
+	groups := make([]vatGroup, 0, len(byRate))
+	for _, g := range byRate {
+		g.Net, g.VAT = models.SplitGross(g.Gross, g.Rate)
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Rate > groups[j].Rate })
+	return groups
+}
+
+func invoiceGross(lines []models.InvoiceLine) models.Money {
+	var total models.Money
+	for _, l := range lines {
+		total += l.Amount
+	}
+	return total
+}
+
+// invoiceNumberAlias stands in for the invoice number in a laid out PDF until the number is assigned
+const invoiceNumberAlias = "{invoice_number}"
+
+// layoutPDF lays out an invoice or credit note on A4. The number is left as invoiceNumberAlias;
+// register it with RegisterAlias before writing the PDF.
+func (s *InvoiceService) layoutPDF(invoice *models.Invoice, lines []models.InvoiceLine, corrects *models.Invoice) *gofpdf.Fpdf {
+	loc := berlinLocation()
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 25)
+	pdf.AddPage()
+
+	// Seller
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 7, tr(invoice.SellerName), "", 1, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	for _, l := range strings.Split(invoice.SellerAddress, "\n") {
+		if l != "" {
+			pdf.CellFormat(0, 4.5, tr(l), "", 1, "R", false, 0, "")
+		}
+	}
+	if s.cfg.InvoiceSellerEmail != "" {
+		pdf.CellFormat(0, 4.5, tr(s.cfg.InvoiceSellerEmail), "", 1, "R", false, 0, "")
+	}
+
+	// Buyer
+	pdf.SetY(55)
+	pdf.SetFont("Arial", "", 11)
+	pdf.CellFormat(0, 5.5, tr(invoice.BuyerName), "", 1, "L", false, 0, "")
+	if invoice.BuyerEmail != "" {
+		pdf.CellFormat(0, 5.5, tr(invoice.BuyerEmail), "", 1, "L", false, 0, "")
+	}
+
+	// Title and document data
+	title := "Rechnung"
+	if invoice.Kind == models.InvoiceKindCreditNote {
+		title = "Gutschrift (Rechnungskorrektur)"
+	}
+	pdf.SetY(85)
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(0, 9, tr(title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	meta := [][2]string{
+		{"Nummer", invoiceNumberAlias},
+		{"Datum", invoice.IssuedAt.In(loc).Format("02.01.2006")},
+		{"Leistungsdatum", invoice.ServiceDate.In(loc).Format("02.01.2006")},
+	}
+	if corrects != nil {
+		meta = append(meta, [2]string{"Zu Rechnung", fmt.Sprintf("%s vom %s", corrects.Number, corrects.IssuedAt.In(loc).Format("02.01.2006"))})
+	}
+	for _, m := range meta {
+		pdf.CellFormat(40, 5.5, tr(m[0]+":"), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5.5, tr(m[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// Lines (gross prices)
+	widths := []float64{10, 86, 14, 25, 15, 20}
+	headers := []string{"Pos.", "Beschreibung", "Menge", "Einzelpreis", "USt", "Betrag"}
+	aligns := []string{"L", "L", "R", "R", "R", "R"}
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for i, h := range headers {
+		pdf.CellFormat(widths[i], 7, tr(h), "B", 0, aligns[i], true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Arial", "", 9)
+	for i, l := range lines {
+		// Discounts are part of the item name (see ticketLineItemName)
+		desc := l.Description
+		for pdf.GetStringWidth(tr(desc)) > widths[1]-2 && len([]rune(desc)) > 4 {
+			r := []rune(desc)
+			desc = string(r[:len(r)-4]) + "..."
+		}
+		vat := fmt.Sprintf("%d %%", l.VATRate)
+		if invoice.SmallBusiness {
+			vat = ""
+		}
+		cells := []string{
+			fmt.Sprintf("%d", i+1),
+			desc,
+			fmt.Sprintf("%d", l.Quantity),
+			l.UnitPrice.Format(),
+			vat,
+			l.Amount.Format(),
+		}
+		for j, c := range cells {
+			pdf.CellFormat(widths[j], 6, tr(c), "", 0, aligns[j], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
+	pdf.Ln(3)
+
+	// Totals and VAT breakdown
+	total := func(label, value string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Arial", style, 10)
+		pdf.CellFormat(130, 6, tr(label), "", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 6, tr(value), "", 1, "R", false, 0, "")
+	}
+	if invoice.SmallBusiness {
+		total("Gesamtbetrag", invoice.GrossAmount.Format(), true)
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "", 9)
+		pdf.MultiCell(0, 5, tr("Gemäß § 19 UStG wird keine Umsatzsteuer berechnet."), "", "L", false)
+	} else {
+		for _, g := range vatBreakdown(lines) {
+			total(fmt.Sprintf("Nettobetrag (%d %% USt)", g.Rate), g.Net.Format(), false)
+			total(fmt.Sprintf("zzgl. %d %% USt", g.Rate), g.VAT.Format(), false)
+		}
+		total("Gesamtbetrag", invoice.GrossAmount.Format(), true)
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Arial", "", 9)
+	if invoice.Kind == models.InvoiceKindCreditNote {
+		pdf.MultiCell(0, 5, tr("Der Betrag wurde über das ursprüngliche Zahlungsmittel erstattet."), "", "L", false)
+	} else {
+		pdf.MultiCell(0, 5, tr("Der Rechnungsbetrag wurde bereits bezahlt."), "", "L", false)
+	}
+
+	// Footer with tax number
+	if invoice.SellerTaxID != "" {
+		pdf.SetAutoPageBreak(false, 0)
+		pdf.SetY(-25)
+		pdf.SetFont("Arial", "", 8)
+		pdf.CellFormat(0, 4, tr(invoice.SellerName+" · "+invoice.SellerTaxID), "", 1, "C", false, 0, "")
+	}
+	return pdf
+}
+
+// GetTicketInvoices lists the invoice and credit notes of a ticket issued to the given user
+func (s *InvoiceService) GetTicketInvoices(ticketID, userID uuid.UUID) ([]*models.Invoice, error) {
+	var invoices []*models.Invoice
+	err := s.db.Omit("pdf").Where("ticket_id = ? AND user_id = ?", ticketID, userID).
+		Order("issued_at ASC").Find(&invoices).Error
+	return invoices, err
+}
+
+// UserTicketInvoice returns the invoice of a ticket if the user paid for it, issuing it on first access
+func (s *InvoiceService) UserTicketInvoice(ticketID, userID uuid.UUID) (*models.Invoice, error) {
+	var ticket models.Ticket
+	if err := s.db.First(&ticket, "id = ?", ticketID).Error; err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	payer, err := ticketPayer(s.db, &ticket)
+	if err != nil || payer.ID != userID {
+		return nil, ErrInvoiceNotFound
+	}
+	return s.IssueInvoice(ticketID)
+}
+
+// GetUserInvoice returns an invoice or credit note of a ticket issued to the given user
+func (s *InvoiceService) GetUserInvoice(invoiceID, ticketID, userID uuid.UUID) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := s.db.Where("id = ? AND ticket_id = ? AND user_id = ?", invoiceID, ticketID, userID).First(&invoice).Error; err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	return &invoice, nil
+}
+
+// GetInvoice returns an invoice or credit note by ID (admin)
+func (s *InvoiceService) GetInvoice(invoiceID uuid.UUID) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := s.db.First(&invoice, "id = ?", invoiceID).Error; err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	return &invoice, nil
+}
+
+// GetInvoices lists issued documents for the accountant, newest first. Empty filters are ignored;
+// from is inclusive, to exclusive.
+func (s *InvoiceService) GetInvoices(page, limit int, kind string, from, to *time.Time) ([]*models.Invoice, int64, error) {
+	var invoices []*models.Invoice
+	var total int64
+
+	query := s.db.Model(&models.Invoice{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if from != nil {
+		query = query.Where("issued_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("issued_at < ?", *to)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	err := query.Omit("pdf").Order("issued_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&invoices).Error
+	return invoices, total, err
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LedgerService reads the payment transaction ledger and books manual adjustments.
+// Payments, refunds and fees are booked by the payment code paths through the record* helpers below.
+type LedgerService struct {
+	db *gorm.DB
+}
+
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{db: db}
+}
+
+// appendTransaction adds an entry to the ledger; an entry with an already booked idempotency key is skipped
+func appendTransaction(tx *gorm.DB, entry *models.PaymentTransaction) error {
+	if entry.Amount == 0 {
+		return nil
+	}
+	if entry.IdempotencyKey == nil {
+		return tx.Create(entry).Error
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "idempotency_key"}},
+		DoNothing: true,
+	}).Create(entry).Error
+}
+
+func ledgerKey(parts ...string) *string {
+	key := parts[0]
+	for _, p := range parts[1:] {
+		key += ":" + p
+	}
+	return &key
+}
+
+// checkoutTransactionType returns the ledger type of money received through a provider checkout
+func checkoutTransactionType(provider string) string {
+	if provider == "paypal" {
+		return models.TransactionCapture
+	}
+	return models.TransactionPayment
+}
+
+// recordOrderPayment books the payment of the order a ticket belongs to. The checkout covers the
+// whole order, so it is booked once per order no matter which ticket or notification confirms it.
+func recordOrderPayment(tx *gorm.DB, ticketID uuid.UUID, provider, providerRef string) error {
+	order, err := ledgerOrder(tx, ticketID)
+	if err != nil || order == nil {
+		return err
+	}
+	return appendTransaction(tx, &models.PaymentTransaction{
+		Type:           checkoutTransactionType(provider),
+		Amount:         order.TotalAmount,
+		Provider:       provider,
+		ProviderRef:    providerRef,
+		EventID:        &order.EventID,
+		OrderID:        &order.ID,
+		TicketID:       &ticketID,
+		Description:    "Bestellung",
+		IdempotencyKey: ledgerKey("payment", "order", order.ID.String()),
+	})
+}
+
+// reverseOrderPayment books back the payment of an order that the provider denied after it had been booked
+func reverseOrderPayment(tx *gorm.DB, ticketID uuid.UUID, provider, providerRef string) error {
+	order, err := ledgerOrder(tx, ticketID)
+	if err != nil || order == nil {
+		return err
+	}
+	var booked models.PaymentTransaction
+	if err := tx.Where("idempotency_key = ?", *ledgerKey("payment", "order", order.ID.String())).First(&booked).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return appendTransaction(tx, &models.PaymentTransaction{
+		Type:           models.TransactionAdjustment,
+		Amount:         -booked.Amount,
+		Provider:       provider,
+		ProviderRef:    providerRef,
+		EventID:        &order.EventID,
+		OrderID:        &order.ID,
+		TicketID:       &ticketID,
+		Description:    "Zahlung abgelehnt",
+		IdempotencyKey: ledgerKey("payment", "order", order.ID.String(), "denied"),
+	})
+}
+
+// recordOrderFee books the provider fee withheld from the payment of an order
+func recordOrderFee(tx *gorm.DB, ticketID uuid.UUID, provider, providerRef string, fee models.Money) error {
+	if fee <= 0 {
+		return nil
+	}
+	order, err := ledgerOrder(tx, ticketID)
+	if err != nil || order == nil {
+		return err
+	}
+	return appendTransaction(tx, &models.PaymentTransaction{
+		Type:           models.TransactionFee,
+		Amount:         -fee,
+		Provider:       provider,
+		ProviderRef:    providerRef,
+		EventID:        &order.EventID,
+		OrderID:        &order.ID,
+		TicketID:       &ticketID,
+		Description:    "Gebühr Zahlungsanbieter",
+		IdempotencyKey: ledgerKey("fee", "order", order.ID.String()),
+	})
+}
+
+// ledgerOrder returns the order of a ticket, or nil if the ticket has none
+func ledgerOrder(tx *gorm.DB, ticketID uuid.UUID) (*models.Order, error) {
+	var ticket models.Ticket
+	if err := tx.Unscoped().Select("id", "order_id").First(&ticket, "id = ?", ticketID).Error; err != nil {
+		return nil, err
+	}
+	if ticket.OrderID == nil {
+		return nil, nil
+	}
+	var order models.Order
+	if err := tx.First(&order, "id = ?", *ticket.OrderID).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// recordTransferCharge books the price difference a transfer recipient paid
+func recordTransferCharge(tx *gorm.DB, transfer *models.TicketTransfer, eventID uuid.UUID, paymentRef string) error {
+	ticketID := transfer.TicketID
+	return appendTransaction(tx, &models.PaymentTransaction{
+		Type:           checkoutTransactionType(transfer.ChargeProvider),
+		Amount:         transfer.PriceDifference,
+		Provider:       transfer.ChargeProvider,
+		ProviderRef:    paymentRef,
+		EventID:        &eventID,
+		TicketID:       &ticketID,
+		TransferID:     &transfer.ID,
+		Description:    "Aufpreis Ticket-Übertragung",
+		IdempotencyKey: ledgerKey("payment", "transfer", transfer.ID.String()),
+	})
+}
+
+// recordRefundTransaction books a refund the provider has carried out
+func recordRefundTransaction(tx *gorm.DB, r *models.Refund) error {
+	entry := &models.PaymentTransaction{
+		Type:           models.TransactionRefund,
+		Amount:         -r.Amount,
+		Provider:       r.Provider,
+		ProviderRef:    r.ProviderRefundID,
+		OrderID:        r.OrderID,
+		TicketID:       r.TicketID,
+		RefundID:       &r.ID,
+		TransferID:     r.TransferID,
+		Description:    "Erstattung: " + r.Reason,
+		IdempotencyKey: ledgerKey("refund", r.ID.String()),
+	}
+	var eventID uuid.UUID
+	if r.OrderID != nil {
+		tx.Model(&models.Order{}).Select("event_id").Where("id = ?", *r.OrderID).Scan(&eventID)
+	} else if r.TicketID != nil {
+		tx.Unscoped().Model(&models.Ticket{}).Select("event_id").Where("id = ?", *r.TicketID).Scan(&eventID)
+	}
+	if eventID != uuid.Nil {
+		entry.EventID = &eventID
+	}
+	return appendTransaction(tx, entry)
+}
+
+// logLedgerError reports a ledger entry that could not be booked after the money already moved
+func logLedgerError(what string, err error) {
+	if err != nil {
+		log.Printf("Ledger: CRITICAL - failed to book %s: %v", what, err)
+	}
+}
+
+// AddAdjustment books a manual correction (e.g. cash payment, provider payout difference)
+func (s *LedgerService) AddAdjustment(entry *models.PaymentTransaction) error {
+	if entry.Amount == 0 {
+		return errors.New("amount must not be zero")
+	}
+	if entry.Description == "" {
+		return errors.New("description is required")
+	}
+	if entry.Provider != "" && entry.Provider != "stripe" && entry.Provider != "paypal" {
+		return errors.New("provider must be stripe, paypal or empty")
+	}
+	entry.Type = models.TransactionAdjustment
+	entry.IdempotencyKey = nil
+	return appendTransaction(s.db, entry)
+}
+
+// LedgerFilter narrows down ledger queries; zero values are ignored
+type LedgerFilter struct {
+	EventID  *uuid.UUID
+	Type     string
+	Provider string
+	From     *time.Time // inclusive
+	To       *time.Time // exclusive
+}
+
+func (f LedgerFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.EventID != nil {
+		query = query.Where("payment_transactions.event_id = ?", *f.EventID)
+	}
+	if f.Type != "" {
+		query = query.Where("payment_transactions.type = ?", f.Type)
+	}
+	if f.Provider != "" {
+		query = query.Where("payment_transactions.provider = ?", f.Provider)
+	}
+	if f.From != nil {
+		query = query.Where("payment_transactions.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("payment_transactions.created_at < ?", *f.To)
+	}
+	return query
+}
+
+// GetTransactions lists ledger entries, newest first
+func (s *LedgerService) GetTransactions(page, limit int, filter LedgerFilter) ([]*models.PaymentTransaction, int64, error) {
+	var entries []*models.PaymentTransaction
+	var total int64
+
+	query := filter.apply(s.db.Model(&models.PaymentTransaction{}))
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
+
+// LedgerBalance sums the ledger entries of one group
+type LedgerBalance struct {
+	Key          string       `json:"key"`             // event ID, provider or day (YYYY-MM-DD, Europe/Berlin)
+	Label        string       `json:"label,omitempty"` // event name
+	Payments     models.Money `json:"payments"`
+	Refunds      models.Money `json:"refunds"`
+	Fees         models.Money `json:"fees"`
+	Adjustments  models.Money `json:"adjustments"`
+	Net          models.Money `json:"net"`
+	Transactions int64        `json:"transactions"`
+}
+
+// Balances sums the ledger per event, provider or day
+func (s *LedgerService) Balances(groupBy string, filter LedgerFilter) ([]LedgerBalance, error) {
+	var keyExpr, group string
+	labelExpr := "''"
+	query := s.db.Table("payment_transactions")
+	switch groupBy {
+	case "event":
+		keyExpr = "COALESCE(CAST(payment_transactions.event_id AS text), '')"
+		labelExpr = "COALESCE(events.name, '')"
+		group = keyExpr + ", " + labelExpr
+		query = query.Joins("LEFT JOIN events ON events.id = payment_transactions.event_id")
+	case "provider":
+		keyExpr = "COALESCE(NULLIF(payment_transactions.provider, ''), 'manual')"
+		group = keyExpr
+	case "day":
+		keyExpr = "TO_CHAR(payment_transactions.created_at AT TIME ZONE 'Europe/Berlin', 'YYYY-MM-DD')"
+		group = keyExpr
+	default:
+		return nil, fmt.Errorf("unknown grouping: %s", groupBy)
+	}
+
+	var rows []LedgerBalance
+	err := filter.apply(query).
+		Select(fmt.Sprintf(`%s AS key, %s AS label,
+			COALESCE(SUM(CASE WHEN payment_transactions.type IN ('payment', 'capture') THEN payment_transactions.amount ELSE 0 END), 0)::bigint AS payments,
+			COALESCE(SUM(CASE WHEN payment_transactions.type = 'refund' THEN payment_transactions.amount ELSE 0 END), 0)::bigint AS refunds,
+			COALESCE(SUM(CASE WHEN payment_transactions.type = 'fee' THEN payment_transactions.amount ELSE 0 END), 0)::bigint AS fees,
+			COALESCE(SUM(CASE WHEN payment_transactions.type = 'adjustment' THEN payment_transactions.amount ELSE 0 END), 0)::bigint AS adjustments,
+			COALESCE(SUM(payment_transactions.amount), 0)::bigint AS net,
+			COUNT(*) AS transactions`, keyExpr, labelExpr)).
+		Group(group).
+		Order("key").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
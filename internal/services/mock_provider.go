@@ -0,0 +1,605 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stripe/stripe-go/v82"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	MockOutcomeSuccess      = "success"       // paid right away
+	MockOutcomeDecline      = "decline"       // payment declined, checkout stays open
+	MockOutcomeDelayed      = "delayed"       // paid after PaymentMockDelaySeconds (e.g. SEPA)
+	MockOutcomeAsyncFailure = "async_failure" // looks paid to the buyer, fails after PaymentMockDelaySeconds
+	MockOutcomeCancel       = "cancel"        // buyer leaves the checkout
+
+	mockStatusOpen       = "open"
+	mockStatusProcessing = "processing"
+	mockStatusPaid       = "paid"
+	mockStatusFailed     = "failed"
+	mockStatusCancelled  = "cancelled"
+)
+
+// MockOutcomes lists the outcomes a mock checkout can be completed with
+var MockOutcomes = []string{MockOutcomeSuccess, MockOutcomeDecline, MockOutcomeDelayed, MockOutcomeAsyncFailure, MockOutcomeCancel}
+
+// mockPaymentEnvs are the only environments in which PAYMENT_MOCK_ENABLED takes effect
+var mockPaymentEnvs = map[string]bool{"development": true, "test": true}
+
+// mockPaymentsEnabled reports whether the mock provider replaces Stripe and PayPal.
+// Any other ENV (production, staging, a typo) keeps the real providers.
+func mockPaymentsEnabled(cfg *config.Config) bool {
+	return cfg != nil && cfg.PaymentMockEnabled && mockPaymentEnvs[cfg.Env]
+}
+
+// MockCheckout is a checkout of the mock provider
+type MockCheckout struct {
+	ID          string            `json:"id"`
+	Provider    string            `json:"provider"` // provider the mock stands in for: stripe or paypal
+	Description string            `json:"description"`
+	Amount      models.Money      `json:"amount"`
+	Metadata    map[string]string `json:"metadata"`
+	Status      string            `json:"status"` // open, processing, paid, failed, cancelled
+	Outcome     string            `json:"outcome,omitempty"`
+	PaymentRef  string            `json:"payment_ref,omitempty"` // payment intent / capture ID once completed
+	Refunded    models.Money      `json:"refunded"`
+	CreatedAt   time.Time         `json:"created_at"`
+	SettleAt    time.Time         `json:"settle_at,omitempty"`
+	successURL  string
+	cancelURL   string
+}
+
+// mockPayments keeps the checkouts of all mock providers. TicketService is also built ad hoc
+// (e.g. by admin handlers), so the state must not belong to one provider instance. It is lost on restart
+// and only exists in this process: with more than one API instance behind a load balancer, a checkout,
+// webhook or refund reaching another instance than the one that created the checkout fails.
+// Run the mock with a single instance.
+var mockPayments = struct {
+	sync.Mutex
+	checkouts map[string]*MockCheckout // by checkout ID
+	payments  map[string]*MockCheckout // by payment reference
+	refunds   map[string]string        // refund IDs by idempotency key
+}{
+	checkouts: map[string]*MockCheckout{},
+	payments:  map[string]*MockCheckout{},
+	refunds:   map[string]string{},
+}
+
+// MockProvider implements PaymentProvider without network access for development and tests.
+// It stands in for Stripe or PayPal under their name, so tickets, polling and webhooks keep
+// their usual fields and paths: checkouts are completed on a fake hosted page served by this
+// API, and the outcome is reported with simulated webhooks posted to our own webhook handlers.
+type MockProvider struct {
+	cfg  *config.Config
+	db   *gorm.DB
+	name string
+}
+
+// NewMockProvider creates a mock provider standing in for "stripe" or "paypal"
+func NewMockProvider(cfg *config.Config, db *gorm.DB, name string) *MockProvider {
+	return &MockProvider{cfg: cfg, db: db, name: name}
+}
+
+// GetProviderName returns the name of the provider the mock stands in for
+func (p *MockProvider) GetProviderName() string {
+	return p.name
+}
+
+func mockID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// checkoutURL is the fake hosted checkout page of a checkout
+func (p *MockProvider) checkoutURL(id string) string {
+	return fmt.Sprintf("%s/api/v1/mock-payments/checkout/%s", strings.TrimRight(p.cfg.PublicURL, "/"), id)
+}
+
+func (p *MockProvider) newCheckout(description string, amount models.Money, metadata map[string]string) *MockCheckout {
+	id := mockID("cs_mock_")
+	if p.name == "paypal" {
+		id = mockID("MOCK-")
+	}
+	return &MockCheckout{
+		ID:          id,
+		Provider:    p.name,
+		Description: description,
+		Amount:      amount,
+		Metadata:    metadata,
+		Status:      mockStatusOpen,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// CreateCheckout creates a mock checkout for the order total
+func (p *MockProvider) CreateCheckout(order *models.Order, event *models.Event, user *models.User) (string, error) {
+	ticket := &order.Tickets[0]
+	c := p.newCheckout(paypalOrderDescription(order, event), order.TotalAmount, map[string]string{
+		"ticket_id": ticket.ID.String(),
+		"order_id":  order.ID.String(),
+		"user_id":   user.ID.String(),
+		"event_id":  event.ID.String(),
+	})
+
+	updates := map[string]interface{}{"payment_provider": p.name}
+	if p.name == "paypal" {
+		c.successURL = fmt.Sprintf("%s?ticket_id=%s&token=%s&PayerID=MOCKPAYER", p.cfg.PayPalSuccessURL, ticket.ID, c.ID)
+		c.cancelURL = fmt.Sprintf("%s?ticket_id=%s", p.cfg.PayPalCancelURL, ticket.ID)
+		updates["paypal_order_id"] = c.ID
+	} else {
+		c.successURL = fmt.Sprintf("%s?ticket_id=%s&session_id=%s", p.cfg.StripeSuccessURL, ticket.ID, c.ID)
+		c.cancelURL = fmt.Sprintf("%s?ticket_id=%s", p.cfg.StripeCancelURL, ticket.ID)
+		updates["stripe_session_id"] = c.ID
+	}
+
+	if err := p.db.Model(&models.Ticket{}).Scopes(models.SameCheckout(ticket.ID)).Updates(updates).Error; err != nil {
+		return "", fmt.Errorf("failed to save ticket: %w", err)
+	}
+	for i := range order.Tickets {
+		order.Tickets[i].PaymentProvider = p.name
+		if p.name == "paypal" {
+			order.Tickets[i].PayPalOrderID = c.ID
+		} else {
+			order.Tickets[i].StripeSessionID = c.ID
+		}
+	}
+
+	mockPayments.Lock()
+	mockPayments.checkouts[c.ID] = c
+	mockPayments.Unlock()
+
+	log.Printf("Mock payment: %s checkout %s for ticket %s (%s EUR)", p.name, c.ID, ticket.ID, c.Amount)
+	return p.checkoutURL(c.ID), nil
+}
+
+// CreateChargeCheckout creates a mock checkout for an extra amount carrying the charge reference
+func (p *MockProvider) CreateChargeCheckout(ticket *models.Ticket, user *models.User, amount models.Money, description, reference string) (string, string, error) {
+	c := p.newCheckout(description, amount, map[string]string{
+		"charge_ref":        reference,
+		"related_ticket_id": ticket.ID.String(),
+		"user_id":           user.ID.String(),
+	})
+	successURL, cancelURL := p.cfg.StripeSuccessURL, p.cfg.StripeCancelURL
+	if p.name == "paypal" {
+		successURL, cancelURL = p.cfg.PayPalSuccessURL, p.cfg.PayPalCancelURL
+	}
+	c.successURL = fmt.Sprintf("%s?charge_ref=%s&session_id=%s", successURL, reference, c.ID)
+	c.cancelURL = fmt.Sprintf("%s?charge_ref=%s", cancelURL, reference)
+
+	mockPayments.Lock()
+	mockPayments.checkouts[c.ID] = c
+	mockPayments.Unlock()
+
+	return p.checkoutURL(c.ID), c.ID, nil
+}
+
+// settle moves a processing checkout to its final state once its settle time has passed (caller holds the lock)
+func (c *MockCheckout) settle(now time.Time) {
+	if c.Status != mockStatusProcessing || now.Before(c.SettleAt) {
+		return
+	}
+	if c.Outcome == MockOutcomeAsyncFailure {
+		c.Status = mockStatusFailed
+	} else {
+		c.Status = mockStatusPaid
+	}
+}
+
+// paidCheckout returns the payment reference of a checkout once it is paid
+func paidCheckout(id string) (string, bool, error) {
+	mockPayments.Lock()
+	defer mockPayments.Unlock()
+	c, ok := mockPayments.checkouts[id]
+	if !ok {
+		return "", false, fmt.Errorf("mock checkout %s not found", id)
+	}
+	c.settle(time.Now())
+	return c.PaymentRef, c.Status == mockStatusPaid, nil
+}
+
+// GetCheckout returns a copy of a mock checkout
+func (p *MockProvider) GetCheckout(id string) (*MockCheckout, error) {
+	mockPayments.Lock()
+	defer mockPayments.Unlock()
+	c, ok := mockPayments.checkouts[id]
+	if !ok {
+		return nil, errors.New("checkout not found")
+	}
+	c.settle(time.Now())
+	cp := *c
+	return &cp, nil
+}
+
+// Complete finishes a checkout as the buyer would on the hosted page and returns the URL to send the buyer to.
+// Webhooks for the outcome are delivered in the background; delayed outcomes after PaymentMockDelaySeconds.
+func (p *MockProvider) Complete(id, outcome string) (string, error) {
+	if outcome == "" {
+		outcome = p.cfg.PaymentMockOutcome
+	}
+	delay := time.Duration(p.cfg.PaymentMockDelaySeconds) * time.Second
+
+	mockPayments.Lock()
+	c, ok := mockPayments.checkouts[id]
+	if !ok {
+		mockPayments.Unlock()
+		return "", errors.New("checkout not found")
+	}
+	if c.Status != mockStatusOpen {
+		mockPayments.Unlock()
+		return "", errors.New("checkout has already been completed")
+	}
+
+	var redirect, event string
+	switch outcome {
+	case MockOutcomeSuccess:
+		c.Status = mockStatusPaid
+		redirect, event = c.successURL, "completed"
+		delay = 0
+	case MockOutcomeDelayed, MockOutcomeAsyncFailure:
+		c.Status = mockStatusProcessing
+		c.SettleAt = time.Now().Add(delay)
+		redirect, event = c.successURL, "completed"
+		if outcome == MockOutcomeAsyncFailure {
+			event = "failed"
+		}
+	case MockOutcomeDecline:
+		// A declined payment leaves the checkout open for another attempt
+		redirect, event = c.cancelURL, "declined"
+		delay = 0
+	case MockOutcomeCancel:
+		c.Status = mockStatusCancelled
+		redirect = c.cancelURL
+	default:
+		mockPayments.Unlock()
+		return "", fmt.Errorf("unknown outcome %q", outcome)
+	}
+	if outcome != MockOutcomeDecline {
+		c.Outcome = outcome
+	}
+	if c.Status == mockStatusPaid || c.Status == mockStatusProcessing {
+		if c.Provider == "paypal" {
+			c.PaymentRef = mockID("MOCKCAP-")
+		} else {
+			c.PaymentRef = mockID("pi_mock_")
+		}
+		mockPayments.payments[c.PaymentRef] = c
+	}
+	mockPayments.Unlock()
+
+	log.Printf("Mock payment: checkout %s completed with outcome %s", id, outcome)
+	if event != "" {
+		time.AfterFunc(delay, func() {
+			mockPayments.Lock()
+			c.settle(time.Now())
+			settled := *c
+			mockPayments.Unlock()
+			p.sendCheckoutWebhook(&settled, event)
+		})
+	}
+	return redirect, nil
+}
+
+// CaptureCharge reports whether a charge checkout was paid
+func (p *MockProvider) CaptureCharge(providerRef string) (string, bool, error) {
+	ref, paid, err := paidCheckout(providerRef)
+	if err != nil || !paid {
+		return "", false, err
+	}
+	return ref, true, nil
+}
+
+// CheckAndCaptureOrder marks the tickets of a paid mock checkout as paid (for active polling)
+func (p *MockProvider) CheckAndCaptureOrder(ticket *models.Ticket) bool {
+	id, refColumn := ticket.StripeSessionID, "stripe_payment_intent_id"
+	if p.name == "paypal" {
+		id, refColumn = ticket.PayPalOrderID, "paypal_capture_id"
+	}
+	if id == "" {
+		return false
+	}
+	ref, paid, err := paidCheckout(id)
+	if err != nil || !paid {
+		return false
+	}
+
+	updates := map[string]interface{}{
+		"cancelled_at": nil,
+		refColumn:      ref,
+	}
+	_, refund, err := confirmCheckout(p.db, ticket.ID, p.name,
+		models.StatusChange{Actor: models.StatusActorProvider, Reason: "mock_poll", ProviderRef: ref},
+		updates, models.TicketPending, models.TicketPendingCancellation)
+	if err != nil {
+		log.Printf("Mock payment: failed to update ticket %s: %v", ticket.ID, err)
+		return false
+	}
+	logLedgerError("mock payment", recordOrderPayment(p.db, ticket.ID, p.name, ref))
+	// The refund of a checkout whose seats were taken meanwhile is sent by the refund recovery job
+	return refund == nil
+}
+
+// GetPaymentStatus reports the payment of the ticket's mock checkout
+func (p *MockProvider) GetPaymentStatus(ticket *models.Ticket) (*ProviderPayment, error) {
+	id := ticket.StripeSessionID
+	if p.name == "paypal" {
+		id = ticket.PayPalOrderID
+	}
+	mockPayments.Lock()
+	defer mockPayments.Unlock()
+	c, ok := mockPayments.checkouts[id]
+	if !ok {
+		return nil, fmt.Errorf("mock checkout %s not found (state is lost on restart)", id)
+	}
+	c.settle(time.Now())
+	if c.Status != mockStatusPaid {
+		return &ProviderPayment{Status: ProviderPaymentUnpaid}, nil
+	}
+	payment := &ProviderPayment{
+		Status:         ProviderPaymentPaid,
+		PaymentRef:     c.PaymentRef,
+		Amount:         c.Amount,
+		RefundedAmount: c.Refunded,
+	}
+	if c.Refunded >= c.Amount {
+		payment.Status = ProviderPaymentRefunded
+	}
+	return payment, nil
+}
+
+// ProcessRefund refunds part of a mock payment. PAYMENT_MOCK_REFUND_OUTCOME=fail makes refunds fail.
+// Payments from before a restart are unknown and refunded without checking the amount.
+// A repeated idempotency key returns the earlier refund, as Stripe and PayPal do.
+func (p *MockProvider) ProcessRefund(ticket *models.Ticket, amount models.Money, idempotencyKey string) (string, error) {
+	ref := ticket.StripePaymentIntentID
+	if p.name == "paypal" {
+		ref = ticket.PayPalCaptureID
+	}
+	if ref == "" {
+		return "", errors.New("no mock payment reference found")
+	}
+	if p.cfg.PaymentMockRefundOutcome == "fail" {
+		return "", errors.New("mock refund declined (PAYMENT_MOCK_REFUND_OUTCOME=fail)")
+	}
+
+	mockPayments.Lock()
+	if refundID, ok := mockPayments.refunds[idempotencyKey]; ok && idempotencyKey != "" {
+		mockPayments.Unlock()
+		return refundID, nil
+	}
+	c, known := mockPayments.payments[ref]
+	if known {
+		if c.Refunded+amount > c.Amount {
+			mockPayments.Unlock()
+			return "", fmt.Errorf("refund of %s EUR exceeds the remaining %s EUR", amount, c.Amount-c.Refunded)
+		}
+		c.Refunded += amount
+	}
+	refundID := mockID("re_mock_")
+	if p.name == "paypal" {
+		refundID = mockID("MOCKREF-")
+	}
+	if idempotencyKey != "" {
+		mockPayments.refunds[idempotencyKey] = refundID
+	}
+	mockPayments.Unlock()
+
+	log.Printf("Mock payment: refunded %s EUR of %s (%s)", amount, ref, refundID)
+	go p.sendRefundWebhook(ref, refundID, amount)
+	return refundID, nil
+}
+
+// sendCheckoutWebhook reports the outcome of a checkout the way Stripe or PayPal would
+func (p *MockProvider) sendCheckoutWebhook(c *MockCheckout, event string) {
+	if c.Provider == "paypal" {
+		custom := c.Metadata["ticket_id"]
+		if ref, ok := c.Metadata["charge_ref"]; ok {
+			custom = ref
+		}
+		resource := map[string]interface{}{
+			"id":        c.PaymentRef,
+			"custom_id": custom,
+			"amount":    map[string]string{"currency_code": models.Currency, "value": c.Amount.String()},
+		}
+		eventType := "PAYMENT.CAPTURE.COMPLETED"
+		switch event {
+		case "completed":
+			resource["status"] = "COMPLETED"
+			// Roughly PayPal's standard rate, so fees show up in the ledger
+			fee := models.BasisPoints(249).Of(c.Amount) + 35
+			resource["seller_receivable_breakdown"] = map[string]interface{}{
+				"paypal_fee": map[string]string{"currency_code": models.Currency, "value": fee.String()},
+			}
+		case "failed":
+			// A pending capture that is denied later
+			eventType = "PAYMENT.CAPTURE.DENIED"
+			resource["status"] = "DENIED"
+		default:
+			// PayPal lets the buyer retry a declined payment on its page and sends nothing
+			return
+		}
+		p.sendPayPalEvent(eventType, resource)
+		return
+	}
+
+	session := map[string]interface{}{
+		"id":             c.ID,
+		"object":         "checkout.session",
+		"amount_total":   c.Amount.Cents(),
+		"currency":       stripeCurrency,
+		"metadata":       c.Metadata,
+		"payment_intent": c.PaymentRef,
+		"status":         "complete",
+		"payment_status": "paid",
+	}
+	switch event {
+	case "completed":
+		p.sendStripeEvent("checkout.session.completed", session)
+	case "failed":
+		session["payment_status"] = "unpaid"
+		p.sendStripeEvent("checkout.session.async_payment_failed", session)
+	case "declined":
+		p.sendStripeEvent("payment_intent.payment_failed", map[string]interface{}{
+			"id":                 mockID("pi_mock_"),
+			"object":             "payment_intent",
+			"amount":             c.Amount.Cents(),
+			"currency":           stripeCurrency,
+			"status":             "requires_payment_method",
+			"metadata":           c.Metadata,
+			"last_payment_error": map[string]string{"code": "card_declined", "message": "Your card was declined."},
+		})
+	}
+}
+
+// sendRefundWebhook reports a refund the way Stripe or PayPal would
+func (p *MockProvider) sendRefundWebhook(ref, refundID string, amount models.Money) {
+	if p.name == "paypal" {
+		p.sendPayPalEvent("PAYMENT.CAPTURE.REFUNDED", map[string]interface{}{
+			"id":     refundID,
+			"status": "COMPLETED",
+			"amount": map[string]string{"currency_code": models.Currency, "value": amount.String()},
+			"links": []map[string]string{
+				{"rel": "self", "href": "https://api.sandbox.paypal.com/v2/payments/refunds/" + refundID},
+				{"rel": "up", "href": "https://api.sandbox.paypal.com/v2/payments/captures/" + ref},
+			},
+		})
+		return
+	}
+	chargeID := mockID("ch_mock_")
+	p.sendStripeEvent("charge.refunded", map[string]interface{}{
+		"id":              chargeID,
+		"object":          "charge",
+		"payment_intent":  ref,
+		"amount_refunded": amount.Cents(),
+		"currency":        stripeCurrency,
+		"refunded":        true,
+		"refunds": map[string]interface{}{
+			"object": "list",
+			"data": []map[string]interface{}{{
+				"id":             refundID,
+				"object":         "refund",
+				"amount":         amount.Cents(),
+				"currency":       stripeCurrency,
+				"charge":         chargeID,
+				"payment_intent": ref,
+				"status":         "succeeded",
+			}},
+		},
+	})
+}
+
+// sendStripeEvent posts a Stripe event signed with the webhook secret, so it passes the regular signature check
+func (p *MockProvider) sendStripeEvent(eventType string, object map[string]interface{}) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":          mockID("evt_mock_"),
+		"object":      "event",
+		"type":        eventType,
+		"api_version": stripe.APIVersion,
+		"created":     time.Now().Unix(),
+		"livemode":    false,
+		"data":        map[string]interface{}{"object": object},
+	})
+	ts := time.Now().Unix()
+	mac := hmac.New(sha256.New, []byte(p.cfg.StripeWebhookSecret))
+	mac.Write([]byte(fmt.Sprintf("%d.%s", ts, payload)))
+	signature := fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+
+	_, _ = p.postWebhook("/stripe/webhook", eventType, payload, map[string]string{"Stripe-Signature": signature})
+}
+
+// sendPayPalEvent posts a PayPal webhook event
+func (p *MockProvider) sendPayPalEvent(eventType string, resource map[string]interface{}) {
+	resourceType := "capture"
+	if eventType == "PAYMENT.CAPTURE.REFUNDED" || eventType == "PAYMENT.CAPTURE.REVERSED" {
+		resourceType = "refund"
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":            mockID("WH-MOCK-"),
+		"event_type":    eventType,
+		"create_time":   time.Now().UTC().Format(time.RFC3339),
+		"resource_type": resourceType,
+		"resource":      resource,
+	})
+	_, _ = p.DeliverPayPalWebhook(payload)
+}
+
+// DeliverPayPalWebhook signs a PayPal webhook body the way VerifyWebhookSignature expects and posts it
+// to our PayPal webhook; used for simulated events and to replay recorded fixtures. Returns the response status.
+func (p *MockProvider) DeliverPayPalWebhook(payload []byte) (int, error) {
+	var event struct {
+		EventType string `json:"event_type"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return 0, fmt.Errorf("invalid PayPal webhook JSON: %w", err)
+	}
+
+	transmissionID := mockID("")
+	transmissionTime := time.Now().UTC().Format(time.RFC3339)
+	return p.postWebhook("/paypal/webhook", event.EventType, payload, map[string]string{
+		"PAYPAL-AUTH-ALGO":         "MOCK-HMAC-SHA256",
+		"PAYPAL-TRANSMISSION-ID":   transmissionID,
+		"PAYPAL-TRANSMISSION-TIME": transmissionTime,
+		"PAYPAL-TRANSMISSION-SIG":  p.paypalSignature(transmissionID, transmissionTime, payload),
+	})
+}
+
+// paypalSignature mirrors PayPal's signed message (transmission ID, time, webhook ID and CRC32 of the body),
+// with an HMAC keyed by the webhook ID instead of PayPal's certificate
+func (p *MockProvider) paypalSignature(transmissionID, transmissionTime string, payload []byte) string {
+	message := fmt.Sprintf("%s|%s|%s|%d", transmissionID, transmissionTime, p.cfg.PayPalWebhookID, crc32.ChecksumIEEE(payload))
+	mac := hmac.New(sha256.New, []byte(p.cfg.PayPalWebhookID))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature DeliverPayPalWebhook puts on simulated PayPal webhooks
+func (p *MockProvider) VerifyWebhookSignature(header http.Header, body []byte) error {
+	sig := header.Get("PAYPAL-TRANSMISSION-SIG")
+	if sig == "" {
+		return errors.New("missing PayPal transmission signature")
+	}
+	expected := p.paypalSignature(header.Get("PAYPAL-TRANSMISSION-ID"), header.Get("PAYPAL-TRANSMISSION-TIME"), body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return errors.New("mock PayPal signature mismatch")
+	}
+	return nil
+}
+
+func (p *MockProvider) postWebhook(path, eventType string, payload []byte, headers map[string]string) (int, error) {
+	url := strings.TrimRight(p.cfg.PaymentMockWebhookURL, "/") + path
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Mock payment: failed to build webhook %s: %v", eventType, err)
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Mock payment: webhook %s to %s failed: %v", eventType, url, err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+	log.Printf("Mock payment: webhook %s delivered to %s (status %d)", eventType, url, resp.StatusCode)
+	return resp.StatusCode, nil
+}
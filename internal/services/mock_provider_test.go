@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/testutil"
+)
+
+// TestMockProviderPaymentLifecycle books a ticket and pays and refunds it through the PaymentProvider
+// interface, with the mock provider standing in for Stripe
+func TestMockProviderPaymentLifecycle(t *testing.T) {
+	db := testutil.OpenDB(t)
+	s := NewTicketService(db, testutil.Config())
+	mock := s.MockProvider()
+	var provider PaymentProvider = mock
+
+	event := testutil.CreateEvent(t, db, 2, 2000)
+	buyer := testutil.CreateUser(t, db, "guests")
+	order, event, buyer, err := s.reserveOrder(buyer.ID, &OrderRequest{EventID: event.ID, Tickets: []OrderTicketRequest{{}}, PaymentProvider: "stripe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.CreateCheckout(order, event, buyer); err != nil {
+		t.Fatalf("CreateCheckout: %v", err)
+	}
+	ticket := &order.Tickets[0]
+	if ticket.StripeSessionID == "" {
+		t.Fatal("checkout ID not stored on the ticket")
+	}
+
+	// Unpaid until the buyer completes the checkout
+	if provider.CheckAndCaptureOrder(ticket) {
+		t.Fatal("open checkout confirmed")
+	}
+	if _, err := mock.Complete(ticket.StripeSessionID, MockOutcomeSuccess); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !provider.CheckAndCaptureOrder(ticket) {
+		t.Fatal("paid checkout not confirmed")
+	}
+	paid, err := s.GetTicketByID(ticket.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid.Status != models.TicketPaid || paid.StripePaymentIntentID == "" {
+		t.Fatalf("ticket %s with payment %q, want paid", paid.Status, paid.StripePaymentIntentID)
+	}
+	payment, err := provider.GetPaymentStatus(paid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != ProviderPaymentPaid || payment.Amount != order.TotalAmount || payment.PaymentRef != paid.StripePaymentIntentID {
+		t.Fatalf("provider payment %+v, want %s paid with %s", payment, order.TotalAmount, paid.StripePaymentIntentID)
+	}
+
+	if err := s.RefundTicket(ticket.ID, true, nil); err != nil {
+		t.Fatalf("RefundTicket: %v", err)
+	}
+	var refund models.Refund
+	if err := db.Where("ticket_id = ?", ticket.ID).First(&refund).Error; err != nil {
+		t.Fatal(err)
+	}
+	if refund.Status != models.RefundStatusSucceeded || refund.Amount != order.TotalAmount || refund.ProviderRefundID == "" {
+		t.Fatalf("refund %s of %s (%q), want %s succeeded", refund.Status, refund.Amount, refund.ProviderRefundID, order.TotalAmount)
+	}
+	refunded, err := s.GetTicketByID(ticket.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.Status != models.TicketRefunded {
+		t.Errorf("ticket %s, want refunded", refunded.Status)
+	}
+
+	// Sending the refund again with its idempotency key returns the first refund
+	refundID, err := provider.ProcessRefund(refunded, refund.Amount, refundIdempotencyKey(&refund))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refundID != refund.ProviderRefundID {
+		t.Errorf("repeated refund %s, want %s", refundID, refund.ProviderRefundID)
+	}
+	payment, err = provider.GetPaymentStatus(refunded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != ProviderPaymentRefunded || payment.RefundedAmount != order.TotalAmount {
+		t.Errorf("provider payment %+v, want %s refunded", payment, order.TotalAmount)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type OrderService struct {
+	db            *gorm.DB
+	ticketService *TicketService
+}
+
+func NewOrderService(db *gorm.DB, ticketService *TicketService) *OrderService {
+	return &OrderService{
+		db:            db,
+		ticketService: ticketService,
+	}
+}
+
+// GetUserOrders returns the orders of a user with their items and tickets, newest first
+func (s *OrderService) GetUserOrders(userID uuid.UUID) ([]*models.Order, error) {
+	var orders []*models.Order
+	err := s.db.Preload("Event").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Tickets", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&orders).Error
+	return orders, err
+}
+
+// GetOrder returns an order with its items, tickets, buyer and event
+func (s *OrderService) GetOrder(orderID uuid.UUID) (*models.Order, error) {
+	var order models.Order
+	if err := s.db.Preload("Event").Preload("User").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Tickets", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&order, "id = ?", orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetUserOrder returns an order of the given user
+func (s *OrderService) GetUserOrder(orderID, userID uuid.UUID) (*models.Order, error) {
+	order, err := s.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, errors.New("order not found")
+	}
+	return order, nil
+}
+
+// RefundItem fully refunds a single item of a paid order (admin action).
+// Refunding a ticket also refunds its pickup and frees the spot; pickup and add-on items are refunded on their own.
+func (s *OrderService) RefundItem(orderID, itemID uuid.UUID, actorID *uuid.UUID) (*models.OrderItem, error) {
+	order, err := s.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var item *models.OrderItem
+	for i := range order.Items {
+		if order.Items[i].ID == itemID {
+			item = &order.Items[i]
+		}
+	}
+	if item == nil {
+		return nil, errors.New("order item not found")
+	}
+	if item.Status != models.OrderItemStatusActive {
+		return nil, errors.New("order item has already been refunded or cancelled")
+	}
+
+	var ticket *models.Ticket
+	if item.TicketID != nil {
+		for i := range order.Tickets {
+			if order.Tickets[i].ID == *item.TicketID {
+				ticket = &order.Tickets[i]
+			}
+		}
+		if ticket == nil {
+			return nil, errors.New("ticket not found")
+		}
+		if ticket.Status != "paid" {
+			return nil, errors.New("only paid tickets can be refunded")
+		}
+	}
+
+	amount := item.RefundableAmount()
+	switch item.Kind {
+	case models.OrderItemTicket:
+		// Refunds the ticket and its pickup and settles both items
+		if err := s.ticketService.refundTicket(ticket.ID, true, models.RefundReasonOrderItem, models.RefundInitiatorAdmin, actorID); err != nil {
+			return nil, err
+		}
+		s.ticketService.offerFreedSpots(order.EventID)
+	case models.OrderItemPickup, models.OrderItemAddon:
+		if err := s.ticketService.refundOrderItem(order, order.PaymentTicket(), item, models.RefundReasonOrderItem, models.RefundInitiatorAdmin, actorID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unknown order item kind")
+	}
+
+	if ticket != nil {
+		if err := recordTicketHistory(s.db, ticket.ID, "order_item_refunded", actorID, map[string]interface{}{
+			"order_id": order.ID,
+			"item_id":  item.ID,
+			"kind":     item.Kind,
+			"amount":   amount,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var refunded models.OrderItem
+	if err := s.db.First(&refunded, "id = ?", item.ID).Error; err != nil {
+		return nil, err
+	}
+	return &refunded, nil
+}
+
+// settleTicketItems closes the active ticket and pickup items of a ticket that is cancelled or refunded.
+// refundAmount is booked on the ticket item first, the rest on the pickup item, and added to the order.
+func settleTicketItems(tx *gorm.DB, ticketID uuid.UUID, refundAmount models.Money, status string) error {
+	var items []models.OrderItem
+	// "ticket" sorts after "pickup"
+	if err := tx.Where("ticket_id = ? AND status = ?", ticketID, models.OrderItemStatusActive).
+		Order("kind DESC").Find(&items).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	remaining := refundAmount
+	for i := range items {
+		item := &items[i]
+		share := min(remaining, item.RefundableAmount())
+		updates := map[string]interface{}{"status": status}
+		if share > 0 {
+			remaining -= share
+			updates["refunded_amount"] = item.RefundedAmount + share
+			updates["refunded_at"] = now
+		}
+		if err := tx.Model(item).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	if refundAmount <= 0 {
+		return nil
+	}
+	return tx.Model(&models.Order{}).
+		Where("id = (SELECT order_id FROM tickets WHERE id = ?)", ticketID).
+		Update("refunded_amount", gorm.Expr("refunded_amount + ?", refundAmount)).Error
+}
@@ -1,21 +1,70 @@
 package services
 
 import (
+	"net/http"
+
 	"github.com/synesthesie/backend/internal/models"
 )
 
 // PaymentProvider defines the interface for payment providers (Stripe, PayPal, etc.)
 type PaymentProvider interface {
-	// CreateCheckout creates a checkout session and returns the checkout URL
-	CreateCheckout(ticket *models.Ticket, event *models.Event, user *models.User, totalAmount float64) (checkoutURL string, err error)
+	// CreateCheckout creates one checkout session for the items of an order and returns the checkout URL.
+	// order.Tickets[0] is the reference ticket of the checkout; the session/order ID is stored on all tickets of the order.
+	CreateCheckout(order *models.Order, event *models.Event, user *models.User) (checkoutURL string, err error)
 
-	// ProcessRefund processes a refund for a ticket
-	ProcessRefund(ticket *models.Ticket, amount float64) error
+	// ProcessRefund refunds part of the payment referenced by the ticket and returns the provider's refund ID.
+	// Only RefundService calls it, so every refund is recorded. A repeated call with the same idempotencyKey
+	// returns the first refund instead of refunding again.
+	ProcessRefund(ticket *models.Ticket, amount models.Money, idempotencyKey string) (refundID string, err error)
 
 	// CheckAndCaptureOrder checks payment status and captures if approved (for active polling)
 	CheckAndCaptureOrder(ticket *models.Ticket) bool
 
+	// CreateChargeCheckout creates a checkout for an extra amount related to a ticket
+	// (e.g. a transfer price difference) without touching the ticket's own payment references.
+	// reference identifies the charge in provider metadata; returns the checkout URL and session/order ID.
+	CreateChargeCheckout(ticket *models.Ticket, user *models.User, amount models.Money, description, reference string) (checkoutURL, providerRef string, err error)
+
+	// CaptureCharge checks a charge checkout and captures it if approved.
+	// Returns the payment reference (Stripe payment intent / PayPal capture) once paid.
+	CaptureCharge(providerRef string) (paymentRef string, paid bool, err error)
+
+	// GetPaymentStatus looks up the payment of a ticket's checkout at the provider without changing anything
+	// (for reconciliation). A checkout that was never paid is reported as unpaid.
+	GetPaymentStatus(ticket *models.Ticket) (*ProviderPayment, error)
+
 	// GetProviderName returns the name of the provider ("stripe" or "paypal")
 	GetProviderName() string
 }
 
+const (
+	ProviderPaymentUnpaid   = "unpaid"
+	ProviderPaymentPaid     = "paid"
+	ProviderPaymentRefunded = "refunded" // fully refunded
+)
+
+// ProviderPayment is the state of a checkout's payment as the provider sees it
+type ProviderPayment struct {
+	Status         string       // unpaid, paid, refunded
+	PaymentRef     string       // Stripe payment intent / PayPal capture
+	Amount         models.Money // amount captured
+	RefundedAmount models.Money
+	Disputed       bool
+}
+
+// PayPalWebhookVerifier checks that a PayPal webhook was sent by PayPal for the configured webhook (PAYPAL_WEBHOOK_ID)
+type PayPalWebhookVerifier interface {
+	VerifyWebhookSignature(header http.Header, body []byte) error
+}
+
+// ProviderRefund is a refund as listed by the payment provider
+type ProviderRefund struct {
+	ID     string
+	Amount models.Money
+	Status string // provider status, e.g. succeeded, pending, failed
+}
+
+// ChargeRefundLister lists the refunds of a Stripe charge; charge.refunded webhooks do not always include them
+type ChargeRefundLister interface {
+	ChargeRefunds(chargeID string) ([]ProviderRefund, error)
+}
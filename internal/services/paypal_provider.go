@@ -51,24 +51,53 @@ func NewPayPalProvider(cfg *config.Config, db *gorm.DB) (*PayPalProvider, error)
 	}, nil
 }
 
+// PayPalAmount is an amount as PayPal writes it, e.g. {"currency_code": "EUR", "value": "35.15"}
+type PayPalAmount struct {
+	Currency string `json:"currency_code"`
+	Value    string `json:"value"`
+}
+
+// newPayPalAmount writes an amount in the shop currency for PayPal
+func newPayPalAmount(amount models.Money) PayPalAmount {
+	return PayPalAmount{Currency: models.Currency, Value: amount.String()}
+}
+
+// Money parses the amount; amounts in another currency than the shop's are refused
+func (a PayPalAmount) Money() (models.Money, error) {
+	if a.Currency != "" && a.Currency != models.Currency {
+		return 0, fmt.Errorf("unexpected PayPal currency %s", a.Currency)
+	}
+	return models.ParseMoney(a.Value)
+}
+
 // GetProviderName returns "paypal"
 func (p *PayPalProvider) GetProviderName() string {
 	return "paypal"
 }
 
-// CreateCheckout creates a PayPal order and returns the approval URL
-func (p *PayPalProvider) CreateCheckout(ticket *models.Ticket, event *models.Event, user *models.User, totalAmount float64) (string, error) {
-	// Build purchase units
-	amountStr := fmt.Sprintf("%.2f", totalAmount)
+// CreateCheckout creates a PayPal order for the order total and returns the approval URL
+func (p *PayPalProvider) CreateCheckout(order *models.Order, event *models.Event, user *models.User) (string, error) {
+	ticket := &order.Tickets[0]
+
+	// Build purchase units (one unit for the whole order)
+	amountStr := order.TotalAmount.String()
+	amount := &paypal.PurchaseUnitAmount{
+		Currency: models.Currency,
+		Value:    amountStr,
+	}
+	// Prices are gross: the VAT contained in the total is passed as tax_total next to the net item_total
+	if net, tax := orderCheckoutTax(order); tax > 0 && net+tax == order.TotalAmount {
+		amount.Breakdown = &paypal.PurchaseUnitAmountBreakdown{
+			ItemTotal: &paypal.Money{Currency: models.Currency, Value: net.String()},
+			TaxTotal:  &paypal.Money{Currency: models.Currency, Value: tax.String()},
+		}
+	}
 	purchaseUnits := []paypal.PurchaseUnitRequest{
 		{
 			ReferenceID: ticket.ID.String(),
-			Description: fmt.Sprintf("Ticket für %s", event.Name),
+			Description: paypalOrderDescription(order, event),
 			CustomID:    ticket.ID.String(),
-			Amount: &paypal.PurchaseUnitAmount{
-				Currency: "EUR",
-				Value:    amountStr,
-			},
+			Amount:      amount,
 		},
 	}
 
@@ -104,12 +133,17 @@ func (p *PayPalProvider) CreateCheckout(ticket *models.Ticket, event *models.Eve
 
 	fmt.Printf("[PayPal DEBUG] Order created successfully: %s\n", createdOrder.ID)
 
-	// Save PayPal order ID to ticket
-	ticket.PayPalOrderID = createdOrder.ID
-	ticket.PaymentProvider = "paypal"
-	if err := p.db.Save(ticket).Error; err != nil {
+	// Save PayPal order ID to the tickets of the order
+	if err := p.db.Model(&models.Ticket{}).Scopes(models.SameCheckout(ticket.ID)).Updates(map[string]interface{}{
+		"paypal_order_id":  createdOrder.ID,
+		"payment_provider": "paypal",
+	}).Error; err != nil {
 		return "", fmt.Errorf("failed to save ticket: %w", err)
 	}
+	for i := range order.Tickets {
+		order.Tickets[i].PayPalOrderID = createdOrder.ID
+		order.Tickets[i].PaymentProvider = "paypal"
+	}
 
 	// Extract approval URL
 	var approvalURL string
@@ -130,6 +164,37 @@ func (p *PayPalProvider) CreateCheckout(ticket *models.Ticket, event *models.Eve
 	return approvalURL, nil
 }
 
+// paypalOrderDescription summarizes an order for the purchase unit description (max. 127 characters)
+func paypalOrderDescription(order *models.Order, event *models.Event) string {
+	items := orderCheckoutItems(order)
+	if len(items) == 1 {
+		return truncateRunes(items[0].Name, 127)
+	}
+	tickets := 0
+	for _, item := range items {
+		if item.Kind == models.OrderItemTicket {
+			tickets++
+		}
+	}
+	desc := fmt.Sprintf("%d Tickets für %s", tickets, event.Name)
+	if tickets == 1 {
+		desc = fmt.Sprintf("Ticket für %s", event.Name)
+	}
+	if extras := len(items) - tickets; extras > 0 {
+		desc += fmt.Sprintf(" + %d Extras", extras)
+	}
+	return truncateRunes(desc, 127)
+}
+
+// truncateRunes shortens s to at most max characters
+func truncateRunes(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max])
+}
+
 // pollOrderStatus polls PayPal order status in background (fallback if webhook fails)
 func (p *PayPalProvider) pollOrderStatus(ticketID uuid.UUID, orderID string) {
 	fmt.Printf("[PayPal Polling] Starting status polling for ticket %s, order %s\n", ticketID, orderID)
@@ -174,6 +239,17 @@ func (p *PayPalProvider) pollOrderStatus(ticketID uuid.UUID, orderID string) {
 
 		// Check if order is approved (user completed payment)
 		if order.Status == "APPROVED" {
+			// An approved order is only charged while its seats are still there; PayPal voids it later
+			keepsSeats, err := checkoutKeepsSeats(p.db, ticketID)
+			if err != nil {
+				fmt.Printf("[PayPal Polling] Failed to check seats of ticket %s: %v\n", ticketID, err)
+				continue
+			}
+			if !keepsSeats {
+				fmt.Printf("⚠️ [PayPal Polling] Seats of ticket %s were taken after its hold expired, not capturing order %s\n", ticketID, orderID)
+				return
+			}
+
 			// Order is approved, now we need to capture it
 			captureResp, err := p.client.CaptureOrder(orderID, paypal.CaptureOrderRequest{})
 			if err != nil {
@@ -196,6 +272,9 @@ func (p *PayPalProvider) pollOrderStatus(ticketID uuid.UUID, orderID string) {
 				fmt.Printf("[PayPal Polling] Warning: Could not extract capture ID, using order ID as fallback\n")
 			}
 
+			// The money has arrived, whatever happens to the ticket below
+			logLedgerError("PayPal capture", recordOrderPayment(p.db, ticketID, "paypal", captureID))
+
 			// Update or reactivate ticket
 			if ticket.Status == "cancelled" || ticket.Status == "pending_cancellation" || ticket.ID == uuid.Nil {
 				// Ticket was cancelled/pending_cancellation/deleted but payment went through
@@ -205,36 +284,43 @@ func (p *PayPalProvider) pollOrderStatus(ticketID uuid.UUID, orderID string) {
 
 				// Try to reactivate or update
 				updates := map[string]interface{}{
-					"status":             "paid",
-					"paypal_capture_id":  captureID,
-					"cancelled_at":       nil,
+					"paypal_capture_id": captureID,
+					"cancelled_at":      nil,
+				}
+
+				// Use Unscoped to update even soft-deleted records; the other tickets of the order were paid as well
+				reactivated, refund, err := confirmCheckout(p.db.Unscoped(), ticketID, "paypal",
+					models.StatusChange{Actor: models.StatusActorProvider, Reason: "paypal_poll_grace_period", ProviderRef: captureID},
+					updates, models.TicketPending, models.TicketPendingCancellation, models.TicketCancelled)
+				if err != nil {
+					log.Printf("ERROR: [PayPal Polling] Failed to reactivate ticket %s after capture %s: %v - user paid but has no ticket, manual intervention required", ticketID, captureID, err)
+					return
 				}
 
-				// Use Unscoped to update even soft-deleted records
-				result := p.db.Unscoped().Model(&models.Ticket{}).Where("id = ?", ticketID).Updates(updates)
-				if result.Error != nil {
-					fmt.Printf("⚠️ [PayPal Polling] CRITICAL - Failed to reactivate ticket %s: %v\n", ticketID, result.Error)
-					fmt.Printf("⚠️ [PayPal Polling] User paid but ticket lost! Manual intervention required!\n")
-					// TODO: Send admin alert email
+				if refund != nil {
+					fmt.Printf("⚠️ [PayPal Polling] Seats of ticket %s were taken meanwhile, capture %s will be refunded\n", ticketID, captureID)
 					return
 				}
 
-				if result.RowsAffected == 0 {
-					fmt.Printf("⚠️ [PayPal Polling] CRITICAL - Ticket %s not found in DB but payment completed!\n", ticketID)
-					fmt.Printf("⚠️ [PayPal Polling] Capture ID: %s - Manual refund or ticket recreation required!\n", captureID)
-					// TODO: Send admin alert email
+				if reactivated == 0 {
+					log.Printf("ERROR: [PayPal Polling] Ticket %s not found but capture %s completed - manual refund or ticket recreation required", ticketID, captureID)
 					return
 				}
 
 				fmt.Printf("✅ [PayPal Polling] Ticket %s reactivated and marked as paid (was: %s, capture: %s)\n", ticketID, ticket.Status, captureID)
 			} else {
-				// Normal flow - ticket is pending
-				ticket.Status = "paid"
-				ticket.PayPalCaptureID = captureID
-				if err := p.db.Save(&ticket).Error; err != nil {
+				// Normal flow - tickets of the order are pending
+				_, refund, err := confirmCheckout(p.db, ticketID, "paypal",
+					models.StatusChange{Actor: models.StatusActorProvider, Reason: "paypal_poll", ProviderRef: captureID},
+					map[string]interface{}{"paypal_capture_id": captureID}, models.TicketPending)
+				if err != nil {
 					fmt.Printf("[PayPal Polling] Failed to update ticket: %v\n", err)
 					continue
 				}
+				if refund != nil {
+					fmt.Printf("⚠️ [PayPal Polling] Seats of ticket %s were taken meanwhile, capture %s will be refunded\n", ticketID, captureID)
+					return
+				}
 
 				fmt.Printf("[PayPal Polling] ✅ Ticket %s marked as paid (capture ID: %s)\n", ticketID, captureID)
 			}
@@ -243,11 +329,14 @@ func (p *PayPalProvider) pollOrderStatus(ticketID uuid.UUID, orderID string) {
 
 		// Check if order is already completed (captured by webhook)
 		if order.Status == "COMPLETED" {
-			// Webhook already processed this, just update ticket if needed
-			if ticket.Status != "paid" {
-				ticket.Status = "paid"
-				ticket.PayPalCaptureID = orderID // Use order ID as fallback
-				p.db.Save(&ticket)
+			// Webhook already processed this, just update ticket if needed.
+			// Without the capture ID a checkout whose seats are gone is left to the webhook, which refunds it.
+			if keepsSeats, _ := checkoutKeepsSeats(p.db, ticketID); ticket.Status != "paid" && keepsSeats {
+				models.TransitionTickets(p.db, models.TicketPaid,
+					models.StatusChange{Actor: models.StatusActorProvider, Reason: "paypal_poll", ProviderRef: orderID},
+					map[string]interface{}{"paypal_capture_id": orderID}, // Use order ID as fallback
+					models.SameCheckout(ticketID), models.WithTicketStatus(models.TicketPending, models.TicketPendingCancellation))
+				logLedgerError("PayPal capture", recordOrderPayment(p.db, ticketID, "paypal", orderID))
 				fmt.Printf("[PayPal Polling] ✅ Ticket %s marked as paid (order already completed)\n", ticketID)
 			}
 			return
@@ -263,58 +352,269 @@ func (p *PayPalProvider) pollOrderStatus(ticketID uuid.UUID, orderID string) {
 	fmt.Printf("[PayPal Polling] ⏱️ Polling timeout for ticket %s after %d attempts\n", ticketID, maxAttempts)
 }
 
-// ProcessRefund processes a PayPal refund
-func (p *PayPalProvider) ProcessRefund(ticket *models.Ticket, amount float64) error {
+// CreateChargeCheckout creates a PayPal order for an extra amount.
+// CustomID carries the charge reference instead of a ticket ID; the order is captured via CaptureCharge.
+func (p *PayPalProvider) CreateChargeCheckout(ticket *models.Ticket, user *models.User, amount models.Money, description, reference string) (string, string, error) {
+	purchaseUnits := []paypal.PurchaseUnitRequest{
+		{
+			ReferenceID: ticket.ID.String(),
+			Description: description,
+			CustomID:    reference,
+			Amount: &paypal.PurchaseUnitAmount{
+				Currency: models.Currency,
+				Value:    amount.String(),
+			},
+		},
+	}
+
+	appContext := &paypal.ApplicationContext{
+		BrandName:          "Synesthesie",
+		LandingPage:        "LOGIN",
+		ShippingPreference: "NO_SHIPPING",
+		UserAction:         "PAY_NOW",
+		ReturnURL:          fmt.Sprintf("%s?charge_ref=%s", p.cfg.PayPalSuccessURL, reference),
+		CancelURL:          fmt.Sprintf("%s?charge_ref=%s", p.cfg.PayPalCancelURL, reference),
+	}
+
+	createdOrder, err := p.client.CreateOrder(paypal.OrderIntentCapture, purchaseUnits, &paypal.CreateOrderPayer{}, appContext)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create PayPal order: %w", err)
+	}
+
+	for _, link := range createdOrder.Links {
+		if link.Rel == "approve" {
+			return link.Href, createdOrder.ID, nil
+		}
+	}
+	return "", "", fmt.Errorf("no approval URL found in PayPal order response")
+}
+
+// CaptureCharge captures an approved charge order
+func (p *PayPalProvider) CaptureCharge(providerRef string) (string, bool, error) {
+	order, err := p.client.GetOrder(providerRef)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get PayPal order: %w", err)
+	}
+
+	switch order.Status {
+	case "APPROVED":
+		captureResp, err := p.client.CaptureOrder(providerRef, paypal.CaptureOrderRequest{})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to capture PayPal order: %w", err)
+		}
+		captureID := providerRef
+		if len(captureResp.PurchaseUnits) > 0 &&
+			captureResp.PurchaseUnits[0].Payments != nil &&
+			len(captureResp.PurchaseUnits[0].Payments.Captures) > 0 {
+			captureID = captureResp.PurchaseUnits[0].Payments.Captures[0].ID
+		}
+		return captureID, true, nil
+	case "COMPLETED":
+		// Captured earlier; the SDK does not expose the capture ID here
+		return providerRef, true, nil
+	}
+	return "", false, nil
+}
+
+// ProcessRefund processes a PayPal refund; the idempotency key is sent as PayPal-Request-Id
+func (p *PayPalProvider) ProcessRefund(ticket *models.Ticket, amount models.Money, idempotencyKey string) (string, error) {
 	if ticket.PayPalCaptureID == "" {
-		return fmt.Errorf("no PayPal capture ID found")
+		return "", fmt.Errorf("no PayPal capture ID found")
 	}
 
 	// Get access token
 	accessToken, err := p.client.GetAccessToken()
 	if err != nil {
-		return fmt.Errorf("failed to get PayPal access token: %w", err)
+		return "", fmt.Errorf("failed to get PayPal access token: %w", err)
 	}
 
 	// Build refund request
-	amountStr := fmt.Sprintf("%.2f", amount)
 	refundRequest := map[string]interface{}{
-		"amount": map[string]string{
-			"currency_code": "EUR",
-			"value":         amountStr,
-		},
+		"amount": newPayPalAmount(amount),
 	}
 
 	// Call PayPal Refund Capture API directly
 	// https://developer.paypal.com/docs/api/payments/v2/#captures_refund
-	apiBase := "https://api.sandbox.paypal.com"
-	if p.cfg.PayPalMode == "live" {
-		apiBase = "https://api.paypal.com"
-	}
-
-	url := fmt.Sprintf("%s/v2/payments/captures/%s/refund", apiBase, ticket.PayPalCaptureID)
+	url := fmt.Sprintf("%s/v2/payments/captures/%s/refund", p.apiBase(), ticket.PayPalCaptureID)
 
 	reqBody, _ := json.Marshal(refundRequest)
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
 	if err != nil {
-		return fmt.Errorf("failed to create refund request: %w", err)
+		return "", fmt.Errorf("failed to create refund request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+	if idempotencyKey != "" {
+		req.Header.Set("PayPal-Request-Id", idempotencyKey)
+	}
 
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send refund request: %w", err)
+		return "", fmt.Errorf("failed to send refund request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 && resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("PayPal refund failed (status %d): %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("PayPal refund failed (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode refund response: %w", err)
+	}
+
+	fmt.Printf("[PayPal Refund] Successfully refunded %s EUR for ticket %s (capture: %s, refund: %s)\n", amount, ticket.ID, ticket.PayPalCaptureID, result.ID)
+	return result.ID, nil
+}
+
+// apiBase returns the REST API base URL for the configured mode
+func (p *PayPalProvider) apiBase() string {
+	if p.cfg.PayPalMode == "live" {
+		return "https://api.paypal.com"
+	}
+	return "https://api.sandbox.paypal.com"
+}
+
+// GetPaymentStatus looks up the captures and refunds of the ticket's PayPal order
+// https://developer.paypal.com/docs/api/orders/v2/#orders_get
+func (p *PayPalProvider) GetPaymentStatus(ticket *models.Ticket) (*ProviderPayment, error) {
+	if ticket.PayPalOrderID == "" {
+		return nil, fmt.Errorf("ticket has no PayPal order")
+	}
+
+	accessToken, err := p.client.GetAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PayPal access token: %w", err)
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v2/checkout/orders/%s", p.apiBase(), ticket.PayPalOrderID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PayPal order: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Orders that were never approved expire and are gone after a while
+	if resp.StatusCode == http.StatusNotFound {
+		return &ProviderPayment{Status: ProviderPaymentUnpaid}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("PayPal order lookup failed (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var order struct {
+		PurchaseUnits []struct {
+			Payments struct {
+				Captures []struct {
+					ID     string       `json:"id"`
+					Status string       `json:"status"`
+					Amount PayPalAmount `json:"amount"`
+				} `json:"captures"`
+				Refunds []struct {
+					Status string       `json:"status"`
+					Amount PayPalAmount `json:"amount"`
+				} `json:"refunds"`
+			} `json:"payments"`
+		} `json:"purchase_units"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return nil, fmt.Errorf("failed to decode PayPal order: %w", err)
+	}
+
+	payment := &ProviderPayment{Status: ProviderPaymentUnpaid}
+	for _, unit := range order.PurchaseUnits {
+		for _, capture := range unit.Payments.Captures {
+			switch capture.Status {
+			case "COMPLETED", "PARTIALLY_REFUNDED", "REFUNDED":
+				amount, err := capture.Amount.Money()
+				if err != nil {
+					return nil, fmt.Errorf("capture %s: %w", capture.ID, err)
+				}
+				payment.Amount += amount
+				payment.PaymentRef = capture.ID
+			}
+		}
+		for _, refund := range unit.Payments.Refunds {
+			if refund.Status == "COMPLETED" {
+				amount, err := refund.Amount.Money()
+				if err != nil {
+					return nil, fmt.Errorf("refund: %w", err)
+				}
+				payment.RefundedAmount += amount
+			}
+		}
 	}
+	if payment.Amount > 0 {
+		payment.Status = ProviderPaymentPaid
+		if payment.RefundedAmount >= payment.Amount {
+			payment.Status = ProviderPaymentRefunded
+		}
+	}
+	return payment, nil
+}
 
-	fmt.Printf("[PayPal Refund] Successfully refunded %.2f EUR for ticket %s (capture: %s)\n", amount, ticket.ID, ticket.PayPalCaptureID)
+// VerifyWebhookSignature lets PayPal check the transmission headers of a webhook against PAYPAL_WEBHOOK_ID
+// https://developer.paypal.com/docs/api/webhooks/v1/#verify-webhook-signature_post
+func (p *PayPalProvider) VerifyWebhookSignature(header http.Header, body []byte) error {
+	if p.cfg.PayPalWebhookID == "" {
+		return fmt.Errorf("PAYPAL_WEBHOOK_ID is not configured")
+	}
+	if header.Get("PAYPAL-TRANSMISSION-SIG") == "" {
+		return fmt.Errorf("missing PayPal transmission signature")
+	}
+
+	accessToken, err := p.client.GetAccessToken()
+	if err != nil {
+		return fmt.Errorf("failed to get PayPal access token: %w", err)
+	}
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"auth_algo":         header.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          header.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   header.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  header.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": header.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        p.cfg.PayPalWebhookID,
+		"webhook_event":     json.RawMessage(body),
+	})
+	req, err := http.NewRequest("POST", p.apiBase()+"/v1/notifications/verify-webhook-signature", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return fmt.Errorf("failed to create verification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send verification request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("PayPal signature verification failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	var result struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode verification response: %w", err)
+	}
+	if result.VerificationStatus != "SUCCESS" {
+		return fmt.Errorf("PayPal signature verification status: %s", result.VerificationStatus)
+	}
 	return nil
 }
 
@@ -336,6 +636,11 @@ func (p *PayPalProvider) CheckAndCaptureOrder(ticket *models.Ticket) bool {
 
 	// Check if order is approved and needs capturing
 	if order.Status == "APPROVED" {
+		// An approved order is only charged while its seats are still there; PayPal voids it later
+		if keepsSeats, err := checkoutKeepsSeats(p.db, ticket.ID); err != nil || !keepsSeats {
+			log.Printf("⚠️ Payment check: Not capturing PayPal order %s, seats of ticket %s are gone (err: %v)", ticket.PayPalOrderID, ticket.ID, err)
+			return false
+		}
 		log.Printf("✅ Payment check: Found approved PayPal order %s, capturing...", ticket.PayPalOrderID)
 
 		// Capture the order
@@ -354,18 +659,26 @@ func (p *PayPalProvider) CheckAndCaptureOrder(ticket *models.Ticket) bool {
 		} else {
 			captureID = ticket.PayPalOrderID // Fallback
 		}
+		logLedgerError("PayPal capture", recordOrderPayment(p.db, ticket.ID, "paypal", captureID))
 
 		// Update ticket to paid
 		updates := map[string]interface{}{
-			"status":             "paid",
-			"cancelled_at":       nil,
-			"paypal_capture_id":  captureID,
+			"cancelled_at":      nil,
+			"paypal_capture_id": captureID,
 		}
 
-		if err := p.db.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Updates(updates).Error; err != nil {
+		_, refund, err := confirmCheckout(p.db, ticket.ID, "paypal",
+			models.StatusChange{Actor: models.StatusActorProvider, Reason: "paypal_poll", ProviderRef: captureID},
+			updates, models.TicketPending, models.TicketPendingCancellation)
+		if err != nil {
 			log.Printf("⚠️ Payment check: Failed to update PayPal ticket %s: %v", ticket.ID, err)
 			return false
 		}
+		if refund != nil {
+			// Sent by the refund recovery job
+			log.Printf("⚠️ Payment check: Seats of PayPal ticket %s were taken meanwhile, capture %s will be refunded", ticket.ID, captureID)
+			return false
+		}
 
 		log.Printf("✅ Payment check: PayPal ticket %s confirmed as paid (capture: %s)", ticket.ID, captureID)
 		return true
@@ -384,19 +697,27 @@ func (p *PayPalProvider) CheckAndCaptureOrder(ticket *models.Ticket) bool {
 			captureID = ticket.PayPalCaptureID
 		}
 
-		// Update ticket to paid if not already
+		// Update ticket to paid if not already; a checkout whose seats are gone is left to the
+		// capture webhook, which refunds it with the real capture ID
+		keepsSeats, err := checkoutKeepsSeats(p.db, ticket.ID)
+		if err != nil || !keepsSeats {
+			return false
+		}
 		if ticket.Status != "paid" {
 			updates := map[string]interface{}{
-				"status":             "paid",
-				"cancelled_at":       nil,
-				"paypal_capture_id":  captureID,
+				"cancelled_at":      nil,
+				"paypal_capture_id": captureID,
 			}
 
-			if err := p.db.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Updates(updates).Error; err != nil {
+			if _, err := models.TransitionTickets(p.db, models.TicketPaid,
+				models.StatusChange{Actor: models.StatusActorProvider, Reason: "paypal_poll", ProviderRef: captureID},
+				updates, models.SameCheckout(ticket.ID),
+				models.WithTicketStatus(models.TicketPending, models.TicketPendingCancellation)); err != nil {
 				log.Printf("⚠️ Payment check: Failed to update completed PayPal ticket %s: %v", ticket.ID, err)
 				return false
 			}
 
+			logLedgerError("PayPal capture", recordOrderPayment(p.db, ticket.ID, "paypal", captureID))
 			log.Printf("✅ Payment check: Completed PayPal ticket %s confirmed as paid", ticket.ID)
 			return true
 		}
@@ -0,0 +1,1008 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PickupService manages the pickup zones (priced by postal code), the pickup time slots of events
+// with the seats of their vehicle, the manifests the drivers work from and the progress they report.
+type PickupService struct {
+	db         *gorm.DB
+	smsService *SMSService
+}
+
+func NewPickupService(db *gorm.DB, smsService *SMSService) *PickupService {
+	return &PickupService{db: db, smsService: smsService}
+}
+
+// pickupBooking is the zone, time slot and price of the pickup of one ticket
+type pickupBooking struct {
+	price models.Money
+	zone  *models.PickupZone
+	slot  *models.PickupSlot
+}
+
+// bookPickup checks the pickup of a ticket against the pickup zones and the time slots of the event.
+// With active zones the postal code must lie in one of them and the zone's price applies; without
+// zones the pickup_service_price setting applies everywhere. If the event has active time slots,
+// one serving the zone with a free seat must be chosen.
+// Must run under the event lock. Tickets are inserted one by one, so the seats counted include the
+// earlier tickets of the same order.
+func bookPickup(tx *gorm.DB, eventID uuid.UUID, tr *OrderTicketRequest) (*pickupBooking, error) {
+	booking := &pickupBooking{}
+	postalCode := models.NormalizePostalCode(tr.PickupPostalCode)
+
+	zone, err := findPickupZone(tx, postalCode)
+	if err != nil {
+		return nil, err
+	}
+	if zone != nil {
+		booking.zone = zone
+		booking.price = zone.Price
+	} else {
+		if booking.price, err = pickupServicePrice(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	var slotCount int64
+	if err := tx.Model(&models.PickupSlot{}).Where("event_id = ? AND is_active = ?", eventID, true).
+		Count(&slotCount).Error; err != nil {
+		return nil, err
+	}
+	if slotCount == 0 && tr.PickupSlotID == uuid.Nil {
+		return booking, nil
+	}
+	if tr.PickupSlotID == uuid.Nil {
+		return nil, errors.New("please choose a pickup time slot")
+	}
+
+	var slot models.PickupSlot
+	if err := tx.Where("id = ? AND event_id = ? AND is_active = ?", tr.PickupSlotID, eventID, true).
+		First(&slot).Error; err != nil {
+		return nil, errors.New("pickup time slot not found")
+	}
+	if !slot.StartsAt.After(time.Now()) {
+		return nil, errors.New("pickup time slot has already started")
+	}
+	var zoneID *uuid.UUID
+	if zone != nil {
+		zoneID = &zone.ID
+	}
+	if !slot.ServesZone(zoneID) {
+		return nil, errors.New("pickup time slot does not serve your postal code")
+	}
+	if slot.Remaining(tx) <= 0 {
+		return nil, errors.New("pickup time slot is fully booked")
+	}
+	booking.slot = &slot
+	return booking, nil
+}
+
+// findPickupZone returns the active zone with the longest code matching the postal code.
+// It returns nil if no zones are set up (the pickup service then drives anywhere).
+func findPickupZone(db *gorm.DB, postalCode string) (*models.PickupZone, error) {
+	var zones []models.PickupZone
+	if err := db.Where("is_active = ?", true).Order("sort_order ASC, name ASC").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, nil
+	}
+	if postalCode == "" {
+		return nil, errors.New("postal code is required for the pickup service")
+	}
+
+	var best *models.PickupZone
+	bestLength := 0
+	for i := range zones {
+		if n := zones[i].MatchLength(postalCode); n > bestLength {
+			best, bestLength = &zones[i], n
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("the pickup service does not drive to postal code %s", postalCode)
+	}
+	return best, nil
+}
+
+// pickupItemDescription describes the pickup of a ticket on its order item and invoice
+func pickupItemDescription(ticket *models.Ticket, slot *models.PickupSlot) string {
+	desc := fmt.Sprintf("Abholung von: %s", ticket.PickupAddress)
+	if slot != nil {
+		loc := berlinLocation()
+		desc += fmt.Sprintf(" (%s–%s Uhr)", slot.StartsAt.In(loc).Format("02.01.2006 15:04"), slot.EndsAt.In(loc).Format("15:04"))
+	}
+	return desc
+}
+
+// validatePickupZone checks and normalizes a pickup zone definition
+func validatePickupZone(z *models.PickupZone) error {
+	z.Name = strings.TrimSpace(z.Name)
+	if z.Name == "" {
+		return errors.New("zone name is required")
+	}
+	if z.Price < 0 {
+		return errors.New("prices cannot be negative")
+	}
+	codes := z.Codes()
+	if len(codes) == 0 {
+		return errors.New("zone needs at least one postal code")
+	}
+	z.PostalCodes = strings.Join(codes, ",")
+	return nil
+}
+
+// GetZones returns the pickup zones in display order
+func (s *PickupService) GetZones(activeOnly bool) ([]*models.PickupZone, error) {
+	var zones []*models.PickupZone
+	query := s.db
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	err := query.Order("sort_order ASC, name ASC").Find(&zones).Error
+	return zones, err
+}
+
+// CreateZone adds a pickup zone
+func (s *PickupService) CreateZone(z *models.PickupZone) error {
+	if err := validatePickupZone(z); err != nil {
+		return err
+	}
+	return s.db.Create(z).Error
+}
+
+// UpdateZone replaces the definition of a pickup zone.
+// Tickets already booked keep the price they were bought with.
+func (s *PickupService) UpdateZone(zoneID uuid.UUID, z *models.PickupZone) error {
+	var existing models.PickupZone
+	if err := s.db.First(&existing, "id = ?", zoneID).Error; err != nil {
+		return errors.New("zone not found")
+	}
+	if err := validatePickupZone(z); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"name":         z.Name,
+			"postal_codes": z.PostalCodes,
+			"price":        z.Price,
+			"sort_order":   z.SortOrder,
+			"is_active":    z.IsActive,
+		}).Error; err != nil {
+			return err
+		}
+		if existing.Name != z.Name {
+			return tx.Model(&models.Ticket{}).Where("pickup_zone_id = ?", zoneID).
+				Update("pickup_zone_name", z.Name).Error
+		}
+		return nil
+	})
+}
+
+// DeleteZone removes a pickup zone no ticket or time slot refers to
+func (s *PickupService) DeleteZone(zoneID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&models.Ticket{}).Where("pickup_zone_id = ?", zoneID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("zone has bookings; deactivate it instead")
+	}
+	if err := s.db.Model(&models.PickupSlot{}).Where("zone_id = ?", zoneID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("zone is used by pickup time slots")
+	}
+
+	result := s.db.Where("id = ?", zoneID).Delete(&models.PickupZone{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("zone not found")
+	}
+	return nil
+}
+
+// validatePickupSlot checks and normalizes a pickup time slot definition
+func (s *PickupService) validatePickupSlot(slot *models.PickupSlot) error {
+	slot.Name = strings.TrimSpace(slot.Name)
+	slot.DriverName = strings.TrimSpace(slot.DriverName)
+	slot.DriverPhone = strings.TrimSpace(slot.DriverPhone)
+	if slot.StartsAt.IsZero() || slot.EndsAt.IsZero() {
+		return errors.New("starts_at and ends_at are required")
+	}
+	if !slot.EndsAt.After(slot.StartsAt) {
+		return errors.New("pickup time slot must end after it starts")
+	}
+	if slot.Capacity <= 0 {
+		return errors.New("capacity must be positive")
+	}
+	if slot.ZoneID != nil {
+		var zone models.PickupZone
+		if err := s.db.First(&zone, "id = ?", *slot.ZoneID).Error; err != nil {
+			return errors.New("zone not found")
+		}
+	}
+	if slot.DriverID != nil {
+		driver, err := s.getDriver(*slot.DriverID)
+		if err != nil {
+			return err
+		}
+		if slot.DriverName == "" {
+			slot.DriverName = driver.Name
+		}
+		if slot.DriverPhone == "" {
+			slot.DriverPhone = driver.Mobile
+		}
+	}
+	return nil
+}
+
+// SlotAvailability is a pickup time slot with its booked and free seats
+type SlotAvailability struct {
+	*models.PickupSlot
+	Booked    int `json:"booked"`
+	Remaining int `json:"remaining"`
+}
+
+// GetSlots returns the pickup time slots of an event in time order with their free seats
+func (s *PickupService) GetSlots(eventID uuid.UUID, activeOnly bool) ([]SlotAvailability, error) {
+	var slots []*models.PickupSlot
+	query := s.db.Where("event_id = ?", eventID)
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	if err := query.Order("starts_at ASC, name ASC").Find(&slots).Error; err != nil {
+		return nil, err
+	}
+	out := make([]SlotAvailability, 0, len(slots))
+	for _, slot := range slots {
+		booked := slot.BookedCount(s.db)
+		remaining := slot.Capacity - booked
+		if remaining < 0 {
+			remaining = 0
+		}
+		out = append(out, SlotAvailability{PickupSlot: slot, Booked: booked, Remaining: remaining})
+	}
+	return out, nil
+}
+
+// CreateSlot adds a pickup time slot to an event
+func (s *PickupService) CreateSlot(eventID uuid.UUID, slot *models.PickupSlot) error {
+	var event models.Event
+	if err := s.db.First(&event, "id = ?", eventID).Error; err != nil {
+		return errors.New("event not found")
+	}
+	slot.EventID = eventID
+	if err := s.validatePickupSlot(slot); err != nil {
+		return err
+	}
+	return s.db.Create(slot).Error
+}
+
+// UpdateSlot replaces the definition of a pickup time slot. The capacity cannot drop below the seats
+// already booked, and the zone can only change while no rider of another zone is booked on the slot.
+func (s *PickupService) UpdateSlot(eventID, slotID uuid.UUID, slot *models.PickupSlot) error {
+	var existing models.PickupSlot
+	if err := s.db.First(&existing, "id = ? AND event_id = ?", slotID, eventID).Error; err != nil {
+		return errors.New("pickup time slot not found")
+	}
+	if err := s.validatePickupSlot(slot); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Same lock as bookings, so no seat is taken while the capacity changes
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error; err != nil {
+			return errors.New("event not found")
+		}
+		if booked := existing.BookedCount(tx); slot.Capacity < booked {
+			return fmt.Errorf("capacity cannot be below the %d seats already booked", booked)
+		}
+		if slot.ZoneID != nil {
+			var others int64
+			if err := tx.Model(&models.Ticket{}).
+				Where("pickup_slot_id = ? AND includes_pickup = ? AND (pickup_zone_id IS NULL OR pickup_zone_id <> ?)", slotID, true, *slot.ZoneID).
+				Where("status IN ?", []models.TicketStatus{models.TicketPending, models.TicketPaid}).
+				Count(&others).Error; err != nil {
+				return err
+			}
+			if others > 0 {
+				return errors.New("riders of other zones are booked on this time slot")
+			}
+		}
+		return tx.Model(&existing).Updates(map[string]interface{}{
+			"zone_id":      slot.ZoneID,
+			"name":         slot.Name,
+			"starts_at":    slot.StartsAt,
+			"ends_at":      slot.EndsAt,
+			"capacity":     slot.Capacity,
+			"driver_id":    slot.DriverID,
+			"driver_name":  slot.DriverName,
+			"driver_phone": slot.DriverPhone,
+			"is_active":    slot.IsActive,
+		}).Error
+	})
+}
+
+// DeleteSlot removes a pickup time slot that was never booked
+func (s *PickupService) DeleteSlot(eventID, slotID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&models.Ticket{}).Where("pickup_slot_id = ?", slotID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("pickup time slot has bookings; deactivate it instead")
+	}
+
+	result := s.db.Where("id = ? AND event_id = ?", slotID, eventID).Delete(&models.PickupSlot{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("pickup time slot not found")
+	}
+	return nil
+}
+
+// PickupOptions is what a buyer can choose for the pickup of an event
+type PickupOptions struct {
+	Price models.Money         `json:"price"`          // price for the postal code, or the general price without zones
+	Zone  *models.PickupZone   `json:"zone,omitempty"` // zone of the postal code
+	Zones []*models.PickupZone `json:"zones"`
+	Slots []SlotAvailability   `json:"slots"` // bookable time slots (serving the zone if a postal code is given)
+}
+
+// GetOptions returns the pickup zones and the bookable time slots of an event.
+// With a postal code the price and slots are those of its zone.
+func (s *PickupService) GetOptions(eventID uuid.UUID, postalCode string) (*PickupOptions, error) {
+	zones, err := s.GetZones(true)
+	if err != nil {
+		return nil, err
+	}
+	opts := &PickupOptions{Zones: zones, Slots: []SlotAvailability{}}
+
+	postalCode = models.NormalizePostalCode(postalCode)
+	if postalCode != "" {
+		if opts.Zone, err = findPickupZone(s.db, postalCode); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Zone != nil {
+		opts.Price = opts.Zone.Price
+	} else if len(zones) == 0 {
+		if opts.Price, err = pickupServicePrice(s.db); err != nil {
+			return nil, err
+		}
+	}
+
+	slots, err := s.GetSlots(eventID, true)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, slot := range slots {
+		if !slot.StartsAt.After(now) {
+			continue
+		}
+		if postalCode != "" && !slot.ServesZone(zoneIDOf(opts.Zone)) {
+			continue
+		}
+		opts.Slots = append(opts.Slots, slot)
+	}
+	return opts, nil
+}
+
+func zoneIDOf(zone *models.PickupZone) *uuid.UUID {
+	if zone == nil {
+		return nil
+	}
+	return &zone.ID
+}
+
+// PickupStop is one rider on a pickup manifest
+type PickupStop struct {
+	TicketID       uuid.UUID           `json:"ticket_id"`
+	Name           string              `json:"name"`
+	Mobile         string              `json:"mobile"`
+	Address        string              `json:"address"`
+	PostalCode     string              `json:"postal_code"`
+	Status         models.TicketStatus `json:"status"`
+	PickupStatus   string              `json:"pickup_status"`
+	PickupStatusAt *time.Time          `json:"pickup_status_at,omitempty"`
+	// Driver of the stop: the one assigned to the ticket, else the driver of the time slot
+	DriverID   *uuid.UUID `json:"driver_id,omitempty"`
+	DriverName string     `json:"driver_name,omitempty"`
+}
+
+// PickupManifestZone lists the stops of one zone within a time slot
+type PickupManifestZone struct {
+	ZoneID *uuid.UUID   `json:"zone_id,omitempty"`
+	Name   string       `json:"name"` // empty for pickups booked without zones
+	Stops  []PickupStop `json:"stops"`
+}
+
+// PickupManifestSlot lists the stops of one time slot grouped by zone
+type PickupManifestSlot struct {
+	Slot   *models.PickupSlot   `json:"slot,omitempty"` // nil for pickups booked without a time slot
+	Booked int                  `json:"booked"`
+	Zones  []PickupManifestZone `json:"zones"`
+}
+
+// withStops returns the time slot with only the stops matching keep (zones left empty are dropped)
+func (ms PickupManifestSlot) withStops(keep func(*PickupStop) bool) PickupManifestSlot {
+	out := PickupManifestSlot{Slot: ms.Slot, Booked: ms.Booked, Zones: []PickupManifestZone{}}
+	for _, zone := range ms.Zones {
+		z := PickupManifestZone{ZoneID: zone.ZoneID, Name: zone.Name}
+		for i := range zone.Stops {
+			if keep(&zone.Stops[i]) {
+				z.Stops = append(z.Stops, zone.Stops[i])
+			}
+		}
+		if len(z.Stops) > 0 {
+			out.Zones = append(out.Zones, z)
+		}
+	}
+	return out
+}
+
+// PickupManifest lists all pickups of an event by time slot and zone
+type PickupManifest struct {
+	EventID   uuid.UUID            `json:"event_id"`
+	EventName string               `json:"event_name"`
+	EventDate time.Time            `json:"event_date"`
+	Slots     []PickupManifestSlot `json:"slots"`
+}
+
+// ForDriver returns the manifest with only the stops of the given driver
+func (m *PickupManifest) ForDriver(driverID uuid.UUID) *PickupManifest {
+	out := &PickupManifest{EventID: m.EventID, EventName: m.EventName, EventDate: m.EventDate, Slots: []PickupManifestSlot{}}
+	for _, ms := range m.Slots {
+		filtered := ms.withStops(func(stop *PickupStop) bool {
+			return stop.DriverID != nil && *stop.DriverID == driverID
+		})
+		if len(filtered.Zones) > 0 {
+			out.Slots = append(out.Slots, filtered)
+		}
+	}
+	return out
+}
+
+// Manifest returns the pickups of an event grouped by time slot (in time order) and zone.
+// statusFilter: "paid" (default) | "all" (includes pending & paid)
+func (s *PickupService) Manifest(eventID uuid.UUID, statusFilter string) (*PickupManifest, error) {
+	var event models.Event
+	if err := s.db.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, errors.New("event not found")
+	}
+
+	var slots []*models.PickupSlot
+	if err := s.db.Where("event_id = ?", eventID).Order("starts_at ASC, name ASC").Find(&slots).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.db.Preload("User").Where("event_id = ? AND includes_pickup = ?", eventID, true)
+	switch statusFilter {
+	case "all":
+		query = query.Where("status IN ?", []models.TicketStatus{models.TicketPending, models.TicketPaid})
+	default:
+		query = query.Where("status = ?", models.TicketPaid)
+	}
+	var tickets []*models.Ticket
+	if err := query.Order("pickup_postal_code ASC, created_at ASC").Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+
+	// Drivers assigned to single pickups
+	drivers := map[uuid.UUID]*models.User{}
+	var driverIDs []uuid.UUID
+	for _, t := range tickets {
+		if t.PickupDriverID != nil {
+			driverIDs = append(driverIDs, *t.PickupDriverID)
+		}
+	}
+	if len(driverIDs) > 0 {
+		var users []*models.User
+		if err := s.db.Where("id IN ?", driverIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			drivers[u.ID] = u
+		}
+	}
+
+	bySlot := map[uuid.UUID][]*models.Ticket{}
+	var withoutSlot []*models.Ticket
+	for _, t := range tickets {
+		if t.PickupSlotID == nil {
+			withoutSlot = append(withoutSlot, t)
+			continue
+		}
+		bySlot[*t.PickupSlotID] = append(bySlot[*t.PickupSlotID], t)
+	}
+
+	manifest := &PickupManifest{
+		EventID:   event.ID,
+		EventName: event.Name,
+		EventDate: event.DateFrom,
+		Slots:     []PickupManifestSlot{},
+	}
+	for _, slot := range slots {
+		// Inactive slots only show up while they still have riders
+		if !slot.IsActive && len(bySlot[slot.ID]) == 0 {
+			continue
+		}
+		manifest.Slots = append(manifest.Slots, manifestSlot(slot, bySlot[slot.ID], drivers))
+	}
+	if len(withoutSlot) > 0 {
+		manifest.Slots = append(manifest.Slots, manifestSlot(nil, withoutSlot, drivers))
+	}
+	return manifest, nil
+}
+
+// manifestSlot groups the tickets of a time slot by zone (zones by name, tickets by postal code)
+func manifestSlot(slot *models.PickupSlot, tickets []*models.Ticket, drivers map[uuid.UUID]*models.User) PickupManifestSlot {
+	out := PickupManifestSlot{Slot: slot, Booked: len(tickets), Zones: []PickupManifestZone{}}
+	index := map[string]int{}
+	for _, t := range tickets {
+		key := ""
+		if t.PickupZoneID != nil {
+			key = t.PickupZoneID.String()
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(out.Zones)
+			index[key] = i
+			out.Zones = append(out.Zones, PickupManifestZone{ZoneID: t.PickupZoneID, Name: t.PickupZoneName})
+		}
+		stop := PickupStop{
+			TicketID:       t.ID,
+			Name:           t.AttendeeName(),
+			Mobile:         t.User.Mobile,
+			Address:        t.PickupAddress,
+			PostalCode:     t.PickupPostalCode,
+			Status:         t.Status,
+			PickupStatus:   t.PickupStatus,
+			PickupStatusAt: t.PickupStatusAt,
+		}
+		switch {
+		case t.PickupDriverID != nil:
+			stop.DriverID = t.PickupDriverID
+			if d, ok := drivers[*t.PickupDriverID]; ok {
+				stop.DriverName = d.Name
+			}
+		case slot != nil:
+			stop.DriverID = slot.DriverID
+			stop.DriverName = slot.DriverName
+		}
+		out.Zones[i].Stops = append(out.Zones[i].Stops, stop)
+	}
+	sort.SliceStable(out.Zones, func(i, j int) bool { return out.Zones[i].Name < out.Zones[j].Name })
+	return out
+}
+
+// noDriver is the manifest heading of pickups without a driver
+const noDriver = "Ohne Fahrer"
+
+// ManifestPDF renders the paid pickups of an event as a printable list, one section per driver
+// starting on a new page. With a driver name only that driver's pickups are included.
+func (s *PickupService) ManifestPDF(eventID uuid.UUID, driver string) ([]byte, error) {
+	manifest, err := s.Manifest(eventID, "paid")
+	if err != nil {
+		return nil, err
+	}
+
+	var drivers []string
+	byDriver := map[string][]PickupManifestSlot{}
+	add := func(name string, ms PickupManifestSlot) {
+		if name == "" {
+			name = noDriver
+		}
+		if driver != "" && !strings.EqualFold(name, strings.TrimSpace(driver)) {
+			return
+		}
+		if _, ok := byDriver[name]; !ok {
+			drivers = append(drivers, name)
+		}
+		byDriver[name] = append(byDriver[name], ms)
+	}
+	for _, ms := range manifest.Slots {
+		if len(ms.Zones) == 0 {
+			// Empty tours are listed for their driver
+			if ms.Slot != nil {
+				add(ms.Slot.DriverName, ms)
+			}
+			continue
+		}
+		// Pickups assigned to another driver than the tour's go on that driver's list
+		var names []string
+		seen := map[string]bool{}
+		for _, zone := range ms.Zones {
+			for _, stop := range zone.Stops {
+				if !seen[stop.DriverName] {
+					seen[stop.DriverName] = true
+					names = append(names, stop.DriverName)
+				}
+			}
+		}
+		for _, name := range names {
+			name := name
+			add(name, ms.withStops(func(stop *PickupStop) bool { return stop.DriverName == name }))
+		}
+	}
+	if len(drivers) == 0 {
+		return nil, errors.New("no pickups for this driver")
+	}
+
+	loc := berlinLocation()
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+
+	widths := []float64{8, 45, 32, 62, 15, 18}
+	headers := []string{"#", "Name", "Mobil", "Adresse", "PLZ", "Abgeholt"}
+	for _, name := range drivers {
+		pdf.AddPage()
+		pdf.SetFont("Arial", "B", 16)
+		pdf.CellFormat(0, 8, tr(fmt.Sprintf("Abholliste %s", manifest.EventName)), "", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		pdf.CellFormat(0, 5.5, tr(manifest.EventDate.In(loc).Format("02.01.2006")), "", 1, "L", false, 0, "")
+		driverLine := "Fahrer: " + name
+		if slot := byDriver[name][0].Slot; slot != nil && slot.DriverName == name && slot.DriverPhone != "" {
+			driverLine += " (" + slot.DriverPhone + ")"
+		}
+		pdf.CellFormat(0, 5.5, tr(driverLine), "", 1, "L", false, 0, "")
+		pdf.Ln(4)
+
+		for _, ms := range byDriver[name] {
+			title := "Ohne Zeitfenster"
+			if ms.Slot != nil {
+				title = fmt.Sprintf("%s–%s Uhr", ms.Slot.StartsAt.In(loc).Format("02.01. 15:04"), ms.Slot.EndsAt.In(loc).Format("15:04"))
+				if ms.Slot.Name != "" {
+					title = ms.Slot.Name + ", " + title
+				}
+				title += fmt.Sprintf(" · %d/%d Plätze", ms.Booked, ms.Slot.Capacity)
+			}
+			pdf.SetFont("Arial", "B", 12)
+			pdf.CellFormat(0, 7, tr(title), "", 1, "L", false, 0, "")
+			if len(ms.Zones) == 0 {
+				pdf.SetFont("Arial", "", 9)
+				pdf.CellFormat(0, 6, tr("Keine Abholungen"), "", 1, "L", false, 0, "")
+				pdf.Ln(3)
+				continue
+			}
+
+			n := 0
+			for _, zone := range ms.Zones {
+				if zone.Name != "" {
+					pdf.SetFont("Arial", "B", 10)
+					pdf.CellFormat(0, 6, tr("Zone "+zone.Name), "", 1, "L", false, 0, "")
+				}
+				pdf.SetFont("Arial", "B", 9)
+				pdf.SetFillColor(235, 235, 235)
+				for i, h := range headers {
+					pdf.CellFormat(widths[i], 6, tr(h), "B", 0, "L", true, 0, "")
+				}
+				pdf.Ln(-1)
+				pdf.SetFont("Arial", "", 9)
+				for _, stop := range zone.Stops {
+					n++
+					cells := []string{fmt.Sprintf("%d", n), stop.Name, stop.Mobile, stop.Address, stop.PostalCode, ""}
+					for i, c := range cells {
+						for pdf.GetStringWidth(tr(c)) > widths[i]-2 && len([]rune(c)) > 4 {
+							r := []rune(c)
+							c = string(r[:len(r)-4]) + "..."
+						}
+						border := ""
+						if i == len(cells)-1 {
+							border = "1"
+						}
+						pdf.CellFormat(widths[i], 7, tr(c), border, 0, "L", false, 0, "")
+					}
+					pdf.Ln(-1)
+				}
+				pdf.Ln(2)
+			}
+			pdf.Ln(3)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// getDriver returns an active user with the driver role
+func (s *PickupService) getDriver(driverID uuid.UUID) (*models.User, error) {
+	var driver models.User
+	if err := s.db.First(&driver, "id = ? AND is_driver = ? AND is_active = ?", driverID, true, true).Error; err != nil {
+		return nil, errors.New("driver not found")
+	}
+	return &driver, nil
+}
+
+// GetDrivers returns the active users with the driver role
+func (s *PickupService) GetDrivers() ([]*models.User, error) {
+	var drivers []*models.User
+	err := s.db.Where("is_driver = ? AND is_active = ?", true, true).Order("name ASC").Find(&drivers).Error
+	return drivers, err
+}
+
+// AssignPickups assigns pickups of an event to a driver. A nil driver hands them back to the driver of their time slot.
+func (s *PickupService) AssignPickups(eventID uuid.UUID, ticketIDs []uuid.UUID, driverID, actorID *uuid.UUID) error {
+	ids := make([]uuid.UUID, 0, len(ticketIDs))
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ticketIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return errors.New("no pickups selected")
+	}
+	if driverID != nil {
+		if _, err := s.getDriver(*driverID); err != nil {
+			return err
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Ticket{}).
+			Where("id IN ? AND event_id = ? AND includes_pickup = ?", ids, eventID, true).
+			Update("pickup_driver_id", driverID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if int(res.RowsAffected) != len(ids) {
+			return errors.New("pickup not found")
+		}
+		for _, id := range ids {
+			if err := recordTicketHistory(tx, id, "pickup_assigned", actorID, map[string]interface{}{
+				"driver_id": driverID,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// pickupDriverSQL matches the pickups of a driver in a ticket query joined with pickup_slots:
+// pickups assigned to the driver, and pickups without an assignment on the driver's time slots
+const pickupDriverSQL = "COALESCE(tickets.pickup_driver_id, pickup_slots.driver_id) = ?"
+
+// DriverEvent is an upcoming event a driver has pickups for
+type DriverEvent struct {
+	EventID  uuid.UUID `json:"event_id"`
+	Name     string    `json:"name"`
+	DateFrom time.Time `json:"date_from"`
+	TimeFrom string    `json:"time_from"`
+	Pickups  int       `json:"pickups"`
+}
+
+// DriverEvents returns the events (not yet over) with paid pickups of a driver
+func (s *PickupService) DriverEvents(driverID uuid.UUID) ([]DriverEvent, error) {
+	events := []DriverEvent{}
+	err := s.db.Table("tickets").
+		Joins("JOIN events ON events.id = tickets.event_id").
+		Joins("LEFT JOIN pickup_slots ON pickup_slots.id = tickets.pickup_slot_id").
+		Where("tickets.includes_pickup = ? AND tickets.status = ?", true, models.TicketPaid).
+		Where(pickupDriverSQL, driverID).
+		Where("events.date_to >= ?", time.Now().AddDate(0, 0, -1)).
+		Select("events.id AS event_id, events.name, events.date_from, events.time_from, COUNT(*) AS pickups").
+		Group("events.id, events.name, events.date_from, events.time_from").
+		Order("events.date_from ASC").
+		Scan(&events).Error
+	return events, err
+}
+
+// DriverManifest returns the paid pickups of a driver for an event, by time slot and zone
+func (s *PickupService) DriverManifest(driverID, eventID uuid.UUID) (*PickupManifest, error) {
+	manifest, err := s.Manifest(eventID, "paid")
+	if err != nil {
+		return nil, err
+	}
+	return manifest.ForDriver(driverID), nil
+}
+
+// UpdatePickupStatus records the progress of a pickup reported by its driver (admins may update any pickup).
+// The first time the driver is on the way, the rider gets an SMS.
+func (s *PickupService) UpdatePickupStatus(ticketID, actorID uuid.UUID, isAdmin bool, status string) (*models.Ticket, error) {
+	if !models.IsPickupStatus(status) {
+		return nil, fmt.Errorf("invalid status; must be one of %s", strings.Join(models.PickupStatuses, ", "))
+	}
+
+	var ticket models.Ticket
+	if err := s.db.Preload("User").First(&ticket, "id = ? AND includes_pickup = ?", ticketID, true).Error; err != nil {
+		return nil, errors.New("pickup not found")
+	}
+	if ticket.Status != models.TicketPaid {
+		return nil, errors.New("pickup has not been paid")
+	}
+	var slot *models.PickupSlot
+	if ticket.PickupSlotID != nil {
+		var sl models.PickupSlot
+		if err := s.db.First(&sl, "id = ?", *ticket.PickupSlotID).Error; err == nil {
+			slot = &sl
+		}
+	}
+	driverID := ticket.PickupDriverID
+	if driverID == nil && slot != nil {
+		driverID = slot.DriverID
+	}
+	if !isAdmin && (driverID == nil || *driverID != actorID) {
+		return nil, errors.New("pickup is not assigned to you")
+	}
+	if ticket.PickupStatus == status {
+		return &ticket, nil
+	}
+
+	now := time.Now()
+	from := ticket.PickupStatus
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Updates(map[string]interface{}{
+			"pickup_status":    status,
+			"pickup_status_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return recordTicketHistory(tx, ticket.ID, "pickup_status_changed", &actorID, map[string]interface{}{
+			"from": from,
+			"to":   status,
+		})
+	}); err != nil {
+		return nil, err
+	}
+	ticket.PickupStatus = status
+	ticket.PickupStatusAt = &now
+
+	if status == models.PickupStatusEnRoute {
+		s.notifyEnRoute(&ticket, driverID, slot)
+	}
+	return &ticket, nil
+}
+
+// notifyEnRoute tells the rider by SMS that the driver is on the way, once per pickup
+func (s *PickupService) notifyEnRoute(ticket *models.Ticket, driverID *uuid.UUID, slot *models.PickupSlot) {
+	if s.smsService == nil || !ticket.User.MobileVerified || ticket.User.Mobile == "" {
+		return
+	}
+	// Claim the notification so repeated status updates do not send it again
+	res := s.db.Model(&models.Ticket{}).Where("id = ? AND pickup_notified_at IS NULL", ticket.ID).
+		Update("pickup_notified_at", time.Now())
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+
+	name, phone := s.driverContact(driverID, slot)
+	body := fmt.Sprintf("Synesthesie: Dein Fahrer ist auf dem Weg zu %s", ticket.PickupAddress)
+	if name != "" {
+		body = fmt.Sprintf("Synesthesie: %s ist auf dem Weg zu %s", name, ticket.PickupAddress)
+	}
+	if ticket.HolderName != "" {
+		body += fmt.Sprintf(" (Abholung für %s)", ticket.HolderName)
+	}
+	body += "."
+	if phone != "" {
+		body += " Fahrer: " + phone
+	}
+	if err := s.smsService.SendSMS(ticket.User.Mobile, body); err != nil {
+		log.Printf("Pickup: failed to send en-route SMS for ticket %s: %v", ticket.ID, err)
+		// Let the next en-route update try again
+		if err := s.db.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Update("pickup_notified_at", nil).Error; err != nil {
+			log.Printf("Pickup: failed to release en-route SMS for ticket %s: %v", ticket.ID, err)
+		}
+	}
+}
+
+// driverContact returns the name and phone number of a pickup's driver shown to the rider
+func (s *PickupService) driverContact(driverID *uuid.UUID, slot *models.PickupSlot) (name, phone string) {
+	if slot != nil && (driverID == nil || (slot.DriverID != nil && *slot.DriverID == *driverID)) {
+		name, phone = slot.DriverName, slot.DriverPhone
+	}
+	if driverID != nil && (name == "" || phone == "") {
+		var driver models.User
+		if err := s.db.First(&driver, "id = ?", *driverID).Error; err == nil {
+			if name == "" {
+				name = driver.Name
+			}
+			if phone == "" {
+				phone = driver.Mobile
+			}
+		}
+	}
+	return name, phone
+}
+
+// DriverProgress counts the paid pickups of one driver by status
+type DriverProgress struct {
+	DriverID   *uuid.UUID `json:"driver_id,omitempty"` // nil: no driver account
+	DriverName string     `json:"driver_name"`
+	Total      int        `json:"total"`
+	Open       int        `json:"open"`
+	EnRoute    int        `json:"en_route"`
+	PickedUp   int        `json:"picked_up"`
+	NoShow     int        `json:"no_show"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+}
+
+func (p *DriverProgress) add(stop *PickupStop) {
+	p.Total++
+	switch stop.PickupStatus {
+	case models.PickupStatusEnRoute:
+		p.EnRoute++
+	case models.PickupStatusPickedUp:
+		p.PickedUp++
+	case models.PickupStatusNoShow:
+		p.NoShow++
+	default:
+		p.Open++
+	}
+	if stop.PickupStatusAt != nil && (p.LastUpdate == nil || stop.PickupStatusAt.After(*p.LastUpdate)) {
+		p.LastUpdate = stop.PickupStatusAt
+	}
+}
+
+// PickupProgress is the live progress of the pickups of an event
+type PickupProgress struct {
+	EventID uuid.UUID        `json:"event_id"`
+	Drivers []DriverProgress `json:"drivers"` // in order of their first time slot
+	Total   DriverProgress   `json:"total"`
+}
+
+// Progress counts the paid pickups of an event by driver and status
+func (s *PickupService) Progress(eventID uuid.UUID) (*PickupProgress, error) {
+	manifest, err := s.Manifest(eventID, "paid")
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &PickupProgress{EventID: eventID, Drivers: []DriverProgress{}}
+	index := map[string]int{}
+	for _, ms := range manifest.Slots {
+		for _, zone := range ms.Zones {
+			for i := range zone.Stops {
+				stop := &zone.Stops[i]
+				key := stop.DriverName
+				if stop.DriverID != nil {
+					key = stop.DriverID.String()
+				}
+				n, ok := index[key]
+				if !ok {
+					n = len(progress.Drivers)
+					index[key] = n
+					name := stop.DriverName
+					if name == "" {
+						name = noDriver
+					}
+					progress.Drivers = append(progress.Drivers, DriverProgress{DriverID: stop.DriverID, DriverName: name})
+				}
+				progress.Drivers[n].add(stop)
+				progress.Total.add(stop)
+			}
+		}
+	}
+	progress.Total.DriverName = "Summe"
+	return progress, nil
+}
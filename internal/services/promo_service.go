@@ -0,0 +1,201 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromoCodeService struct {
+	db *gorm.DB
+}
+
+func NewPromoCodeService(db *gorm.DB) *PromoCodeService {
+	return &PromoCodeService{db: db}
+}
+
+// validatePromoCode checks and normalizes a promo code definition
+func validatePromoCode(p *models.PromoCode) error {
+	p.Code = models.NormalizePromoCode(p.Code)
+	if p.Code == "" {
+		return errors.New("code is required")
+	}
+	if len(p.Code) > 64 {
+		return errors.New("code is too long (max 64 characters)")
+	}
+	switch p.DiscountType {
+	case models.PromoDiscountPercent:
+		if p.DiscountPercent <= 0 || p.DiscountPercent > models.FullPercent {
+			return errors.New("percentage discount must be between 0 and 100")
+		}
+		p.DiscountAmount = 0
+	case models.PromoDiscountFixed:
+		if p.DiscountAmount <= 0 {
+			return errors.New("fixed discount must be positive")
+		}
+		p.DiscountPercent = 0
+	default:
+		return errors.New("invalid discount_type; must be 'percent' or 'fixed'")
+	}
+	if p.MaxUses < 0 || p.MaxUsesPerUser < 0 {
+		return errors.New("usage limits cannot be negative")
+	}
+	if p.ValidFrom != nil && p.ValidUntil != nil && p.ValidFrom.After(*p.ValidUntil) {
+		return errors.New("valid_from must be before valid_until")
+	}
+	groups := p.Groups()
+	for _, g := range groups {
+		if !validTicketTypeGroups[g] {
+			return errors.New("invalid allowed_groups; must be 'guests', 'bubble' or 'plus'")
+		}
+	}
+	p.AllowedGroups = strings.Join(groups, ",")
+	return nil
+}
+
+// GetPromoCodes returns all promo codes, newest first
+func (s *PromoCodeService) GetPromoCodes() ([]*models.PromoCode, error) {
+	var codes []*models.PromoCode
+	err := s.db.Order("created_at DESC").Find(&codes).Error
+	return codes, err
+}
+
+// GetPromoCode returns a promo code by ID
+func (s *PromoCodeService) GetPromoCode(id uuid.UUID) (*models.PromoCode, error) {
+	var code models.PromoCode
+	if err := s.db.First(&code, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("promo code not found")
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+// CreatePromoCode stores a new promo code
+func (s *PromoCodeService) CreatePromoCode(p *models.PromoCode) error {
+	if err := validatePromoCode(p); err != nil {
+		return err
+	}
+	var count int64
+	s.db.Model(&models.PromoCode{}).Where("code = ?", p.Code).Count(&count)
+	if count > 0 {
+		return errors.New("code already exists")
+	}
+	return s.db.Create(p).Error
+}
+
+// UpdatePromoCode replaces the definition of a promo code.
+// Tickets already booked keep their discount.
+func (s *PromoCodeService) UpdatePromoCode(id uuid.UUID, p *models.PromoCode) error {
+	existing, err := s.GetPromoCode(id)
+	if err != nil {
+		return err
+	}
+	if err := validatePromoCode(p); err != nil {
+		return err
+	}
+	if p.Code != existing.Code {
+		var count int64
+		s.db.Model(&models.PromoCode{}).Where("code = ? AND id <> ?", p.Code, id).Count(&count)
+		if count > 0 {
+			return errors.New("code already exists")
+		}
+	}
+
+	return s.db.Model(existing).Updates(map[string]interface{}{
+		"code":              p.Code,
+		"description":       p.Description,
+		"discount_type":     p.DiscountType,
+		"discount_percent":  p.DiscountPercent,
+		"discount_amount":   p.DiscountAmount,
+		"max_uses":          p.MaxUses,
+		"max_uses_per_user": p.MaxUsesPerUser,
+		"valid_from":        p.ValidFrom,
+		"valid_until":       p.ValidUntil,
+		"event_ids":         p.EventIDs,
+		"allowed_groups":    p.AllowedGroups,
+		"is_active":         p.IsActive,
+	}).Error
+}
+
+// DeletePromoCode removes a promo code that was never used
+func (s *PromoCodeService) DeletePromoCode(id uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&models.Ticket{}).Where("promo_code_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("promo code has been used; deactivate it instead")
+	}
+
+	result := s.db.Where("id = ?", id).Delete(&models.PromoCode{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("promo code not found")
+	}
+	return nil
+}
+
+// Preview returns the price and discount a user would get when booking with the code.
+// Limits are re-checked under lock when the ticket is actually booked.
+func (s *PromoCodeService) Preview(userID, eventID, ticketTypeID uuid.UUID, code string) (*models.PromoCode, *models.TicketType, models.Money, error) {
+	var event models.Event
+	if err := s.db.First(&event, "id = ?", eventID).Error; err != nil {
+		return nil, nil, 0, errors.New("event not found")
+	}
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, nil, 0, errors.New("user not found")
+	}
+	if !event.AllowsGroup(user.Group) {
+		return nil, nil, 0, errors.New("event not available for your group")
+	}
+	ticketType, err := selectTicketType(s.db, &event, &user, ticketTypeID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	promo, discount, err := redeemPromoCode(s.db, code, &user, event.ID, ticketType.Price)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return promo, ticketType, discount, nil
+}
+
+// redeemPromoCode checks a code for a booking and returns the discount on the ticket price.
+// Inside a booking transaction the code row is locked so usage limits cannot be exceeded.
+func redeemPromoCode(tx *gorm.DB, code string, user *models.User, eventID uuid.UUID, price models.Money) (*models.PromoCode, models.Money, error) {
+	var promo models.PromoCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&promo, "code = ?", models.NormalizePromoCode(code)).Error; err != nil {
+		return nil, 0, errors.New("invalid promo code")
+	}
+	if !promo.ValidAt(time.Now()) {
+		return nil, 0, errors.New("promo code is not valid")
+	}
+	if !promo.AppliesToEvent(eventID) {
+		return nil, 0, errors.New("promo code is not valid for this event")
+	}
+	if !promo.AllowsGroup(user.Group) {
+		return nil, 0, errors.New("promo code is not valid for your group")
+	}
+	if promo.MaxUses > 0 && promo.UsageCount(tx) >= promo.MaxUses {
+		return nil, 0, errors.New("promo code has been used up")
+	}
+	if promo.MaxUsesPerUser > 0 && promo.UserUsageCount(tx, user.ID) >= promo.MaxUsesPerUser {
+		return nil, 0, errors.New("you have already used this promo code")
+	}
+	return &promo, promo.Discount(price), nil
+}
+
+// UsageCount returns how many booked tickets currently use the code
+func (s *PromoCodeService) UsageCount(p *models.PromoCode) int {
+	return p.UsageCount(s.db)
+}
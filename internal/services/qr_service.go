@@ -2,19 +2,74 @@ package services
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jung-kurt/gofpdf"
 	qrcode "github.com/skip2/go-qrcode"
 	"github.com/synesthesie/backend/internal/config"
 	"github.com/synesthesie/backend/internal/models"
 )
 
+// ticketTokenPrefix marks the Ed25519 token: SYT1.<base64url claims>.<base64url signature>
+const ticketTokenPrefix = "SYT1"
+
 type QRService struct {
-	cfg *config.Config
+	cfg        *config.Config
+	signingKey ed25519.PrivateKey
+}
+
+// NewQRService fails without a valid TICKET_SIGNING_KEY in production
+func NewQRService(cfg *config.Config) (*QRService, error) {
+	key, err := loadTicketSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &QRService{cfg: cfg, signingKey: key}, nil
+}
+
+// TicketTokenClaims are the fields carried in an offline-verifiable ticket token
+type TicketTokenClaims struct {
+	TicketID  uuid.UUID `json:"t"`
+	EventID   uuid.UUID `json:"e"`
+	Name      string    `json:"n"`
+	Group     string    `json:"g"`
+	ExpiresAt int64     `json:"x"`           // unix seconds
+	Version   int       `json:"v,omitempty"` // ticket.TokenVersion at issue time
 }
 
-func NewQRService(cfg *config.Config) *QRService { return &QRService{cfg: cfg} }
+var (
+	devSigningKeyOnce sync.Once
+	devSigningKey     ed25519.PrivateKey
+)
+
+// loadTicketSigningKey reads the Ed25519 seed from config. Outside production a missing seed is replaced
+// by a random key for the lifetime of the process, so tickets issued before a restart no longer verify.
+func loadTicketSigningKey(cfg *config.Config) (ed25519.PrivateKey, error) {
+	if cfg.TicketSigningKey != "" {
+		seed, err := base64.StdEncoding.DecodeString(cfg.TicketSigningKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("TICKET_SIGNING_KEY must be a base64 encoded %d byte seed", ed25519.SeedSize)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if cfg.Env == "production" {
+		return nil, errors.New("TICKET_SIGNING_KEY is required in production")
+	}
+	devSigningKeyOnce.Do(func() {
+		log.Printf("WARN: TICKET_SIGNING_KEY not set; signing tickets with a random key until restart")
+		_, devSigningKey, _ = ed25519.GenerateKey(nil)
+	})
+	return devSigningKey, nil
+}
 
 // GenerateInviteQRPDF generates a simple A4 PDF with a QR code for the invite link
 func (s *QRService) GenerateInviteQRPDF(invite *models.InviteCode) ([]byte, error) {
@@ -53,3 +108,63 @@ func (s *QRService) GenerateInviteQRPDF(invite *models.InviteCode) ([]byte, erro
 	}
 	return out.Bytes(), nil
 }
+
+// TicketPublicKey returns the Ed25519 key scanners use to verify ticket tokens offline
+func (s *QRService) TicketPublicKey() ed25519.PublicKey {
+	return s.signingKey.Public().(ed25519.PublicKey)
+}
+
+// SignTicketToken issues a compact Ed25519-signed token for a ticket.
+// ticket.User and ticket.Event must be loaded. Tokens stay valid until a day after the event.
+func (s *QRService) SignTicketToken(ticket *models.Ticket) string {
+	end := ticket.Event.DateTo
+	if end.IsZero() || end.Before(ticket.Event.DateFrom) {
+		end = ticket.Event.DateFrom
+	}
+	claims := TicketTokenClaims{
+		TicketID:  ticket.ID,
+		EventID:   ticket.EventID,
+		Name:      ticket.AttendeeName(),
+		Group:     ticket.User.Group,
+		ExpiresAt: end.Add(24 * time.Hour).Unix(),
+		Version:   ticket.TokenVersion,
+	}
+	payload, _ := json.Marshal(claims)
+	signed := ticketTokenPrefix + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(s.signingKey, []byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// VerifyTicketToken checks signature and expiry of a ticket token as of the given time
+func (s *QRService) VerifyTicketToken(token string, at time.Time) (*TicketTokenClaims, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 || parts[0] != ticketTokenPrefix {
+		return nil, errors.New("invalid qr code")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(s.TicketPublicKey(), []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, errors.New("invalid qr signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid qr code")
+	}
+	var claims TicketTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("invalid qr code")
+	}
+	if at.Unix() > claims.ExpiresAt {
+		return nil, errors.New("ticket token expired")
+	}
+	return &claims, nil
+}
+
+// TicketQRPayload returns the payload encoded in a ticket's QR code (an Ed25519 ticket token)
+func (s *QRService) TicketQRPayload(ticket *models.Ticket) string {
+	return s.SignTicketToken(ticket)
+}
+
+// GenerateTicketQRPNG renders the ticket token as PNG. ticket.User and ticket.Event must be loaded.
+func (s *QRService) GenerateTicketQRPNG(ticket *models.Ticket) ([]byte, error) {
+	return qrcode.Encode(s.TicketQRPayload(ticket), qrcode.Medium, 512)
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrReconciliationRunNotFound = errors.New("reconciliation run not found")
+
+// reconciliationMaxPeriod limits the period of one run (every checkout costs a provider request)
+const reconciliationMaxPeriod = 92 * 24 * time.Hour
+
+// ReconciliationService compares the tickets of a period with their payments at Stripe and PayPal.
+// The polling loops only look at the last 30 minutes; this catches what slipped through afterwards
+// (lost webhooks, dashboard refunds, manual changes). Each run is stored as a report and can be emailed.
+type ReconciliationService struct {
+	db            *gorm.DB
+	cfg           *config.Config
+	ticketService *TicketService
+	emailService  *EmailService
+}
+
+func NewReconciliationService(db *gorm.DB, cfg *config.Config, ticketService *TicketService, emailService *EmailService) *ReconciliationService {
+	return &ReconciliationService{
+		db:            db,
+		cfg:           cfg,
+		ticketService: ticketService,
+		emailService:  emailService,
+	}
+}
+
+// reconciliationCheckout is the tickets of one checkout (Stripe session / PayPal order)
+type reconciliationCheckout struct {
+	provider   string
+	checkoutID string
+	tickets    []*models.Ticket
+}
+
+// Run compares every checkout with tickets created in [from, to) with its payment at the provider and
+// stores the report. With autoFix, tickets still pending although paid are confirmed and refunds missing
+// locally are booked; everything else is only reported.
+func (s *ReconciliationService) Run(from, to time.Time, autoFix bool, trigger string, triggeredBy *uuid.UUID) (*models.ReconciliationRun, error) {
+	if !to.After(from) {
+		return nil, errors.New("period end must be after its start")
+	}
+	if to.Sub(from) > reconciliationMaxPeriod {
+		return nil, errors.New("period must not exceed 92 days")
+	}
+
+	run := &models.ReconciliationRun{
+		PeriodFrom:  from,
+		PeriodTo:    to,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		AutoFix:     autoFix,
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+
+	var tickets []*models.Ticket
+	err := s.db.Where("created_at >= ? AND created_at < ? AND (stripe_session_id <> '' OR paypal_order_id <> '')", from, to).
+		Order("created_at ASC").Find(&tickets).Error
+	if err != nil {
+		run.Error = err.Error()
+	} else {
+		for _, checkout := range groupCheckouts(tickets) {
+			run.Checked++
+			run.Items = append(run.Items, s.reconcile(checkout, autoFix)...)
+		}
+	}
+	if run.Items == nil {
+		run.Items = []models.ReconciliationItem{}
+	}
+	run.Mismatches = len(run.Items)
+	for _, item := range run.Items {
+		if item.Fixed {
+			run.Fixed++
+		}
+	}
+	now := time.Now()
+	run.FinishedAt = &now
+	if uErr := s.db.Save(run).Error; uErr != nil {
+		log.Printf("Reconciliation %s: failed to store report: %v", run.ID, uErr)
+	}
+
+	log.Printf("Reconciliation %s (%s): checked %d checkouts from %s to %s, %d mismatches, %d fixed",
+		run.ID, trigger, run.Checked, from.Format(time.RFC3339), to.Format(time.RFC3339), run.Mismatches, run.Fixed)
+	return run, err
+}
+
+// groupCheckouts groups tickets by the checkout they were paid with, in order of appearance
+func groupCheckouts(tickets []*models.Ticket) []*reconciliationCheckout {
+	var checkouts []*reconciliationCheckout
+	byKey := map[string]*reconciliationCheckout{}
+	for _, t := range tickets {
+		provider, checkoutID := "stripe", t.StripeSessionID
+		if t.PaymentProvider == "paypal" {
+			provider, checkoutID = "paypal", t.PayPalOrderID
+		}
+		if checkoutID == "" {
+			continue
+		}
+		key := provider + ":" + checkoutID
+		checkout, ok := byKey[key]
+		if !ok {
+			checkout = &reconciliationCheckout{provider: provider, checkoutID: checkoutID}
+			byKey[key] = checkout
+			checkouts = append(checkouts, checkout)
+		}
+		checkout.tickets = append(checkout.tickets, t)
+	}
+	return checkouts
+}
+
+// reconcile compares one checkout with the provider and returns its mismatches
+func (s *ReconciliationService) reconcile(checkout *reconciliationCheckout, autoFix bool) []models.ReconciliationItem {
+	first := checkout.tickets[0]
+	base := models.ReconciliationItem{
+		Provider:   checkout.provider,
+		CheckoutID: checkout.checkoutID,
+		OrderID:    first.OrderID,
+	}
+
+	statuses := map[string]bool{}
+	paidLocally, pending := false, false
+	localRef := ""
+	for _, t := range checkout.tickets {
+		base.TicketIDs = append(base.TicketIDs, t.ID)
+		statuses[string(t.Status)] = true
+		switch t.Status {
+		case "paid", "disputed", "refunded":
+			paidLocally = true
+		case "pending", "pending_cancellation":
+			pending = true
+		}
+		if _, ref := ticketPaymentRef(t); ref != "" {
+			localRef = ref
+		}
+	}
+	var statusList []string
+	for status := range statuses {
+		statusList = append(statusList, status)
+	}
+	sort.Strings(statusList)
+	base.LocalStatus = strings.Join(statusList, ",")
+	base.PaymentRef = localRef
+
+	failed := func(note string) []models.ReconciliationItem {
+		item := base
+		item.Type, item.Note = models.ReconciliationCheckFailed, note
+		return []models.ReconciliationItem{item}
+	}
+
+	amount, refunded, err := s.localAmounts(checkout, localRef)
+	if err != nil {
+		return failed(err.Error())
+	}
+	base.LocalAmount, base.LocalRefunded = amount, refunded
+
+	provider := s.ticketService.providerFor(checkout.provider)
+	if provider == nil {
+		return failed(checkout.provider + " is not enabled")
+	}
+	payment, err := provider.GetPaymentStatus(first)
+	if err != nil {
+		return failed(err.Error())
+	}
+	base.ProviderStatus = payment.Status
+	base.ProviderAmount, base.ProviderRefunded = payment.Amount, payment.RefundedAmount
+	if base.PaymentRef == "" {
+		base.PaymentRef = payment.PaymentRef
+	}
+	paidAtProvider := payment.Status != ProviderPaymentUnpaid
+
+	var items []models.ReconciliationItem
+	switch {
+	case paidAtProvider && !paidLocally && pending:
+		item := base
+		item.Type = models.ReconciliationPaidNotConfirmed
+		if autoFix {
+			if provider.CheckAndCaptureOrder(first) {
+				item.Fixed = true
+				item.Note = "tickets confirmed as paid; no confirmation email was sent"
+			} else {
+				item.FixError = "confirming the payment failed"
+			}
+		}
+		return append(items, item)
+	case paidAtProvider && !paidLocally:
+		item := base
+		item.Type = models.ReconciliationPaidButCancelled
+		item.Note = "refund at the provider or restore the tickets manually"
+		return append(items, item)
+	case !paidAtProvider && paidLocally:
+		item := base
+		item.Type = models.ReconciliationNotPaidAtProvider
+		return append(items, item)
+	case !paidAtProvider:
+		return nil
+	}
+
+	if payment.Amount != amount {
+		item := base
+		item.Type = models.ReconciliationAmountMismatch
+		items = append(items, item)
+	}
+	if missing := payment.RefundedAmount - refunded; missing > 0 {
+		item := base
+		item.Type = models.ReconciliationRefundNotRecorded
+		if autoFix {
+			if localRef == "" {
+				item.FixError = "tickets have no payment reference"
+			} else {
+				// Keyed by the provider's refunded total, so later runs do not book the same difference again
+				refundID := fmt.Sprintf("reconciliation:%s:%d", localRef, payment.RefundedAmount.Cents())
+				if err := s.ticketService.ApplyProviderRefund(checkout.provider, localRef, refundID, missing, models.RefundReasonProviderRefund); err != nil {
+					item.FixError = err.Error()
+				} else {
+					item.Fixed = true
+				}
+			}
+		}
+		items = append(items, item)
+	} else if refunded > payment.RefundedAmount {
+		item := base
+		item.Type = models.ReconciliationRefundNotAtProvider
+		items = append(items, item)
+	}
+	return items
+}
+
+// localAmounts returns what the checkout charged (order total) and what we booked as refunded for its payment
+func (s *ReconciliationService) localAmounts(checkout *reconciliationCheckout, paymentRef string) (amount, refunded models.Money, err error) {
+	first := checkout.tickets[0]
+	if first.OrderID != nil {
+		var order models.Order
+		if err := s.db.Select("id", "total_amount").First(&order, "id = ?", *first.OrderID).Error; err != nil {
+			return 0, 0, fmt.Errorf("failed to load order: %w", err)
+		}
+		amount = order.TotalAmount
+	} else {
+		for _, t := range checkout.tickets {
+			amount += t.TotalAmount
+		}
+	}
+
+	if paymentRef != "" {
+		if err := s.db.Model(&models.Refund{}).
+			Where("provider = ? AND provider_ref = ? AND status = ?", checkout.provider, paymentRef, models.RefundStatusSucceeded).
+			Select("COALESCE(SUM(amount), 0)::bigint").Scan(&refunded).Error; err != nil {
+			return 0, 0, fmt.Errorf("failed to sum refunds: %w", err)
+		}
+	}
+	return amount, refunded, nil
+}
+
+// RunNightly reconciles the last ReconciliationLookbackDays (up to an hour ago, younger checkouts are still
+// polled) and emails the report
+func (s *ReconciliationService) RunNightly() (*models.ReconciliationRun, error) {
+	days := s.cfg.ReconciliationLookbackDays
+	if days < 1 {
+		days = 30
+	}
+	to := time.Now().Add(-time.Hour)
+	run, err := s.Run(to.AddDate(0, 0, -days), to, s.cfg.ReconciliationAutoFix, "nightly", nil)
+	if run != nil {
+		if mErr := s.SendReport(run); mErr != nil {
+			log.Printf("Reconciliation %s: failed to email report: %v", run.ID, mErr)
+		}
+	}
+	return run, err
+}
+
+// SendReport emails a run's report to ReconciliationEmail
+func (s *ReconciliationService) SendReport(run *models.ReconciliationRun) error {
+	if s.emailService == nil || s.cfg.ReconciliationEmail == "" {
+		return nil
+	}
+	loc := berlinLocation()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Zahlungsabgleich mit Stripe und PayPal\n\n")
+	fmt.Fprintf(&b, "Zeitraum: %s – %s\n", run.PeriodFrom.In(loc).Format("02.01.2006 15:04"), run.PeriodTo.In(loc).Format("02.01.2006 15:04"))
+	fmt.Fprintf(&b, "Geprüfte Checkouts: %d\nAbweichungen: %d\n", run.Checked, run.Mismatches)
+	if run.AutoFix {
+		fmt.Fprintf(&b, "Automatisch behoben: %d\n", run.Fixed)
+	}
+	if run.Error != "" {
+		fmt.Fprintf(&b, "\nFEHLER: %s\n", run.Error)
+	}
+	for _, item := range run.Items {
+		fmt.Fprintf(&b, "\n- %s (%s, Checkout %s", item.Type, item.Provider, item.CheckoutID)
+		if item.PaymentRef != "" {
+			fmt.Fprintf(&b, ", Zahlung %s", item.PaymentRef)
+		}
+		fmt.Fprintf(&b, ")\n  Tickets: %s\n", joinUUIDs(item.TicketIDs))
+		fmt.Fprintf(&b, "  Lokal: %s, %s EUR, erstattet %s EUR\n", item.LocalStatus, item.LocalAmount, item.LocalRefunded)
+		if item.Type != models.ReconciliationCheckFailed {
+			fmt.Fprintf(&b, "  Anbieter: %s, %s EUR, erstattet %s EUR\n", item.ProviderStatus, item.ProviderAmount, item.ProviderRefunded)
+		}
+		if item.Fixed {
+			fmt.Fprintf(&b, "  Behoben\n")
+		} else if item.FixError != "" {
+			fmt.Fprintf(&b, "  Behebung fehlgeschlagen: %s\n", item.FixError)
+		}
+		if item.Note != "" {
+			fmt.Fprintf(&b, "  Hinweis: %s\n", item.Note)
+		}
+	}
+	fmt.Fprintf(&b, "\nDetails: GET /api/v1/admin/reconciliation/%s\n", run.ID)
+
+	subject := fmt.Sprintf("Zahlungsabgleich %s: %d Abweichungen", run.PeriodTo.In(loc).Format("02.01.2006"), run.Mismatches)
+	if run.Mismatches == 0 && run.Error == "" {
+		subject = fmt.Sprintf("Zahlungsabgleich %s: keine Abweichungen", run.PeriodTo.In(loc).Format("02.01.2006"))
+	}
+	return s.emailService.SendGenericTextEmail(s.cfg.ReconciliationEmail, subject, b.String())
+}
+
+func joinUUIDs(ids []uuid.UUID) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = id.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GetRun returns a stored run including its mismatches
+func (s *ReconciliationService) GetRun(id uuid.UUID) (*models.ReconciliationRun, error) {
+	var run models.ReconciliationRun
+	if err := s.db.First(&run, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReconciliationRunNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetRuns lists stored runs without their mismatches, newest first
+func (s *ReconciliationService) GetRuns(page, limit int) ([]*models.ReconciliationRun, int64, error) {
+	var runs []*models.ReconciliationRun
+	var total int64
+
+	query := s.db.Model(&models.ReconciliationRun{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	err := query.Omit("items").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&runs).Error
+	return runs, total, err
+}
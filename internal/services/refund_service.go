@@ -0,0 +1,359 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundService is the only place that asks payment providers for refunds.
+// Every attempt is stored as a models.Refund: callers record the refund in the same transaction
+// as the ticket/order change that causes it and execute it after the commit. A provider error does
+// not undo that change; the refund is marked failed and can be retried by an admin. Refunds left
+// pending (e.g. by a restart before the execution) are sent by the refund recovery job (ExecuteStale).
+// Succeeded refunds are documented with a credit note (Gutschrift).
+type RefundService struct {
+	db             *gorm.DB
+	stripeProvider PaymentProvider
+	paypalProvider PaymentProvider
+	invoiceService *InvoiceService
+}
+
+func NewRefundService(db *gorm.DB, stripeProvider, paypalProvider PaymentProvider, invoiceService *InvoiceService) *RefundService {
+	return &RefundService{
+		db:             db,
+		stripeProvider: stripeProvider,
+		paypalProvider: paypalProvider,
+		invoiceService: invoiceService,
+	}
+}
+
+// RefundRequest describes money to give back for one payment
+type RefundRequest struct {
+	Provider    string
+	ProviderRef string // Stripe payment intent or PayPal capture
+	OrderID     *uuid.UUID
+	TicketID    *uuid.UUID
+	OrderItemID *uuid.UUID
+	TransferID  *uuid.UUID
+	Amount      models.Money
+	Reason      string
+	Initiator   string
+	InitiatedBy *uuid.UUID
+}
+
+// ticketPaymentRef returns the provider and payment reference carried by a ticket
+func ticketPaymentRef(ticket *models.Ticket) (string, string) {
+	if ticket.PaymentProvider == "paypal" && ticket.PayPalCaptureID != "" {
+		return "paypal", ticket.PayPalCaptureID
+	}
+	if ticket.StripePaymentIntentID != "" {
+		return "stripe", ticket.StripePaymentIntentID
+	}
+	return ticket.PaymentProvider, ""
+}
+
+// ticketRefund builds a request refunding part of the payment carried by a ticket
+func ticketRefund(payment *models.Ticket, amount models.Money, reason, initiator string, initiatedBy *uuid.UUID) *RefundRequest {
+	provider, ref := ticketPaymentRef(payment)
+	ticketID := payment.ID
+	return &RefundRequest{
+		Provider:    provider,
+		ProviderRef: ref,
+		OrderID:     payment.OrderID,
+		TicketID:    &ticketID,
+		Amount:      amount,
+		Reason:      reason,
+		Initiator:   initiator,
+		InitiatedBy: initiatedBy,
+	}
+}
+
+// Record stores a pending refund within tx. Nothing is recorded for amounts <= 0.
+// A refund without payment reference is stored as failed right away so it shows up for review.
+func (s *RefundService) Record(tx *gorm.DB, req *RefundRequest) (*models.Refund, error) {
+	return recordRefund(tx, req)
+}
+
+// recordRefund stores a pending refund within tx for code paths without a RefundService (e.g. provider polling);
+// such refunds are sent by the refund recovery job
+func recordRefund(tx *gorm.DB, req *RefundRequest) (*models.Refund, error) {
+	if req.Amount <= 0 {
+		return nil, nil
+	}
+	r := &models.Refund{
+		OrderID:     req.OrderID,
+		TicketID:    req.TicketID,
+		OrderItemID: req.OrderItemID,
+		TransferID:  req.TransferID,
+		Amount:      req.Amount,
+		Provider:    req.Provider,
+		ProviderRef: req.ProviderRef,
+		Status:      models.RefundStatusPending,
+		Reason:      req.Reason,
+		Initiator:   req.Initiator,
+		InitiatedBy: req.InitiatedBy,
+	}
+	if r.ProviderRef == "" {
+		r.Status = models.RefundStatusFailed
+		r.Error = "no payment found to refund"
+	}
+	if err := tx.Create(r).Error; err != nil {
+		return nil, fmt.Errorf("failed to record refund: %w", err)
+	}
+	return r, nil
+}
+
+// RecordCompleted stores a refund the provider has already carried out (e.g. reported by a webhook)
+// and books it in the ledger within tx. Credit notes are issued with IssueCreditNotes after the commit.
+func (s *RefundService) RecordCompleted(tx *gorm.DB, req *RefundRequest, providerRefundID string) (*models.Refund, error) {
+	now := time.Now()
+	r := &models.Refund{
+		OrderID:          req.OrderID,
+		TicketID:         req.TicketID,
+		OrderItemID:      req.OrderItemID,
+		TransferID:       req.TransferID,
+		Amount:           req.Amount,
+		Provider:         req.Provider,
+		ProviderRef:      req.ProviderRef,
+		ProviderRefundID: providerRefundID,
+		Status:           models.RefundStatusSucceeded,
+		Reason:           req.Reason,
+		Initiator:        req.Initiator,
+		InitiatedBy:      req.InitiatedBy,
+		CompletedAt:      &now,
+	}
+	if err := tx.Create(r).Error; err != nil {
+		return nil, fmt.Errorf("failed to record refund: %w", err)
+	}
+	return r, recordRefundTransaction(tx, r)
+}
+
+// IssueCreditNotes documents refunds stored with RecordCompleted once their transaction is committed
+func (s *RefundService) IssueCreditNotes(refunds []*models.Refund) {
+	for _, r := range refunds {
+		s.issueCreditNote(r)
+	}
+}
+
+// Execute sends a pending refund to its provider and stores the outcome.
+// Failures are logged and kept on the record, so callers may ignore the returned error.
+// If another execution (e.g. the recovery job) stored an outcome first, that one is kept,
+// except that a success replaces a failure: the provider did refund.
+func (s *RefundService) Execute(r *models.Refund) error {
+	if r == nil || r.Status != models.RefundStatusPending {
+		return nil
+	}
+
+	refundID, err := s.process(r)
+	updates := map[string]interface{}{}
+	replaceable := []string{models.RefundStatusPending, models.RefundStatusFailed}
+	if err != nil {
+		log.Printf("Refund %s: %s EUR via %s (%s) failed: %v", r.ID, r.Amount, r.Provider, r.ProviderRef, err)
+		r.Status = models.RefundStatusFailed
+		r.Error = err.Error()
+		updates["status"] = r.Status
+		updates["error"] = r.Error
+		replaceable = []string{models.RefundStatusPending}
+	} else {
+		now := time.Now()
+		r.Status = models.RefundStatusSucceeded
+		r.ProviderRefundID = refundID
+		r.CompletedAt = &now
+		updates["status"] = r.Status
+		updates["provider_refund_id"] = refundID
+		updates["completed_at"] = now
+	}
+	stored := false
+	if uErr := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Refund{}).Where("id = ? AND status IN ?", r.ID, replaceable).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if stored = res.RowsAffected > 0; !stored {
+			return tx.First(r, "id = ?", r.ID).Error
+		}
+		if r.Status != models.RefundStatusSucceeded {
+			return nil
+		}
+		return recordRefundTransaction(tx, r)
+	}); uErr != nil {
+		log.Printf("Refund %s: CRITICAL - failed to store outcome (%s): %v", r.ID, r.Status, uErr)
+		return err
+	}
+	if !stored {
+		log.Printf("Refund %s: outcome already stored by another attempt (%s)", r.ID, r.Status)
+		return nil
+	}
+	s.issueCreditNote(r)
+	return err
+}
+
+// issueCreditNote documents a succeeded refund; a failure is logged and does not affect the refund
+func (s *RefundService) issueCreditNote(r *models.Refund) {
+	if s.invoiceService == nil || r.Status != models.RefundStatusSucceeded {
+		return
+	}
+	if _, err := s.invoiceService.IssueCreditNote(r); err != nil {
+		log.Printf("Refund %s: failed to issue credit note: %v", r.ID, err)
+	}
+}
+
+// process calls the provider; ProcessRefund works on a ticket's payment references, so they are pointed at the stored payment
+func (s *RefundService) process(r *models.Refund) (string, error) {
+	var provider PaymentProvider
+	carrier := &models.Ticket{PaymentProvider: r.Provider}
+	if r.TicketID != nil {
+		carrier.ID = *r.TicketID
+	}
+	switch r.Provider {
+	case "paypal":
+		provider = s.paypalProvider
+		carrier.PayPalCaptureID = r.ProviderRef
+	case "stripe":
+		provider = s.stripeProvider
+		carrier.StripePaymentIntentID = r.ProviderRef
+	}
+	if provider == nil {
+		return "", fmt.Errorf("payment provider %q not available", r.Provider)
+	}
+	return provider.ProcessRefund(carrier, r.Amount, refundIdempotencyKey(r))
+}
+
+// refundIdempotencyKey identifies a refund attempt at the provider, so sending it again never refunds twice
+func refundIdempotencyKey(r *models.Refund) string {
+	return "refund-" + r.ID.String()
+}
+
+const (
+	// staleRefundAfter is how long a pending refund may wait for the execution after its commit
+	// before the recovery job sends it (e.g. after a restart in between)
+	staleRefundAfter = 10 * time.Minute
+	// refundKeyLifetime is how long providers keep idempotency keys (Stripe: 24 hours)
+	refundKeyLifetime = 24 * time.Hour
+)
+
+// ExecuteStale sends pending refunds that were committed but never executed, or whose outcome was never
+// stored. They are sent with the idempotency key of their first attempt, so a refund the provider already
+// carried out is not repeated. Refunds older than the providers keep idempotency keys are marked failed
+// for review instead, as sending them again could refund twice. Returns the number of refunds handled.
+func (s *RefundService) ExecuteStale() (int64, error) {
+	now := time.Now()
+	res := s.db.Model(&models.Refund{}).
+		Where("status = ? AND created_at < ?", models.RefundStatusPending, now.Add(-refundKeyLifetime)).
+		Updates(map[string]interface{}{
+			"status": models.RefundStatusFailed,
+			"error":  "refund outcome unknown; check the payment at the provider before retrying",
+		})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	var refunds []*models.Refund
+	if err := s.db.Where("status = ? AND created_at < ?", models.RefundStatusPending, now.Add(-staleRefundAfter)).
+		Order("created_at ASC").Find(&refunds).Error; err != nil {
+		return res.RowsAffected, err
+	}
+	for _, r := range refunds {
+		log.Printf("Refund %s: still pending after %s, sending it again", r.ID, now.Sub(r.CreatedAt).Round(time.Minute))
+		_ = s.Execute(r)
+	}
+	return res.RowsAffected + int64(len(refunds)), nil
+}
+
+// Retry repeats a failed refund as a new attempt (admin action).
+// Attempts are chained to the first one; a chain with a pending or succeeded attempt cannot be retried.
+func (s *RefundService) Retry(refundID uuid.UUID, actorID *uuid.UUID) (*models.Refund, error) {
+	var retry *models.Refund
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var original models.Refund
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, "id = ?", refundID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("refund not found")
+			}
+			return err
+		}
+		if original.Status != models.RefundStatusFailed {
+			return errors.New("only failed refunds can be retried")
+		}
+		if original.ProviderRef == "" {
+			return errors.New("refund has no payment reference")
+		}
+
+		rootID := original.ID
+		if original.RetryOfID != nil {
+			rootID = *original.RetryOfID
+		}
+		// Lock the first attempt so concurrent retries of the same chain wait for each other
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Refund{}, "id = ?", rootID).Error; err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&models.Refund{}).
+			Where("(id = ? OR retry_of_id = ?) AND status IN ?", rootID, rootID, []string{models.RefundStatusPending, models.RefundStatusSucceeded}).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return errors.New("refund has already been retried")
+		}
+
+		retry = &models.Refund{
+			OrderID:     original.OrderID,
+			TicketID:    original.TicketID,
+			OrderItemID: original.OrderItemID,
+			TransferID:  original.TransferID,
+			Amount:      original.Amount,
+			Provider:    original.Provider,
+			ProviderRef: original.ProviderRef,
+			Status:      models.RefundStatusPending,
+			Reason:      original.Reason,
+			Initiator:   models.RefundInitiatorAdmin,
+			InitiatedBy: actorID,
+			RetryOfID:   &rootID,
+		}
+		return tx.Create(retry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.Execute(retry)
+	return retry, nil
+}
+
+// GetRefunds lists refunds, newest first. Empty filters are ignored.
+func (s *RefundService) GetRefunds(page, limit int, status string, ticketID, orderID *uuid.UUID) ([]*models.Refund, int64, error) {
+	var refunds []*models.Refund
+	var total int64
+
+	query := s.db.Model(&models.Refund{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if ticketID != nil {
+		query = query.Where("ticket_id = ?", *ticketID)
+	}
+	if orderID != nil {
+		query = query.Where("order_id = ?", *orderID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&refunds).Error
+	return refunds, total, err
+}
@@ -0,0 +1,490 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+)
+
+const (
+	// jobLockTTL is how long a job lock survives its instance; it is renewed while the job runs
+	jobLockTTL     = 30 * time.Second
+	jobLockRenewal = 10 * time.Second
+)
+
+// Lock scripts only touch a key still holding our token, so an expired and re-acquired lock is left alone
+var (
+	renewJobLockScript   = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`)
+	releaseJobLockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+)
+
+// Job is a background task run by the scheduler, either every Interval or on a Cron schedule
+type Job struct {
+	Name        string
+	Description string
+	// Interval runs are aligned to the clock (every 30s = :00 and :30), so all instances agree on the slots
+	Interval time.Duration
+	// Cron is a five-field expression evaluated in Europe/Berlin, e.g. "0 3 * * *"
+	Cron string
+	// Local jobs work on the instance's own files and run on every instance; all others run on one instance per slot
+	Local bool
+	// Timeout cancels the job's context after this long (0 = no timeout)
+	Timeout time.Duration
+	// Run does the work and returns a short summary ("" if there was nothing to do)
+	Run func(ctx context.Context) (result string, err error)
+}
+
+type scheduledJob struct {
+	Job
+	cron *cronSchedule
+
+	mu      sync.Mutex
+	running bool // on this instance
+}
+
+// next returns the first slot after now
+func (j *scheduledJob) next(now time.Time) time.Time {
+	if j.cron != nil {
+		return j.cron.Next(now)
+	}
+	return now.Truncate(j.Interval).Add(j.Interval)
+}
+
+// SchedulerService runs the registered background jobs. Every run of a shared job claims its slot and
+// a lock in Redis first, so with several API instances each slot runs once and runs never overlap.
+// Every run updates the job's JobStatus; runs that did something or failed are also stored as a JobRun.
+type SchedulerService struct {
+	db       *gorm.DB
+	redis    *redis.Client
+	cfg      *config.Config
+	instance string
+
+	jobs   []*scheduledJob
+	byName map[string]*scheduledJob
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewSchedulerService(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *SchedulerService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SchedulerService{
+		db:       db,
+		redis:    redisClient,
+		cfg:      cfg,
+		instance: cfg.SchedulerInstanceID,
+		byName:   map[string]*scheduledJob{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Register adds a job; it must be called before Start
+func (s *SchedulerService) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("job needs a name and a run function")
+	}
+	if _, exists := s.byName[job.Name]; exists {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	sj := &scheduledJob{Job: job}
+	switch {
+	case job.Cron != "" && job.Interval > 0:
+		return fmt.Errorf("job %s: set either an interval or a cron expression", job.Name)
+	case job.Cron != "":
+		schedule, err := parseCron(job.Cron, berlinLocation())
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		sj.cron = schedule
+	case job.Interval <= 0:
+		return fmt.Errorf("job %s: interval or cron expression required", job.Name)
+	}
+	s.jobs = append(s.jobs, sj)
+	s.byName[job.Name] = sj
+	return nil
+}
+
+// MustRegister registers a job and panics on an invalid definition (for the static job list at startup)
+func (s *SchedulerService) MustRegister(job Job) {
+	if err := s.Register(job); err != nil {
+		panic(err)
+	}
+}
+
+// Start schedules all registered jobs. With SCHEDULER_ENABLED=false nothing is scheduled on this
+// instance, but jobs can still be triggered manually.
+func (s *SchedulerService) Start() {
+	if !s.cfg.SchedulerEnabled {
+		log.Printf("Scheduler disabled on this instance (%d jobs registered)", len(s.jobs))
+		return
+	}
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+	log.Printf("Scheduler started on %s with %d jobs", s.instance, len(s.jobs))
+}
+
+// Stop stops scheduling, cancels the context of running jobs and waits for them until ctx is done
+func (s *SchedulerService) Stop(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("timed out waiting for running jobs")
+	}
+}
+
+func (s *SchedulerService) loop(job *scheduledJob) {
+	defer s.wg.Done()
+	for {
+		slot := job.next(time.Now())
+		if slot.IsZero() {
+			log.Printf("Scheduler: job %s has no future run", job.Name)
+			return
+		}
+		timer := time.NewTimer(time.Until(slot))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runSlot(job, slot)
+	}
+}
+
+// runSlot runs a scheduled slot unless another instance claimed it or the previous run is still going
+func (s *SchedulerService) runSlot(job *scheduledJob, slot time.Time) {
+	if !job.Local {
+		slotTTL := job.Interval + time.Minute
+		if job.cron != nil {
+			slotTTL = time.Hour
+		}
+		slotKey := fmt.Sprintf("scheduler:slot:%s:%d", job.Name, slot.Unix())
+		claimed, err := s.redis.SetNX(s.ctx, slotKey, s.instance, slotTTL).Result()
+		if err != nil {
+			log.Printf("Scheduler: job %s skipped, cannot claim slot: %v", job.Name, err)
+			return
+		}
+		if !claimed {
+			return
+		}
+	}
+
+	run, release, err := s.acquire(job, models.JobTriggerSchedule, nil)
+	if err != nil {
+		if !errors.Is(err, ErrJobRunning) {
+			log.Printf("Scheduler: job %s skipped: %v", job.Name, err)
+		}
+		return
+	}
+	s.execute(job, run, release)
+}
+
+// acquire marks the job as running (locally, and in Redis for shared jobs) and prepares its run record.
+// The returned release function must be called when the run is over.
+func (s *SchedulerService) acquire(job *scheduledJob, trigger string, triggeredBy *uuid.UUID) (*models.JobRun, func(), error) {
+	job.mu.Lock()
+	if job.running {
+		job.mu.Unlock()
+		return nil, nil, ErrJobRunning
+	}
+	job.running = true
+	job.mu.Unlock()
+	releaseLocal := func() {
+		job.mu.Lock()
+		job.running = false
+		job.mu.Unlock()
+	}
+
+	run := &models.JobRun{
+		ID:          uuid.New(),
+		JobName:     job.Name,
+		Instance:    s.instance,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Status:      models.JobRunRunning,
+	}
+	if job.Local {
+		return run, releaseLocal, nil
+	}
+
+	key := "scheduler:lock:" + job.Name
+	token := s.instance + ":" + run.ID.String()
+	locked, err := s.redis.SetNX(s.ctx, key, token, jobLockTTL).Result()
+	if err != nil || !locked {
+		releaseLocal()
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot acquire lock: %w", err)
+		}
+		return nil, nil, ErrJobRunning
+	}
+
+	stopRenewal := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobLockRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopRenewal:
+				return
+			case <-ticker.C:
+				if err := renewJobLockScript.Run(context.Background(), s.redis, []string{key}, token, jobLockTTL.Milliseconds()).Err(); err != nil {
+					log.Printf("Scheduler: failed to renew lock of job %s: %v", job.Name, err)
+				}
+			}
+		}
+	}()
+
+	return run, func() {
+		close(stopRenewal)
+		if err := releaseJobLockScript.Run(context.Background(), s.redis, []string{key}, token).Err(); err != nil {
+			log.Printf("Scheduler: failed to release lock of job %s: %v", job.Name, err)
+		}
+		releaseLocal()
+	}, nil
+}
+
+// execute runs the job, stores the run record and releases the job
+func (s *SchedulerService) execute(job *scheduledJob, run *models.JobRun, release func()) {
+	defer release()
+
+	ctx := s.ctx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	run.StartedAt = time.Now()
+	result, err := func() (result string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+				log.Printf("Scheduler: job %s panicked: %v\n%s", job.Name, r, debug.Stack())
+			}
+		}()
+		return job.Run(ctx)
+	}()
+	finished := time.Now()
+
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Result = result
+	run.Status = models.JobRunSucceeded
+	if err != nil {
+		run.Status = models.JobRunFailed
+		run.Error = err.Error()
+		log.Printf("Scheduler: job %s failed after %dms: %v", job.Name, run.DurationMs, err)
+	} else if result != "" {
+		log.Printf("Scheduler: job %s: %s", job.Name, result)
+	}
+
+	if err := s.recordStatus(run); err != nil {
+		log.Printf("Scheduler: failed to update status of job %s: %v", job.Name, err)
+	}
+	// Most scheduled runs find nothing to do; they only count towards the job status
+	if run.Status == models.JobRunSucceeded && result == "" && run.Trigger == models.JobTriggerSchedule {
+		return
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		log.Printf("Scheduler: failed to record run of job %s: %v", job.Name, err)
+	}
+}
+
+// recordStatus stores a finished run as the latest run of its job and counts it
+func (s *SchedulerService) recordStatus(run *models.JobRun) error {
+	failed := 0
+	if run.Status == models.JobRunFailed {
+		failed = 1
+	}
+	status := models.JobStatus{
+		JobName:        run.JobName,
+		LastRunAt:      run.StartedAt,
+		LastStatus:     run.Status,
+		LastDurationMs: run.DurationMs,
+		LastInstance:   run.Instance,
+		Runs:           1,
+		Failures:       int64(failed),
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "job_name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_run_at":      status.LastRunAt,
+			"last_status":      status.LastStatus,
+			"last_duration_ms": status.LastDurationMs,
+			"last_instance":    status.LastInstance,
+			"runs":             gorm.Expr("job_statuses.runs + 1"),
+			"failures":         gorm.Expr("job_statuses.failures + ?", failed),
+		}),
+	}).Create(&status).Error
+}
+
+// Trigger starts a job now, outside its schedule, and returns the run that will be recorded when it is done.
+// Manual runs are always recorded, even with nothing to do.
+func (s *SchedulerService) Trigger(name string, triggeredBy *uuid.UUID) (*models.JobRun, error) {
+	job, ok := s.byName[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	if s.ctx.Err() != nil {
+		return nil, errors.New("scheduler is shutting down")
+	}
+
+	run, release, err := s.acquire(job, models.JobTriggerManual, triggeredBy)
+	if err != nil {
+		return nil, err
+	}
+	run.StartedAt = time.Now()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(job, run, release)
+	}()
+	return run, nil
+}
+
+// JobInfo describes a registered job and its state for the admin overview
+type JobInfo struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Interval    string            `json:"interval,omitempty"`
+	Cron        string            `json:"cron,omitempty"`
+	Local       bool              `json:"local"`
+	NextRun     *time.Time        `json:"next_run,omitempty"`
+	Running     bool              `json:"running"`
+	RunningOn   string            `json:"running_on,omitempty"`
+	LastRun     *models.JobRun    `json:"last_run,omitempty"` // latest recorded run
+	Stats       *models.JobStatus `json:"stats,omitempty"`
+}
+
+// ListJobs returns all registered jobs with their next and last run
+func (s *SchedulerService) ListJobs() ([]JobInfo, error) {
+	var lastRuns []models.JobRun
+	if err := s.db.Raw(`SELECT DISTINCT ON (job_name) * FROM job_runs ORDER BY job_name, started_at DESC`).Scan(&lastRuns).Error; err != nil {
+		return nil, err
+	}
+	lastByName := map[string]*models.JobRun{}
+	for i := range lastRuns {
+		lastByName[lastRuns[i].JobName] = &lastRuns[i]
+	}
+	var statuses []models.JobStatus
+	if err := s.db.Find(&statuses).Error; err != nil {
+		return nil, err
+	}
+	statusByName := map[string]*models.JobStatus{}
+	for i := range statuses {
+		statusByName[statuses[i].JobName] = &statuses[i]
+	}
+
+	now := time.Now()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		info := JobInfo{
+			Name:        job.Name,
+			Description: job.Description,
+			Cron:        job.Cron,
+			Local:       job.Local,
+			LastRun:     lastByName[job.Name],
+			Stats:       statusByName[job.Name],
+		}
+		if job.Interval > 0 {
+			info.Interval = job.Interval.String()
+		}
+		if s.cfg.SchedulerEnabled {
+			if next := job.next(now); !next.IsZero() {
+				info.NextRun = &next
+			}
+		}
+
+		job.mu.Lock()
+		if job.running {
+			info.Running, info.RunningOn = true, s.instance
+		}
+		job.mu.Unlock()
+		if !info.Running && !job.Local {
+			if holder, err := s.redis.Get(s.ctx, "scheduler:lock:"+job.Name).Result(); err == nil {
+				info.Running, info.RunningOn = true, lockHolder(holder)
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// lockHolder returns the instance part of a lock token (instance:runID)
+func lockHolder(token string) string {
+	if len(token) > 37 && token[len(token)-37] == ':' {
+		return token[:len(token)-37]
+	}
+	return token
+}
+
+// GetRuns lists the recorded runs of a job, newest first; status optionally filters (succeeded|failed)
+func (s *SchedulerService) GetRuns(name, status string, page, limit int) ([]*models.JobRun, int64, error) {
+	if _, ok := s.byName[name]; !ok {
+		return nil, 0, ErrJobNotFound
+	}
+
+	var runs []*models.JobRun
+	var total int64
+
+	query := s.db.Model(&models.JobRun{}).Where("job_name = ?", name)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	err := query.Order("started_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&runs).Error
+	return runs, total, err
+}
+
+// CleanupRuns deletes job runs older than SCHEDULER_RUN_RETENTION_DAYS
+func (s *SchedulerService) CleanupRuns(ctx context.Context) (string, error) {
+	days := s.cfg.SchedulerRunRetentionDays
+	if days < 1 {
+		days = 7
+	}
+	res := s.db.WithContext(ctx).Where("started_at < ?", time.Now().AddDate(0, 0, -days)).Delete(&models.JobRun{})
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("deleted %d job runs", res.RowsAffected), nil
+}
@@ -1,11 +1,18 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/stripe/stripe-go/v82"
 	"github.com/stripe/stripe-go/v82/checkout/session"
+	"github.com/stripe/stripe-go/v82/paymentintent"
 	"github.com/stripe/stripe-go/v82/refund"
+	"github.com/stripe/stripe-go/v82/taxrate"
 	"github.com/synesthesie/backend/internal/config"
 	"github.com/synesthesie/backend/internal/models"
 	"gorm.io/gorm"
@@ -15,52 +22,56 @@ import (
 type StripeProvider struct {
 	cfg *config.Config
 	db  *gorm.DB
+
+	taxRatesMu sync.Mutex
+	taxRates   map[int]string // VAT percent -> Stripe tax rate ID
 }
 
 // NewStripeProvider creates a new Stripe payment provider
 func NewStripeProvider(cfg *config.Config, db *gorm.DB) *StripeProvider {
 	stripe.Key = cfg.StripeSecretKey
 	return &StripeProvider{
-		cfg: cfg,
-		db:  db,
+		cfg:      cfg,
+		db:       db,
+		taxRates: map[int]string{},
 	}
 }
 
+// stripeCurrency is the shop currency as Stripe writes it
+var stripeCurrency = strings.ToLower(models.Currency)
+
 // GetProviderName returns "stripe"
 func (p *StripeProvider) GetProviderName() string {
 	return "stripe"
 }
 
-// CreateCheckout creates a Stripe checkout session
-func (p *StripeProvider) CreateCheckout(ticket *models.Ticket, event *models.Event, user *models.User, totalAmount float64) (string, error) {
-	// Build line items
-	lineItems := []*stripe.CheckoutSessionLineItemParams{
-		{
-			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-				Currency: stripe.String("eur"),
-				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-					Name:        stripe.String(event.Name),
-					Description: stripe.String(fmt.Sprintf("Ticket für %s", event.Name)),
-				},
-				UnitAmount: stripe.Int64(int64(ticket.Price * 100)),
-			},
-			Quantity: stripe.Int64(1),
-		},
-	}
+// CreateCheckout creates a Stripe checkout session with one line item per order item
+func (p *StripeProvider) CreateCheckout(order *models.Order, event *models.Event, user *models.User) (string, error) {
+	ticket := &order.Tickets[0]
 
-	// Add pickup service if included
-	if ticket.IncludesPickup {
-		lineItems = append(lineItems, &stripe.CheckoutSessionLineItemParams{
+	// Build line items
+	var lineItems []*stripe.CheckoutSessionLineItemParams
+	for _, item := range orderCheckoutItems(order) {
+		unitAmount, quantity := orderItemCents(item)
+		lineItem := &stripe.CheckoutSessionLineItemParams{
 			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-				Currency: stripe.String("eur"),
+				Currency: stripe.String(stripeCurrency),
 				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-					Name:        stripe.String("Abholservice"),
-					Description: stripe.String(fmt.Sprintf("Abholung von: %s", ticket.PickupAddress)),
+					Name:        stripe.String(item.Name),
+					Description: stripe.String(item.Description),
 				},
-				UnitAmount: stripe.Int64(int64(ticket.PickupPrice * 100)),
+				UnitAmount: stripe.Int64(unitAmount),
 			},
-			Quantity: stripe.Int64(1),
-		})
+			Quantity: stripe.Int64(quantity),
+		}
+		if item.TaxRate > 0 {
+			taxRateID, err := p.taxRateID(item.TaxRate)
+			if err != nil {
+				return "", err
+			}
+			lineItem.TaxRates = stripe.StringSlice([]string{taxRateID})
+		}
+		lineItems = append(lineItems, lineItem)
 	}
 
 	// Build URLs with ticket_id
@@ -77,11 +88,14 @@ func (p *StripeProvider) CreateCheckout(ticket *models.Ticket, event *models.Eve
 		CustomerEmail:      stripe.String(user.Email),
 		Metadata: map[string]string{
 			"ticket_id": ticket.ID.String(),
+			"order_id":  order.ID.String(),
 			"user_id":   user.ID.String(),
 			"event_id":  event.ID.String(),
 		},
 	}
 
+	params.ExpiresAt = stripeSessionExpiry(ticket.HoldExpiresAt, time.Now())
+
 	// Enable automatic payment methods if configured
 	if p.cfg.StripeAutomaticPaymentMethods {
 		params.PaymentMethodTypes = nil
@@ -95,32 +109,236 @@ func (p *StripeProvider) CreateCheckout(ticket *models.Ticket, event *models.Eve
 		return "", fmt.Errorf("failed to create Stripe session: %w", err)
 	}
 
-	// Save Stripe session ID to ticket
-	ticket.StripeSessionID = sess.ID
-	ticket.PaymentProvider = "stripe"
-	if err := p.db.Save(ticket).Error; err != nil {
+	// Save Stripe session ID to the tickets of the order
+	if err := p.db.Model(&models.Ticket{}).Scopes(models.SameCheckout(ticket.ID)).Updates(map[string]interface{}{
+		"stripe_session_id": sess.ID,
+		"payment_provider":  "stripe",
+	}).Error; err != nil {
 		return "", fmt.Errorf("failed to save ticket: %w", err)
 	}
+	for i := range order.Tickets {
+		order.Tickets[i].StripeSessionID = sess.ID
+		order.Tickets[i].PaymentProvider = "stripe"
+	}
 
 	return sess.URL, nil
 }
 
-// ProcessRefund processes a Stripe refund
-func (p *StripeProvider) ProcessRefund(ticket *models.Ticket, amount float64) error {
+// stripeSessionExpiry returns when the checkout session of a hold ending at holdExpiresAt expires.
+// Stripe accepts 30 minutes to 24 hours from now, so a shorter remaining hold ends before the session;
+// a payment after the hold is checked against the event's capacity (confirmCheckout).
+func stripeSessionExpiry(holdExpiresAt *time.Time, now time.Time) *int64 {
+	if holdExpiresAt == nil {
+		return nil
+	}
+	expiresAt := *holdExpiresAt
+	if earliest := now.Add(31 * time.Minute); expiresAt.Before(earliest) {
+		expiresAt = earliest
+	}
+	if latest := now.Add(24 * time.Hour); expiresAt.After(latest) {
+		expiresAt = latest
+	}
+	return stripe.Int64(expiresAt.Unix())
+}
+
+// taxRateID returns the Stripe tax rate for a VAT percent. Prices are gross, so the rate is inclusive:
+// Stripe shows the VAT contained in the line without changing the amount. Rates are looked up by
+// their metadata and created on first use.
+func (p *StripeProvider) taxRateID(rate int) (string, error) {
+	p.taxRatesMu.Lock()
+	defer p.taxRatesMu.Unlock()
+	if id, ok := p.taxRates[rate]; ok {
+		return id, nil
+	}
+
+	key := strconv.Itoa(rate)
+	iter := taxrate.List(&stripe.TaxRateListParams{
+		Active:    stripe.Bool(true),
+		Inclusive: stripe.Bool(true),
+	})
+	for iter.Next() {
+		if tr := iter.TaxRate(); tr.Metadata["vat_rate"] == key {
+			p.taxRates[rate] = tr.ID
+			return tr.ID, nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return "", fmt.Errorf("failed to list Stripe tax rates: %w", err)
+	}
+
+	tr, err := taxrate.New(&stripe.TaxRateParams{
+		DisplayName: stripe.String("USt."),
+		Description: stripe.String(fmt.Sprintf("Umsatzsteuer %d %%", rate)),
+		Percentage:  stripe.Float64(float64(rate)),
+		Inclusive:   stripe.Bool(true),
+		Country:     stripe.String("DE"),
+		TaxType:     stripe.String(string(stripe.TaxRateTaxTypeVAT)),
+		Metadata:    map[string]string{"vat_rate": key},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create Stripe tax rate: %w", err)
+	}
+	p.taxRates[rate] = tr.ID
+	return tr.ID, nil
+}
+
+// CreateChargeCheckout creates a Stripe checkout session for an extra amount.
+// The session carries charge_ref instead of ticket_id so the webhook does not confirm the ticket itself.
+func (p *StripeProvider) CreateChargeCheckout(ticket *models.Ticket, user *models.User, amount models.Money, description, reference string) (string, string, error) {
+	successURL := fmt.Sprintf("%s?charge_ref=%s&session_id={CHECKOUT_SESSION_ID}", p.cfg.StripeSuccessURL, reference)
+	cancelURL := fmt.Sprintf("%s?charge_ref=%s", p.cfg.StripeCancelURL, reference)
+
+	params := &stripe.CheckoutSessionParams{
+		PaymentMethodTypes: stripe.StringSlice(p.cfg.StripePaymentMethods),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency: stripe.String(stripeCurrency),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(description),
+					},
+					UnitAmount: stripe.Int64(amount.Cents()),
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		Mode:          stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL:    stripe.String(successURL),
+		CancelURL:     stripe.String(cancelURL),
+		CustomerEmail: stripe.String(user.Email),
+		Metadata: map[string]string{
+			"charge_ref":        reference,
+			"related_ticket_id": ticket.ID.String(),
+			"user_id":           user.ID.String(),
+		},
+	}
+
+	if p.cfg.StripeAutomaticPaymentMethods {
+		params.PaymentMethodTypes = nil
+		params.PaymentMethodOptions = &stripe.CheckoutSessionPaymentMethodOptionsParams{
+			Card: &stripe.CheckoutSessionPaymentMethodOptionsCardParams{},
+		}
+	}
+
+	sess, err := session.New(params)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create Stripe session: %w", err)
+	}
+
+	return sess.URL, sess.ID, nil
+}
+
+// CaptureCharge checks whether a charge session was paid (Stripe captures automatically)
+func (p *StripeProvider) CaptureCharge(providerRef string) (string, bool, error) {
+	sess, err := session.Get(providerRef, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get Stripe session: %w", err)
+	}
+	if sess.PaymentStatus != "paid" {
+		return "", false, nil
+	}
+
+	paymentIntentID := ""
+	if sess.PaymentIntent != nil {
+		paymentIntentID = sess.PaymentIntent.ID
+	}
+	return paymentIntentID, true, nil
+}
+
+// ProcessRefund processes a Stripe refund. Stripe keeps idempotency keys for 24 hours.
+func (p *StripeProvider) ProcessRefund(ticket *models.Ticket, amount models.Money, idempotencyKey string) (string, error) {
 	if ticket.StripePaymentIntentID == "" {
-		return fmt.Errorf("no Stripe payment intent ID found")
+		return "", fmt.Errorf("no Stripe payment intent ID found")
 	}
 
-	_, err := refund.New(&stripe.RefundParams{
+	params := &stripe.RefundParams{
 		PaymentIntent: stripe.String(ticket.StripePaymentIntentID),
-		Amount:        stripe.Int64(int64(amount * 100)),
-	})
+		Amount:        stripe.Int64(amount.Cents()),
+	}
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+	r, err := refund.New(params)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to process Stripe refund: %w", err)
+	}
+
+	return r.ID, nil
+}
+
+// ChargeRefunds lists the refunds of a charge
+func (p *StripeProvider) ChargeRefunds(chargeID string) ([]ProviderRefund, error) {
+	var refunds []ProviderRefund
+	iter := refund.List(&stripe.RefundListParams{Charge: stripe.String(chargeID)})
+	for iter.Next() {
+		r := iter.Refund()
+		refunds = append(refunds, ProviderRefund{
+			ID:     r.ID,
+			Amount: models.Money(r.Amount),
+			Status: string(r.Status),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list Stripe refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// GetPaymentStatus looks up the payment of the ticket's checkout session
+func (p *StripeProvider) GetPaymentStatus(ticket *models.Ticket) (*ProviderPayment, error) {
+	paymentIntentID := ticket.StripePaymentIntentID
+	if paymentIntentID == "" {
+		if ticket.StripeSessionID == "" {
+			return nil, errors.New("ticket has no Stripe checkout")
+		}
+		sess, err := session.Get(ticket.StripeSessionID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Stripe session: %w", err)
+		}
+		if sess.PaymentStatus != "paid" || sess.PaymentIntent == nil {
+			return &ProviderPayment{Status: ProviderPaymentUnpaid}, nil
+		}
+		paymentIntentID = sess.PaymentIntent.ID
+	}
 
+	params := &stripe.PaymentIntentParams{}
+	params.AddExpand("latest_charge")
+	pi, err := paymentintent.Get(paymentIntentID, params)
 	if err != nil {
-		return fmt.Errorf("failed to process Stripe refund: %w", err)
+		return nil, fmt.Errorf("failed to get Stripe payment intent: %w", err)
 	}
+	payment := &ProviderPayment{Status: ProviderPaymentUnpaid, PaymentRef: pi.ID}
+	if pi.Status != stripe.PaymentIntentStatusSucceeded {
+		return payment, nil
+	}
+	if string(pi.Currency) != stripeCurrency {
+		return nil, fmt.Errorf("payment %s is in %s, expected %s", pi.ID, pi.Currency, stripeCurrency)
+	}
+	payment.Status = ProviderPaymentPaid
+	payment.Amount = models.Money(pi.AmountReceived)
+	if charge := pi.LatestCharge; charge != nil {
+		payment.RefundedAmount = models.Money(charge.AmountRefunded)
+		payment.Disputed = charge.Disputed
+		if charge.Refunded {
+			payment.Status = ProviderPaymentRefunded
+		}
+	}
+	return payment, nil
+}
 
-	return nil
+// PaymentFee returns the Stripe fee of a payment intent from its balance transaction
+func (p *StripeProvider) PaymentFee(paymentIntentID string) (models.Money, error) {
+	params := &stripe.PaymentIntentParams{}
+	params.AddExpand("latest_charge.balance_transaction")
+	pi, err := paymentintent.Get(paymentIntentID, params)
+	if err != nil {
+		return 0, err
+	}
+	if pi.LatestCharge == nil || pi.LatestCharge.BalanceTransaction == nil {
+		return 0, errors.New("balance transaction not available yet")
+	}
+	return models.Money(pi.LatestCharge.BalanceTransaction.Fee), nil
 }
 
 // CheckAndCaptureOrder checks if a Stripe payment was completed (for active polling)
@@ -146,16 +364,20 @@ func (p *StripeProvider) CheckAndCaptureOrder(ticket *models.Ticket) bool {
 
 		// Update ticket to paid
 		updates := map[string]interface{}{
-			"status":                   "paid",
 			"cancelled_at":             nil,
 			"stripe_payment_intent_id": paymentIntentID,
 		}
 
-		if err := p.db.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Updates(updates).Error; err != nil {
+		_, refund, err := confirmCheckout(p.db, ticket.ID, "stripe",
+			models.StatusChange{Actor: models.StatusActorProvider, Reason: "stripe_poll", ProviderRef: paymentIntentID},
+			updates, models.TicketPending, models.TicketPendingCancellation)
+		if err != nil {
 			return false
 		}
+		logLedgerError("Stripe payment", recordOrderPayment(p.db, ticket.ID, "stripe", paymentIntentID))
 
-		return true
+		// The refund of a checkout whose seats were taken meanwhile is sent by the refund recovery job
+		return refund == nil
 	}
 
 	return false
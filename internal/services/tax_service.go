@@ -0,0 +1,326 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultTaxRates returns the VAT rate of each product type for events without their own rates:
+// TAX_RATES, INVOICE_VAT_RATE for product types not listed there, and 0 for small businesses (§ 19 UStG)
+func defaultTaxRates(cfg *config.Config) models.TaxRates {
+	rates := models.TaxRates{}
+	if cfg == nil {
+		for _, kind := range models.TaxProductTypes {
+			rates[kind] = 0
+		}
+		return rates
+	}
+
+	var configured models.TaxRates
+	if cfg.TaxRates != "" {
+		parsed, err := models.ParseTaxRates(cfg.TaxRates)
+		if err != nil {
+			log.Printf("Invalid TAX_RATES, falling back to INVOICE_VAT_RATE: %v", err)
+		}
+		configured = parsed
+	}
+	for _, kind := range models.TaxProductTypes {
+		rate, ok := configured[kind]
+		if !ok {
+			rate = cfg.InvoiceVATRate
+		}
+		if cfg.InvoiceSmallBusiness {
+			rate = 0
+		}
+		rates[kind] = rate
+	}
+	return rates
+}
+
+// eventTaxRates returns the VAT rate of each product type for an event: its own rates over the default rates
+func eventTaxRates(cfg *config.Config, event *models.Event) models.TaxRates {
+	rates := defaultTaxRates(cfg)
+	if cfg != nil && cfg.InvoiceSmallBusiness {
+		return rates
+	}
+	for kind, rate := range event.TaxRates {
+		rates[kind] = rate
+	}
+	return rates
+}
+
+// TaxService provides the VAT rates and the monthly VAT report.
+// Every order item stores its VAT rate and the net and VAT parts of its amount when it is booked,
+// so later rate changes do not alter what was sold.
+type TaxService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewTaxService(db *gorm.DB, cfg *config.Config) *TaxService {
+	return &TaxService{db: db, cfg: cfg}
+}
+
+// DefaultRates returns the VAT rates for events without their own
+func (s *TaxService) DefaultRates() models.TaxRates {
+	return defaultTaxRates(s.cfg)
+}
+
+// BackfillOrderItems records the VAT of order items booked before rates were stored on them.
+// Until then every line was invoiced at INVOICE_VAT_RATE, so that rate is used.
+func (s *TaxService) BackfillOrderItems() error {
+	rate := s.cfg.InvoiceVATRate
+	if s.cfg.InvoiceSmallBusiness {
+		rate = 0
+	}
+	result := s.db.Exec(`
+		UPDATE order_items
+		SET tax_rate = ?,
+			net_amount = round(amount * 100.0 / (100 + ?))::bigint,
+			tax_amount = amount - round(amount * 100.0 / (100 + ?))::bigint
+		WHERE net_amount = 0 AND tax_amount = 0 AND amount <> 0`, rate, rate, rate)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Tax: recorded %d %% VAT on %d order items", rate, result.RowsAffected)
+	}
+	return nil
+}
+
+// VATAmounts sums sales and refunds; refunds are negative
+type VATAmounts struct {
+	SalesGross   models.Money `json:"sales_gross"`
+	SalesNet     models.Money `json:"sales_net"`
+	SalesTax     models.Money `json:"sales_tax"`
+	RefundsGross models.Money `json:"refunds_gross"`
+	RefundsNet   models.Money `json:"refunds_net"`
+	RefundsTax   models.Money `json:"refunds_tax"`
+	Gross        models.Money `json:"gross"`
+	Net          models.Money `json:"net"`
+	Tax          models.Money `json:"tax"`
+}
+
+func (a *VATAmounts) addSales(gross, net, tax models.Money) {
+	a.SalesGross += gross
+	a.SalesNet += net
+	a.SalesTax += tax
+	a.Gross += gross
+	a.Net += net
+	a.Tax += tax
+}
+
+func (a *VATAmounts) addRefunds(gross, net, tax models.Money) {
+	a.RefundsGross += gross
+	a.RefundsNet += net
+	a.RefundsTax += tax
+	a.Gross += gross
+	a.Net += net
+	a.Tax += tax
+}
+
+// VATRateSummary sums the sales and refunds of one VAT rate
+type VATRateSummary struct {
+	Rate int `json:"rate"`
+	VATAmounts
+}
+
+// VATReport is the VAT summary of one month (Europe/Berlin)
+type VATReport struct {
+	Month string           `json:"month"` // YYYY-MM
+	Rates []VATRateSummary `json:"rates"` // highest rate first
+	Total VATAmounts       `json:"total"`
+}
+
+// MonthlyReport sums the VAT of the month containing the given time.
+// Sales are the order items of the orders paid in the month (a payment the provider denied later
+// is deducted in the month it was denied); refunds are the refunds completed in the month, split over
+// the VAT rates of the items they refund. Transfer charges are not invoiced and not included.
+func (s *TaxService) MonthlyReport(month time.Time) (*VATReport, error) {
+	loc := berlinLocation()
+	month = month.In(loc)
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 1, 0)
+
+	byRate := map[int]*VATRateSummary{}
+	summary := func(rate int) *VATRateSummary {
+		if _, ok := byRate[rate]; !ok {
+			byRate[rate] = &VATRateSummary{Rate: rate}
+		}
+		return byRate[rate]
+	}
+
+	// A denied payment is booked as an adjustment reversing the payment of the order
+	sign := fmt.Sprintf("CASE WHEN payment_transactions.type = '%s' THEN -1 ELSE 1 END", models.TransactionAdjustment)
+	var sales []struct {
+		Rate  int
+		Gross models.Money
+		Net   models.Money
+		Tax   models.Money
+	}
+	err := s.db.Table("payment_transactions").
+		Joins("JOIN order_items ON order_items.order_id = payment_transactions.order_id").
+		Where("payment_transactions.created_at >= ? AND payment_transactions.created_at < ?", from, to).
+		Where("payment_transactions.transfer_id IS NULL").
+		Where("(payment_transactions.type IN ? OR payment_transactions.idempotency_key LIKE ?)",
+			[]string{models.TransactionPayment, models.TransactionCapture}, *ledgerKey("payment", "order", "%", "denied")).
+		Select(fmt.Sprintf(`order_items.tax_rate AS rate,
+			COALESCE(SUM(%[1]s * order_items.amount), 0)::bigint AS gross,
+			COALESCE(SUM(%[1]s * order_items.net_amount), 0)::bigint AS net,
+			COALESCE(SUM(%[1]s * order_items.tax_amount), 0)::bigint AS tax`, sign)).
+		Group("order_items.tax_rate").
+		Scan(&sales).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum sales: %w", err)
+	}
+	for _, row := range sales {
+		summary(row.Rate).addSales(row.Gross, row.Net, row.Tax)
+	}
+
+	refunded, err := s.refundsByRate(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for rate, amount := range refunded {
+		net, tax := models.SplitGross(-amount, rate)
+		summary(rate).addRefunds(-amount, net, tax)
+	}
+
+	report := &VATReport{Month: from.Format("2006-01"), Rates: []VATRateSummary{}}
+	for _, sum := range byRate {
+		report.Rates = append(report.Rates, *sum)
+		report.Total.addSales(sum.SalesGross, sum.SalesNet, sum.SalesTax)
+		report.Total.addRefunds(sum.RefundsGross, sum.RefundsNet, sum.RefundsTax)
+	}
+	sort.Slice(report.Rates, func(i, j int) bool { return report.Rates[i].Rate > report.Rates[j].Rate })
+	return report, nil
+}
+
+// refundsByRate sums the refunds completed in [from, to) per VAT rate. A refund of an order item
+// uses the rate of the item; a refund of a ticket or an order is shared over the rates of its items.
+func (s *TaxService) refundsByRate(from, to time.Time) (map[int]models.Money, error) {
+	var refunds []models.Refund
+	if err := s.db.Where("status = ? AND completed_at >= ? AND completed_at < ? AND reason <> ?",
+		models.RefundStatusSucceeded, from, to, models.RefundReasonTransferCharge).
+		Find(&refunds).Error; err != nil {
+		return nil, fmt.Errorf("failed to load refunds: %w", err)
+	}
+
+	orderIDs := map[uuid.UUID]bool{}
+	var ticketIDs []uuid.UUID
+	for _, r := range refunds {
+		if r.OrderID != nil {
+			orderIDs[*r.OrderID] = true
+		} else if r.TicketID != nil {
+			ticketIDs = append(ticketIDs, *r.TicketID)
+		}
+	}
+	ticketOrders := map[uuid.UUID]uuid.UUID{}
+	if len(ticketIDs) > 0 {
+		var tickets []models.Ticket
+		if err := s.db.Select("id", "order_id").Where("id IN ? AND order_id IS NOT NULL", ticketIDs).
+			Find(&tickets).Error; err != nil {
+			return nil, fmt.Errorf("failed to load refunded tickets: %w", err)
+		}
+		for _, t := range tickets {
+			ticketOrders[t.ID] = *t.OrderID
+			orderIDs[*t.OrderID] = true
+		}
+	}
+
+	itemsByOrder := map[uuid.UUID][]*models.OrderItem{}
+	if len(orderIDs) > 0 {
+		ids := make([]uuid.UUID, 0, len(orderIDs))
+		for id := range orderIDs {
+			ids = append(ids, id)
+		}
+		var items []models.OrderItem
+		if err := s.db.Where("order_id IN ? AND amount > 0", ids).Find(&items).Error; err != nil {
+			return nil, fmt.Errorf("failed to load refunded items: %w", err)
+		}
+		for i := range items {
+			itemsByOrder[items[i].OrderID] = append(itemsByOrder[items[i].OrderID], &items[i])
+		}
+	}
+
+	fallbackRate := defaultTaxRates(s.cfg)[models.OrderItemTicket]
+	byRate := map[int]models.Money{}
+	for i := range refunds {
+		r := &refunds[i]
+		orderID := r.OrderID
+		if orderID == nil && r.TicketID != nil {
+			if id, ok := ticketOrders[*r.TicketID]; ok {
+				orderID = &id
+			}
+		}
+		var items []*models.OrderItem
+		if orderID != nil {
+			items = refundedItems(r, itemsByOrder[*orderID])
+		}
+		if len(items) == 0 {
+			// Payments without order items (booked before orders existed)
+			byRate[fallbackRate] += r.Amount
+			continue
+		}
+		for rate, amount := range splitByRate(r.Amount, items) {
+			byRate[rate] += amount
+		}
+	}
+	return byRate, nil
+}
+
+// refundedItems returns the items of an order a refund applies to
+func refundedItems(r *models.Refund, items []*models.OrderItem) []*models.OrderItem {
+	var out []*models.OrderItem
+	for _, item := range items {
+		switch {
+		case r.OrderItemID != nil:
+			if item.ID == *r.OrderItemID {
+				return []*models.OrderItem{item}
+			}
+		case r.TicketID != nil:
+			// A transfer price difference only concerns the ticket, not its pickup service
+			if item.TicketID != nil && *item.TicketID == *r.TicketID &&
+				(r.Reason != models.RefundReasonTransferPrice || item.Kind == models.OrderItemTicket) {
+				out = append(out, item)
+			}
+		default:
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// splitByRate shares an amount over the VAT rates of items in proportion to their amounts
+func splitByRate(amount models.Money, items []*models.OrderItem) map[int]models.Money {
+	gross := map[int]models.Money{}
+	var total models.Money
+	for _, item := range items {
+		gross[item.TaxRate] += item.Amount
+		total += item.Amount
+	}
+	rates := make([]int, 0, len(gross))
+	for rate := range gross {
+		rates = append(rates, rate)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rates)))
+
+	out := map[int]models.Money{}
+	remaining := amount
+	for i, rate := range rates {
+		share := remaining
+		if i < len(rates)-1 && total != 0 {
+			share = amount.MulDiv(gross[rate].Cents(), total.Cents())
+		}
+		remaining -= share
+		out[rate] += share
+	}
+	return out
+}
@@ -1,18 +1,20 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stripe/stripe-go/v82"
 	"github.com/stripe/stripe-go/v82/checkout/session"
-	"github.com/stripe/stripe-go/v82/refund"
 	"github.com/synesthesie/backend/internal/config"
 	"github.com/synesthesie/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TicketService struct {
@@ -20,6 +22,9 @@ type TicketService struct {
 	cfg             *config.Config
 	stripeProvider  PaymentProvider
 	paypalProvider  PaymentProvider
+	refundService   *RefundService
+	invoiceService  *InvoiceService
+	waitlistService *WaitlistService
 }
 
 func NewTicketService(db *gorm.DB, cfg *config.Config) *TicketService {
@@ -32,173 +37,582 @@ func NewTicketService(db *gorm.DB, cfg *config.Config) *TicketService {
 		cfg: cfg,
 	}
 
-	// Initialize Stripe provider (always available)
-	service.stripeProvider = NewStripeProvider(cfg, db)
-
-	// Initialize PayPal provider (if enabled)
-	if cfg != nil && cfg.PayPalEnabled && cfg.PayPalClientID != "" {
-		paypalProvider, err := NewPayPalProvider(cfg, db)
-		if err == nil {
-			service.paypalProvider = paypalProvider
+	if mockPaymentsEnabled(cfg) {
+		// Offline stand-ins for both providers (development and tests)
+		service.stripeProvider = NewMockProvider(cfg, db, "stripe")
+		service.paypalProvider = NewMockProvider(cfg, db, "paypal")
+	} else {
+		// Initialize Stripe provider (always available)
+		service.stripeProvider = NewStripeProvider(cfg, db)
+
+		// Initialize PayPal provider (if enabled)
+		if cfg != nil && cfg.PayPalEnabled && cfg.PayPalClientID != "" {
+			paypalProvider, err := NewPayPalProvider(cfg, db)
+			if err == nil {
+				service.paypalProvider = paypalProvider
+			}
 		}
 	}
 
+	service.invoiceService = NewInvoiceService(db, cfg)
+	service.refundService = NewRefundService(db, service.stripeProvider, service.paypalProvider, service.invoiceService)
+
 	return service
 }
 
-// ListPickupTickets returns tickets that include pickup service.
-// statusFilter: "paid" (default) | "all" (includes pending & paid)
-func (s *TicketService) ListPickupTickets(eventID *uuid.UUID, statusFilter string) ([]*models.Ticket, error) {
-	query := s.db.Preload("User").Where("includes_pickup = ?", true)
-	if eventID != nil {
-		query = query.Where("event_id = ?", *eventID)
+// RefundService returns the refund service using this service's payment providers
+func (s *TicketService) RefundService() *RefundService {
+	return s.refundService
+}
+
+// InvoiceService returns the service issuing invoices and credit notes for tickets
+func (s *TicketService) InvoiceService() *InvoiceService {
+	return s.invoiceService
+}
+
+// MockProvider returns the mock payment provider if mock payments are enabled, otherwise nil
+func (s *TicketService) MockProvider() *MockProvider {
+	mock, _ := s.stripeProvider.(*MockProvider)
+	return mock
+}
+
+// PayPalWebhookVerifier returns the signature check for PayPal webhooks, or nil if PayPal is not enabled
+func (s *TicketService) PayPalWebhookVerifier() PayPalWebhookVerifier {
+	verifier, _ := s.paypalProvider.(PayPalWebhookVerifier)
+	return verifier
+}
+
+// ChargeRefundLister returns the refund listing of the Stripe provider, or nil if it has none (mock payments)
+func (s *TicketService) ChargeRefundLister() ChargeRefundLister {
+	lister, _ := s.stripeProvider.(ChargeRefundLister)
+	return lister
+}
+
+// AttachWaitlistService enables offering freed spots to the waitlist
+func (s *TicketService) AttachWaitlistService(ws *WaitlistService) {
+	s.waitlistService = ws
+}
+
+// offerFreedSpots passes freed spots of an event on to its waitlist
+func (s *TicketService) offerFreedSpots(eventID uuid.UUID) {
+	if s.waitlistService == nil {
+		return
 	}
-	switch statusFilter {
-	case "all":
-		query = query.Where("status IN ?", []string{"pending", "paid"})
-	default:
-		query = query.Where("status = ?", "paid")
+	if _, err := s.waitlistService.OfferFreedSpots(eventID); err != nil {
+		log.Printf("Waitlist: failed to offer freed spots for event %s: %v", eventID, err)
 	}
-	// Exclude cancelled/refunded implicitly via status filter above
-	var tickets []*models.Ticket
-	if err := query.Order("created_at DESC").Find(&tickets).Error; err != nil {
-		return nil, err
+}
+
+// processWaitlistOffers re-checks all waitlists after a bulk status change
+func (s *TicketService) processWaitlistOffers() {
+	if s.waitlistService == nil {
+		return
+	}
+	if _, err := s.waitlistService.ProcessOffers(); err != nil {
+		log.Printf("Waitlist: failed to process offers: %v", err)
 	}
-	return tickets, nil
 }
 
-// CreateTicket creates a new ticket for a user
-func (s *TicketService) CreateTicket(userID, eventID uuid.UUID, includesPickup bool, pickupAddress string) (*models.Ticket, *stripe.CheckoutSession, error) {
-	// Check if user already has a ticket for this event
-	var existingTicket models.Ticket
-	err := s.db.Where("user_id = ? AND event_id = ? AND status IN ?", userID, eventID, []string{"pending", "paid"}).First(&existingTicket).Error
-	if err == nil {
-		return nil, nil, errors.New("user already has a ticket for this event")
+// providerFor returns the payment provider with the given name, or nil if it is not enabled
+func (s *TicketService) providerFor(name string) PaymentProvider {
+	if name == "paypal" {
+		return s.paypalProvider
 	}
+	return s.stripeProvider
+}
 
-	// Get event details
-	var event models.Event
-	if err := s.db.First(&event, eventID).Error; err != nil {
-		return nil, nil, errors.New("event not found")
+// CreateTicket creates a new ticket for a user.
+// The returned ticket holds a seat until ticket.HoldExpiresAt.
+func (s *TicketService) CreateTicket(userID, eventID uuid.UUID, ticketReq OrderTicketRequest) (*models.Ticket, *stripe.CheckoutSession, error) {
+	order, event, _, err := s.reserveOrder(userID, &OrderRequest{
+		EventID:         eventID,
+		Tickets:         []OrderTicketRequest{ticketReq},
+		PaymentProvider: "stripe",
+	})
+	if err != nil {
+		return nil, nil, err
 	}
+	ticket := &order.Tickets[0]
 
-	// Get user for group
-	var user models.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		return nil, nil, errors.New("user not found")
+	// Create Stripe checkout session
+	checkoutSession, err := s.createStripeCheckoutSession(order, event)
+	if err != nil {
+		// Delete order if Stripe session creation fails (releases the seat)
+		s.deleteOrder(order.ID)
+		return nil, nil, err
 	}
 
-	// Enforce allowed_group
-	if event.AllowedGroup == "guests" && user.Group != "guests" {
-		return nil, nil, errors.New("event not available for your group")
+	// Update ticket with Stripe session ID
+	ticket.StripeSessionID = checkoutSession.ID
+	if err := s.db.Save(ticket).Error; err != nil {
+		return nil, nil, err
 	}
-	if event.AllowedGroup == "bubble" && user.Group != "bubble" {
-		return nil, nil, errors.New("event not available for your group")
+
+	return ticket, checkoutSession, nil
+}
+
+// selectTicketType returns the requested ticket type, or the first one on sale for the user's group.
+// Must run under the event lock so type quotas cannot be oversold.
+func selectTicketType(tx *gorm.DB, event *models.Event, user *models.User, ticketTypeID uuid.UUID) (*models.TicketType, error) {
+	var types []models.TicketType
+	if err := tx.Where("event_id = ?", event.ID).Order("sort_order ASC, price ASC").Find(&types).Error; err != nil {
+		return nil, err
 	}
-	if event.AllowedGroup == "plus" && user.Group != "plus" {
-		return nil, nil, errors.New("event not available for your group")
+
+	now := time.Now()
+	if ticketTypeID != uuid.Nil {
+		for i := range types {
+			t := &types[i]
+			if t.ID != ticketTypeID {
+				continue
+			}
+			if !t.OnSale(now) {
+				return nil, errors.New("ticket type is not on sale")
+			}
+			if !t.AllowsGroup(user.Group) {
+				return nil, errors.New("ticket type not available for your group")
+			}
+			if t.Remaining(tx) == 0 {
+				return nil, errors.New("ticket type is sold out")
+			}
+			return t, nil
+		}
+		return nil, errors.New("ticket type not found")
 	}
 
-	// Check availability
-	availableSpots := event.GetAvailableSpots(s.db)
-	if availableSpots <= 0 {
-		return nil, nil, errors.New("event is fully booked")
+	soldOut := false
+	for i := range types {
+		t := &types[i]
+		if !t.OnSale(now) || !t.AllowsGroup(user.Group) {
+			continue
+		}
+		if t.Remaining(tx) == 0 {
+			soldOut = true
+			continue
+		}
+		return t, nil
+	}
+	if soldOut {
+		return nil, errors.New("ticket type is sold out")
 	}
+	return nil, errors.New("no ticket type available")
+}
 
-	// Determine base price based on user group and event prices
-	basePrice := event.GuestsPrice
-	if user.Group == "bubble" {
-		basePrice = event.BubblePrice
+// ticketLineItemName is the checkout line item name of a ticket, including its type and promo code
+func ticketLineItemName(ticket *models.Ticket, event *models.Event) string {
+	name := fmt.Sprintf("Ticket für %s", event.Name)
+	if ticket.TicketTypeName != "" {
+		name = fmt.Sprintf("Ticket für %s (%s)", event.Name, ticket.TicketTypeName)
 	}
-	if user.Group == "plus" {
-		basePrice = event.PlusPrice
+	if ticket.DiscountAmount > 0 {
+		name += fmt.Sprintf(" – Rabattcode %s: -%s", ticket.PromoCode, ticket.DiscountAmount.Format())
 	}
+	return name
+}
 
-	// Get pickup service price
-	pickupPrice := 0.0
-	if includesPickup {
-		var setting models.SystemSetting
-		if err := s.db.Where("key = ?", "pickup_service_price").First(&setting).Error; err == nil {
-			fmt.Sscanf(setting.Value, "%f", &pickupPrice)
+// ticketItemDescription is the checkout line item description of a ticket: the event date and, for companions, the holder
+func ticketItemDescription(ticket *models.Ticket, event *models.Event) string {
+	desc := fmt.Sprintf("Event am %s", event.DateFrom.Format("02.01.2006"))
+	if ticket.HolderName != "" {
+		desc += fmt.Sprintf(" – für %s", ticket.HolderName)
+	}
+	return desc
+}
+
+// orderItemCents returns the checkout unit price in cents and the quantity of an order item.
+// Discounted items are charged as one unit of their net amount.
+func orderItemCents(item *models.OrderItem) (int64, int64) {
+	if item.Quantity > 1 && item.DiscountAmount == 0 {
+		return item.UnitPrice.Cents(), int64(item.Quantity)
+	}
+	return item.Amount.Cents(), 1
+}
+
+// orderCheckoutItems returns the items of an order that are charged at checkout
+func orderCheckoutItems(order *models.Order) []*models.OrderItem {
+	var items []*models.OrderItem
+	for i := range order.Items {
+		item := &order.Items[i]
+		if item.Status == models.OrderItemStatusActive && item.Amount > 0 {
+			items = append(items, item)
 		}
 	}
+	return items
+}
 
-	// Create ticket
-	ticket := &models.Ticket{
-		UserID:         userID,
-		EventID:        eventID,
-		Status:         "pending",
-		Price:          basePrice,
-		IncludesPickup: includesPickup,
-		PickupPrice:    pickupPrice,
-		PickupAddress:  pickupAddress,
+// orderCheckoutTax sums the net amounts and the VAT of the items charged at checkout
+func orderCheckoutTax(order *models.Order) (net, tax models.Money) {
+	for _, item := range orderCheckoutItems(order) {
+		net += item.NetAmount
+		tax += item.TaxAmount
 	}
-	ticket.CalculateTotalAmount()
+	return net, tax
+}
 
-	// Save ticket
-	if err := s.db.Create(ticket).Error; err != nil {
-		return nil, nil, err
+// holdDuration returns how long a pending ticket keeps its seat
+func (s *TicketService) holdDuration() time.Duration {
+	if s.cfg == nil || s.cfg.PendingTicketTTLMinutes <= 0 {
+		return 30 * time.Minute
 	}
+	return time.Duration(s.cfg.PendingTicketTTLMinutes) * time.Minute
+}
 
-	// Create Stripe checkout session
-	checkoutSession, err := s.createStripeCheckoutSession(ticket, &event)
-	if err != nil {
-		// Delete ticket if Stripe session creation fails
-		s.db.Delete(ticket)
-		return nil, nil, err
+// OrderTicketRequest is one ticket of an order.
+// An empty HolderName books the buyer's own ticket, otherwise the ticket is for the named companion.
+type OrderTicketRequest struct {
+	TicketTypeID   uuid.UUID // uuid.Nil = first ticket type available to the buyer
+	HolderName     string
+	IncludesPickup bool
+	PickupAddress  string
+	// PickupPostalCode selects the pickup zone and its price; PickupSlotID is required if the event has time slots
+	PickupPostalCode string
+	PickupSlotID     uuid.UUID
+}
+
+// OrderAddonRequest is an add-on bought with an order
+type OrderAddonRequest struct {
+	AddonID  uuid.UUID
+	Quantity int
+}
+
+// OrderRequest describes everything bought with one checkout
+type OrderRequest struct {
+	EventID         uuid.UUID
+	Tickets         []OrderTicketRequest
+	Addons          []OrderAddonRequest
+	PromoCode       string // applied to every ticket as far as the code's limits allow
+	PaymentProvider string
+	WaitlistToken   string // claims the buyer's open waitlist offer for the event
+}
+
+// maxTicketsPerOrder limits how many tickets one checkout can book
+const maxTicketsPerOrder = 10
+
+// validateOrderRequest checks the shape of an order before anything is reserved
+func validateOrderRequest(req *OrderRequest) error {
+	if len(req.Tickets) == 0 {
+		return errors.New("order must contain at least one ticket")
+	}
+	if len(req.Tickets) > maxTicketsPerOrder {
+		return fmt.Errorf("an order can contain at most %d tickets", maxTicketsPerOrder)
+	}
+	ownTickets := 0
+	holders := map[string]bool{}
+	for i := range req.Tickets {
+		t := &req.Tickets[i]
+		t.HolderName = strings.TrimSpace(t.HolderName)
+		if t.HolderName == "" {
+			ownTickets++
+			continue
+		}
+		key := strings.ToLower(t.HolderName)
+		if holders[key] {
+			return errors.New("each companion can only get one ticket")
+		}
+		holders[key] = true
+	}
+	if ownTickets > 1 {
+		return errors.New("an order can contain only one ticket for yourself; name your companions")
 	}
+	addons := map[uuid.UUID]bool{}
+	for _, a := range req.Addons {
+		if a.Quantity <= 0 {
+			return errors.New("add-on quantity must be positive")
+		}
+		if addons[a.AddonID] {
+			return errors.New("add-on listed twice")
+		}
+		addons[a.AddonID] = true
+	}
+	return nil
+}
 
-	// Update ticket with Stripe session ID
-	ticket.StripeSessionID = checkoutSession.ID
-	if err := s.db.Save(ticket).Error; err != nil {
-		return nil, nil, err
+// reserveOrder atomically reserves the seats of an order by inserting its pending tickets.
+// The event row is locked (SELECT ... FOR UPDATE) for the duration of the
+// transaction, so concurrent bookings for the same event are serialized and
+// the availability check and inserts cannot interleave.
+// The returned order has its tickets (the buyer's own ticket first) and items loaded.
+func (s *TicketService) reserveOrder(userID uuid.UUID, req *OrderRequest) (*models.Order, *models.Event, *models.User, error) {
+	if err := validateOrderRequest(req); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var (
+		order *models.Order
+		event models.Event
+		user  models.User
+	)
+	ownTicket := false
+	for _, t := range req.Tickets {
+		if t.HolderName == "" {
+			ownTicket = true
+		}
 	}
 
-	return ticket, checkoutSession, nil
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Lock event row; all bookings for this event wait here
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", req.EventID).Error; err != nil {
+			return errors.New("event not found")
+		}
+
+		// Check if user already has a ticket of their own for this event
+		var existingTicket models.Ticket
+		if ownTicket {
+			err := tx.Where("user_id = ? AND event_id = ? AND holder_name = '' AND status IN ?", userID, req.EventID, []string{"pending", "paid"}).First(&existingTicket).Error
+			if err == nil && !existingTicket.HoldExpired() {
+				return errors.New("user already has a ticket for this event")
+			}
+		}
+
+		// Get user for group
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return errors.New("user not found")
+		}
+
+		// Enforce allowed_group
+		if event.AllowedGroup == "guests" && user.Group != "guests" {
+			return errors.New("event not available for your group")
+		}
+		if event.AllowedGroup == "bubble" && user.Group != "bubble" {
+			return errors.New("event not available for your group")
+		}
+		if event.AllowedGroup == "plus" && user.Group != "plus" {
+			return errors.New("event not available for your group")
+		}
+
+		if req.WaitlistToken != "" {
+			var offer models.WaitlistEntry
+			if err := tx.Where("offer_token = ? AND user_id = ? AND event_id = ? AND status = ?",
+				req.WaitlistToken, userID, req.EventID, models.WaitlistStatusOffered).First(&offer).Error; err != nil {
+				return errors.New("waitlist offer not found")
+			}
+			if offer.OfferExpired() {
+				return errors.New("waitlist offer expired")
+			}
+		}
+
+		// Booking takes the user off the waitlist; an open offer releases its held spot to this booking
+		if err := tx.Model(&models.WaitlistEntry{}).
+			Where("user_id = ? AND event_id = ? AND status IN ?", userID, req.EventID,
+				[]string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}).
+			Updates(map[string]interface{}{"status": models.WaitlistStatusClaimed, "claimed_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		// Check availability (under lock)
+		available := event.GetAvailableSpots(tx)
+		if available <= 0 {
+			return errors.New("event is fully booked")
+		}
+		if available < len(req.Tickets) {
+			return fmt.Errorf("only %d spots left for this event", available)
+		}
+
+		// Release an expired hold of this user so the partial state does not linger
+		if existingTicket.ID != uuid.Nil && existingTicket.HoldExpired() {
+			if err := existingTicket.TransitionTo(tx, models.TicketCancelled,
+				models.StatusChange{Actor: models.StatusActorSystem, Reason: "hold_expired"},
+				map[string]interface{}{"cancelled_at": time.Now()}); err != nil {
+				return err
+			}
+		}
+
+		order = &models.Order{
+			UserID:          userID,
+			EventID:         req.EventID,
+			PaymentProvider: req.PaymentProvider,
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		// The buyer's own ticket comes first; it is the reference of the checkout
+		ticketReqs := make([]OrderTicketRequest, 0, len(req.Tickets))
+		for _, t := range req.Tickets {
+			if t.HolderName == "" {
+				ticketReqs = append(ticketReqs, t)
+			}
+		}
+		for _, t := range req.Tickets {
+			if t.HolderName != "" {
+				ticketReqs = append(ticketReqs, t)
+			}
+		}
+
+		holdExpiresAt := time.Now().Add(s.holdDuration())
+		for i, tr := range ticketReqs {
+			// Price comes from the chosen (or first available) ticket type.
+			// Tickets are inserted one by one so type quotas count the earlier tickets of this order.
+			ticketType, err := selectTicketType(tx, &event, &user, tr.TicketTypeID)
+			if err != nil {
+				return err
+			}
+
+			var pickup *pickupBooking
+			if tr.IncludesPickup {
+				if pickup, err = bookPickup(tx, req.EventID, &tr); err != nil {
+					return err
+				}
+			}
+
+			ticket := models.Ticket{
+				UserID:          userID,
+				EventID:         req.EventID,
+				OrderID:         &order.ID,
+				HolderName:      tr.HolderName,
+				Status:          models.TicketPending,
+				TicketTypeID:    &ticketType.ID,
+				TicketTypeName:  ticketType.Name,
+				Price:           ticketType.Price,
+				IncludesPickup:  tr.IncludesPickup,
+				PickupAddress:   tr.PickupAddress,
+				PaymentProvider: req.PaymentProvider,
+				HoldExpiresAt:   &holdExpiresAt,
+			}
+			if pickup != nil {
+				ticket.PickupPrice = pickup.price
+				ticket.PickupStatus = models.PickupStatusOpen
+				ticket.PickupPostalCode = models.NormalizePostalCode(tr.PickupPostalCode)
+				if pickup.zone != nil {
+					ticket.PickupZoneID = &pickup.zone.ID
+					ticket.PickupZoneName = pickup.zone.Name
+				}
+				if pickup.slot != nil {
+					ticket.PickupSlotID = &pickup.slot.ID
+				}
+			}
+
+			// Promo code discount (usage limits checked under lock of the code row).
+			// An invalid code fails the order; once the code's limits are reached the remaining tickets pay full price.
+			if req.PromoCode != "" {
+				promo, discount, err := redeemPromoCode(tx, req.PromoCode, &user, req.EventID, ticketType.Price)
+				if err != nil && i == 0 {
+					return err
+				}
+				if err == nil {
+					ticket.PromoCodeID = &promo.ID
+					ticket.PromoCode = promo.Code
+					ticket.DiscountAmount = discount
+				}
+			}
+			ticket.CalculateTotalAmount()
+
+			if err := tx.Create(&ticket).Error; err != nil {
+				return err
+			}
+			if err := models.RecordTicketCreated(tx, &ticket, models.StatusChange{
+				Actor: models.StatusActorUser, ActorID: &userID, Reason: "checkout_started",
+			}); err != nil {
+				return err
+			}
+			order.Tickets = append(order.Tickets, ticket)
+
+			order.Items = append(order.Items, models.OrderItem{
+				OrderID:        order.ID,
+				Kind:           models.OrderItemTicket,
+				TicketID:       &ticket.ID,
+				Name:           ticketLineItemName(&ticket, &event),
+				Description:    ticketItemDescription(&ticket, &event),
+				UnitPrice:      ticket.Price,
+				Quantity:       1,
+				DiscountAmount: ticket.DiscountAmount,
+				Amount:         ticket.NetPrice(),
+				Status:         models.OrderItemStatusActive,
+			})
+			if ticket.IncludesPickup {
+				order.Items = append(order.Items, models.OrderItem{
+					OrderID:     order.ID,
+					Kind:        models.OrderItemPickup,
+					TicketID:    &ticket.ID,
+					Name:        "Abholservice",
+					Description: pickupItemDescription(&ticket, pickup.slot),
+					UnitPrice:   ticket.PickupPrice,
+					Quantity:    1,
+					Amount:      ticket.PickupPrice,
+					Status:      models.OrderItemStatusActive,
+				})
+			}
+		}
+
+		for _, ar := range req.Addons {
+			var addon models.EventAddon
+			if err := tx.Where("id = ? AND event_id = ? AND is_active = ?", ar.AddonID, req.EventID, true).First(&addon).Error; err != nil {
+				return errors.New("add-on not found")
+			}
+			if addon.MaxPerOrder > 0 && ar.Quantity > addon.MaxPerOrder {
+				return fmt.Errorf("at most %d of %s per order", addon.MaxPerOrder, addon.Name)
+			}
+			addonID := addon.ID
+			order.Items = append(order.Items, models.OrderItem{
+				OrderID:     order.ID,
+				Kind:        models.OrderItemAddon,
+				AddonID:     &addonID,
+				Name:        addon.Name,
+				Description: addon.Description,
+				UnitPrice:   addon.Price,
+				Quantity:    ar.Quantity,
+				Amount:      addon.Price * models.Money(ar.Quantity),
+				Status:      models.OrderItemStatusActive,
+			})
+		}
+
+		taxRates := eventTaxRates(s.cfg, &event)
+		for i := range order.Items {
+			order.Items[i].SetTaxRate(taxRates[order.Items[i].Kind])
+		}
+
+		if err := tx.Create(&order.Items).Error; err != nil {
+			return err
+		}
+		for _, item := range order.Items {
+			order.TotalAmount += item.Amount
+		}
+		return tx.Model(order).Update("total_amount", order.TotalAmount).Error
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return order, &event, &user, nil
 }
 
 // GetPickupServicePrice returns current pickup service price for user-facing endpoints
-func (s *TicketService) GetPickupServicePrice() (float64, error) {
+func (s *TicketService) GetPickupServicePrice() (models.Money, error) {
+	return pickupServicePrice(s.db)
+}
+
+// defaultPickupServicePrice applies until an admin sets the price
+const defaultPickupServicePrice models.Money = 1000
+
+// pickupServicePrice reads the pickup service price setting (a decimal amount, e.g. "10.00")
+func pickupServicePrice(db *gorm.DB) (models.Money, error) {
 	var setting models.SystemSetting
-	if err := s.db.Where("key = ?", "pickup_service_price").First(&setting).Error; err != nil {
+	if err := db.Where("key = ?", "pickup_service_price").First(&setting).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return 10.00, nil
+			return defaultPickupServicePrice, nil
 		}
 		return 0, err
 	}
-	var price float64
-	_, _ = fmt.Sscanf(setting.Value, "%f", &price)
-	return price, nil
+	return models.ParseMoney(setting.Value)
 }
 
-// createStripeCheckoutSession creates a Stripe checkout session
-func (s *TicketService) createStripeCheckoutSession(ticket *models.Ticket, event *models.Event) (*stripe.CheckoutSession, error) {
-	lineItems := []*stripe.CheckoutSessionLineItemParams{
-		{
-			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-				Currency: stripe.String("eur"),
-				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-					Name:        stripe.String(fmt.Sprintf("Ticket für %s", event.Name)),
-					Description: stripe.String(fmt.Sprintf("Event am %s", event.DateFrom.Format("02.01.2006"))),
-				},
-				UnitAmount: stripe.Int64(int64(ticket.Price * 100)),
-			},
-			Quantity: stripe.Int64(1),
-		},
-	}
+// createStripeCheckoutSession creates a Stripe checkout session for an order
+func (s *TicketService) createStripeCheckoutSession(order *models.Order, event *models.Event) (*stripe.CheckoutSession, error) {
+	ticket := &order.Tickets[0]
 
-	// Add pickup service if included
-	if ticket.IncludesPickup {
+	var lineItems []*stripe.CheckoutSessionLineItemParams
+	for _, item := range orderCheckoutItems(order) {
+		unitAmount, quantity := orderItemCents(item)
 		lineItems = append(lineItems, &stripe.CheckoutSessionLineItemParams{
 			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-				Currency: stripe.String("eur"),
+				Currency: stripe.String(stripeCurrency),
 				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-					Name:        stripe.String("Abhol- und Bringservice"),
-					Description: stripe.String(fmt.Sprintf("Abholadresse: %s", ticket.PickupAddress)),
+					Name:        stripe.String(item.Name),
+					Description: stripe.String(item.Description),
 				},
-				UnitAmount: stripe.Int64(int64(ticket.PickupPrice * 100)),
+				UnitAmount: stripe.Int64(unitAmount),
 			},
-			Quantity: stripe.Int64(1),
+			Quantity: stripe.Int64(quantity),
 		})
 	}
 
@@ -210,9 +624,11 @@ func (s *TicketService) createStripeCheckoutSession(ticket *models.Ticket, event
 		ClientReferenceID: stripe.String(ticket.ID.String()),
 		Metadata: map[string]string{
 			"ticket_id": ticket.ID.String(),
+			"order_id":  order.ID.String(),
 			"user_id":   ticket.UserID.String(),
 			"event_id":  ticket.EventID.String(),
 		},
+		ExpiresAt: stripeSessionExpiry(ticket.HoldExpiresAt, time.Now()),
 	}
 
 	// Configure allowed payment methods explicitly from config
@@ -220,57 +636,649 @@ func (s *TicketService) createStripeCheckoutSession(ticket *models.Ticket, event
 		params.PaymentMethodTypes = stripe.StringSlice(s.cfg.StripePaymentMethods)
 	}
 
-	return session.New(params)
-}
-
-// ConfirmPayment confirms a ticket payment after successful Stripe webhook
-// Also handles Grace Period: reactivates tickets in "pending_cancellation" status
-func (s *TicketService) ConfirmPayment(ticketID uuid.UUID, paymentIntentID string) error {
-	// First try normal pending tickets
-	result := s.db.Model(&models.Ticket{}).
-		Where("id = ? AND status = ?", ticketID, "pending").
-		Updates(map[string]interface{}{
-			"status":                   "paid",
-			"stripe_payment_intent_id": paymentIntentID,
-		})
-
-	if result.Error != nil {
-		return result.Error
+	return session.New(params)
+}
+
+// ConfirmPayment confirms a ticket payment after successful Stripe webhook.
+// All tickets of the ticket's order were paid with the same checkout and are confirmed together.
+// Also handles Grace Period: reactivates tickets in "pending_cancellation" status, and tickets cancelled
+// after their hold ran out if the event still has room; otherwise the payment is refunded (see confirmCheckout).
+func (s *TicketService) ConfirmPayment(ticketID uuid.UUID, paymentIntentID string) error {
+	reactivated := false
+	var refund *models.Refund
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		change := models.StatusChange{Actor: models.StatusActorProvider, Reason: "stripe_webhook", ProviderRef: paymentIntentID}
+
+		// First try normal pending tickets
+		confirmed, lateRefund, err := confirmCheckout(tx, ticketID, "stripe", change,
+			map[string]interface{}{"stripe_payment_intent_id": paymentIntentID},
+			models.TicketPending)
+		if err != nil {
+			return err
+		}
+
+		if confirmed == 0 && lateRefund == nil {
+			// No rows affected - check if ticket is in pending_cancellation (GRACE PERIOD) or was cancelled
+			change.Reason = "stripe_webhook_grace_period"
+			confirmed, lateRefund, err = confirmCheckout(tx, ticketID, "stripe", change,
+				map[string]interface{}{
+					"stripe_payment_intent_id": paymentIntentID,
+					"cancelled_at":             nil, // Clear cancellation timestamp
+				},
+				models.TicketPendingCancellation, models.TicketCancelled)
+			if err != nil {
+				return err
+			}
+			if confirmed == 0 && lateRefund == nil {
+				// Ticket not found in pending, pending_cancellation or cancelled
+				return errors.New("ticket not found or already paid")
+			}
+			reactivated = confirmed > 0
+		}
+		refund = lateRefund
+
+		return recordOrderPayment(tx, ticketID, "stripe", paymentIntentID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if refund != nil {
+		log.Printf("Stripe webhook: seats of ticket %s were taken after its hold expired, refunding payment %s", ticketID, paymentIntentID)
+		_ = s.refundService.Execute(refund)
+	}
+	if reactivated {
+		log.Printf("✅ Stripe webhook: Ticket %s reactivated from pending_cancellation to paid (GRACE PERIOD)", ticketID)
+	}
+	s.recordStripeFee(ticketID, paymentIntentID)
+	return nil
+}
+
+// confirmCheckout marks the tickets of a checkout in one of the given statuses as paid, along with the
+// payment fields in updates; change.ProviderRef is the payment. The event row is locked as in reserveOrder,
+// because tickets whose seat hold ran out (expired pending or cancelled tickets) only get their seats back
+// if the event still has room for all of them. Otherwise, or if the event was cancelled meanwhile, the tickets of the checkout are cancelled and the
+// payment is recorded as a pending refund, which the caller executes after the commit. A payment refunded
+// that way before returns its refund again. Returns the number of paid tickets.
+func confirmCheckout(db *gorm.DB, ticketID uuid.UUID, provider string, change models.StatusChange, updates map[string]interface{}, statuses ...models.TicketStatus) (int64, *models.Refund, error) {
+	var (
+		confirmed int64
+		refund    *models.Refund
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Select("id", "event_id").First(&ticket, "id = ?", ticketID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", ticket.EventID).Error; err != nil {
+			return err
+		}
+
+		if change.ProviderRef != "" {
+			var refunded models.Refund
+			err := tx.Where("provider = ? AND provider_ref = ? AND reason = ?", provider, change.ProviderRef, models.RefundReasonSeatUnavailable).
+				First(&refunded).Error
+			if err == nil {
+				refund = &refunded
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		var tickets []models.Ticket
+		if err := tx.Scopes(models.SameCheckout(ticketID), models.WithTicketStatus(statuses...)).Find(&tickets).Error; err != nil {
+			return err
+		}
+		if event.IsActive && !seatsMissing(tx, &event, tickets) {
+			var err error
+			confirmed, err = models.TransitionTickets(tx, models.TicketPaid, change, updates,
+				models.SameCheckout(ticketID), models.WithTicketStatus(statuses...))
+			return err
+		}
+
+		var err error
+		refund, err = cancelUnseatedCheckout(tx, ticketID, provider, change.ProviderRef)
+		return err
+	})
+	return confirmed, refund, err
+}
+
+// seatsMissing reports whether the event lacks room for those of the given tickets that lost their seat:
+// pending tickets whose hold expired and cancelled tickets
+func seatsMissing(db *gorm.DB, event *models.Event, tickets []models.Ticket) bool {
+	late := 0
+	for i := range tickets {
+		if tickets[i].Status == models.TicketCancelled || tickets[i].HoldExpired() {
+			late++
+		}
+	}
+	return late > 0 && event.GetAvailableSpots(db) < late
+}
+
+// checkoutKeepsSeats reports whether the unpaid tickets of a checkout still have their seats, i.e. a running
+// hold or room in the event. Providers that capture a payment themselves (PayPal) check it before capturing,
+// so a checkout whose seats were given away is not charged; confirmCheckout decides under the event lock.
+func checkoutKeepsSeats(db *gorm.DB, ticketID uuid.UUID) (bool, error) {
+	var tickets []models.Ticket
+	if err := db.Scopes(models.SameCheckout(ticketID),
+		models.WithTicketStatus(models.TicketPending, models.TicketPendingCancellation, models.TicketCancelled)).
+		Find(&tickets).Error; err != nil {
+		return false, err
+	}
+	if len(tickets) == 0 {
+		return true, nil
+	}
+	var event models.Event
+	if err := db.First(&event, "id = ?", tickets[0].EventID).Error; err != nil {
+		return false, err
+	}
+	return !seatsMissing(db, &event, tickets), nil
+}
+
+// cancelUnseatedCheckout cancels the unpaid tickets of a checkout that was paid after its seats were given
+// away, and records the refund of the whole payment within tx. The payment is booked in the ledger first,
+// so the refund has its counterpart.
+func cancelUnseatedCheckout(tx *gorm.DB, ticketID uuid.UUID, provider, paymentRef string) (*models.Refund, error) {
+	var tickets []models.Ticket
+	if err := tx.Scopes(models.SameCheckout(ticketID),
+		models.WithTicketStatus(models.TicketPending, models.TicketPendingCancellation, models.TicketCancelled)).
+		Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	amount := models.Money(0)
+	for i := range tickets {
+		amount += tickets[i].TotalAmount
+		if err := tickets[i].TransitionTo(tx, models.TicketCancelled,
+			models.StatusChange{Actor: models.StatusActorSystem, Reason: models.RefundReasonSeatUnavailable, ProviderRef: paymentRef},
+			map[string]interface{}{"cancelled_at": now}); err != nil {
+			return nil, err
+		}
+		if err := settleTicketItems(tx, tickets[i].ID, 0, models.OrderItemStatusCancelled); err != nil {
+			return nil, err
+		}
+	}
+
+	var orderID *uuid.UUID
+	if len(tickets) > 0 && tickets[0].OrderID != nil {
+		orderID = tickets[0].OrderID
+		var order models.Order
+		if err := tx.First(&order, "id = ?", *orderID).Error; err != nil {
+			return nil, err
+		}
+		amount = order.TotalAmount
+		if err := tx.Model(&models.OrderItem{}).
+			Where("order_id = ? AND kind = ? AND status = ?", order.ID, models.OrderItemAddon, models.OrderItemStatusActive).
+			Update("status", models.OrderItemStatusCancelled).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordOrderPayment(tx, ticketID, provider, paymentRef); err != nil {
+		return nil, err
+	}
+	refundTicketID := ticketID
+	return recordRefund(tx, &RefundRequest{
+		Provider:    provider,
+		ProviderRef: paymentRef,
+		OrderID:     orderID,
+		TicketID:    &refundTicketID,
+		Amount:      amount,
+		Reason:      models.RefundReasonSeatUnavailable,
+		Initiator:   models.RefundInitiatorSystem,
+	})
+}
+
+// recordStripeFee books the Stripe fee of a payment once Stripe reports it
+func (s *TicketService) recordStripeFee(ticketID uuid.UUID, paymentIntentID string) {
+	sp, ok := s.stripeProvider.(*StripeProvider)
+	if !ok || paymentIntentID == "" {
+		return
+	}
+	fee, err := sp.PaymentFee(paymentIntentID)
+	if err != nil {
+		log.Printf("Ledger: Stripe fee for %s not available: %v", paymentIntentID, err)
+		return
+	}
+	logLedgerError("Stripe fee", recordOrderFee(s.db, ticketID, "stripe", paymentIntentID, fee))
+}
+
+// RecordPayPalCapture books a PayPal capture of the order a ticket belongs to, including the fee PayPal withheld
+func (s *TicketService) RecordPayPalCapture(ticketID uuid.UUID, captureID string, fee models.Money) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := recordOrderPayment(tx, ticketID, "paypal", captureID); err != nil {
+			return err
+		}
+		return recordOrderFee(tx, ticketID, "paypal", captureID, fee)
+	})
+}
+
+// CancelPendingBySystem cancels a pending ticket and the other tickets of its order (e.g., after Stripe session expiration)
+func (s *TicketService) CancelPendingBySystem(ticketID uuid.UUID, reason string) error {
+	_, err := models.TransitionTickets(s.db, models.TicketCancelled,
+		models.StatusChange{Actor: models.StatusActorProvider, Reason: reason},
+		map[string]interface{}{"cancelled_at": time.Now()},
+		models.SameCheckout(ticketID), models.WithTicketStatus(models.TicketPending))
+	return err
+}
+
+// paymentRefColumn returns the ticket column holding a provider's payment reference
+func paymentRefColumn(provider string) string {
+	if provider == "paypal" {
+		return "paypal_capture_id"
+	}
+	return "stripe_payment_intent_id"
+}
+
+// ClaimConfirmationEmail marks a paid ticket as confirmed and reports whether the caller should send its
+// confirmation email; only the first caller gets true. A failed send is handed back with ReleaseConfirmationEmail.
+func (s *TicketService) ClaimConfirmationEmail(ticketID uuid.UUID) (bool, error) {
+	res := s.db.Model(&models.Ticket{}).
+		Where("id = ? AND status = ? AND confirmation_sent_at IS NULL", ticketID, "paid").
+		Update("confirmation_sent_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// ReleaseConfirmationEmail undoes a claim after the confirmation email could not be sent
+func (s *TicketService) ReleaseConfirmationEmail(ticketID uuid.UUID) error {
+	return s.db.Model(&models.Ticket{}).Where("id = ?", ticketID).Update("confirmation_sent_at", nil).Error
+}
+
+// CancelDeniedPayment cancels the tickets of a checkout whose payment the provider denied after all
+// (e.g. a pending PayPal capture) and frees their seats. A payment already booked in the ledger is
+// reversed there. Tickets paid with another payment are left alone. Returns the number of cancelled tickets.
+func (s *TicketService) CancelDeniedPayment(ticketID uuid.UUID, provider, paymentRef string) (int, error) {
+	var tickets []models.Ticket
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(models.SameCheckout(ticketID)).
+			Where("status IN ? OR (status = ? AND "+paymentRefColumn(provider)+" = ?)",
+				[]string{"pending", "pending_cancellation"}, "paid", paymentRef).
+			Find(&tickets).Error; err != nil {
+			return err
+		}
+		if len(tickets) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		for i := range tickets {
+			if err := tickets[i].TransitionTo(tx, models.TicketCancelled,
+				models.StatusChange{Actor: models.StatusActorProvider, Reason: "payment_denied", ProviderRef: paymentRef},
+				map[string]interface{}{"cancelled_at": now}); err != nil {
+				return err
+			}
+			if err := settleTicketItems(tx, tickets[i].ID, 0, models.OrderItemStatusCancelled); err != nil {
+				return err
+			}
+		}
+		if tickets[0].OrderID != nil {
+			if err := tx.Model(&models.OrderItem{}).
+				Where("order_id = ? AND kind = ? AND status = ?", *tickets[0].OrderID, models.OrderItemAddon, models.OrderItemStatusActive).
+				Update("status", models.OrderItemStatusCancelled).Error; err != nil {
+				return err
+			}
+		}
+		return reverseOrderPayment(tx, ticketID, provider, paymentRef)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(tickets) > 0 {
+		log.Printf("Payment %s (%s) denied: cancelled %d tickets of checkout %s", paymentRef, provider, len(tickets), ticketID)
+		s.offerFreedSpots(tickets[0].EventID)
+	}
+	return len(tickets), nil
+}
+
+// ApplyProviderRefund books a refund the provider reports for a payment, e.g. one made in the provider's
+// dashboard or a reversal. Refunds sent by RefundService are recognised by their provider refund ID and
+// skipped. The amount goes to the tickets of the payment first, then to the add-ons of their order; fully
+// refunded paid (or disputed) tickets become "refunded". While a refund of ours for the payment is still in flight an
+// error is returned, so the notification is retried once its outcome is stored.
+func (s *TicketService) ApplyProviderRefund(provider, paymentRef, providerRefundID string, amount models.Money, reason string) error {
+	if paymentRef == "" || providerRefundID == "" {
+		return errors.New("refund has no payment or refund reference")
+	}
+
+	var recorded []*models.Refund
+	var freedEvent *uuid.UUID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the tickets serialises notifications for the same payment
+		var tickets []*models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(paymentRefColumn(provider)+" = ?", paymentRef).
+			Order("created_at ASC, id ASC").Find(&tickets).Error; err != nil {
+			return err
+		}
+
+		var known int64
+		if err := tx.Model(&models.Refund{}).
+			Where("provider = ? AND provider_refund_id = ?", provider, providerRefundID).
+			Count(&known).Error; err != nil {
+			return err
+		}
+		if known > 0 {
+			return nil
+		}
+		var inFlight int64
+		if err := tx.Model(&models.Refund{}).
+			Where("provider = ? AND provider_ref = ? AND status = ? AND created_at > ?",
+				provider, paymentRef, models.RefundStatusPending, time.Now().Add(-10*time.Minute)).
+			Count(&inFlight).Error; err != nil {
+			return err
+		}
+		if inFlight > 0 {
+			return errors.New("a refund for this payment is still in progress")
+		}
+		if len(tickets) == 0 {
+			log.Printf("Provider refund %s: no tickets paid with %s (%s), nothing to book", providerRefundID, paymentRef, provider)
+			return nil
+		}
+
+		now := time.Now()
+		remaining := amount
+		record := func(ticket *models.Ticket, itemID *uuid.UUID, share models.Money) error {
+			req := ticketRefund(ticket, share, reason, models.RefundInitiatorProvider, nil)
+			req.Provider, req.ProviderRef = provider, paymentRef
+			req.OrderItemID = itemID
+			r, err := s.refundService.RecordCompleted(tx, req, providerRefundID)
+			if err != nil {
+				return err
+			}
+			recorded = append(recorded, r)
+			return nil
+		}
+
+		for _, t := range tickets {
+			share := min(remaining, t.RefundableAmount())
+			if share <= 0 {
+				continue
+			}
+			remaining -= share
+			updates := map[string]interface{}{
+				"refunded_amount": t.RefundedAmount + share,
+				"refunded_at":     now,
+			}
+			itemStatus := models.OrderItemStatusActive
+			if (t.Status == models.TicketPaid || t.Status == models.TicketDisputed) && t.RefundableAmount()-share <= 0 {
+				if err := t.TransitionTo(tx, models.TicketRefunded,
+					models.StatusChange{Actor: models.StatusActorProvider, Reason: reason, ProviderRef: providerRefundID},
+					updates); err != nil {
+					return err
+				}
+				itemStatus = models.OrderItemStatusRefunded
+				freedEvent = &t.EventID
+			} else if err := tx.Model(t).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := settleTicketItems(tx, t.ID, share, itemStatus); err != nil {
+				return err
+			}
+			if err := record(t, nil, share); err != nil {
+				return err
+			}
+		}
+
+		payment := tickets[0]
+		if remaining > 0 && payment.OrderID != nil {
+			var items []models.OrderItem
+			if err := tx.Where("order_id = ? AND kind = ? AND status = ?", *payment.OrderID, models.OrderItemAddon, models.OrderItemStatusActive).
+				Order("created_at ASC").Find(&items).Error; err != nil {
+				return err
+			}
+			for i := range items {
+				item := &items[i]
+				share := min(remaining, item.RefundableAmount())
+				if share <= 0 {
+					continue
+				}
+				remaining -= share
+				updates := map[string]interface{}{
+					"refunded_amount": item.RefundedAmount + share,
+					"refunded_at":     now,
+				}
+				if item.RefundableAmount()-share <= 0 {
+					updates["status"] = models.OrderItemStatusRefunded
+				}
+				if err := tx.Model(item).Updates(updates).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).Where("id = ?", item.OrderID).
+					Update("refunded_amount", gorm.Expr("refunded_amount + ?", share)).Error; err != nil {
+					return err
+				}
+				if err := record(payment, &item.ID, share); err != nil {
+					return err
+				}
+			}
+		}
+
+		// More than we know to have sold with this payment: the money is gone anyway, so it is booked
+		if remaining > 0 {
+			log.Printf("Provider refund %s: %s EUR exceed what is refundable for %s (%s)", providerRefundID, remaining, paymentRef, provider)
+			return record(payment, nil, remaining)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(recorded) > 0 {
+		log.Printf("Provider refund %s: booked %s EUR of %s (%s) as %s", providerRefundID, amount, paymentRef, provider, reason)
+	}
+	s.refundService.IssueCreditNotes(recorded)
+	if freedEvent != nil {
+		s.offerFreedSpots(*freedEvent)
+	}
+	return nil
+}
+
+// DisputeInput is a chargeback as reported by the payment provider
+type DisputeInput struct {
+	Provider          string
+	ProviderDisputeID string
+	PaymentRef        string
+	Amount            models.Money // in Currency
+	Currency          string
+	Reason            string
+	Status            string
+	EvidenceDueBy     *time.Time
+}
+
+// OpenDispute records a chargeback against a payment and marks the paid tickets of the payment as
+// disputed, which refuses them at check-in. created is false if the dispute is known already; only its
+// provider status is updated then. The dispute is returned with its tickets (and their user and event).
+func (s *TicketService) OpenDispute(in DisputeInput) (dispute *models.Dispute, created bool, err error) {
+	if in.ProviderDisputeID == "" || in.PaymentRef == "" {
+		return nil, false, errors.New("dispute has no dispute or payment reference")
+	}
+
+	dispute = &models.Dispute{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var tickets []*models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(paymentRefColumn(in.Provider)+" = ?", in.PaymentRef).
+			Order("created_at ASC, id ASC").Find(&tickets).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("provider_dispute_id = ?", in.ProviderDisputeID).First(dispute).Error
+		if err == nil {
+			if dispute.ClosedAt == nil && in.Status != "" && in.Status != dispute.Status {
+				return tx.Model(dispute).Update("status", in.Status).Error
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		created = true
+		*dispute = models.Dispute{
+			Provider:          in.Provider,
+			ProviderDisputeID: in.ProviderDisputeID,
+			PaymentRef:        in.PaymentRef,
+			Amount:            in.Amount,
+			Currency:          in.Currency,
+			Reason:            in.Reason,
+			Status:            in.Status,
+			EvidenceDueBy:     in.EvidenceDueBy,
+		}
+		if err := tx.Create(dispute).Error; err != nil {
+			return err
+		}
+
+		for _, t := range tickets {
+			previous := t.Status
+			updates := map[string]interface{}{"dispute_id": dispute.ID}
+			if t.Status == models.TicketPaid {
+				if err := t.TransitionTo(tx, models.TicketDisputed,
+					models.StatusChange{Actor: models.StatusActorProvider, Reason: "dispute_opened", ProviderRef: dispute.ProviderDisputeID},
+					updates); err != nil {
+					return err
+				}
+			} else if err := tx.Model(t).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := recordTicketHistory(tx, t.ID, "dispute_opened", nil, map[string]interface{}{
+				"dispute_id":          dispute.ID,
+				"provider_dispute_id": dispute.ProviderDisputeID,
+				"amount":              dispute.Amount,
+				"reason":              dispute.Reason,
+				"previous_status":     previous,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if created {
+		log.Printf("Dispute %s opened against %s (%s): %s %s, reason %s", in.ProviderDisputeID, in.PaymentRef, in.Provider, in.Amount, in.Currency, in.Reason)
+	}
+	if err := s.db.Preload("Tickets.User").Preload("Tickets.Event").First(dispute, "id = ?", dispute.ID).Error; err != nil {
+		return nil, created, err
+	}
+	return dispute, created, nil
+}
+
+// CloseDispute stores the outcome of a dispute. A lost dispute is booked as a refund of the disputed
+// amount (reason "chargeback"); tickets it does not fully refund, and all tickets of a won dispute, are
+// paid again. status is the final provider status; anything but "lost" counts as won.
+// closed is false if the dispute was closed already; nothing is changed then.
+func (s *TicketService) CloseDispute(providerDisputeID, status string) (dispute *models.Dispute, closed bool, err error) {
+	dispute = &models.Dispute{}
+	if err := s.db.Where("provider_dispute_id = ?", providerDisputeID).First(dispute).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, errors.New("dispute not found")
+		}
+		return nil, false, err
+	}
+	if dispute.ClosedAt != nil {
+		return dispute, false, nil
+	}
+
+	outcome := models.DisputeOutcomeWon
+	if status == "lost" {
+		outcome = models.DisputeOutcomeLost
+		// The bank kept the money; booking it is idempotent by the dispute ID
+		if err := s.ApplyProviderRefund(dispute.Provider, dispute.PaymentRef, dispute.ProviderDisputeID, dispute.Amount, models.RefundReasonChargeback); err != nil {
+			return nil, false, err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&models.Dispute{}).Where("id = ? AND closed_at IS NULL", dispute.ID).Updates(map[string]interface{}{
+			"status":    status,
+			"outcome":   outcome,
+			"closed_at": now,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil // closed concurrently
+		}
+		closed = true
+
+		var tickets []*models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("dispute_id = ?", dispute.ID).Find(&tickets).Error; err != nil {
+			return err
+		}
+		for _, t := range tickets {
+			if t.Status == models.TicketDisputed {
+				if err := t.TransitionTo(tx, models.TicketPaid,
+					models.StatusChange{Actor: models.StatusActorProvider, Reason: "dispute_" + outcome, ProviderRef: dispute.ProviderDisputeID},
+					nil); err != nil {
+					return err
+				}
+			}
+			if err := recordTicketHistory(tx, t.ID, "dispute_"+outcome, nil, map[string]interface{}{
+				"dispute_id":          dispute.ID,
+				"provider_dispute_id": dispute.ProviderDisputeID,
+				"amount":              dispute.Amount,
+				"status":              t.Status,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if closed {
+		log.Printf("Dispute %s against %s (%s) closed: %s", dispute.ProviderDisputeID, dispute.PaymentRef, dispute.Provider, outcome)
 	}
-
-	if result.RowsAffected > 0 {
-		return nil // Success - normal flow
+	if err := s.db.Preload("Tickets.User").Preload("Tickets.Event").First(dispute, "id = ?", dispute.ID).Error; err != nil {
+		return nil, closed, err
 	}
+	return dispute, closed, nil
+}
 
-	// No rows affected - check if ticket is in pending_cancellation (GRACE PERIOD)
-	result = s.db.Model(&models.Ticket{}).
-		Where("id = ? AND status = ?", ticketID, "pending_cancellation").
-		Updates(map[string]interface{}{
-			"status":                   "paid",
-			"stripe_payment_intent_id": paymentIntentID,
-			"cancelled_at":             nil, // Clear cancellation timestamp
-		})
-
-	if result.Error != nil {
-		return result.Error
+// GetDisputes lists disputes, newest first. state filters by open, won or lost; empty lists all.
+func (s *TicketService) GetDisputes(page, limit int, state string) ([]*models.Dispute, int64, error) {
+	var disputes []*models.Dispute
+	var total int64
+
+	query := s.db.Model(&models.Dispute{})
+	switch state {
+	case "":
+	case "open":
+		query = query.Where("closed_at IS NULL")
+	case models.DisputeOutcomeWon, models.DisputeOutcomeLost:
+		query = query.Where("outcome = ?", state)
+	default:
+		return nil, 0, errors.New("invalid dispute state")
 	}
 
-	if result.RowsAffected > 0 {
-		log.Printf("✅ Stripe webhook: Ticket %s reactivated from pending_cancellation to paid (GRACE PERIOD)", ticketID)
-		return nil
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
 	}
 
-	// Ticket not found in pending or pending_cancellation
-	return errors.New("ticket not found or already paid")
-}
-
-// CancelPendingBySystem cancels a pending ticket (e.g., after Stripe session expiration)
-func (s *TicketService) CancelPendingBySystem(ticketID uuid.UUID, reason string) error {
-	updates := map[string]interface{}{
-		"status":       "cancelled",
-		"cancelled_at": time.Now(),
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
 	}
-	return s.db.Model(&models.Ticket{}).Where("id = ? AND status = ?", ticketID, "pending").Updates(updates).Error
+	err := query.Preload("Tickets").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&disputes).Error
+	return disputes, total, err
 }
 
 // RetryPendingCheckout generates a new checkout URL for a pending ticket
@@ -292,6 +1300,17 @@ func (s *TicketService) RetryPendingCheckout(ticketID, userID uuid.UUID) (string
 		return "", "", fmt.Errorf("ticket is not pending (status: %s)", ticket.Status)
 	}
 
+	// The seat may already be given to someone else once the hold ran out
+	if ticket.HoldExpired() {
+		return "", "", errors.New("reservation expired")
+	}
+
+	// The checkout covers the whole order, with the requested ticket as its reference
+	order, err := s.loadCheckoutOrder(&ticket)
+	if err != nil {
+		return "", "", err
+	}
+
 	// Determine payment provider
 	paymentProvider := ticket.PaymentProvider
 	if paymentProvider == "" {
@@ -299,7 +1318,6 @@ func (s *TicketService) RetryPendingCheckout(ticketID, userID uuid.UUID) (string
 	}
 
 	var checkoutURL string
-	var err error
 
 	// Generate new checkout URL based on provider
 	switch paymentProvider {
@@ -307,12 +1325,12 @@ func (s *TicketService) RetryPendingCheckout(ticketID, userID uuid.UUID) (string
 		if s.stripeProvider == nil {
 			return "", "", errors.New("Stripe provider not available")
 		}
-		checkoutURL, err = s.stripeProvider.CreateCheckout(&ticket, &ticket.Event, &ticket.User, ticket.TotalAmount)
+		checkoutURL, err = s.stripeProvider.CreateCheckout(order, &ticket.Event, &ticket.User)
 	case "paypal":
 		if s.paypalProvider == nil {
 			return "", "", errors.New("PayPal is not enabled")
 		}
-		checkoutURL, err = s.paypalProvider.CreateCheckout(&ticket, &ticket.Event, &ticket.User, ticket.TotalAmount)
+		checkoutURL, err = s.paypalProvider.CreateCheckout(order, &ticket.Event, &ticket.User)
 	default:
 		return "", "", fmt.Errorf("unsupported payment provider: %s", paymentProvider)
 	}
@@ -324,19 +1342,46 @@ func (s *TicketService) RetryPendingCheckout(ticketID, userID uuid.UUID) (string
 	return checkoutURL, paymentProvider, nil
 }
 
-// CleanupStalePending cancels tickets that stayed pending longer than the configured TTL
+// loadCheckoutOrder loads the order of a pending ticket with its items and pending tickets, the given ticket first
+func (s *TicketService) loadCheckoutOrder(ticket *models.Ticket) (*models.Order, error) {
+	if ticket.OrderID == nil {
+		return nil, errors.New("order not found")
+	}
+	var order models.Order
+	if err := s.db.Preload("Items").First(&order, "id = ?", *ticket.OrderID).Error; err != nil {
+		return nil, errors.New("order not found")
+	}
+	var others []models.Ticket
+	if err := s.db.Where("order_id = ? AND id <> ? AND status = ?", order.ID, ticket.ID, "pending").
+		Order("created_at ASC").Find(&others).Error; err != nil {
+		return nil, err
+	}
+	order.Tickets = append([]models.Ticket{*ticket}, others...)
+	return &order, nil
+}
+
+// CleanupStalePending cancels pending tickets whose seat hold expired.
+// Tickets created before seat holds existed (no hold_expires_at) fall back to the configured TTL;
+// without a TTL they are left alone.
 func (s *TicketService) CleanupStalePending() (int64, error) {
-	if s.cfg == nil || s.cfg.PendingTicketTTLMinutes <= 0 {
-		return 0, nil
+	now := time.Now()
+	stale := func(db *gorm.DB) *gorm.DB {
+		return db.Where("hold_expires_at < ?", now)
 	}
-	cutoff := time.Now().Add(-time.Duration(s.cfg.PendingTicketTTLMinutes) * time.Minute)
-	res := s.db.Model(&models.Ticket{}).
-		Where("status = ? AND created_at < ?", "pending", cutoff).
-		Updates(map[string]interface{}{
-			"status":       "cancelled",
-			"cancelled_at": time.Now(),
-		})
-	return res.RowsAffected, res.Error
+	if s.cfg != nil && s.cfg.PendingTicketTTLMinutes > 0 {
+		cutoff := now.Add(-time.Duration(s.cfg.PendingTicketTTLMinutes) * time.Minute)
+		stale = func(db *gorm.DB) *gorm.DB {
+			return db.Where("(hold_expires_at < ? OR (hold_expires_at IS NULL AND created_at < ?))", now, cutoff)
+		}
+	}
+	cancelled, err := models.TransitionTickets(s.db, models.TicketCancelled,
+		models.StatusChange{Actor: models.StatusActorSystem, Reason: "hold_expired"},
+		map[string]interface{}{"cancelled_at": now},
+		models.WithTicketStatus(models.TicketPending), stale)
+	if cancelled > 0 {
+		s.processWaitlistOffers()
+	}
+	return cancelled, err
 }
 
 // CleanupPendingCancellations finalizes tickets in "pending_cancellation" after grace period
@@ -345,15 +1390,17 @@ func (s *TicketService) CleanupPendingCancellations() (int64, error) {
 	gracePeriodMinutes := 5 // 5 minutes grace period
 	cutoff := time.Now().Add(-time.Duration(gracePeriodMinutes) * time.Minute)
 
-	res := s.db.Model(&models.Ticket{}).
-		Where("status = ? AND cancelled_at < ?", "pending_cancellation", cutoff).
-		Update("status", "cancelled")
+	finalized, err := models.TransitionTickets(s.db, models.TicketCancelled,
+		models.StatusChange{Actor: models.StatusActorSystem, Reason: "grace_period_ended"}, nil,
+		models.WithTicketStatus(models.TicketPendingCancellation),
+		func(db *gorm.DB) *gorm.DB { return db.Where("cancelled_at < ?", cutoff) })
 
-	if res.RowsAffected > 0 {
-		log.Printf("CleanupPendingCancellations: Finalized %d cancelled tickets after grace period", res.RowsAffected)
+	if finalized > 0 {
+		log.Printf("CleanupPendingCancellations: Finalized %d cancelled tickets after grace period", finalized)
+		s.processWaitlistOffers()
 	}
 
-	return res.RowsAffected, res.Error
+	return finalized, err
 }
 
 // CheckPendingCancellations actively polls payment status for tickets in pending_cancellation
@@ -503,6 +1550,11 @@ func (s *TicketService) CheckPendingPayments() (int64, error) {
 
 // checkStripePaymentStatus checks if a Stripe payment was completed
 func (s *TicketService) checkStripePaymentStatus(ticket *models.Ticket) bool {
+	// Mock checkouts live in memory, not at Stripe
+	if mock := s.MockProvider(); mock != nil {
+		return mock.CheckAndCaptureOrder(ticket)
+	}
+
 	// Get Stripe session
 	sess, err := session.Get(ticket.StripeSessionID, nil)
 	if err != nil {
@@ -522,15 +1574,24 @@ func (s *TicketService) checkStripePaymentStatus(ticket *models.Ticket) bool {
 
 		// Update ticket to paid
 		updates := map[string]interface{}{
-			"status":                   "paid",
 			"cancelled_at":             nil,
 			"stripe_payment_intent_id": paymentIntentID,
 		}
 
-		if err := s.db.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Updates(updates).Error; err != nil {
+		_, refund, err := confirmCheckout(s.db, ticket.ID, "stripe",
+			models.StatusChange{Actor: models.StatusActorProvider, Reason: "stripe_poll", ProviderRef: paymentIntentID},
+			updates, models.TicketPending, models.TicketPendingCancellation)
+		if err != nil {
 			log.Printf("⚠️ Payment check: Failed to update Stripe ticket %s: %v", ticket.ID, err)
 			return false
 		}
+		logLedgerError("Stripe payment", recordOrderPayment(s.db, ticket.ID, "stripe", paymentIntentID))
+		s.recordStripeFee(ticket.ID, paymentIntentID)
+		if refund != nil {
+			log.Printf("⚠️ Payment check: Seats of Stripe ticket %s were taken after its hold expired, refunding", ticket.ID)
+			_ = s.refundService.Execute(refund)
+			return false
+		}
 
 		log.Printf("✅ Payment check: Stripe ticket %s confirmed as paid", ticket.ID)
 		return true
@@ -565,7 +1626,7 @@ func (s *TicketService) ProactiveConfirmPayment(ticketID, userID uuid.UUID, payp
 	// Only confirm pending or pending_cancellation tickets
 	if ticket.Status != "pending" && ticket.Status != "pending_cancellation" {
 		// Already paid or cancelled
-		return false, ticket.Status, nil
+		return false, string(ticket.Status), nil
 	}
 
 	log.Printf("🔍 Proactive confirm: Checking payment for ticket %s (status: %s)", ticketID, ticket.Status)
@@ -597,7 +1658,7 @@ func (s *TicketService) ProactiveConfirmPayment(ticketID, userID uuid.UUID, payp
 
 	// Payment not yet confirmed
 	log.Printf("⏱️ Proactive confirm: Payment not yet confirmed for ticket %s, will retry via polling", ticketID)
-	return false, ticket.Status, nil
+	return false, string(ticket.Status), nil
 }
 
 // CancelTicket cancels a paid ticket and processes a refund, or deletes a pending ticket.
@@ -607,7 +1668,7 @@ func (s *TicketService) CancelTicket(ticketID, userID uuid.UUID) error {
 }
 
 // AdminCancelTicket cancels a ticket by admin without user ownership check
-func (s *TicketService) AdminCancelTicket(ticketID uuid.UUID, mode string) error {
+func (s *TicketService) AdminCancelTicket(ticketID uuid.UUID, mode string, actorID *uuid.UUID) error {
 	var ticket models.Ticket
 
 	// Get ticket without user ownership check
@@ -620,71 +1681,36 @@ func (s *TicketService) AdminCancelTicket(ticketID uuid.UUID, mode string) error
 
 	switch ticket.Status {
 	case "pending":
-		// Delete pending ticket
-		if err := s.db.Delete(&ticket).Error; err != nil {
-			return fmt.Errorf("failed to delete pending ticket: %w", err)
+		// Cancel pending ticket together with the other tickets of its unpaid checkout
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.OrderItem{}).
+				Where("ticket_id IN (?)", tx.Model(&models.Ticket{}).Select("id").Scopes(models.SameCheckout(ticket.ID)).Where("status = ?", "pending")).
+				Update("status", models.OrderItemStatusCancelled).Error; err != nil {
+				return err
+			}
+			_, err := models.TransitionTickets(tx, models.TicketCancelled,
+				models.StatusChange{Actor: models.StatusActorAdmin, ActorID: actorID, Reason: models.RefundReasonAdminCancellation},
+				map[string]interface{}{"cancelled_at": time.Now()},
+				models.SameCheckout(ticket.ID), models.WithTicketStatus(models.TicketPending))
+			return err
+		}); err != nil {
+			return fmt.Errorf("failed to cancel pending ticket: %w", err)
 		}
+		s.offerFreedSpots(ticket.EventID)
 		return nil
 
 	case "paid":
-		// Admin cancellation: always allow, refund handling based on mode
-		days := 14
-		percent := 50
-		policyEnabled := false
-		if s.cfg != nil {
-			policyEnabled = s.cfg.TicketCancellationEnabled
-			if s.cfg.TicketCancellationDays > 0 {
-				days = s.cfg.TicketCancellationDays
-			}
-			if s.cfg.TicketCancellationRefundPercent > 0 {
-				percent = s.cfg.TicketCancellationRefundPercent
-			}
-		}
-
-		refundAmount := 0.0
-		if policyEnabled && mode != "no_refund" {
-			// Check eligibility window
-			daysUntilEvent := time.Until(ticket.Event.DateFrom).Hours() / 24
-			if int(daysUntilEvent) >= days {
-				refundAmount = ticket.TotalAmount * float64(percent) / 100.0
-
-				// Process refund based on payment provider
-				if refundAmount > 0 {
-					if ticket.PaymentProvider == "paypal" && s.paypalProvider != nil && ticket.PayPalCaptureID != "" {
-						// PayPal refund
-						if err := s.paypalProvider.ProcessRefund(&ticket, refundAmount); err != nil {
-							return fmt.Errorf("failed to process PayPal refund: %w", err)
-						}
-					} else if ticket.StripePaymentIntentID != "" {
-						// Stripe refund (default)
-						if _, err := refund.New(&stripe.RefundParams{
-							PaymentIntent: stripe.String(ticket.StripePaymentIntentID),
-							Amount:        stripe.Int64(int64(refundAmount * 100)),
-						}); err != nil {
-							return fmt.Errorf("failed to process Stripe refund: %w", err)
-						}
-					}
-				}
-			} else if mode == "refund" {
-				// Requested refund but not eligible
-				return errors.New("refund_not_eligible")
-			}
-		}
-
-		// Always cancel ticket; only set refund fields if > 0
-		now := time.Now()
-		updates := map[string]interface{}{
-			"status":       "cancelled",
-			"cancelled_at": now,
-		}
-		if refundAmount > 0 {
-			updates["refunded_amount"] = refundAmount
-			updates["refunded_at"] = now
+		// Admin cancellation: always allow, refund per the event's cancellation policy based on mode
+		refundAmount, err := s.cancellationRefund(&ticket, mode)
+		if err != nil {
+			return err
 		}
 
-		if err := s.db.Model(&ticket).Updates(updates).Error; err != nil {
+		// Always cancel ticket; the refund (if any) is recorded with the cancellation
+		if err := s.closeTicket(&ticket, models.TicketCancelled, refundAmount, models.RefundReasonAdminCancellation, models.RefundInitiatorAdmin, actorID); err != nil {
 			return err
 		}
+		s.offerFreedSpots(ticket.EventID)
 		return nil
 
 	default:
@@ -716,73 +1742,28 @@ func (s *TicketService) CancelTicketWithMode(ticketID, userID uuid.UUID, mode st
 		// - After 5 min → Cleanup job sets to "cancelled" permanently
 		//
 		// This prevents: User cancels → Payment completes → User paid but no ticket!
-		now := time.Now()
-		ticket.Status = "pending_cancellation"
-		ticket.CancelledAt = &now
-		if err := s.db.Save(&ticket).Error; err != nil {
+		//
+		// The checkout covers the whole order, so all its pending tickets are cancelled together.
+		if _, err := models.TransitionTickets(s.db, models.TicketPendingCancellation,
+			models.StatusChange{Actor: models.StatusActorUser, ActorID: &userID, Reason: "checkout_cancelled"},
+			map[string]interface{}{"cancelled_at": time.Now()},
+			models.SameCheckout(ticket.ID), models.WithTicketStatus(models.TicketPending)); err != nil {
 			return fmt.Errorf("failed to mark ticket for cancellation: %w", err)
 		}
 		return nil
 
 	case "paid":
-		// Stornierung ist grundsätzlich erlaubt. Refund ist optional per ENV + Frist.
-		days := 14
-		percent := 50
-		policyEnabled := false
-		if s.cfg != nil {
-			policyEnabled = s.cfg.TicketCancellationEnabled
-			if s.cfg.TicketCancellationDays > 0 {
-				days = s.cfg.TicketCancellationDays
-			}
-			if s.cfg.TicketCancellationRefundPercent > 0 {
-				percent = s.cfg.TicketCancellationRefundPercent
-			}
-		}
-
-		refundAmount := 0.0
-		if policyEnabled && mode != "no_refund" {
-			// Prüfe Fristfenster für Refund
-			daysUntilEvent := time.Until(ticket.Event.DateFrom).Hours() / 24
-			if int(daysUntilEvent) >= days {
-				refundAmount = ticket.TotalAmount * float64(percent) / 100.0
-
-				// Process refund based on payment provider
-				if refundAmount > 0 {
-					if ticket.PaymentProvider == "paypal" && s.paypalProvider != nil && ticket.PayPalCaptureID != "" {
-						// PayPal refund
-						if err := s.paypalProvider.ProcessRefund(&ticket, refundAmount); err != nil {
-							return fmt.Errorf("failed to process PayPal refund: %w", err)
-						}
-					} else if ticket.StripePaymentIntentID != "" {
-						// Stripe refund (default)
-						if _, err := refund.New(&stripe.RefundParams{
-							PaymentIntent: stripe.String(ticket.StripePaymentIntentID),
-							Amount:        stripe.Int64(int64(refundAmount * 100)),
-						}); err != nil {
-							return fmt.Errorf("failed to process Stripe refund: %w", err)
-						}
-					}
-				}
-			} else if mode == "refund" {
-				// Gewünschter Refund, aber nicht eligible → Fehler zurück
-				return errors.New("refund_not_eligible")
-			}
-		}
-
-		// Ticket immer stornieren; Refund-Felder nur setzen, wenn > 0
-		now := time.Now()
-		updates := map[string]interface{}{
-			"status":       "cancelled",
-			"cancelled_at": now,
-		}
-		if refundAmount > 0 {
-			updates["refunded_amount"] = refundAmount
-			updates["refunded_at"] = now
+		// Stornierung ist grundsätzlich erlaubt. Die Erstattung richtet sich nach der Stornostaffel des Events.
+		refundAmount, err := s.cancellationRefund(&ticket, mode)
+		if err != nil {
+			return err
 		}
 
-		if err := s.db.Model(&ticket).Updates(updates).Error; err != nil {
+		// Ticket immer stornieren; ein Refund wird zusammen mit der Stornierung erfasst
+		if err := s.closeTicket(&ticket, models.TicketCancelled, refundAmount, models.RefundReasonUserCancellation, models.RefundInitiatorUser, &userID); err != nil {
 			return err
 		}
+		s.offerFreedSpots(ticket.EventID)
 		return nil
 
 	default:
@@ -791,12 +1772,138 @@ func (s *TicketService) CancelTicketWithMode(ticketID, userID uuid.UUID, mode st
 	}
 }
 
+// DefaultCancellationPolicy returns the policy for events without their own: TICKET_CANCELLATION_TIERS,
+// or else a single tier from TICKET_CANCELLATION_DAYS/TICKET_CANCELLATION_REFUND_PERCENT if enabled.
+// An empty policy refunds nothing.
+func (s *TicketService) DefaultCancellationPolicy() models.CancellationPolicy {
+	if s.cfg == nil {
+		return models.CancellationPolicy{}
+	}
+	if s.cfg.TicketCancellationTiers != "" {
+		policy, err := models.ParseCancellationPolicy(s.cfg.TicketCancellationTiers)
+		if err == nil {
+			return policy
+		}
+		log.Printf("Invalid TICKET_CANCELLATION_TIERS, falling back to TICKET_CANCELLATION_DAYS: %v", err)
+	}
+	if !s.cfg.TicketCancellationEnabled {
+		return models.CancellationPolicy{}
+	}
+	days, percent := 14, 50
+	if s.cfg.TicketCancellationDays > 0 {
+		days = s.cfg.TicketCancellationDays
+	}
+	if s.cfg.TicketCancellationRefundPercent > 0 {
+		percent = s.cfg.TicketCancellationRefundPercent
+	}
+	return models.CancellationPolicy{{DaysBefore: days, RefundPercent: percent}}
+}
+
+// CancellationPolicy returns the policy that applies to an event and where it comes from (event or default)
+func (s *TicketService) CancellationPolicy(event *models.Event) (models.CancellationPolicy, string) {
+	if event.CancellationPolicy != nil {
+		return event.CancellationPolicy, "event"
+	}
+	return s.DefaultCancellationPolicy(), "default"
+}
+
+// policyRefund returns what cancelling a paid ticket at the given time refunds under its event's policy
+// (requires Event to be loaded)
+func (s *TicketService) policyRefund(ticket *models.Ticket, at time.Time) (models.Money, int) {
+	policy, _ := s.CancellationPolicy(&ticket.Event)
+	percent := policy.RefundPercentAt(ticket.Event.DateFrom, at)
+	return ticket.RefundableAmount().Percent(percent), percent
+}
+
+// cancellationRefund returns the refund for cancelling a paid ticket now in the given mode:
+// auto refunds per policy, refund requires a refund (refund_not_eligible otherwise), no_refund refunds nothing.
+// Without any policy, refund cancels without a refund like auto, as before policies existed.
+func (s *TicketService) cancellationRefund(ticket *models.Ticket, mode string) (models.Money, error) {
+	if mode == "no_refund" {
+		return 0, nil
+	}
+	if policy, _ := s.CancellationPolicy(&ticket.Event); len(policy) == 0 {
+		return 0, nil
+	}
+	refundAmount, percent := s.policyRefund(ticket, time.Now())
+	if percent == 0 && mode == "refund" {
+		return 0, errors.New("refund_not_eligible")
+	}
+	return refundAmount, nil
+}
+
+// CancellationQuote is what cancelling a ticket now would refund
+type CancellationQuote struct {
+	TicketID         uuid.UUID           `json:"ticket_id"`
+	Status           models.TicketStatus `json:"status"`
+	CanCancel        bool                `json:"can_cancel"`
+	RefundableAmount models.Money        `json:"refundable_amount"`
+	RefundPercent    int                 `json:"refund_percent"`
+	RefundAmount     models.Money        `json:"refund_amount"`
+	EventStart       time.Time           `json:"event_start"`
+	// Policy is the applying policy, from the event itself or the default policy (PolicySource event|default)
+	Policy       models.CancellationPolicy `json:"policy"`
+	PolicySource string                    `json:"policy_source"`
+	// RefundPercentUntil is when the current refund percentage ends; NextRefundPercent applies afterwards
+	RefundPercentUntil *time.Time `json:"refund_percent_until,omitempty"`
+	NextRefundPercent  *int       `json:"next_refund_percent,omitempty"`
+}
+
+// QuoteCancellation returns what the user would get back for cancelling their ticket now
+func (s *TicketService) QuoteCancellation(ticketID, userID uuid.UUID) (*CancellationQuote, error) {
+	var ticket models.Ticket
+	if err := s.db.Preload("Event").Where("id = ? AND user_id = ?", ticketID, userID).First(&ticket).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("ticket not found")
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	policy, source := s.CancellationPolicy(&ticket.Event)
+	quote := &CancellationQuote{
+		TicketID:     ticket.ID,
+		Status:       ticket.Status,
+		CanCancel:    ticket.Status == "paid" || ticket.Status == "pending",
+		EventStart:   ticket.Event.DateFrom,
+		Policy:       policy,
+		PolicySource: source,
+	}
+	if ticket.Status != "paid" {
+		return quote, nil
+	}
+
+	quote.RefundableAmount = ticket.RefundableAmount()
+	quote.RefundAmount, quote.RefundPercent = s.policyRefund(&ticket, now)
+	if tier := policy.TierAt(ticket.Event.DateFrom, now); tier != nil {
+		until := ticket.Event.DateFrom.Add(-time.Duration(tier.DaysBefore) * 24 * time.Hour)
+		next := 0
+		for _, t := range policy {
+			if t.DaysBefore < tier.DaysBefore && t.RefundPercent != tier.RefundPercent {
+				next = t.RefundPercent
+				break
+			}
+			if t.DaysBefore < tier.DaysBefore {
+				tier = &models.CancellationTier{DaysBefore: t.DaysBefore, RefundPercent: t.RefundPercent}
+				until = ticket.Event.DateFrom.Add(-time.Duration(t.DaysBefore) * 24 * time.Hour)
+			}
+		}
+		quote.RefundPercentUntil, quote.NextRefundPercent = &until, &next
+	}
+	return quote, nil
+}
+
 // RefundTicket processes a full refund for a ticket (admin action)
-func (s *TicketService) RefundTicket(ticketID uuid.UUID, fullRefund bool) error {
+func (s *TicketService) RefundTicket(ticketID uuid.UUID, fullRefund bool, actorID *uuid.UUID) error {
+	return s.refundTicket(ticketID, fullRefund, models.RefundReasonAdminRefund, models.RefundInitiatorAdmin, actorID)
+}
+
+// refundTicket refunds a paid ticket and records the refund with the given reason and initiator
+func (s *TicketService) refundTicket(ticketID uuid.UUID, fullRefund bool, reason, initiator string, actorID *uuid.UUID) error {
 	var ticket models.Ticket
 
 	// Get ticket
-	if err := s.db.First(&ticket, ticketID).Error; err != nil {
+	if err := s.db.Preload("Event").First(&ticket, ticketID).Error; err != nil {
 		return errors.New("ticket not found")
 	}
 
@@ -804,40 +1911,54 @@ func (s *TicketService) RefundTicket(ticketID uuid.UUID, fullRefund bool) error
 		return errors.New("only paid tickets can be refunded")
 	}
 
-	// Calculate refund amount
-	refundAmount := ticket.GetRefundAmount(fullRefund)
-
-	// Process refund based on payment provider
-	if ticket.PaymentProvider == "paypal" && s.paypalProvider != nil {
-		// PayPal refund
-		if err := s.paypalProvider.ProcessRefund(&ticket, refundAmount); err != nil {
-			return fmt.Errorf("failed to process PayPal refund: %w", err)
-		}
-	} else {
-		// Stripe refund (default/fallback)
-		if ticket.StripePaymentIntentID != "" {
-			_, err := refund.New(&stripe.RefundParams{
-				PaymentIntent: stripe.String(ticket.StripePaymentIntentID),
-				Amount:        stripe.Int64(int64(refundAmount * 100)), // Convert to cents
-			})
-			if err != nil {
-				return fmt.Errorf("failed to process Stripe refund: %w", err)
-			}
-		}
+	// Calculate refund amount (based on what was actually paid; free tickets have nothing to refund).
+	// A partial refund follows the event's cancellation policy.
+	refundAmount := ticket.RefundableAmount()
+	if !fullRefund {
+		refundAmount, _ = s.policyRefund(&ticket, time.Now())
 	}
 
-	// Update ticket status
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":          "refunded",
-		"refunded_amount": refundAmount,
-		"refunded_at":     now,
-	}
+	return s.closeTicket(&ticket, models.TicketRefunded, refundAmount, reason, initiator, actorID)
+}
 
-	if err := s.db.Model(&ticket).Updates(updates).Error; err != nil {
+// closeTicket moves a paid ticket to "cancelled" or "refunded", settles its order items and records
+// the refund in one transaction, then sends the refund to the provider. A failed provider refund
+// leaves the ticket closed; the refund stays failed until it is retried.
+func (s *TicketService) closeTicket(ticket *models.Ticket, status models.TicketStatus, refundAmount models.Money, reason, initiator string, actorID *uuid.UUID) error {
+	now := time.Now()
+	updates := map[string]interface{}{}
+	itemStatus := models.OrderItemStatusRefunded
+	if status == models.TicketCancelled {
+		updates["cancelled_at"] = now
+		itemStatus = models.OrderItemStatusCancelled
+	}
+	if refundAmount > 0 || status == models.TicketRefunded {
+		updates["refunded_amount"] = ticket.RefundedAmount + refundAmount
+		updates["refunded_at"] = now
+	}
+
+	var pending *models.Refund
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Guard against a concurrent cancellation refunding the same ticket twice
+		closed, err := models.TransitionTickets(tx, status,
+			models.StatusChange{Actor: initiator, ActorID: actorID, Reason: reason},
+			updates, models.WithTicketID(ticket.ID), models.WithTicketStatus(ticket.Status))
+		if err != nil {
+			return err
+		}
+		if closed == 0 {
+			return errors.New("ticket status has changed, please try again")
+		}
+		if err := settleTicketItems(tx, ticket.ID, refundAmount, itemStatus); err != nil {
+			return err
+		}
+		pending, err = s.refundService.Record(tx, ticketRefund(ticket, refundAmount, reason, initiator, actorID))
+		return err
+	}); err != nil {
 		return err
 	}
 
+	_ = s.refundService.Execute(pending)
 	return nil
 }
 
@@ -879,65 +2000,141 @@ func (s *TicketService) GetEventTickets(eventID uuid.UUID) ([]*models.Ticket, er
 	return tickets, err
 }
 
-// CancelAllTicketsForEvent cancels all tickets of an event.
-// If refundPaid is true, paid tickets are fully refunded and marked refunded; otherwise they are marked cancelled without refund.
-func (s *TicketService) CancelAllTicketsForEvent(eventID uuid.UUID, refundPaid bool) error {
-	var tickets []*models.Ticket
-	if err := s.db.Where("event_id = ? AND status IN ?", eventID, []string{"pending", "paid"}).Find(&tickets).Error; err != nil {
-		return err
+// CancelTicketForEvent cancels one ticket of a cancelled event. Unpaid tickets are cancelled; paid tickets
+// are fully refunded if refundPaid is true, otherwise cancelled without refund. A refund is sent to the
+// provider right away; a failed one is kept for retry. Tickets closed already (e.g. by an interrupted
+// cancellation) are left alone. Returns the ticket and the event cancellation refund of the ticket, if any.
+func (s *TicketService) CancelTicketForEvent(ticketID uuid.UUID, refundPaid bool) (*models.Ticket, *models.Refund, error) {
+	var ticket models.Ticket
+	if err := s.db.First(&ticket, "id = ?", ticketID).Error; err != nil {
+		return nil, nil, err
 	}
 
-	now := time.Now()
-	for _, t := range tickets {
-		switch t.Status {
-		case "pending":
-			// historisieren statt löschen
-			updates := map[string]interface{}{
-				"status":       "cancelled",
-				"cancelled_at": now,
+	eventCancelled := models.StatusChange{Actor: models.StatusActorSystem, Reason: models.RefundReasonEventCancelled}
+	cancel := func() error {
+		// historisieren statt löschen
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			if err := ticket.TransitionTo(tx, models.TicketCancelled, eventCancelled, map[string]interface{}{"cancelled_at": time.Now()}); err != nil {
+				return err
+			}
+			return settleTicketItems(tx, ticket.ID, 0, models.OrderItemStatusCancelled)
+		})
+	}
+
+	switch ticket.Status {
+	case models.TicketPending, models.TicketPendingCancellation:
+		if err := cancel(); err != nil {
+			return &ticket, nil, err
+		}
+	case models.TicketPaid:
+		if refundPaid {
+			// full refund of what is left
+			if err := s.closeTicket(&ticket, models.TicketRefunded, ticket.RefundableAmount(), models.RefundReasonEventCancelled, models.RefundInitiatorSystem, nil); err != nil {
+				return &ticket, nil, err
 			}
-			if err := s.db.Model(&models.Ticket{}).Where("id = ?", t.ID).Updates(updates).Error; err != nil {
+			ticket.Status = models.TicketRefunded
+		} else if err := cancel(); err != nil {
+			return &ticket, nil, err
+		}
+	case models.TicketCancelled, models.TicketRefunded:
+	default:
+		return &ticket, nil, fmt.Errorf("ticket is %s and cannot be cancelled", ticket.Status)
+	}
+
+	var refund models.Refund
+	err := s.db.Where("ticket_id = ? AND reason = ? AND order_item_id IS NULL", ticket.ID, models.RefundReasonEventCancelled).
+		Order("created_at DESC").First(&refund).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &ticket, nil, nil
+	}
+	if err != nil {
+		return &ticket, nil, err
+	}
+	return &ticket, &refund, nil
+}
+
+// RefundEventAddons refunds the add-ons still active in paid orders of a cancelled event; add-ons are only
+// useful at the event. A failed add-on does not stop the others; all failures are returned together.
+func (s *TicketService) RefundEventAddons(eventID uuid.UUID) error {
+	var items []models.OrderItem
+	if err := s.db.Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.event_id = ? AND order_items.kind = ? AND order_items.status = ?", eventID, models.OrderItemAddon, models.OrderItemStatusActive).
+		Find(&items).Error; err != nil {
+		return err
+	}
+
+	var failed []error
+	for i := range items {
+		item := &items[i]
+		var order models.Order
+		if err := s.db.Preload("Tickets").First(&order, "id = ?", item.OrderID).Error; err != nil {
+			failed = append(failed, err)
+			continue
+		}
+		payment := order.PaymentTicket()
+		if payment == nil {
+			// Never paid (pending order or fully discounted); nothing to give back
+			if err := s.db.Model(item).Update("status", models.OrderItemStatusCancelled).Error; err != nil {
 				return err
 			}
-		case "paid":
-			if refundPaid {
-				// full refund - support both Stripe and PayPal
-				if t.PaymentProvider == "paypal" && s.paypalProvider != nil && t.PayPalCaptureID != "" {
-					// PayPal refund
-					if err := s.paypalProvider.ProcessRefund(t, t.TotalAmount); err != nil {
-						return fmt.Errorf("failed to refund PayPal ticket %s: %w", t.ID, err)
-					}
-				} else if t.StripePaymentIntentID != "" {
-					// Stripe refund (default)
-					_, err := refund.New(&stripe.RefundParams{
-						PaymentIntent: stripe.String(t.StripePaymentIntentID),
-						Amount:        stripe.Int64(int64(t.TotalAmount * 100)),
-					})
-					if err != nil {
-						return fmt.Errorf("failed to refund Stripe ticket %s: %w", t.ID, err)
-					}
-				}
-				updates := map[string]interface{}{
-					"status":          "refunded",
-					"refunded_amount": t.TotalAmount,
-					"refunded_at":     now,
-				}
-				if err := s.db.Model(&models.Ticket{}).Where("id = ?", t.ID).Updates(updates).Error; err != nil {
-					return err
-				}
-			} else {
-				// cancel without refund
-				updates := map[string]interface{}{
-					"status":       "cancelled",
-					"cancelled_at": now,
-				}
-				if err := s.db.Model(&models.Ticket{}).Where("id = ?", t.ID).Updates(updates).Error; err != nil {
-					return err
-				}
+			continue
+		}
+		if err := s.refundOrderItem(&order, payment, item, models.RefundReasonEventCancelled, models.RefundInitiatorSystem, nil); err != nil {
+			failed = append(failed, fmt.Errorf("failed to refund add-on %s: %w", item.ID, err))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// refundOrderItem refunds a pickup or add-on item of an order through the payment carried by the given ticket.
+// A refunded pickup is removed from its ticket; ticket items are refunded with RefundTicket instead.
+func (s *TicketService) refundOrderItem(order *models.Order, payment *models.Ticket, item *models.OrderItem, reason, initiator string, actorID *uuid.UUID) error {
+	amount := item.RefundableAmount()
+	if amount > 0 && payment == nil {
+		return errors.New("order has not been paid")
+	}
+
+	now := time.Now()
+	var pending *models.Refund
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(item).Where("status = ?", models.OrderItemStatusActive).Updates(map[string]interface{}{
+			"status":          models.OrderItemStatusRefunded,
+			"refunded_amount": item.RefundedAmount + amount,
+			"refunded_at":     now,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("order item has already been refunded or cancelled")
+		}
+		if item.Kind == models.OrderItemPickup && item.TicketID != nil {
+			if err := tx.Model(&models.Ticket{}).Where("id = ?", *item.TicketID).Updates(map[string]interface{}{
+				"includes_pickup": false,
+				"refunded_amount": gorm.Expr("refunded_amount + ?", amount),
+				"refunded_at":     now,
+			}).Error; err != nil {
+				return err
 			}
 		}
+		if err := tx.Model(order).Update("refunded_amount", gorm.Expr("refunded_amount + ?", amount)).Error; err != nil {
+			return err
+		}
+		if amount <= 0 {
+			return nil
+		}
+		req := ticketRefund(payment, amount, reason, initiator, actorID)
+		req.OrderID = &order.ID
+		req.TicketID = item.TicketID
+		req.OrderItemID = &item.ID
+		var err error
+		pending, err = s.refundService.Record(tx, req)
+		return err
+	}); err != nil {
+		return err
 	}
 
+	_ = s.refundService.Execute(pending)
 	return nil
 }
 
@@ -956,7 +2153,7 @@ func (s *TicketService) RefundEventTickets(eventID uuid.UUID) error {
 
 	// Process refunds for each ticket
 	for _, ticket := range tickets {
-		if err := s.RefundTicket(ticket.ID, true); err != nil {
+		if err := s.refundTicket(ticket.ID, true, models.RefundReasonEventCancelled, models.RefundInitiatorSystem, nil); err != nil {
 			// Log error but continue with other refunds
 			fmt.Printf("Failed to refund ticket %s: %v\n", ticket.ID, err)
 		}
@@ -966,121 +2163,194 @@ func (s *TicketService) RefundEventTickets(eventID uuid.UUID) error {
 }
 
 // CreateTicketWithProvider creates a ticket with a specific payment provider (stripe or paypal)
-// This is the NEW function that supports both providers in parallel
-func (s *TicketService) CreateTicketWithProvider(userID, eventID uuid.UUID, includesPickup bool, pickupAddress, paymentProvider string) (*models.Ticket, string, error) {
+// This is the NEW function that supports both providers in parallel.
+// The returned ticket holds a seat until ticket.HoldExpiresAt.
+// ticketReq.TicketTypeID may be uuid.Nil to pick the first ticket type available to the user; promoCode may be empty.
+// A ticket that costs nothing after the discount is confirmed right away and no checkout URL is returned.
+func (s *TicketService) CreateTicketWithProvider(userID, eventID uuid.UUID, ticketReq OrderTicketRequest, promoCode, paymentProvider string) (*models.Ticket, string, error) {
+	order, checkoutURL, err := s.CreateOrderWithProvider(userID, &OrderRequest{
+		EventID:         eventID,
+		Tickets:         []OrderTicketRequest{ticketReq},
+		PromoCode:       promoCode,
+		PaymentProvider: paymentProvider,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &order.Tickets[0], checkoutURL, nil
+}
+
+// CreateOrderWithProvider books the tickets and add-ons of an order with one checkout of the given provider.
+// The tickets hold their seats until HoldExpiresAt; order.Tickets[0] is the reference of the checkout.
+// An order that costs nothing after discounts is confirmed right away and no checkout URL is returned.
+func (s *TicketService) CreateOrderWithProvider(userID uuid.UUID, req *OrderRequest) (*models.Order, string, error) {
 	// Validate payment provider
-	if paymentProvider != "stripe" && paymentProvider != "paypal" {
+	if req.PaymentProvider != "stripe" && req.PaymentProvider != "paypal" {
 		return nil, "", errors.New("invalid payment provider; must be 'stripe' or 'paypal'")
 	}
 
 	// Check if PayPal is requested but not enabled
-	if paymentProvider == "paypal" && s.paypalProvider == nil {
+	if req.PaymentProvider == "paypal" && s.paypalProvider == nil {
 		return nil, "", errors.New("PayPal is not enabled")
 	}
 
-	// Check if user already has a ticket for this event
-	var existingTicket models.Ticket
-	err := s.db.Where("user_id = ? AND event_id = ? AND status IN ?", userID, eventID, []string{"pending", "paid"}).First(&existingTicket).Error
-	if err == nil {
-		return nil, "", errors.New("user already has a ticket for this event")
-	}
-
-	// Get event details
-	var event models.Event
-	if err := s.db.First(&event, eventID).Error; err != nil {
-		return nil, "", errors.New("event not found")
-	}
-
-	// Get user for group
-	var user models.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		return nil, "", errors.New("user not found")
-	}
+	return s.createOrderWithCheckout(userID, req)
+}
 
-	// Enforce allowed_group
-	if event.AllowedGroup == "guests" && user.Group != "guests" {
-		return nil, "", errors.New("event not available for your group")
+// ClaimWaitlistOffer books the spot offered to the user via a waitlist claim token.
+// The returned ticket holds the seat until ticket.HoldExpiresAt like a regular booking.
+func (s *TicketService) ClaimWaitlistOffer(userID uuid.UUID, token string, ticketReq OrderTicketRequest, promoCode, paymentProvider string) (*models.Ticket, string, error) {
+	if s.waitlistService == nil {
+		return nil, "", errors.New("waitlist is not enabled")
 	}
-	if event.AllowedGroup == "bubble" && user.Group != "bubble" {
-		return nil, "", errors.New("event not available for your group")
-	}
-	if event.AllowedGroup == "plus" && user.Group != "plus" {
-		return nil, "", errors.New("event not available for your group")
+	offer, err := s.waitlistService.GetOfferByToken(userID, token)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Check availability
-	availableSpots := event.GetAvailableSpots(s.db)
-	if availableSpots <= 0 {
-		return nil, "", errors.New("event is fully booked")
+	order, checkoutURL, err := s.CreateOrderWithProvider(userID, &OrderRequest{
+		EventID:         offer.EventID,
+		Tickets:         []OrderTicketRequest{ticketReq},
+		PromoCode:       promoCode,
+		PaymentProvider: paymentProvider,
+		WaitlistToken:   token,
+	})
+	if err != nil {
+		return nil, "", err
 	}
+	return &order.Tickets[0], checkoutURL, nil
+}
 
-	// Determine base price based on user group and event prices
-	basePrice := event.GuestsPrice
-	if user.Group == "bubble" {
-		basePrice = event.BubblePrice
-	}
-	if user.Group == "plus" {
-		basePrice = event.PlusPrice
+// createOrderWithCheckout reserves the seats of an order and opens a checkout with the given provider
+func (s *TicketService) createOrderWithCheckout(userID uuid.UUID, req *OrderRequest) (*models.Order, string, error) {
+	order, event, user, err := s.reserveOrder(userID, req)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Get pickup service price
-	pickupPrice := 0.0
-	if includesPickup {
-		var setting models.SystemSetting
-		if err := s.db.Where("key = ?", "pickup_service_price").First(&setting).Error; err == nil {
-			fmt.Sscanf(setting.Value, "%f", &pickupPrice)
+	// Fully discounted: nothing to charge, providers reject zero-amount checkouts
+	if order.TotalAmount <= 0 {
+		if _, err := models.TransitionTickets(s.db, models.TicketPaid,
+			models.StatusChange{Actor: models.StatusActorUser, ActorID: &userID, Reason: "free_order"}, nil,
+			func(db *gorm.DB) *gorm.DB { return db.Where("order_id = ?", order.ID) }); err != nil {
+			return nil, "", err
 		}
+		for i := range order.Tickets {
+			order.Tickets[i].Status = models.TicketPaid
+		}
+		return order, "", nil
 	}
 
-	// Create ticket
-	ticket := &models.Ticket{
-		UserID:          userID,
-		EventID:         eventID,
-		Status:          "pending",
-		Price:           basePrice,
-		IncludesPickup:  includesPickup,
-		PickupPrice:     pickupPrice,
-		PickupAddress:   pickupAddress,
-		PaymentProvider: paymentProvider,
+	// Create checkout session with selected provider
+	checkoutURL, err := s.providerFor(req.PaymentProvider).CreateCheckout(order, event, user)
+	if err != nil {
+		// Delete order if checkout creation fails (releases the seats)
+		s.deleteOrder(order.ID)
+		if req.WaitlistToken != "" {
+			// Give the offer back so the user can retry with the same link
+			s.db.Model(&models.WaitlistEntry{}).
+				Where("offer_token = ? AND status = ?", req.WaitlistToken, models.WaitlistStatusClaimed).
+				Updates(map[string]interface{}{"status": models.WaitlistStatusOffered, "claimed_at": nil})
+		}
+		return nil, "", fmt.Errorf("failed to create checkout: %w", err)
 	}
-	ticket.CalculateTotalAmount()
 
-	// Save ticket
-	if err := s.db.Create(ticket).Error; err != nil {
-		return nil, "", err
-	}
+	return order, checkoutURL, nil
+}
 
-	// Create checkout session with selected provider
-	var checkoutURL string
-	var provider PaymentProvider
+// deleteOrder removes an order whose checkout could not be created, with its tickets and items
+func (s *TicketService) deleteOrder(orderID uuid.UUID) {
+	s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ?", orderID).Delete(&models.OrderItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", orderID).Delete(&models.Ticket{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", orderID).Delete(&models.Order{}).Error
+	})
+}
 
-	if paymentProvider == "paypal" {
-		provider = s.paypalProvider
-	} else {
-		provider = s.stripeProvider
-	}
+// GetOrderTickets returns the given ticket and the other tickets of its order, with user and event loaded
+func (s *TicketService) GetOrderTickets(ticketID uuid.UUID) ([]*models.Ticket, error) {
+	var tickets []*models.Ticket
+	err := s.db.Preload("User").Preload("Event").
+		Scopes(models.SameCheckout(ticketID)).
+		Order("created_at ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
 
-	checkoutURL, err = provider.CreateCheckout(ticket, &event, &user, ticket.TotalAmount)
+// MarkCheckoutPaid marks a ticket and the other unpaid tickets of its order as paid with a PayPal capture
+// (change.ProviderRef), together with the payment fields in updates. Cancelled tickets are reactivated, as
+// their buyer paid after all, unless their seats were given away meanwhile: then the capture is refunded.
+func (s *TicketService) MarkCheckoutPaid(ticketID uuid.UUID, change models.StatusChange, updates map[string]interface{}) error {
+	_, refund, err := confirmCheckout(s.db, ticketID, "paypal", change, updates,
+		models.TicketPending, models.TicketPendingCancellation, models.TicketCancelled)
 	if err != nil {
-		// Delete ticket if checkout creation fails
-		s.db.Delete(ticket)
-		return nil, "", fmt.Errorf("failed to create checkout: %w", err)
+		return err
 	}
+	if refund != nil {
+		log.Printf("PayPal webhook: seats of ticket %s were taken after its hold expired, refunding capture %s", ticketID, change.ProviderRef)
+		_ = s.refundService.Execute(refund)
+	}
+	return nil
+}
+
+// UpdatePayPalCaptureID updates the PayPal capture ID of a ticket and the other tickets of its order
+func (s *TicketService) UpdatePayPalCaptureID(ticketID uuid.UUID, captureID string) error {
+	return s.db.Model(&models.Ticket{}).Scopes(models.SameCheckout(ticketID)).Update("paypal_capture_id", captureID).Error
+}
 
-	return ticket, checkoutURL, nil
+// recordTicketHistory appends an entry to the history of a ticket
+func recordTicketHistory(tx *gorm.DB, ticketID uuid.UUID, action string, actorID *uuid.UUID, details map[string]interface{}) error {
+	entry := models.TicketHistory{
+		TicketID: ticketID,
+		Action:   action,
+		ActorID:  actorID,
+	}
+	if details != nil {
+		detailsJSON, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		entry.Details = string(detailsJSON)
+	}
+	return tx.Create(&entry).Error
 }
 
-// UpdateTicketStatus updates the status of a ticket
-func (s *TicketService) UpdateTicketStatus(ticketID uuid.UUID, status string) error {
-	return s.db.Model(&models.Ticket{}).Where("id = ?", ticketID).Update("status", status).Error
+// GetTicketHistory returns the history of a ticket, oldest first
+func (s *TicketService) GetTicketHistory(ticketID uuid.UUID) ([]*models.TicketHistory, error) {
+	var entries []*models.TicketHistory
+	err := s.db.Where("ticket_id = ?", ticketID).Order("created_at ASC").Find(&entries).Error
+	return entries, err
 }
 
-// UpdateTicket updates multiple fields of a ticket
-func (s *TicketService) UpdateTicket(ticketID uuid.UUID, updates map[string]interface{}) error {
-	return s.db.Model(&models.Ticket{}).Where("id = ?", ticketID).Updates(updates).Error
+// GetStatusHistory returns the status changes of a ticket, oldest first
+func (s *TicketService) GetStatusHistory(ticketID uuid.UUID) ([]*models.TicketStatusHistory, error) {
+	var entries []*models.TicketStatusHistory
+	err := s.db.Where("ticket_id = ?", ticketID).Order("created_at ASC").Find(&entries).Error
+	return entries, err
 }
 
-// UpdatePayPalCaptureID updates the PayPal capture ID for a ticket
-func (s *TicketService) UpdatePayPalCaptureID(ticketID uuid.UUID, captureID string) error {
-	return s.db.Model(&models.Ticket{}).Where("id = ?", ticketID).Update("paypal_capture_id", captureID).Error
+// GetUserStatusHistory returns the status changes of a ticket the user owns. Which admin made a change is not shown.
+func (s *TicketService) GetUserStatusHistory(ticketID, userID uuid.UUID) ([]*models.TicketStatusHistory, error) {
+	var count int64
+	if err := s.db.Model(&models.Ticket{}).Where("id = ? AND user_id = ?", ticketID, userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("ticket not found")
+	}
+
+	entries, err := s.GetStatusHistory(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.ActorID != nil && *e.ActorID != userID {
+			e.ActorID = nil
+		}
+	}
+	return entries, nil
 }
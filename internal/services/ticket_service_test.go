@@ -0,0 +1,220 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"github.com/synesthesie/backend/internal/testutil"
+)
+
+// TestReserveOrderConcurrentBookingsHoldCapacity lets hundreds of buyers race for the last two seats
+func TestReserveOrderConcurrentBookingsHoldCapacity(t *testing.T) {
+	db := testutil.OpenDB(t)
+	s := NewTicketService(db, testutil.Config())
+
+	const capacity, left, buyers = 50, 2, 300
+	event := testutil.CreateEvent(t, db, capacity, 2000)
+	for i := 0; i < capacity-left; i++ {
+		buyer := testutil.CreateUser(t, db, "guests")
+		if _, _, _, err := s.reserveOrder(buyer.ID, &OrderRequest{EventID: event.ID, Tickets: []OrderTicketRequest{{}}, PaymentProvider: "stripe"}); err != nil {
+			t.Fatalf("booking seat %d: %v", i+1, err)
+		}
+	}
+	users := make([]*models.User, buyers)
+	for i := range users {
+		users[i] = testutil.CreateUser(t, db, "guests")
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		booked int
+	)
+	start := make(chan struct{})
+	for _, user := range users {
+		wg.Add(1)
+		go func(userID uuid.UUID) {
+			defer wg.Done()
+			<-start
+			_, _, _, err := s.reserveOrder(userID, &OrderRequest{
+				EventID:         event.ID,
+				Tickets:         []OrderTicketRequest{{}},
+				PaymentProvider: "stripe",
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				booked++
+			case err.Error() != "event is fully booked":
+				t.Errorf("unexpected booking error: %v", err)
+			}
+		}(user.ID)
+	}
+	close(start)
+	wg.Wait()
+
+	if booked != left {
+		t.Errorf("booked %d orders, want %d", booked, left)
+	}
+	var held int64
+	if err := db.Model(&models.Ticket{}).Where("event_id = ? AND status = ?", event.ID, models.TicketPending).
+		Count(&held).Error; err != nil {
+		t.Fatal(err)
+	}
+	if held != capacity {
+		t.Errorf("%d seats held, want %d", held, capacity)
+	}
+	if spots := event.GetAvailableSpots(db); spots != 0 {
+		t.Errorf("%d spots available, want 0", spots)
+	}
+}
+
+// expireHold lets the seat hold of a ticket run out
+func expireHold(t *testing.T, s *TicketService, ticket *models.Ticket) {
+	t.Helper()
+	if err := s.db.Model(ticket).Update("hold_expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfirmPaymentAfterHoldExpired(t *testing.T) {
+	db := testutil.OpenDB(t)
+	s := NewTicketService(db, testutil.Config())
+
+	t.Run("seat still free", func(t *testing.T) {
+		event := testutil.CreateEvent(t, db, 1, 2000)
+		buyer := testutil.CreateUser(t, db, "guests")
+		order, _, _, err := s.reserveOrder(buyer.ID, &OrderRequest{EventID: event.ID, Tickets: []OrderTicketRequest{{}}, PaymentProvider: "stripe"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ticket := &order.Tickets[0]
+		expireHold(t, s, ticket)
+		if _, err := s.CleanupStalePending(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.ConfirmPayment(ticket.ID, "pi_late_"+uuid.NewString()); err != nil {
+			t.Fatalf("ConfirmPayment: %v", err)
+		}
+		got, err := s.GetTicketByID(ticket.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != models.TicketPaid {
+			t.Errorf("status %s, want paid", got.Status)
+		}
+	})
+
+	t.Run("seat taken", func(t *testing.T) {
+		event := testutil.CreateEvent(t, db, 1, 2000)
+		late, other := testutil.CreateUser(t, db, "guests"), testutil.CreateUser(t, db, "guests")
+		order, _, _, err := s.reserveOrder(late.ID, &OrderRequest{EventID: event.ID, Tickets: []OrderTicketRequest{{}}, PaymentProvider: "stripe"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ticket := &order.Tickets[0]
+		expireHold(t, s, ticket)
+		if _, _, _, err := s.reserveOrder(other.ID, &OrderRequest{EventID: event.ID, Tickets: []OrderTicketRequest{{}}, PaymentProvider: "stripe"}); err != nil {
+			t.Fatalf("second booking: %v", err)
+		}
+
+		paymentRef := "pi_late_" + uuid.NewString()
+		if err := s.ConfirmPayment(ticket.ID, paymentRef); err != nil {
+			t.Fatalf("ConfirmPayment: %v", err)
+		}
+		got, err := s.GetTicketByID(ticket.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != models.TicketCancelled {
+			t.Errorf("status %s, want cancelled", got.Status)
+		}
+		var refunds []models.Refund
+		if err := db.Where("provider_ref = ?", paymentRef).Find(&refunds).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(refunds) != 1 || refunds[0].Reason != models.RefundReasonSeatUnavailable || refunds[0].Amount != order.TotalAmount {
+			t.Fatalf("refunds %+v, want one seat_unavailable refund of %s", refunds, order.TotalAmount)
+		}
+
+		// A repeated webhook neither reactivates the ticket nor refunds again
+		if err := s.ConfirmPayment(ticket.ID, paymentRef); err != nil {
+			t.Fatalf("repeated ConfirmPayment: %v", err)
+		}
+		var count int64
+		db.Model(&models.Refund{}).Where("provider_ref = ?", paymentRef).Count(&count)
+		if count != 1 {
+			t.Errorf("%d refunds after a repeated webhook, want 1", count)
+		}
+		if spots := event.GetAvailableSpots(db); spots != 0 {
+			t.Errorf("%d spots available, want 0", spots)
+		}
+	})
+}
+
+func TestCleanupStalePendingWithoutTTL(t *testing.T) {
+	db := testutil.OpenDB(t)
+	cfg := testutil.Config()
+	cfg.PendingTicketTTLMinutes = 0
+	s := NewTicketService(db, cfg)
+
+	event := testutil.CreateEvent(t, db, 2, 2000)
+	buyer := testutil.CreateUser(t, db, "guests")
+	order, _, _, err := s.reserveOrder(buyer.ID, &OrderRequest{EventID: event.ID, Tickets: []OrderTicketRequest{{}}, PaymentProvider: "stripe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket := &order.Tickets[0]
+	expireHold(t, s, ticket)
+
+	if _, err := s.CleanupStalePending(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetTicketByID(ticket.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.TicketCancelled {
+		t.Errorf("status %s, want cancelled", got.Status)
+	}
+}
+
+func TestCancellationRefundModes(t *testing.T) {
+	soon := &models.Ticket{TotalAmount: 2000, Event: models.Event{DateFrom: time.Now().AddDate(0, 0, 3)}}
+	early := &models.Ticket{TotalAmount: 2000, Event: models.Event{DateFrom: time.Now().AddDate(0, 0, 30)}}
+	withPolicy := &TicketService{cfg: &config.Config{TicketCancellationEnabled: true, TicketCancellationDays: 14, TicketCancellationRefundPercent: 50}}
+	withoutPolicy := &TicketService{cfg: &config.Config{}}
+
+	tests := []struct {
+		name    string
+		s       *TicketService
+		ticket  *models.Ticket
+		mode    string
+		want    models.Money
+		wantErr string
+	}{
+		{"auto in the refund window", withPolicy, early, "auto", 1000, ""},
+		{"refund in the refund window", withPolicy, early, "refund", 1000, ""},
+		{"auto after the refund window", withPolicy, soon, "auto", 0, ""},
+		{"refund after the refund window", withPolicy, soon, "refund", 0, "refund_not_eligible"},
+		{"no_refund in the refund window", withPolicy, early, "no_refund", 0, ""},
+		{"refund without a policy", withoutPolicy, early, "refund", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s.cancellationRefund(tt.ticket, tt.mode)
+			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("refund %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,596 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// transferChargePrefix marks provider charges that belong to a ticket transfer
+const transferChargePrefix = "transfer:"
+
+// TransferService hands paid tickets from one member to another.
+// The owner starts a transfer, the recipient accepts. If the recipient's group needs a
+// different ticket type, the price difference is charged to the recipient or refunded to the owner.
+type TransferService struct {
+	db            *gorm.DB
+	cfg           *config.Config
+	ticketService *TicketService
+	emailService  *EmailService
+}
+
+func NewTransferService(db *gorm.DB, cfg *config.Config, ticketService *TicketService, emailService *EmailService) *TransferService {
+	return &TransferService{
+		db:            db,
+		cfg:           cfg,
+		ticketService: ticketService,
+		emailService:  emailService,
+	}
+}
+
+var errTransferRefunded = errors.New("transfer could not be completed, payment was refunded")
+
+var openTransferStatuses = []string{models.TransferStatusPending, models.TransferStatusAwaitingPayment}
+
+// checkTransferable ensures the ticket can still change hands
+func checkTransferable(ticket *models.Ticket, event *models.Event) error {
+	if ticket.Status != "paid" {
+		return errors.New("only paid tickets can be transferred")
+	}
+	if ticket.CheckedInAt != nil {
+		return errors.New("ticket is already checked in")
+	}
+	if event.DateFrom.Before(time.Now()) {
+		return errors.New("event has already started")
+	}
+	return nil
+}
+
+// checkRecipient ensures the recipient may hold a ticket for the event
+func checkRecipient(tx *gorm.DB, recipient *models.User, event *models.Event) error {
+	if !recipient.IsActive {
+		return errors.New("recipient account is not active")
+	}
+	if !event.AllowsGroup(recipient.Group) {
+		return errors.New("event not available for recipient's group")
+	}
+	var existing models.Ticket
+	if err := tx.Where("user_id = ? AND event_id = ? AND holder_name = '' AND status IN ?", recipient.ID, event.ID, []string{"pending", "paid"}).
+		First(&existing).Error; err == nil && !existing.HoldExpired() {
+		return errors.New("recipient already has a ticket for this event")
+	}
+	return nil
+}
+
+// transferTicketType picks the ticket type the recipient will hold: the current type if their
+// group may buy it (at the ticket's price), otherwise the first active type for their group.
+// Promo discounts are personal and do not carry over; the price difference is computed against
+// what the sender actually paid.
+func transferTicketType(tx *gorm.DB, ticket *models.Ticket, recipient *models.User) (*models.TicketType, models.Money, error) {
+	if ticket.TicketTypeID != nil {
+		var current models.TicketType
+		if err := tx.First(&current, "id = ?", *ticket.TicketTypeID).Error; err == nil && current.AllowsGroup(recipient.Group) {
+			return &current, ticket.Price, nil
+		}
+	}
+
+	var types []models.TicketType
+	if err := tx.Where("event_id = ? AND is_active = ?", ticket.EventID, true).
+		Order("sort_order ASC, price ASC").Find(&types).Error; err != nil {
+		return nil, 0, err
+	}
+	for i := range types {
+		if types[i].AllowsGroup(recipient.Group) {
+			return &types[i], types[i].Price, nil
+		}
+	}
+	return nil, 0, errors.New("no ticket type available for recipient's group")
+}
+
+// Initiate starts a transfer of a paid ticket to another member, found by username or email
+func (s *TransferService) Initiate(ticketID, fromUserID uuid.UUID, recipient string) (*models.TicketTransfer, error) {
+	recipient = strings.TrimSpace(recipient)
+	if recipient == "" {
+		return nil, errors.New("recipient is required")
+	}
+
+	var transfer *models.TicketTransfer
+	var toUser models.User
+	var event models.Event
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, "id = ?", ticketID).Error; err != nil {
+			return errors.New("ticket not found")
+		}
+		if ticket.UserID != fromUserID {
+			return errors.New("ticket not found")
+		}
+		if err := tx.First(&event, "id = ?", ticket.EventID).Error; err != nil {
+			return errors.New("event not found")
+		}
+		if err := checkTransferable(&ticket, &event); err != nil {
+			return err
+		}
+
+		var open int64
+		tx.Model(&models.TicketTransfer{}).
+			Where("ticket_id = ? AND status IN ?", ticket.ID, openTransferStatuses).
+			Count(&open)
+		if open > 0 {
+			return errors.New("ticket already has an open transfer")
+		}
+
+		if err := tx.Where("username = ? OR LOWER(email) = LOWER(?)", recipient, recipient).First(&toUser).Error; err != nil {
+			return errors.New("recipient not found")
+		}
+		if toUser.ID == fromUserID {
+			return errors.New("cannot transfer a ticket to yourself")
+		}
+		if err := checkRecipient(tx, &toUser, &event); err != nil {
+			return err
+		}
+
+		newType, newPrice, err := transferTicketType(tx, &ticket, &toUser)
+		if err != nil {
+			return err
+		}
+		transfer = &models.TicketTransfer{
+			TicketID:        ticket.ID,
+			FromUserID:      fromUserID,
+			ToUserID:        toUser.ID,
+			Status:          models.TransferStatusPending,
+			OldPrice:        ticket.NetPrice(),
+			NewPrice:        newPrice,
+			PriceDifference: newPrice - ticket.NetPrice(),
+			NewTicketTypeID: &newType.ID,
+		}
+		if err := tx.Create(transfer).Error; err != nil {
+			return err
+		}
+
+		return recordTicketHistory(tx, ticket.ID, "transfer_requested", &fromUserID, map[string]interface{}{
+			"transfer_id":      transfer.ID,
+			"to_user_id":       toUser.ID,
+			"price_difference": transfer.PriceDifference,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var fromUser models.User
+	if err := s.db.First(&fromUser, "id = ?", fromUserID).Error; err == nil {
+		msg := fmt.Sprintf("<p>%s möchte dir das Ticket für <strong>%s</strong> übertragen.</p>",
+			html.EscapeString(fromUser.Name), html.EscapeString(event.Name))
+		if transfer.PriceDifference > 0 {
+			msg += fmt.Sprintf("<p>Für deine Gruppe fällt ein Aufpreis von %s an.</p>", transfer.PriceDifference.Format())
+		}
+		msg += fmt.Sprintf(`<p><a href="%s/transfers">Übertragung ansehen</a></p>`, s.cfg.FrontendURL)
+		s.notify(&toUser, "Ticket-Übertragung für "+event.Name, msg)
+	}
+
+	return transfer, nil
+}
+
+// Accept accepts an incoming transfer. Without a positive price difference the ticket
+// changes hands immediately (a lower price is refunded to the owner); otherwise a checkout
+// for the difference is returned and the transfer completes once it is paid.
+func (s *TransferService) Accept(transferID, userID uuid.UUID, paymentProvider string) (*models.TicketTransfer, string, error) {
+	var transfer models.TicketTransfer
+	var refund *models.Refund
+	var ticket *models.Ticket
+	var event *models.Event
+	var toUser *models.User
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, "id = ?", transferID).Error; err != nil {
+			return errors.New("transfer not found")
+		}
+		if transfer.ToUserID != userID {
+			return errors.New("transfer not found")
+		}
+		if !transfer.IsOpen() {
+			return fmt.Errorf("transfer is %s", transfer.Status)
+		}
+
+		var err error
+		ticket, event, toUser, err = s.lockTransferTicket(tx, &transfer)
+		if err != nil {
+			return err
+		}
+		if transfer.PriceDifference > 0 {
+			return nil // the recipient pays the difference, see below
+		}
+
+		if transfer.PriceDifference < 0 {
+			// The owner gets the difference back; sent to the provider after the commit,
+			// or by the refund recovery job if that does not happen
+			req := ticketRefund(ticket, -transfer.PriceDifference, models.RefundReasonTransferPrice, models.RefundInitiatorUser, &userID)
+			req.TransferID = &transfer.ID
+			if refund, err = s.ticketService.refundService.Record(tx, req); err != nil {
+				return err
+			}
+		}
+		return s.complete(tx, &transfer, ticket, &userID, "")
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if transfer.PriceDifference <= 0 {
+		_ = s.ticketService.refundService.Execute(refund)
+		s.notifyCompleted(&transfer)
+		return &transfer, "", nil
+	}
+
+	checkoutURL, err := s.openChargeCheckout(&transfer, ticket, event, toUser, userID, paymentProvider)
+	if err != nil {
+		return nil, "", err
+	}
+	return &transfer, checkoutURL, nil
+}
+
+// openChargeCheckout opens the checkout for the price difference of an accepted transfer; a repeated accept
+// opens a fresh one. The checkout is created outside any transaction, so no rows stay locked during the
+// provider call; the transfer is locked again afterwards to store it. A checkout left over when the transfer
+// changed in between cannot complete it: paying it is refunded (see completeCharged).
+func (s *TransferService) openChargeCheckout(transfer *models.TicketTransfer, ticket *models.Ticket, event *models.Event, toUser *models.User, userID uuid.UUID, paymentProvider string) (string, error) {
+	if paymentProvider == "" {
+		paymentProvider = "stripe"
+	}
+	provider := s.ticketService.providerFor(paymentProvider)
+	if provider == nil {
+		return "", errors.New("PayPal is not enabled")
+	}
+	url, ref, err := provider.CreateChargeCheckout(ticket, toUser, transfer.PriceDifference,
+		fmt.Sprintf("Ticket-Übertragung: Aufpreis für %s", event.Name),
+		transferChargePrefix+transfer.ID.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to create checkout: %w", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(transfer, "id = ?", transfer.ID).Error; err != nil {
+			return errors.New("transfer not found")
+		}
+		if !transfer.IsOpen() {
+			return fmt.Errorf("transfer is %s", transfer.Status)
+		}
+		now := time.Now()
+		if err := tx.Model(transfer).Updates(map[string]interface{}{
+			"status":           models.TransferStatusAwaitingPayment,
+			"charge_provider":  provider.GetProviderName(),
+			"charge_reference": ref,
+			"responded_at":     now,
+		}).Error; err != nil {
+			return err
+		}
+		return recordTicketHistory(tx, ticket.ID, "transfer_accepted", &userID, map[string]interface{}{
+			"transfer_id":      transfer.ID,
+			"price_difference": transfer.PriceDifference,
+			"charge_provider":  provider.GetProviderName(),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// ConfirmPayment checks the price difference checkout after the recipient returns from the provider
+func (s *TransferService) ConfirmPayment(transferID, userID uuid.UUID) (*models.TicketTransfer, error) {
+	var transfer models.TicketTransfer
+	if err := s.db.First(&transfer, "id = ? AND to_user_id = ?", transferID, userID).Error; err != nil {
+		return nil, errors.New("transfer not found")
+	}
+	if transfer.Status == models.TransferStatusCompleted {
+		return &transfer, nil
+	}
+	if transfer.Status != models.TransferStatusAwaitingPayment || transfer.ChargeReference == "" {
+		return nil, fmt.Errorf("transfer is %s", transfer.Status)
+	}
+
+	provider := s.ticketService.providerFor(transfer.ChargeProvider)
+	if provider == nil {
+		return nil, errors.New("payment provider not available")
+	}
+	paymentRef, paid, err := provider.CaptureCharge(transfer.ChargeReference)
+	if err != nil {
+		return nil, err
+	}
+	if !paid {
+		return nil, errors.New("payment not completed yet")
+	}
+
+	return s.completeCharged(transfer.ID, paymentRef)
+}
+
+// HandleChargePaid completes a transfer from a provider webhook.
+// reference is the charge reference passed to CreateChargeCheckout; other references are ignored.
+func (s *TransferService) HandleChargePaid(reference, paymentRef string) error {
+	if !strings.HasPrefix(reference, transferChargePrefix) {
+		return nil
+	}
+	transferID, err := uuid.Parse(strings.TrimPrefix(reference, transferChargePrefix))
+	if err != nil {
+		return errors.New("invalid transfer reference")
+	}
+	if _, err := s.completeCharged(transferID, paymentRef); err != nil && !errors.Is(err, errTransferRefunded) {
+		return err
+	}
+	return nil
+}
+
+// completeCharged finishes a paid transfer. If the transfer was withdrawn or the ticket
+// changed in the meantime, the charge is refunded instead.
+func (s *TransferService) completeCharged(transferID uuid.UUID, paymentRef string) (*models.TicketTransfer, error) {
+	var transfer models.TicketTransfer
+	var refund *models.Refund
+	refunded := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, "id = ?", transferID).Error; err != nil {
+			return errors.New("transfer not found")
+		}
+		if transfer.Status == models.TransferStatusCompleted {
+			return nil // webhook and return page both confirm
+		}
+
+		// The recipient has paid; book the charge whether or not the transfer can still complete
+		var eventID uuid.UUID
+		if err := tx.Model(&models.Ticket{}).Select("event_id").Where("id = ?", transfer.TicketID).Scan(&eventID).Error; err != nil {
+			return err
+		}
+		if err := recordTransferCharge(tx, &transfer, eventID, paymentRef); err != nil {
+			return err
+		}
+
+		ticket, _, _, err := s.lockTransferTicket(tx, &transfer)
+		if err == nil && transfer.Status != models.TransferStatusAwaitingPayment {
+			err = fmt.Errorf("transfer is %s", transfer.Status)
+		}
+		if err != nil {
+			refunded = true
+			if transfer.ChargePaymentRef == paymentRef {
+				return nil // refunded on an earlier delivery
+			}
+			var rErr error
+			if refund, rErr = s.refundCharge(tx, &transfer, paymentRef); rErr != nil {
+				return rErr
+			}
+			updates := map[string]interface{}{"charge_payment_ref": paymentRef}
+			if transfer.IsOpen() {
+				updates["status"] = models.TransferStatusCancelled
+			}
+			if uErr := tx.Model(&transfer).Updates(updates).Error; uErr != nil {
+				return uErr
+			}
+			return recordTicketHistory(tx, transfer.TicketID, "transfer_failed", nil, map[string]interface{}{
+				"transfer_id":     transfer.ID,
+				"reason":          err.Error(),
+				"charge_refunded": transfer.PriceDifference,
+			})
+		}
+
+		return s.complete(tx, &transfer, ticket, nil, paymentRef)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if refunded {
+		_ = s.ticketService.refundService.Execute(refund)
+		return nil, errTransferRefunded
+	}
+
+	s.notifyCompleted(&transfer)
+	return &transfer, nil
+}
+
+// refundCharge records the refund of a price difference payment that can no longer be used
+func (s *TransferService) refundCharge(tx *gorm.DB, transfer *models.TicketTransfer, paymentRef string) (*models.Refund, error) {
+	ticketID := transfer.TicketID
+	return s.ticketService.refundService.Record(tx, &RefundRequest{
+		Provider:    transfer.ChargeProvider,
+		ProviderRef: paymentRef,
+		TicketID:    &ticketID,
+		TransferID:  &transfer.ID,
+		Amount:      transfer.PriceDifference,
+		Reason:      models.RefundReasonTransferCharge,
+		Initiator:   models.RefundInitiatorSystem,
+	})
+}
+
+// lockTransferTicket locks the ticket of a transfer and re-checks that it can still change hands
+func (s *TransferService) lockTransferTicket(tx *gorm.DB, transfer *models.TicketTransfer) (*models.Ticket, *models.Event, *models.User, error) {
+	var ticket models.Ticket
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ticket, "id = ?", transfer.TicketID).Error; err != nil {
+		return nil, nil, nil, errors.New("ticket not found")
+	}
+	if ticket.UserID != transfer.FromUserID {
+		return nil, nil, nil, errors.New("ticket can no longer be transferred")
+	}
+	var event models.Event
+	if err := tx.First(&event, "id = ?", ticket.EventID).Error; err != nil {
+		return nil, nil, nil, errors.New("event not found")
+	}
+	if err := checkTransferable(&ticket, &event); err != nil {
+		return nil, nil, nil, err
+	}
+	var toUser models.User
+	if err := tx.First(&toUser, "id = ?", transfer.ToUserID).Error; err != nil {
+		return nil, nil, nil, errors.New("recipient not found")
+	}
+	if err := checkRecipient(tx, &toUser, &event); err != nil {
+		return nil, nil, nil, err
+	}
+	return &ticket, &event, &toUser, nil
+}
+
+// complete moves the ticket to the recipient and reissues its QR code
+func (s *TransferService) complete(tx *gorm.DB, transfer *models.TicketTransfer, ticket *models.Ticket, actorID *uuid.UUID, paymentRef string) error {
+	previousPromoCode := ticket.PromoCode
+	ticket.Price = transfer.NewPrice
+	ticket.DiscountAmount = 0
+	ticket.CalculateTotalAmount()
+	ticketUpdates := map[string]interface{}{
+		"user_id":         transfer.ToUserID,
+		"holder_name":     "", // the recipient holds the ticket themselves
+		"price":           ticket.Price,
+		"total_amount":    ticket.TotalAmount,
+		"promo_code_id":   nil,
+		"promo_code":      "",
+		"discount_amount": 0,
+		"token_version":   gorm.Expr("token_version + 1"),
+	}
+	if transfer.NewTicketTypeID != nil {
+		var newType models.TicketType
+		if err := tx.First(&newType, "id = ?", *transfer.NewTicketTypeID).Error; err == nil {
+			ticketUpdates["ticket_type_id"] = newType.ID
+			ticketUpdates["ticket_type_name"] = newType.Name
+		}
+	}
+	if err := tx.Model(ticket).Updates(ticketUpdates).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       models.TransferStatusCompleted,
+		"completed_at": now,
+	}
+	if transfer.RespondedAt == nil {
+		updates["responded_at"] = now
+	}
+	if paymentRef != "" {
+		updates["charge_payment_ref"] = paymentRef
+	}
+	if err := tx.Model(transfer).Updates(updates).Error; err != nil {
+		return err
+	}
+	transfer.Status = models.TransferStatusCompleted
+	transfer.CompletedAt = &now
+
+	details := map[string]interface{}{
+		"transfer_id":      transfer.ID,
+		"from_user_id":     transfer.FromUserID,
+		"to_user_id":       transfer.ToUserID,
+		"old_price":        transfer.OldPrice,
+		"new_price":        transfer.NewPrice,
+		"price_difference": transfer.PriceDifference,
+	}
+	if previousPromoCode != "" {
+		details["removed_promo_code"] = previousPromoCode
+	}
+	return recordTicketHistory(tx, ticket.ID, "transferred", actorID, details)
+}
+
+// Decline rejects an incoming transfer
+func (s *TransferService) Decline(transferID, userID uuid.UUID) error {
+	transfer, err := s.close(transferID, userID, false)
+	if err != nil {
+		return err
+	}
+
+	var toUser, fromUser models.User
+	if s.db.First(&toUser, "id = ?", transfer.ToUserID).Error == nil && s.db.First(&fromUser, "id = ?", transfer.FromUserID).Error == nil {
+		s.notify(&fromUser, "Ticket-Übertragung abgelehnt",
+			fmt.Sprintf("<p>%s hat die Übertragung deines Tickets abgelehnt. Dein Ticket bleibt gültig.</p>", html.EscapeString(toUser.Name)))
+	}
+	return nil
+}
+
+// Cancel withdraws an outgoing transfer
+func (s *TransferService) Cancel(transferID, userID uuid.UUID) error {
+	_, err := s.close(transferID, userID, true)
+	return err
+}
+
+// close ends an open transfer as declined (recipient) or cancelled (owner)
+func (s *TransferService) close(transferID, userID uuid.UUID, byOwner bool) (*models.TicketTransfer, error) {
+	var transfer models.TicketTransfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, "id = ?", transferID).Error; err != nil {
+			return errors.New("transfer not found")
+		}
+		if (byOwner && transfer.FromUserID != userID) || (!byOwner && transfer.ToUserID != userID) {
+			return errors.New("transfer not found")
+		}
+		if !transfer.IsOpen() {
+			return fmt.Errorf("transfer is %s", transfer.Status)
+		}
+
+		status, action := models.TransferStatusDeclined, "transfer_declined"
+		if byOwner {
+			status, action = models.TransferStatusCancelled, "transfer_cancelled"
+		}
+		now := time.Now()
+		if err := tx.Model(&transfer).Updates(map[string]interface{}{
+			"status":       status,
+			"responded_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		transfer.Status = status
+		return recordTicketHistory(tx, transfer.TicketID, action, &userID, map[string]interface{}{
+			"transfer_id": transfer.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// GetUserTransfers returns incoming and outgoing transfers of a user, newest first
+func (s *TransferService) GetUserTransfers(userID uuid.UUID) ([]*models.TicketTransfer, error) {
+	var transfers []*models.TicketTransfer
+	err := s.db.Preload("Ticket.Event").Preload("FromUser").Preload("ToUser").
+		Where("from_user_id = ? OR to_user_id = ?", userID, userID).
+		Order("created_at DESC").
+		Find(&transfers).Error
+	return transfers, err
+}
+
+// notifyCompleted tells both parties that the ticket changed hands
+func (s *TransferService) notifyCompleted(transfer *models.TicketTransfer) {
+	var ticket models.Ticket
+	var fromUser, toUser models.User
+	if err := s.db.Preload("Event").First(&ticket, "id = ?", transfer.TicketID).Error; err != nil {
+		log.Printf("Transfer %s: ticket not found for notification: %v", transfer.ID, err)
+		return
+	}
+	if s.db.First(&fromUser, "id = ?", transfer.FromUserID).Error != nil || s.db.First(&toUser, "id = ?", transfer.ToUserID).Error != nil {
+		return
+	}
+	eventName := html.EscapeString(ticket.Event.Name)
+
+	fromMsg := fmt.Sprintf("<p>Dein Ticket für <strong>%s</strong> wurde an %s übertragen. Dein QR-Code ist nicht mehr gültig.</p>",
+		eventName, html.EscapeString(toUser.Name))
+	if transfer.PriceDifference < 0 {
+		fromMsg += fmt.Sprintf("<p>Die Preisdifferenz von %s wird dir erstattet.</p>", (-transfer.PriceDifference).Format())
+	}
+	s.notify(&fromUser, "Ticket übertragen: "+ticket.Event.Name, fromMsg)
+
+	s.notify(&toUser, "Dein Ticket für "+ticket.Event.Name,
+		fmt.Sprintf(`<p>%s hat dir das Ticket für <strong>%s</strong> übertragen.</p><p><a href="%s/tickets">Zu deinen Tickets</a></p>`,
+			html.EscapeString(fromUser.Name), eventName, s.cfg.FrontendURL))
+}
+
+func (s *TransferService) notify(user *models.User, subject, message string) {
+	if s.emailService == nil {
+		return
+	}
+	if err := s.emailService.SendGenericAnnouncement(user.Email, subject, message, map[string]interface{}{"Name": user.Name}); err != nil {
+		log.Printf("Transfer: failed to send email to %s: %v", user.Email, err)
+	}
+}
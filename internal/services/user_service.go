@@ -101,6 +101,18 @@ func (s *UserService) UpdateUserActive(userID uuid.UUID, isActive bool) error {
 	return nil
 }
 
+// UpdateUserDriver sets is_driver
+func (s *UserService) UpdateUserDriver(userID uuid.UUID, isDriver bool) error {
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Update("is_driver", isDriver)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
 // GetAllUsers retrieves all users with pagination
 func (s *UserService) GetAllUsers(offset, limit int) ([]*models.User, int64, error) {
 	var users []*models.User
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/config"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WaitlistService manages the per-event queue for fully booked events.
+// Freed spots are offered to the next waiting user with a time-limited claim link;
+// unclaimed offers expire and pass on to the next person in line.
+type WaitlistService struct {
+	db           *gorm.DB
+	cfg          *config.Config
+	emailService *EmailService
+	smsService   *SMSService
+}
+
+func NewWaitlistService(db *gorm.DB, cfg *config.Config, emailService *EmailService, smsService *SMSService) *WaitlistService {
+	return &WaitlistService{
+		db:           db,
+		cfg:          cfg,
+		emailService: emailService,
+		smsService:   smsService,
+	}
+}
+
+var activeWaitlistStatuses = []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}
+
+// offerDuration returns how long a waitlist offer can be claimed
+func (s *WaitlistService) offerDuration() time.Duration {
+	if s.cfg == nil || s.cfg.WaitlistOfferTTLMinutes <= 0 {
+		return 2 * time.Hour
+	}
+	return time.Duration(s.cfg.WaitlistOfferTTLMinutes) * time.Minute
+}
+
+// Join puts a user at the end of the waitlist of a fully booked event
+func (s *WaitlistService) Join(userID, eventID uuid.UUID) (*models.WaitlistEntry, error) {
+	var entry *models.WaitlistEntry
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Same lock as the booking path, so availability cannot change underneath us
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error; err != nil {
+			return errors.New("event not found")
+		}
+		if !event.IsActive || event.DateFrom.Before(time.Now()) {
+			return errors.New("event is not available")
+		}
+
+		var user models.User
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return errors.New("user not found")
+		}
+		if (event.AllowedGroup == "guests" || event.AllowedGroup == "bubble" || event.AllowedGroup == "plus") && user.Group != event.AllowedGroup {
+			return errors.New("event not available for your group")
+		}
+
+		var existingTicket models.Ticket
+		if err := tx.Where("user_id = ? AND event_id = ? AND holder_name = '' AND status IN ?", userID, eventID, []string{"pending", "paid"}).
+			First(&existingTicket).Error; err == nil && !existingTicket.HoldExpired() {
+			return errors.New("user already has a ticket for this event")
+		}
+
+		var count int64
+		tx.Model(&models.WaitlistEntry{}).
+			Where("user_id = ? AND event_id = ? AND status IN ?", userID, eventID, activeWaitlistStatuses).
+			Count(&count)
+		if count > 0 {
+			return errors.New("already on waitlist")
+		}
+
+		if event.GetAvailableSpots(tx) > 0 {
+			return errors.New("event is not fully booked")
+		}
+
+		var maxPosition int
+		tx.Model(&models.WaitlistEntry{}).Where("event_id = ?", eventID).
+			Select("COALESCE(MAX(position), 0)").Scan(&maxPosition)
+
+		entry = &models.WaitlistEntry{
+			EventID:  eventID,
+			UserID:   userID,
+			Position: maxPosition + 1,
+			Status:   models.WaitlistStatusWaiting,
+		}
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Leave removes the user from the waitlist of an event.
+// An open offer is released and passed on to the next person.
+func (s *WaitlistService) Leave(userID, eventID uuid.UUID) error {
+	var entry models.WaitlistEntry
+	if err := s.db.Where("user_id = ? AND event_id = ? AND status IN ?", userID, eventID, activeWaitlistStatuses).
+		First(&entry).Error; err != nil {
+		return errors.New("waitlist entry not found")
+	}
+	return s.removeEntry(&entry)
+}
+
+// Remove takes an entry off the waitlist (admin action)
+func (s *WaitlistService) Remove(eventID, entryID uuid.UUID) error {
+	var entry models.WaitlistEntry
+	if err := s.db.Where("id = ? AND event_id = ? AND status IN ?", entryID, eventID, activeWaitlistStatuses).
+		First(&entry).Error; err != nil {
+		return errors.New("waitlist entry not found")
+	}
+	return s.removeEntry(&entry)
+}
+
+func (s *WaitlistService) removeEntry(entry *models.WaitlistEntry) error {
+	wasOffered := entry.Status == models.WaitlistStatusOffered
+	if err := s.db.Model(entry).Updates(map[string]interface{}{
+		"status":      models.WaitlistStatusRemoved,
+		"offer_token": nil,
+	}).Error; err != nil {
+		return err
+	}
+	if wasOffered {
+		if _, err := s.OfferFreedSpots(entry.EventID); err != nil {
+			log.Printf("Waitlist: failed to pass on offer for event %s: %v", entry.EventID, err)
+		}
+	}
+	return nil
+}
+
+// GetUserEntries returns the active waitlist entries of a user
+func (s *WaitlistService) GetUserEntries(userID uuid.UUID) ([]*models.WaitlistEntry, error) {
+	var entries []*models.WaitlistEntry
+	err := s.db.Preload("Event").
+		Where("user_id = ? AND status IN ?", userID, activeWaitlistStatuses).
+		Order("created_at ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// GetEventWaitlist returns the active queue of an event in order
+func (s *WaitlistService) GetEventWaitlist(eventID uuid.UUID) ([]*models.WaitlistEntry, error) {
+	var entries []*models.WaitlistEntry
+	err := s.db.Preload("User").
+		Where("event_id = ? AND status IN ?", eventID, activeWaitlistStatuses).
+		Order("position ASC, created_at ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// GetOfferByToken returns the open offer for a claim token of the given user
+func (s *WaitlistService) GetOfferByToken(userID uuid.UUID, token string) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	if err := s.db.Where("offer_token = ? AND user_id = ? AND status = ?", token, userID, models.WaitlistStatusOffered).
+		First(&entry).Error; err != nil {
+		return nil, errors.New("waitlist offer not found")
+	}
+	if entry.OfferExpired() {
+		return nil, errors.New("waitlist offer expired")
+	}
+	return &entry, nil
+}
+
+// Reorder sets the queue order of an event. entryIDs must contain every active entry exactly once.
+func (s *WaitlistService) Reorder(eventID uuid.UUID, entryIDs []uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var entries []models.WaitlistEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("event_id = ? AND status IN ?", eventID, activeWaitlistStatuses).
+			Find(&entries).Error; err != nil {
+			return err
+		}
+
+		if len(entryIDs) != len(entries) {
+			return errors.New("entry list does not match waitlist")
+		}
+		active := make(map[uuid.UUID]bool, len(entries))
+		for _, e := range entries {
+			active[e.ID] = true
+		}
+		for _, id := range entryIDs {
+			if !active[id] {
+				return errors.New("entry list does not match waitlist")
+			}
+			delete(active, id) // rejects duplicates
+		}
+
+		for i, id := range entryIDs {
+			if err := tx.Model(&models.WaitlistEntry{}).Where("id = ?", id).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// OfferFreedSpots offers every free spot of an event to the next waiting users
+// and notifies them. Returns the number of offers made.
+func (s *WaitlistService) OfferFreedSpots(eventID uuid.UUID) (int, error) {
+	var offered []models.WaitlistEntry
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error; err != nil {
+			return errors.New("event not found")
+		}
+		if !event.IsActive || event.DateFrom.Before(time.Now()) {
+			return nil
+		}
+
+		free := event.GetAvailableSpots(tx)
+		if free <= 0 {
+			return nil
+		}
+
+		var next []models.WaitlistEntry
+		if err := tx.Where("event_id = ? AND status = ?", eventID, models.WaitlistStatusWaiting).
+			Order("position ASC, created_at ASC").
+			Limit(free).
+			Find(&next).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(s.offerDuration())
+		for i := range next {
+			token, err := generateSecureCode(24)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&next[i]).Updates(map[string]interface{}{
+				"status":           models.WaitlistStatusOffered,
+				"offer_token":      token,
+				"offered_at":       now,
+				"offer_expires_at": expiresAt,
+			}).Error; err != nil {
+				return err
+			}
+			next[i].OfferToken = &token
+			next[i].OfferExpiresAt = &expiresAt
+			offered = append(offered, next[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range offered {
+		s.notifyOffer(&offered[i])
+	}
+	return len(offered), nil
+}
+
+// ProcessOffers expires unclaimed offers and offers free spots for all events with a queue
+func (s *WaitlistService) ProcessOffers() (int, error) {
+	res := s.db.Model(&models.WaitlistEntry{}).
+		Where("status = ? AND offer_expires_at < ?", models.WaitlistStatusOffered, time.Now()).
+		Updates(map[string]interface{}{
+			"status":      models.WaitlistStatusExpired,
+			"offer_token": nil,
+		})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("Waitlist: expired %d unclaimed offers", res.RowsAffected)
+	}
+
+	var eventIDs []uuid.UUID
+	if err := s.db.Model(&models.WaitlistEntry{}).
+		Where("status = ?", models.WaitlistStatusWaiting).
+		Distinct("event_id").
+		Pluck("event_id", &eventIDs).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, eventID := range eventIDs {
+		n, err := s.OfferFreedSpots(eventID)
+		if err != nil {
+			log.Printf("Waitlist: failed to offer spots for event %s: %v", eventID, err)
+			continue
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// notifyOffer sends the claim link by email and, for verified mobiles, by SMS
+func (s *WaitlistService) notifyOffer(entry *models.WaitlistEntry) {
+	var user models.User
+	var event models.Event
+	if err := s.db.First(&user, "id = ?", entry.UserID).Error; err != nil {
+		log.Printf("Waitlist: user %s for offer %s not found: %v", entry.UserID, entry.ID, err)
+		return
+	}
+	if err := s.db.First(&event, "id = ?", entry.EventID).Error; err != nil {
+		log.Printf("Waitlist: event %s for offer %s not found: %v", entry.EventID, entry.ID, err)
+		return
+	}
+
+	claimURL := s.cfg.FrontendURL + "/waitlist/claim?token=" + *entry.OfferToken
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	expiresAt := entry.OfferExpiresAt.In(loc).Format("02.01.2006 15:04")
+
+	if s.emailService != nil {
+		data := map[string]interface{}{
+			"UserName":  user.Name,
+			"EventName": event.Name,
+			"EventDate": event.DateFrom.In(loc).Format("02.01.2006"),
+			"EventTime": event.TimeFrom,
+			"ClaimURL":  claimURL,
+			"ExpiresAt": expiresAt,
+		}
+		if err := s.emailService.SendWaitlistOffer(user.Email, data); err != nil {
+			log.Printf("Waitlist: failed to send offer email to %s: %v", user.Email, err)
+		}
+	}
+
+	if s.smsService != nil && user.MobileVerified && user.Mobile != "" {
+		body := fmt.Sprintf("Synesthesie: Ein Platz für %s ist frei! Sichere ihn dir bis %s: %s", event.Name, expiresAt, claimURL)
+		if err := s.smsService.SendSMS(user.Mobile, body); err != nil {
+			log.Printf("Waitlist: failed to send offer SMS to user %s: %v", user.ID, err)
+		}
+	}
+}
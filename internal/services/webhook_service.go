@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/synesthesie/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrWebhookEventNotFound = errors.New("webhook event not found")
+
+// webhookStaleAfter is how long an event may stay in processing before it is considered
+// abandoned (e.g. the server restarted mid-way) and may be replayed
+const webhookStaleAfter = 10 * time.Minute
+
+// WebhookProcessor handles the raw body of a verified webhook; a returned error marks the event failed
+type WebhookProcessor func(payload []byte) error
+
+// WebhookService stores every incoming payment webhook before it is processed.
+// Redeliveries of an event that is processed or being processed are skipped, a redelivery of a
+// failed event processes it again, and admins can replay failed events from the stored body.
+// The provider handlers register their processing with RegisterProcessor.
+type WebhookService struct {
+	db         *gorm.DB
+	mu         sync.RWMutex
+	processors map[string]WebhookProcessor
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{
+		db:         db,
+		processors: map[string]WebhookProcessor{},
+	}
+}
+
+// RegisterProcessor sets the processing used for events of a provider (stripe, paypal)
+func (s *WebhookService) RegisterProcessor(provider string, processor WebhookProcessor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.processors[provider] = processor
+}
+
+// Reject stores a webhook that failed verification; it is kept for inspection and never processed
+func (s *WebhookService) Reject(provider, eventID, eventType string, payload []byte, verifyErr error) {
+	evt := &models.WebhookEvent{
+		Provider:     provider,
+		EventID:      eventID,
+		EventType:    eventType,
+		Payload:      string(payload),
+		Verification: models.WebhookVerificationFailed,
+		Status:       models.WebhookStatusRejected,
+	}
+	if verifyErr != nil {
+		evt.VerificationError = verifyErr.Error()
+	}
+	if err := s.db.Create(evt).Error; err != nil {
+		log.Printf("Webhook %s %s: failed to store rejected event: %v", provider, eventID, err)
+	}
+}
+
+// Receive stores a webhook and processes it. duplicate is true when the event was already
+// processed or is being processed; it is skipped then. The returned error is the processing error.
+func (s *WebhookService) Receive(provider, eventID, eventType string, payload []byte, verification string) (evt *models.WebhookEvent, duplicate bool, err error) {
+	if eventID == "" {
+		return nil, false, errors.New("webhook has no event ID")
+	}
+
+	evt = &models.WebhookEvent{
+		Provider:     provider,
+		EventID:      eventID,
+		EventType:    eventType,
+		Payload:      string(payload),
+		Verification: verification,
+		Status:       models.WebhookStatusProcessing,
+		Attempts:     1,
+	}
+	res := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(evt)
+	if res.Error != nil {
+		return nil, false, fmt.Errorf("failed to store webhook: %w", res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		// Seen before: only a failed event is processed again
+		existing := &models.WebhookEvent{}
+		if err := s.db.Where("provider = ? AND event_id = ? AND status <> ?", provider, eventID, models.WebhookStatusRejected).
+			First(existing).Error; err != nil {
+			return nil, false, fmt.Errorf("failed to load webhook: %w", err)
+		}
+		claimed, err := s.claim(existing.ID, false, nil)
+		if err != nil {
+			return nil, false, err
+		}
+		if !claimed {
+			s.db.Model(existing).UpdateColumn("duplicates", gorm.Expr("duplicates + 1"))
+			log.Printf("Webhook %s %s (%s): duplicate delivery skipped (%s)", provider, eventID, eventType, existing.Status)
+			return existing, true, nil
+		}
+		evt = existing
+	}
+
+	return evt, false, s.process(evt)
+}
+
+// claim moves a failed (or, for replays, abandoned) event back to processing.
+// It returns false if the event is not in such a state, e.g. because someone else claimed it first.
+func (s *WebhookService) claim(id uuid.UUID, replay bool, actorID *uuid.UUID) (bool, error) {
+	query := s.db.Model(&models.WebhookEvent{}).Where("id = ?", id)
+	if replay {
+		query = query.Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.WebhookStatusFailed, models.WebhookStatusProcessing, time.Now().Add(-webhookStaleAfter))
+	} else {
+		query = query.Where("status = ?", models.WebhookStatusFailed)
+	}
+	updates := map[string]interface{}{
+		"status":   models.WebhookStatusProcessing,
+		"attempts": gorm.Expr("attempts + 1"),
+	}
+	if actorID != nil {
+		updates["last_replay_by"] = *actorID
+	}
+	res := query.Updates(updates)
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to claim webhook: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// process runs the provider's processor on a claimed event and stores the outcome
+func (s *WebhookService) process(evt *models.WebhookEvent) (err error) {
+	s.mu.RLock()
+	processor := s.processors[evt.Provider]
+	s.mu.RUnlock()
+
+	if processor == nil {
+		err = fmt.Errorf("no processor registered for %s webhooks", evt.Provider)
+	} else {
+		err = runWebhookProcessor(processor, []byte(evt.Payload))
+	}
+
+	updates := map[string]interface{}{}
+	if err != nil {
+		log.Printf("Webhook %s %s (%s): processing failed: %v", evt.Provider, evt.EventID, evt.EventType, err)
+		evt.Status = models.WebhookStatusFailed
+		evt.Error = err.Error()
+		updates["status"] = evt.Status
+		updates["error"] = evt.Error
+	} else {
+		now := time.Now()
+		evt.Status = models.WebhookStatusProcessed
+		evt.Error = ""
+		evt.ProcessedAt = &now
+		updates["status"] = evt.Status
+		updates["error"] = ""
+		updates["processed_at"] = now
+	}
+	if uErr := s.db.Model(evt).Updates(updates).Error; uErr != nil {
+		log.Printf("Webhook %s %s: CRITICAL - failed to store outcome (%s): %v", evt.Provider, evt.EventID, evt.Status, uErr)
+	}
+	return err
+}
+
+// runWebhookProcessor turns a panic into an error, so the event does not stay in processing
+func runWebhookProcessor(processor WebhookProcessor, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return processor(payload)
+}
+
+// Replay processes a failed event again from its stored body (admin action).
+// Events stuck in processing for longer than webhookStaleAfter can be replayed too.
+// The outcome, including a new failure, is on the returned event.
+func (s *WebhookService) Replay(id uuid.UUID, actorID *uuid.UUID) (*models.WebhookEvent, error) {
+	evt, err := s.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	if evt.Status == models.WebhookStatusRejected {
+		return nil, errors.New("rejected webhooks cannot be replayed")
+	}
+
+	claimed, err := s.claim(id, true, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, errors.New("only failed webhooks can be replayed")
+	}
+	log.Printf("Webhook %s %s (%s): replay requested", evt.Provider, evt.EventID, evt.EventType)
+
+	// Reload for the claimed state (attempts, replay actor)
+	if evt, err = s.GetEvent(id); err != nil {
+		return nil, err
+	}
+	_ = s.process(evt)
+	return evt, nil
+}
+
+// GetEvent returns a stored webhook including its raw body
+func (s *WebhookService) GetEvent(id uuid.UUID) (*models.WebhookEvent, error) {
+	var evt models.WebhookEvent
+	if err := s.db.First(&evt, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookEventNotFound
+		}
+		return nil, err
+	}
+	return &evt, nil
+}
+
+// GetEvents lists stored webhooks without their body, newest first. Empty filters are ignored.
+func (s *WebhookService) GetEvents(page, limit int, provider, status, eventType string) ([]*models.WebhookEvent, int64, error) {
+	var events []*models.WebhookEvent
+	var total int64
+
+	query := s.db.Model(&models.WebhookEvent{})
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	err := query.Omit("payload").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&events).Error
+	return events, total, err
+}